@@ -17,8 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"net"
 	"strconv"
-	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,56 +30,252 @@ type Protocol string
 const (
 	// ProtocolSSH is the TCP protocol.
 	ProtocolSSH Protocol = "SSH"
+	// ProtocolSNMP is the SNMP protocol, which only allows to monitor a host.
+	ProtocolSNMP Protocol = "SNMP"
+	// ProtocolNETCONF is the NETCONF protocol over SSH, which allows
+	// to manage the configuration of network devices.
+	ProtocolNETCONF Protocol = "NETCONF"
+)
+
+// SNMPVersion is the version of the SNMP protocol.
+type SNMPVersion string
+
+const (
+	// SNMPVersion2c is SNMPv2c, which authenticates with a community string.
+	SNMPVersion2c SNMPVersion = "v2c"
+	// SNMPVersion3 is SNMPv3 with the user-based security model.
+	SNMPVersion3 SNMPVersion = "v3"
+)
+
+// SNMPAuthProtocol is the authentication protocol of the user-based security model.
+type SNMPAuthProtocol string
+
+const (
+	// SNMPAuthProtocolMD5 is HMAC-MD5-96.
+	SNMPAuthProtocolMD5 SNMPAuthProtocol = "MD5"
+	// SNMPAuthProtocolSHA is HMAC-SHA-96.
+	SNMPAuthProtocolSHA SNMPAuthProtocol = "SHA"
+	// SNMPAuthProtocolSHA224 is HMAC-SHA-224-128.
+	SNMPAuthProtocolSHA224 SNMPAuthProtocol = "SHA-224"
+	// SNMPAuthProtocolSHA256 is HMAC-SHA-256-192.
+	SNMPAuthProtocolSHA256 SNMPAuthProtocol = "SHA-256"
+	// SNMPAuthProtocolSHA384 is HMAC-SHA-384-256.
+	SNMPAuthProtocolSHA384 SNMPAuthProtocol = "SHA-384"
+	// SNMPAuthProtocolSHA512 is HMAC-SHA-512-384.
+	SNMPAuthProtocolSHA512 SNMPAuthProtocol = "SHA-512"
+)
+
+// SNMPPrivProtocol is the privacy protocol of the user-based security model.
+type SNMPPrivProtocol string
+
+const (
+	// SNMPPrivProtocolDES is CBC-DES.
+	SNMPPrivProtocolDES SNMPPrivProtocol = "DES"
+	// SNMPPrivProtocolAES is CFB128-AES-128.
+	SNMPPrivProtocolAES SNMPPrivProtocol = "AES"
+)
+
+const (
+	// HostConditionHostKeyChanged indicates that the host, the proxy or
+	// a jump host presented a host key that does not match the expected fingerprint.
+	HostConditionHostKeyChanged = "HostKeyChanged"
+	// HostConditionReferenceNotPermitted indicates that the host references a
+	// Secret in another namespace, which did not grant access to the namespace
+	// of the host via the annotation `kraut.nicklasfrahm.dev/allowed-namespaces`.
+	HostConditionReferenceNotPermitted = "ReferenceNotPermitted"
 )
 
 const (
 	// OSUbuntu is the Ubuntu operating system.
 	OSUbuntu = "Ubuntu"
+	// OSDebian is the Debian operating system.
+	OSDebian = "Debian"
+	// OSRHEL is the Red Hat Enterprise Linux operating system.
+	OSRHEL = "RHEL"
+	// OSRocky is the Rocky Linux operating system.
+	OSRocky = "Rocky"
+	// OSAlma is the AlmaLinux operating system.
+	OSAlma = "AlmaLinux"
+	// OSCentOS is the CentOS operating system.
+	OSCentOS = "CentOS"
+	// OSFedora is the Fedora operating system.
+	OSFedora = "Fedora"
+	// OSAlpine is the Alpine Linux operating system.
+	OSAlpine = "Alpine"
+	// OSFlatcar is the Flatcar Container Linux operating system.
+	OSFlatcar = "Flatcar"
 	// OSNXOS is the Cisco NX-OS operating system.
 	OSNXOS = "NX-OS"
 )
 
-// OSVersion describes the version of an operating system.
-type OSVersion string
+// OSFamily describes a family of operating systems that
+// share their tooling, such as the package manager.
+type OSFamily string
 
-// Major returns the major version of the operating system.
-// Returns -1 if the version could not be parsed.
-func (v OSVersion) Major() int {
-	segments := strings.Split(strings.TrimPrefix(string(v), "v"), ".")
+const (
+	// OSFamilyDebian contains Debian and its derivatives, such as Ubuntu.
+	OSFamilyDebian OSFamily = "Debian"
+	// OSFamilyRHEL contains Red Hat Enterprise Linux and its
+	// derivatives, such as Rocky Linux, AlmaLinux and CentOS.
+	OSFamilyRHEL OSFamily = "RHEL"
+	// OSFamilyAlpine contains Alpine Linux.
+	OSFamilyAlpine OSFamily = "Alpine"
+	// OSFamilyFlatcar contains Flatcar Container Linux.
+	OSFamilyFlatcar OSFamily = "Flatcar"
+	// OSFamilyNXOS contains Cisco NX-OS.
+	OSFamilyNXOS OSFamily = "NX-OS"
+	// OSFamilyUnknown is used if the family could not be detected.
+	OSFamilyUnknown OSFamily = "Unknown"
+)
 
-	if len(segments) > 0 {
-		major, err := strconv.Atoi(segments[0])
-		if err == nil {
-			return major
-		}
-	}
+// OSInfo describes the operating system of a system.
+type OSInfo struct {
+	// Name is the name of the operating system. For known
+	// operating systems this is normalized, e.g. `Ubuntu` or `RHEL`.
+	Name string `json:"name,omitempty"`
+	// ID is the raw identifier of the operating system, e.g. `ubuntu` or `rocky`.
+	ID string `json:"id,omitempty"`
+	// Family is the family of the operating system.
+	Family OSFamily `json:"family,omitempty"`
+	// Version is the version of the operating system.
+	Version OSVersion `json:"version,omitempty"`
+	// KernelVersion is the kernel version of the operating system.
+	KernelVersion string `json:"kernelVersion,omitempty"`
+	// Description is the description of the system as reported via SNMP.
+	Description string `json:"description,omitempty"`
+	// ObjectID is the vendor object identifier of the system
+	// as reported via SNMP, e.g. `1.3.6.1.4.1.9.12.3.1.3.1208`.
+	ObjectID string `json:"objectID,omitempty"`
+	// BootTime is the time at which the system was last booted.
+	BootTime *metav1.Time `json:"bootTime,omitempty"`
+}
 
-	return -1
+// HostInterface describes a network interface of a host.
+type HostInterface struct {
+	// Index is the index of the interface, which is unique on the host.
+	Index int `json:"index"`
+	// Name is the name of the interface, e.g. `eth0` or `Ethernet1/1`.
+	Name string `json:"name,omitempty"`
+	// Description is the description of the interface, which is configured by an administrator.
+	Description string `json:"description,omitempty"`
+	// Type is the IANA type of the interface, e.g. `ethernetCsmacd`.
+	Type string `json:"type,omitempty"`
+	// MTU is the maximum transmission unit of the interface in bytes.
+	MTU int `json:"mtu,omitempty"`
+	// Speed is the speed of the interface in bits per second.
+	Speed int64 `json:"speed,omitempty"`
+	// MACAddress is the physical address of the interface.
+	MACAddress string `json:"macAddress,omitempty"`
+	// AdminStatus is the desired state of the interface, e.g. `up` or `down`.
+	AdminStatus string `json:"adminStatus,omitempty"`
+	// OperStatus is the operational state of the interface, e.g. `up` or `down`.
+	OperStatus string `json:"operStatus,omitempty"`
 }
 
-// Minor returns the minor version of the operating system.
-// Returns -1 if the version could not be parsed.
-func (v OSVersion) Minor() int {
-	segments := strings.Split(string(v), ".")
+// PackageManager is a package manager that is available on a host.
+type PackageManager string
+
+const (
+	// PackageManagerAPT is the package manager of Debian and its derivatives.
+	PackageManagerAPT PackageManager = "apt"
+	// PackageManagerDNF is the package manager of modern RHEL derivatives.
+	PackageManagerDNF PackageManager = "dnf"
+	// PackageManagerYUM is the package manager of older RHEL derivatives.
+	PackageManagerYUM PackageManager = "yum"
+	// PackageManagerAPK is the package manager of Alpine Linux.
+	PackageManagerAPK PackageManager = "apk"
+)
+
+// InitSystem is the init system that is used by a host.
+type InitSystem string
+
+const (
+	// InitSystemSystemd is the systemd init system.
+	InitSystemSystemd InitSystem = "systemd"
+	// InitSystemOpenRC is the OpenRC init system.
+	InitSystemOpenRC InitSystem = "openrc"
+	// InitSystemSysVInit is the SysV init system.
+	InitSystemSysVInit InitSystem = "sysvinit"
+)
+
+// FirewallBackend is a firewall backend that is available on a host.
+type FirewallBackend string
 
-	if len(segments) > 1 {
-		minor, err := strconv.Atoi(segments[1])
-		if err == nil {
-			return minor
+const (
+	// FirewallBackendNFTables is the "nftables" packet filtering framework.
+	FirewallBackendNFTables FirewallBackend = "nftables"
+	// FirewallBackendFirewalld is the "firewalld" firewall daemon.
+	FirewallBackendFirewalld FirewallBackend = "firewalld"
+	// FirewallBackendIPTables is the legacy "iptables" packet filtering framework.
+	FirewallBackendIPTables FirewallBackend = "iptables"
+)
+
+// HostCapabilities describes the tooling that is available on a host.
+type HostCapabilities struct {
+	// PackageManager is the package manager of the host.
+	PackageManager PackageManager `json:"packageManager,omitempty"`
+	// InitSystem is the init system of the host.
+	InitSystem InitSystem `json:"initSystem,omitempty"`
+	// FirewallBackends are the firewall backends that are available on the host.
+	FirewallBackends []FirewallBackend `json:"firewallBackends,omitempty"`
+}
+
+// HasFirewallBackend returns true if the firewall backend is available on the host.
+func (c *HostCapabilities) HasFirewallBackend(backend FirewallBackend) bool {
+	for _, candidate := range c.FirewallBackends {
+		if candidate == backend {
+			return true
 		}
 	}
+	return false
+}
 
-	return -1
+// HostSpecSSHCertificate configures the authentication with short-lived
+// SSH user certificates, which are signed by the SSH CA of the operator.
+type HostSpecSSHCertificate struct {
+	// Principals are the principals of the certificate. Defaults to the user.
+	Principals []string `json:"principals,omitempty"`
+	// Validity is the duration for which a certificate is valid. A certificate is
+	// only checked during the authentication, which is why it may be short-lived.
+	//+kubebuilder:default="5m"
+	Validity *metav1.Duration `json:"validity,omitempty"`
 }
 
-// OSInfo describes the operating system of a system.
-type OSInfo struct {
-	// Name is the name of the operating system.
-	Name string `json:"name,omitempty"`
-	// Version is the version of the operating system.
-	Version OSVersion `json:"version,omitempty"`
-	// KernelVersion is the kernel version of the operating system.
-	KernelVersion string `json:"kernelVersion,omitempty"`
+// HostSpecSSHKeyRotation configures the rotation of the private key in the Secret.
+type HostSpecSSHKeyRotation struct {
+	// Schedule is a cron expression, such as `0 3 * * 0`, that
+	// determines when the private key is rotated.
+	//+kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+}
+
+// HostSpecSSHJumpHost describes an SSH jump host, through which the
+// connection to the next jump host or the host itself is tunneled.
+type HostSpecSSHJumpHost struct {
+	// Host is the jump host to connect to.
+	//+kubebuilder:validation:Required
+	Host string `json:"host"`
+	// Port is the port to connect to.
+	Port int `json:"port,omitempty"`
+	// Fingerprint is the SSH host key fingerprint in the format `{algorithm}:{hash}`.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// User is the SSH user to connect as.
+	User string `json:"user,omitempty"`
+	// SecretRef is the reference to a secret containing the credentials of the
+	// jump host in the keys `key`, `passphrase`, `passwordInsecure` and
+	// `certificate`. Defaults to the secret of the host. A secret in another
+	// namespace must grant access to the namespace of the host.
+	SecretRef *corev1.SecretReference `json:"secretRef,omitempty"`
+}
+
+// Address returns the network address of the jump host.
+func (j *HostSpecSSHJumpHost) Address() string {
+	port := j.Port
+	if port == 0 {
+		port = 22
+	}
+
+	return net.JoinHostPort(j.Host, strconv.Itoa(port))
 }
 
 // HostSpecSSHOptions defines the SSH connection options.
@@ -96,6 +292,59 @@ type HostSpecSSHOptions struct {
 	ProxyFingerprint string `json:"proxyFingerprint,omitempty"`
 	// ProxyUser is the SSH proxy user to connect as.
 	ProxyUser string `json:"proxyUser,omitempty"`
+	// BastionRef is the reference to an SSHBastion in the namespace of the
+	// host, through which the host is reached. The connection to the bastion
+	// is shared with other hosts. It must not be combined with the proxy
+	// options, but the jump hosts are reached through the bastion.
+	BastionRef *corev1.LocalObjectReference `json:"bastionRef,omitempty"`
+	// JumpHosts are the SSH jump hosts, which are traversed in the given order
+	// to reach the host. They must not be combined with the proxy options,
+	// which are equivalent to a single jump host with credentials in the keys
+	// `proxyKey`, `proxyPassphrase`, `proxyPasswordInsecure` and
+	// `proxyCertificate` of the secret of the host.
+	JumpHosts []HostSpecSSHJumpHost `json:"jumpHosts,omitempty"`
+	// TrustOnFirstUse records the host key fingerprints of the host, the proxy
+	// and the jump hosts in the status on the first connection, if they are not
+	// specified, and strictly enforces them afterwards.
+	TrustOnFirstUse bool `json:"trustOnFirstUse,omitempty"`
+	// HostCAKeys contains the public keys of SSH certificate authorities in the
+	// authorized keys format. Host certificates of the host, the proxy and the
+	// jump hosts that are signed by one of them are trusted without verifying
+	// their fingerprints.
+	HostCAKeys []string `json:"hostCAKeys,omitempty"`
+	// Certificate enables the authentication of the user, the proxy user and the
+	// users of the jump hosts with short-lived certificates that are signed by
+	// the SSH CA of the operator. This takes precedence over the credentials in
+	// the Secret.
+	Certificate *HostSpecSSHCertificate `json:"certificate,omitempty"`
+	// KeyRotation enables the periodic rotation of the private key of the user,
+	// which is stored in the key `key` of the Secret, unless the operator is
+	// configured with another key name. The Secret must not be shared with
+	// other hosts.
+	KeyRotation *HostSpecSSHKeyRotation `json:"keyRotation,omitempty"`
+}
+
+// HostSpecSNMPOptions contains additional SNMP connection options. The
+// credentials are read from the keys `community`, `authPassphrase` and
+// `privPassphrase` of the Secret.
+type HostSpecSNMPOptions struct {
+	// Version is the version of the SNMP protocol. Defaults to `v2c`.
+	//+kubebuilder:validation:Enum=v2c;v3
+	//+kubebuilder:default=v2c
+	Version SNMPVersion `json:"version,omitempty"`
+	// User is the name of the SNMPv3 user.
+	User string `json:"user,omitempty"`
+	// AuthProtocol is the SNMPv3 authentication protocol. Messages are
+	// authenticated if the Secret contains an authentication passphrase.
+	// Defaults to `SHA`.
+	//+kubebuilder:validation:Enum=MD5;SHA;SHA-224;SHA-256;SHA-384;SHA-512
+	AuthProtocol SNMPAuthProtocol `json:"authProtocol,omitempty"`
+	// PrivProtocol is the SNMPv3 privacy protocol. Messages are encrypted
+	// if the Secret contains a privacy passphrase. Defaults to `AES`.
+	//+kubebuilder:validation:Enum=DES;AES
+	PrivProtocol SNMPPrivProtocol `json:"privProtocol,omitempty"`
+	// ContextName is the SNMPv3 context name.
+	ContextName string `json:"contextName,omitempty"`
 }
 
 // HostSpec defines the desired state of Host
@@ -103,24 +352,63 @@ type HostSpec struct {
 	// Host is the host to connect to.
 	//+kubebuilder:validation:Required
 	Host string `json:"host,omitempty"`
-	// Port is the port to connect to.
+	// Port is the port to connect to. Defaults to
+	// `22` for SSH, `161` for SNMP and `830` for NETCONF.
 	Port int `json:"port,omitempty"`
 	// Protocol is the protocol used to connect to the host.
-	// Currently only supports `SSH`.
+	// Supports `SSH`, `SNMP`, which only allows to monitor the
+	// host, and `NETCONF`, which uses the SSH connection options.
 	//+kubebuilder:validation:Required
-	//+kubebuilder:validation:Enum=SSH
+	//+kubebuilder:validation:Enum=SSH;SNMP;NETCONF
 	Protocol Protocol `json:"protocol"`
-	// SSH contains additional SSH connection options.
+	// SSH contains additional SSH connection options, which also apply to NETCONF.
 	SSH HostSpecSSHOptions `json:"ssh,omitempty"`
+	// SNMP contains additional SNMP connection options.
+	SNMP HostSpecSNMPOptions `json:"snmp,omitempty"`
+	// OutOfBand configures the BMC of the host, which allows to control the
+	// power and to read the inventory of the host while its OS is down.
+	OutOfBand *HostSpecOutOfBand `json:"outOfBand,omitempty"`
 	// SecretRef is the reference to a secret containing sensitive connection credentials.
+	// A secret in another namespace must grant access to the namespace of the host by
+	// listing it in the annotation `kraut.nicklasfrahm.dev/allowed-namespaces`.
 	//+kubebuilder:validation:Required
 	SecretRef corev1.SecretReference `json:"secretRef"`
 }
 
+// HostStatusSSH describes the observed state of the SSH connection.
+type HostStatusSSH struct {
+	// Fingerprint is the SSH host key fingerprint in the format `{algorithm}:{hash}`,
+	// which was trusted on first use.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// ProxyFingerprint is the SSH proxy host key fingerprint in the format
+	// `{algorithm}:{hash}`, which was trusted on first use.
+	ProxyFingerprint string `json:"proxyFingerprint,omitempty"`
+	// JumpHostFingerprints are the SSH host key fingerprints of the jump hosts
+	// in the format `{algorithm}:{hash}`, which were trusted on first use. They
+	// are keyed by the network address of the jump host in the format `{host}:{port}`.
+	JumpHostFingerprints map[string]string `json:"jumpHostFingerprints,omitempty"`
+	// LastKeyRotationTime is the time at which the private key was last rotated.
+	LastKeyRotationTime *metav1.Time `json:"lastKeyRotationTime,omitempty"`
+	// NextKeyRotationTime is the time at which the private key is rotated next.
+	NextKeyRotationTime *metav1.Time `json:"nextKeyRotationTime,omitempty"`
+}
+
 // HostStatus defines the observed state of Host
 type HostStatus struct {
 	// OS contains information about the discovered operating system.
 	OS OSInfo `json:"os,omitempty"`
+	// Capabilities contains information about the discovered tooling of the host.
+	Capabilities HostCapabilities `json:"capabilities,omitempty"`
+	// SSH contains the observed state of the SSH connection.
+	SSH HostStatusSSH `json:"ssh,omitempty"`
+	// Interfaces contains the discovered network interfaces of the host.
+	Interfaces []HostInterface `json:"interfaces,omitempty"`
+	// OutOfBand contains the observed state of the BMC of the host.
+	OutOfBand *HostStatusOutOfBand `json:"outOfBand,omitempty"`
+	// Conditions describe the current state of the host.
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -131,6 +419,7 @@ type HostStatus struct {
 //+kubebuilder:printcolumn:name="OS-Name",type=string,JSONPath=`.status.os.name`
 //+kubebuilder:printcolumn:name="OS-Version",type=string,JSONPath=`.status.os.version`
 //+kubebuilder:printcolumn:name="Kernel-Version",type=string,JSONPath=`.status.os.kernelVersion`
+//+kubebuilder:printcolumn:name="Power",type=string,JSONPath=`.status.outOfBand.powerState`,priority=1
 
 // Host is the Schema for the hosts API
 type Host struct {
@@ -0,0 +1,200 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HostActionType is the type of an action.
+type HostActionType string
+
+const (
+	// HostActionTypeReboot reboots the operating system of the host.
+	HostActionTypeReboot HostActionType = "Reboot"
+	// HostActionTypeShutdown shuts down the operating system and powers off the host.
+	HostActionTypeShutdown HostActionType = "Shutdown"
+	// HostActionTypePowerCycle powers the host off and on again via its BMC.
+	HostActionTypePowerCycle HostActionType = "PowerCycle"
+)
+
+// HostActionMethod describes how an action is submitted to a host.
+type HostActionMethod string
+
+const (
+	// HostActionMethodAuto submits the action via the operating system if the
+	// host is reachable and falls back to the BMC otherwise.
+	HostActionMethodAuto HostActionMethod = "Auto"
+	// HostActionMethodInBand submits the action via the operating system.
+	HostActionMethodInBand HostActionMethod = "InBand"
+	// HostActionMethodOutOfBand submits the action via the BMC.
+	HostActionMethodOutOfBand HostActionMethod = "OutOfBand"
+)
+
+// HostActionPhase describes the state of an action.
+type HostActionPhase string
+
+const (
+	// HostActionPhasePending means that the action has not been started yet.
+	HostActionPhasePending HostActionPhase = "Pending"
+	// HostActionPhaseRunning means that the action is currently running.
+	HostActionPhaseRunning HostActionPhase = "Running"
+	// HostActionPhaseSucceeded means that the action succeeded on all hosts.
+	HostActionPhaseSucceeded HostActionPhase = "Succeeded"
+	// HostActionPhaseFailed means that the action failed on at least one host.
+	HostActionPhaseFailed HostActionPhase = "Failed"
+	// HostActionPhaseSkipped means that the action was not started on a
+	// host, because it failed on another host.
+	HostActionPhaseSkipped HostActionPhase = "Skipped"
+)
+
+// HostActionStep is a step in the timeline of an action on a host.
+type HostActionStep string
+
+const (
+	// HostActionStepStarted means that the action was started on the host.
+	HostActionStepStarted HostActionStep = "Started"
+	// HostActionStepSubmitted means that the action was submitted to the host.
+	HostActionStepSubmitted HostActionStep = "Submitted"
+	// HostActionStepHostDown means that the host became unreachable.
+	HostActionStepHostDown HostActionStep = "HostDown"
+	// HostActionStepHostUp means that the host was booted and is reachable again.
+	HostActionStepHostUp HostActionStep = "HostUp"
+	// HostActionStepPoweredOff means that the host was powered off.
+	HostActionStepPoweredOff HostActionStep = "PoweredOff"
+	// HostActionStepFailed means that the action failed on the host.
+	HostActionStepFailed HostActionStep = "Failed"
+)
+
+// HostActionSpec defines the desired state of HostAction
+type HostActionSpec struct {
+	// Type is the type of the action.
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum=Reboot;Shutdown;PowerCycle
+	Type HostActionType `json:"type"`
+	// HostSelector selects the hosts in the namespace of
+	// the HostAction on which the action is performed.
+	//+kubebuilder:validation:Required
+	HostSelector metav1.LabelSelector `json:"hostSelector"`
+	// Method describes how the action is submitted to the hosts. A power
+	// cycle is always submitted via the BMC. Defaults to `Auto`.
+	//+kubebuilder:validation:Enum=Auto;InBand;OutOfBand
+	//+kubebuilder:default=Auto
+	Method HostActionMethod `json:"method,omitempty"`
+	// Concurrency is the maximum number of selected hosts on which an action
+	// is in progress at the same time. Actions of other HostActions on the
+	// selected hosts count towards the limit, so that overlapping selectors
+	// never exceed it.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default=1
+	Concurrency int `json:"concurrency,omitempty"`
+	// Timeout is the maximum duration for a single host to complete
+	// the action, e.g. to come back after a reboot. Defaults to 15m.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// HostActionEvent is an entry in the timeline of an action on a host.
+type HostActionEvent struct {
+	// Time is the time at which the step was observed.
+	Time metav1.Time `json:"time"`
+	// Step is the step of the action.
+	Step HostActionStep `json:"step"`
+	// Message describes the step.
+	Message string `json:"message,omitempty"`
+}
+
+// HostActionHostStatus describes the progress of an action on a single host.
+type HostActionHostStatus struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+	// Phase describes the state of the action on the host.
+	Phase HostActionPhase `json:"phase"`
+	// Method is the method via which the action was submitted,
+	// which is either `InBand` or `OutOfBand`.
+	Method HostActionMethod `json:"method,omitempty"`
+	// BootID is the ID of the boot of the host before the action was submitted.
+	// It falls back to the boot time if the boot ID cannot be read.
+	BootID string `json:"bootID,omitempty"`
+	// Error describes why the action failed.
+	Error string `json:"error,omitempty"`
+	// StartTime is the time at which the action was started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time at which the action completed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Timeline contains the steps of the action in the order they were observed.
+	Timeline []HostActionEvent `json:"timeline,omitempty"`
+}
+
+// HasStep checks if the step was observed.
+func (s *HostActionHostStatus) HasStep(step HostActionStep) bool {
+	for _, event := range s.Timeline {
+		if event.Step == step {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HostActionStatus defines the observed state of HostAction
+type HostActionStatus struct {
+	// ObservedGeneration is the generation of the spec that was last run.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase describes the state of the action.
+	Phase HostActionPhase `json:"phase,omitempty"`
+	// StartTime is the time at which the action was started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time at which the action completed on all hosts.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Succeeded is the number of hosts on which the action succeeded.
+	Succeeded int `json:"succeeded,omitempty"`
+	// Failed is the number of hosts on which the action failed.
+	Failed int `json:"failed,omitempty"`
+	// Hosts contains the progress of the action for each host.
+	Hosts []HostActionHostStatus `json:"hosts,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=hostact,path=hostactions,singular=hostaction
+//+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Succeeded",type=integer,JSONPath=`.status.succeeded`
+//+kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.failed`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// HostAction is the Schema for the hostactions API
+type HostAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostActionSpec   `json:"spec,omitempty"`
+	Status HostActionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HostActionList contains a list of HostAction
+type HostActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostAction `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostAction{}, &HostActionList{})
+}
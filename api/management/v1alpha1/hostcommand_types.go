@@ -0,0 +1,138 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HostCommandPhase describes the state of the last run of a command.
+type HostCommandPhase string
+
+const (
+	// HostCommandPhasePending means that the command has not been run yet.
+	HostCommandPhasePending HostCommandPhase = "Pending"
+	// HostCommandPhaseRunning means that the command is currently running.
+	HostCommandPhaseRunning HostCommandPhase = "Running"
+	// HostCommandPhaseSucceeded means that the command succeeded on all hosts.
+	HostCommandPhaseSucceeded HostCommandPhase = "Succeeded"
+	// HostCommandPhaseFailed means that the command failed on at least one host.
+	HostCommandPhaseFailed HostCommandPhase = "Failed"
+)
+
+// HostCommandSpec defines the desired state of HostCommand
+type HostCommandSpec struct {
+	// HostSelector selects the hosts in the namespace of
+	// the HostCommand on which the command is executed.
+	//+kubebuilder:validation:Required
+	HostSelector metav1.LabelSelector `json:"hostSelector"`
+	// Command is the command line that is interpreted by the shell of the host.
+	// Either `command` or `script` must be specified.
+	Command string `json:"command,omitempty"`
+	// Script is a shell script that is passed to `sh -s` via the standard input.
+	// Either `command` or `script` must be specified.
+	Script string `json:"script,omitempty"`
+	// Env contains additional environment variables for the command.
+	Env map[string]string `json:"env,omitempty"`
+	// Timeout is the maximum duration of the command on a single host.
+	// Defaults to the command timeout of the operator.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Concurrency is the maximum number of hosts on which
+	// the command is executed at the same time.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default=1
+	Concurrency int `json:"concurrency,omitempty"`
+	// Schedule is a cron expression that describes when the command is executed,
+	// such as `0 3 * * *`. If not specified, the command is executed once and
+	// again whenever the spec of the HostCommand changes.
+	Schedule string `json:"schedule,omitempty"`
+	// Suspend prevents further scheduled runs of the command.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// HostCommandResult contains the result of a command on a single host.
+type HostCommandResult struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+	// ExitCode is the exit code of the command.
+	// It is not set if the command could not be run.
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Stdout contains the end of the standard output of the command.
+	Stdout string `json:"stdout,omitempty"`
+	// Stderr contains the end of the standard error of the command.
+	Stderr string `json:"stderr,omitempty"`
+	// Error describes why the command could not be run.
+	Error string `json:"error,omitempty"`
+	// StartTime is the time at which the command was started.
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time at which the command completed.
+	CompletionTime metav1.Time `json:"completionTime,omitempty"`
+}
+
+// Succeeded checks if the command was run and exited with exit code 0.
+func (r *HostCommandResult) Succeeded() bool {
+	return r.Error == "" && r.ExitCode != nil && *r.ExitCode == 0
+}
+
+// HostCommandStatus defines the observed state of HostCommand
+type HostCommandStatus struct {
+	// ObservedGeneration is the generation of the spec that was last run.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase describes the state of the last run.
+	Phase HostCommandPhase `json:"phase,omitempty"`
+	// LastRunTime is the time at which the last run was started.
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// NextRunTime is the time of the next scheduled run.
+	NextRunTime *metav1.Time `json:"nextRunTime,omitempty"`
+	// Succeeded is the number of hosts on which the last run succeeded.
+	Succeeded int `json:"succeeded,omitempty"`
+	// Failed is the number of hosts on which the last run failed.
+	Failed int `json:"failed,omitempty"`
+	// Results contains the results of the last run for each host.
+	Results []HostCommandResult `json:"results,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=hostcmd,path=hostcommands,singular=hostcommand
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Succeeded",type=integer,JSONPath=`.status.succeeded`
+//+kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.failed`
+//+kubebuilder:printcolumn:name="Last-Run",type=date,JSONPath=`.status.lastRunTime`
+
+// HostCommand is the Schema for the hostcommands API
+type HostCommand struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostCommandSpec   `json:"spec,omitempty"`
+	Status HostCommandStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HostCommandList contains a list of HostCommand
+type HostCommandList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostCommand `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostCommand{}, &HostCommandList{})
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ContentSource references content in the namespace of the referencing resource.
+// Exactly one of `configMapKeyRef` or `secretKeyRef` must be specified.
+type ContentSource struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef selects a key of a Secret.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// HostFileSpec defines the desired state of HostFile
+type HostFileSpec struct {
+	// HostSelector selects the hosts in the namespace of
+	// the HostFile on which the file is managed.
+	//+kubebuilder:validation:Required
+	HostSelector metav1.LabelSelector `json:"hostSelector"`
+	// Path is the absolute path of the file on the host.
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path"`
+	// Content is the inline content of the file.
+	// Either `content` or `contentFrom` must be specified.
+	Content string `json:"content,omitempty"`
+	// ContentFrom references the content of the file.
+	// Either `content` or `contentFrom` must be specified.
+	ContentFrom *ContentSource `json:"contentFrom,omitempty"`
+	// Mode contains the octal permission bits of the file.
+	//+kubebuilder:validation:Pattern=`^0?[0-7]{3}$`
+	//+kubebuilder:default="0644"
+	Mode string `json:"mode,omitempty"`
+	// Owner is the name or the numeric ID of the user that owns the file.
+	Owner string `json:"owner,omitempty"`
+	// Group is the name or the numeric ID of the group that owns the file.
+	Group string `json:"group,omitempty"`
+	// OnChange is a command that is run after the file was written, such as
+	// `systemctl reload chronyd`. It is retried until it succeeds.
+	OnChange string `json:"onChange,omitempty"`
+	// RemoveOnDelete removes the file from the hosts if the HostFile is
+	// deleted or if a host no longer matches the selector.
+	RemoveOnDelete bool `json:"removeOnDelete,omitempty"`
+	// Interval is the interval at which the file is checked for drift.
+	//+kubebuilder:default="10m"
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// HostFileHostStatus describes the state of the file on a single host.
+type HostFileHostStatus struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+	// InSync indicates that the file on the host matches the desired state.
+	InSync bool `json:"inSync"`
+	// Checksum is the SHA256 checksum of the file on the host before it was synced.
+	Checksum string `json:"checksum,omitempty"`
+	// Error describes why the file could not be synced.
+	Error string `json:"error,omitempty"`
+	// ReloadPending indicates that the file was written, but that the
+	// on-change command has not succeeded yet. It is retried until it does.
+	ReloadPending bool `json:"reloadPending,omitempty"`
+	// LastSyncTime is the time at which the file was last written.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// LastDriftTime is the time at which the file was last found to deviate
+	// from the desired state after it had been written by the controller.
+	LastDriftTime *metav1.Time `json:"lastDriftTime,omitempty"`
+}
+
+// HostFileStatus defines the observed state of HostFile
+type HostFileStatus struct {
+	// ObservedGeneration is the generation of the spec that was last synced.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Checksum is the SHA256 checksum of the desired content.
+	Checksum string `json:"checksum,omitempty"`
+	// InSync is the number of hosts on which the file is in sync.
+	InSync int `json:"inSync,omitempty"`
+	// OutOfSync is the number of hosts on which the file could not be synced.
+	OutOfSync int `json:"outOfSync,omitempty"`
+	// Hosts contains the state of the file for each selected host.
+	Hosts []HostFileHostStatus `json:"hosts,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=hostfile,path=hostfiles,singular=hostfile
+//+kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.spec.path`
+//+kubebuilder:printcolumn:name="In-Sync",type=integer,JSONPath=`.status.inSync`
+//+kubebuilder:printcolumn:name="Out-Of-Sync",type=integer,JSONPath=`.status.outOfSync`
+
+// HostFile is the Schema for the hostfiles API
+type HostFile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostFileSpec   `json:"spec,omitempty"`
+	Status HostFileStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HostFileList contains a list of HostFile
+type HostFileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostFile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostFile{}, &HostFileList{})
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HostKernelSpec defines the desired state of HostKernel
+type HostKernelSpec struct {
+	// HostSelector selects the hosts in the namespace of
+	// the HostKernel on which the kernel is configured.
+	//+kubebuilder:validation:Required
+	HostSelector metav1.LabelSelector `json:"hostSelector"`
+	// Sysctl contains the kernel parameters, such as `net.ipv4.ip_forward: "1"`.
+	// The parameters are applied at runtime and persisted to
+	// `/etc/sysctl.d/90-kraut-<name>.conf`.
+	Sysctl map[string]string `json:"sysctl,omitempty"`
+	// Modules contains the kernel modules, such as `br_netfilter`. The
+	// modules are loaded at runtime and persisted to
+	// `/etc/modules-load.d/kraut-<name>.conf`. They are loaded before
+	// the kernel parameters are applied.
+	Modules []string `json:"modules,omitempty"`
+	// RemoveOnDelete removes the persisted configuration from the hosts if
+	// the HostKernel is deleted or if a host no longer matches the selector.
+	// The runtime configuration is not reverted.
+	RemoveOnDelete bool `json:"removeOnDelete,omitempty"`
+	// Interval is the interval at which the kernel is checked.
+	//+kubebuilder:default="10m"
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// HostKernelHostStatus describes the state of the kernel on a single host.
+type HostKernelHostStatus struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+	// InSync indicates that the kernel on the host matches the desired state.
+	InSync bool `json:"inSync"`
+	// Sysctl contains the effective values of the kernel parameters on the host.
+	Sysctl map[string]string `json:"sysctl,omitempty"`
+	// Modules contains the kernel modules that are loaded on the host.
+	Modules []string `json:"modules,omitempty"`
+	// Error describes why the kernel could not be synced.
+	Error string `json:"error,omitempty"`
+	// LastSyncTime is the time at which the kernel was last checked.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// HostKernelStatus defines the observed state of HostKernel
+type HostKernelStatus struct {
+	// ObservedGeneration is the generation of the spec that was last synced.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// InSync is the number of hosts on which the kernel is in sync.
+	InSync int `json:"inSync,omitempty"`
+	// OutOfSync is the number of hosts on which the kernel could not be synced.
+	OutOfSync int `json:"outOfSync,omitempty"`
+	// Hosts contains the state of the kernel for each selected host.
+	Hosts []HostKernelHostStatus `json:"hosts,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=hostkernel,path=hostkernels,singular=hostkernel
+//+kubebuilder:printcolumn:name="In-Sync",type=integer,JSONPath=`.status.inSync`
+//+kubebuilder:printcolumn:name="Out-Of-Sync",type=integer,JSONPath=`.status.outOfSync`
+
+// HostKernel is the Schema for the hostkernels API
+type HostKernel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostKernelSpec   `json:"spec,omitempty"`
+	Status HostKernelStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HostKernelList contains a list of HostKernel
+type HostKernelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostKernel `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostKernel{}, &HostKernelList{})
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PackageState is the desired state of a package.
+type PackageState string
+
+const (
+	// PackageStatePresent ensures that a package is installed.
+	PackageStatePresent PackageState = "Present"
+	// PackageStateLatest ensures that the latest version of a package is installed.
+	PackageStateLatest PackageState = "Latest"
+	// PackageStateAbsent ensures that a package is not installed.
+	PackageStateAbsent PackageState = "Absent"
+)
+
+// HostPackageSpecPackage describes the desired state of a single package.
+type HostPackageSpecPackage struct {
+	// Name is the name of the package.
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Version is the exact version of the package as reported by the package
+	// manager, such as `4.2-1` for apt. Only valid with the state `Present`.
+	Version string `json:"version,omitempty"`
+	// State is the desired state of the package.
+	//+kubebuilder:validation:Enum=Present;Latest;Absent
+	//+kubebuilder:default=Present
+	State PackageState `json:"state,omitempty"`
+	// Hold prevents the package from being upgraded outside of this resource,
+	// such as by unattended upgrades. If the hold is removed from the spec,
+	// the package is only released if it was held by this resource.
+	Hold bool `json:"hold,omitempty"`
+}
+
+// HostPackageSpec defines the desired state of HostPackage
+type HostPackageSpec struct {
+	// HostSelector selects the hosts in the namespace of
+	// the HostPackage on which the packages are managed.
+	//+kubebuilder:validation:Required
+	HostSelector metav1.LabelSelector `json:"hostSelector"`
+	// Packages contains the desired state of the packages.
+	//+kubebuilder:validation:MinItems=1
+	Packages []HostPackageSpecPackage `json:"packages"`
+	// Interval is the interval at which the packages are checked.
+	//+kubebuilder:default="1h"
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// InstalledPackage describes a package that is installed on a host.
+type InstalledPackage struct {
+	// Name is the name of the package.
+	Name string `json:"name"`
+	// Version is the installed version of the package.
+	Version string `json:"version"`
+}
+
+// HostPackageHostStatus describes the state of the packages on a single host.
+type HostPackageHostStatus struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+	// PackageManager is the package manager that is used on the host.
+	PackageManager PackageManager `json:"packageManager,omitempty"`
+	// InSync indicates that the packages on the host match the desired state.
+	InSync bool `json:"inSync"`
+	// Packages contains the installed versions of the managed packages.
+	Packages []InstalledPackage `json:"packages,omitempty"`
+	// Held contains the names of the packages that were held by this resource.
+	// Holds that were placed outside of this resource are never released.
+	Held []string `json:"held,omitempty"`
+	// SecurityUpdates contains the names of the installed
+	// packages for which a security update is available.
+	SecurityUpdates []string `json:"securityUpdates,omitempty"`
+	// Error describes why the packages could not be synced.
+	Error string `json:"error,omitempty"`
+	// LastSyncTime is the time at which the packages were last checked.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// HostPackageStatus defines the observed state of HostPackage
+type HostPackageStatus struct {
+	// ObservedGeneration is the generation of the spec that was last synced.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// InSync is the number of hosts on which the packages are in sync.
+	InSync int `json:"inSync,omitempty"`
+	// OutOfSync is the number of hosts on which the packages could not be synced.
+	OutOfSync int `json:"outOfSync,omitempty"`
+	// Hosts contains the state of the packages for each selected host.
+	Hosts []HostPackageHostStatus `json:"hosts,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=hostpkg,path=hostpackages,singular=hostpackage
+//+kubebuilder:printcolumn:name="In-Sync",type=integer,JSONPath=`.status.inSync`
+//+kubebuilder:printcolumn:name="Out-Of-Sync",type=integer,JSONPath=`.status.outOfSync`
+
+// HostPackage is the Schema for the hostpackages API
+type HostPackage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostPackageSpec   `json:"spec,omitempty"`
+	Status HostPackageStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HostPackageList contains a list of HostPackage
+type HostPackageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostPackage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostPackage{}, &HostPackageList{})
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceState is the desired runtime state of a unit.
+type ServiceState string
+
+const (
+	// ServiceStateStarted ensures that a unit is running.
+	ServiceStateStarted ServiceState = "Started"
+	// ServiceStateStopped ensures that a unit is not running.
+	ServiceStateStopped ServiceState = "Stopped"
+)
+
+// HostServiceSpec defines the desired state of HostService
+type HostServiceSpec struct {
+	// HostSelector selects the hosts in the namespace of
+	// the HostService on which the unit is managed.
+	//+kubebuilder:validation:Required
+	HostSelector metav1.LabelSelector `json:"hostSelector"`
+	// Unit is the name of the systemd unit, such as `chrony.service`.
+	//+kubebuilder:validation:Required
+	Unit string `json:"unit"`
+	// Enabled configures whether the unit starts automatically
+	// during boot. The setting is not changed if not specified.
+	Enabled *bool `json:"enabled,omitempty"`
+	// State configures whether the unit is started or stopped.
+	// The state is not changed if not specified.
+	//+kubebuilder:validation:Enum=Started;Stopped
+	State ServiceState `json:"state,omitempty"`
+	// UnitFile is the content of the unit file, which is written to
+	// `/etc/systemd/system/<unit>`. This takes precedence over the
+	// unit file that is shipped by a package.
+	UnitFile string `json:"unitFile,omitempty"`
+	// DropIn is the content of a drop-in file, which is written
+	// to `/etc/systemd/system/<unit>.d/50-kraut.conf`. Unit files
+	// and drop-in files that are removed from the spec are deleted.
+	DropIn string `json:"dropIn,omitempty"`
+	// Interval is the interval at which the unit is checked.
+	//+kubebuilder:default="5m"
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// HostServiceHostStatus describes the state of the unit on a single host.
+type HostServiceHostStatus struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+	// InSync indicates that the unit on the host matches the desired state.
+	InSync bool `json:"inSync"`
+	// ActiveState is the high-level state of the unit, such as `active` or `failed`.
+	ActiveState string `json:"activeState,omitempty"`
+	// SubState is the low-level state of the unit, such as `running` or `exited`.
+	SubState string `json:"subState,omitempty"`
+	// UnitFileState describes if the unit is enabled, such as `enabled` or `static`.
+	UnitFileState string `json:"unitFileState,omitempty"`
+	// MainPID is the ID of the main process of the unit.
+	MainPID int `json:"mainPID,omitempty"`
+	// ManagedFiles contains the paths of the unit files and drop-in
+	// files that were written to the host by this resource.
+	ManagedFiles []string `json:"managedFiles,omitempty"`
+	// Journal contains the most recent journal lines of the unit if it failed.
+	Journal string `json:"journal,omitempty"`
+	// Error describes why the unit could not be synced.
+	Error string `json:"error,omitempty"`
+	// LastSyncTime is the time at which the unit was last checked.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// HostServiceStatus defines the observed state of HostService
+type HostServiceStatus struct {
+	// ObservedGeneration is the generation of the spec that was last synced.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// InSync is the number of hosts on which the unit is in sync.
+	InSync int `json:"inSync,omitempty"`
+	// OutOfSync is the number of hosts on which the unit could not be synced.
+	OutOfSync int `json:"outOfSync,omitempty"`
+	// Hosts contains the state of the unit for each selected host.
+	Hosts []HostServiceHostStatus `json:"hosts,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=hostsvc,path=hostservices,singular=hostservice
+//+kubebuilder:printcolumn:name="Unit",type=string,JSONPath=`.spec.unit`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.spec.state`
+//+kubebuilder:printcolumn:name="In-Sync",type=integer,JSONPath=`.status.inSync`
+//+kubebuilder:printcolumn:name="Out-Of-Sync",type=integer,JSONPath=`.status.outOfSync`
+
+// HostService is the Schema for the hostservices API
+type HostService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostServiceSpec   `json:"spec,omitempty"`
+	Status HostServiceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HostServiceList contains a list of HostService
+type HostServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostService `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostService{}, &HostServiceList{})
+}
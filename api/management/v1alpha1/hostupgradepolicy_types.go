@@ -0,0 +1,181 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// HostUpgradePolicyAnnotationRetry retries the upgrade of the failed hosts and
+// resumes a paused rollout. The annotation is removed once it was processed.
+const HostUpgradePolicyAnnotationRetry = "kraut.nicklasfrahm.dev/retry"
+
+// HostUpgradePolicyPhase describes the state of a rollout.
+type HostUpgradePolicyPhase string
+
+const (
+	// HostUpgradePolicyPhaseRunning means that hosts are being upgraded.
+	HostUpgradePolicyPhaseRunning HostUpgradePolicyPhase = "Running"
+	// HostUpgradePolicyPhasePaused means that the upgrade failed on at least
+	// one host and that the rollout does not upgrade further hosts.
+	HostUpgradePolicyPhasePaused HostUpgradePolicyPhase = "Paused"
+	// HostUpgradePolicyPhaseSucceeded means that all hosts were upgraded.
+	HostUpgradePolicyPhaseSucceeded HostUpgradePolicyPhase = "Succeeded"
+)
+
+// HostUpgradePhase describes the progress of the upgrade of a single host.
+type HostUpgradePhase string
+
+const (
+	// HostUpgradePhasePending means that the upgrade has not been started yet.
+	HostUpgradePhasePending HostUpgradePhase = "Pending"
+	// HostUpgradePhaseUpgrading means that the pre-check
+	// and the upgrade of the packages are running.
+	HostUpgradePhaseUpgrading HostUpgradePhase = "Upgrading"
+	// HostUpgradePhaseRebooting means that the host is rebooting to complete the upgrade.
+	HostUpgradePhaseRebooting HostUpgradePhase = "Rebooting"
+	// HostUpgradePhaseVerifying means that the post-check is running.
+	HostUpgradePhaseVerifying HostUpgradePhase = "Verifying"
+	// HostUpgradePhaseSucceeded means that the host was upgraded and passed the post-check.
+	HostUpgradePhaseSucceeded HostUpgradePhase = "Succeeded"
+	// HostUpgradePhaseFailed means that the upgrade of the host failed.
+	HostUpgradePhaseFailed HostUpgradePhase = "Failed"
+)
+
+// HostUpgradeCheck is a command that checks the health of a host.
+type HostUpgradeCheck struct {
+	// Command is the command line that is interpreted by the shell of the
+	// host. The host is considered healthy if it exits with exit code 0.
+	//+kubebuilder:validation:Required
+	Command string `json:"command"`
+	// Timeout is the maximum duration of the command.
+	// Defaults to the command timeout of the operator.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// HostUpgradePolicySpec defines the desired state of HostUpgradePolicy
+type HostUpgradePolicySpec struct {
+	// HostSelector selects the hosts in the namespace of
+	// the HostUpgradePolicy that are upgraded.
+	//+kubebuilder:validation:Required
+	HostSelector metav1.LabelSelector `json:"hostSelector"`
+	// MaxUnavailable is the maximum number or percentage of selected hosts
+	// that are upgraded at the same time. Percentages are rounded down, but
+	// at least one host is upgraded at a time.
+	//+kubebuilder:validation:XIntOrString
+	//+kubebuilder:default=1
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// PreCheck is run before a host is upgraded. If it fails,
+	// the host is not upgraded and the rollout is paused.
+	PreCheck *HostUpgradeCheck `json:"preCheck,omitempty"`
+	// PostCheck is run after a host was upgraded and rebooted. It is retried
+	// until it succeeds or the timeout expires, which pauses the rollout.
+	PostCheck *HostUpgradeCheck `json:"postCheck,omitempty"`
+	// Timeout is the maximum duration for a host to come back after a
+	// reboot and to pass the post-check. Defaults to 15m.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Schedule is a cron expression that describes when a rollout is started,
+	// such as `0 3 * * 6`. If not specified, a rollout is started once and
+	// again whenever the spec of the HostUpgradePolicy changes.
+	Schedule string `json:"schedule,omitempty"`
+	// Suspend prevents further scheduled rollouts.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// HostUpgradeHostStatus describes the progress of the upgrade of a single host.
+type HostUpgradeHostStatus struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+	// Phase describes the progress of the upgrade of the host.
+	Phase HostUpgradePhase `json:"phase"`
+	// Message describes the last step of the upgrade.
+	Message string `json:"message,omitempty"`
+	// Error describes why the upgrade failed.
+	Error string `json:"error,omitempty"`
+	// RebootRequired indicates that the host requested a reboot after the upgrade.
+	RebootRequired bool `json:"rebootRequired,omitempty"`
+	// BootID is the ID of the boot of the host before the reboot.
+	BootID string `json:"bootID,omitempty"`
+	// PreviousOSVersion is the version of the operating system before the upgrade.
+	PreviousOSVersion OSVersion `json:"previousOSVersion,omitempty"`
+	// OSVersion is the version of the operating system after the upgrade.
+	OSVersion OSVersion `json:"osVersion,omitempty"`
+	// PreviousKernelVersion is the kernel version before the upgrade.
+	PreviousKernelVersion string `json:"previousKernelVersion,omitempty"`
+	// KernelVersion is the kernel version after the upgrade.
+	KernelVersion string `json:"kernelVersion,omitempty"`
+	// StartTime is the time at which the upgrade was started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// UpgradeTime is the time at which the packages were upgraded.
+	UpgradeTime *metav1.Time `json:"upgradeTime,omitempty"`
+	// RebootTime is the time at which the reboot was requested.
+	RebootTime *metav1.Time `json:"rebootTime,omitempty"`
+	// CompletionTime is the time at which the upgrade completed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// HostUpgradePolicyStatus defines the observed state of HostUpgradePolicy
+type HostUpgradePolicyStatus struct {
+	// ObservedGeneration is the generation of the spec of the last rollout.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase describes the state of the last rollout.
+	Phase HostUpgradePolicyPhase `json:"phase,omitempty"`
+	// LastRunTime is the time at which the last rollout was started.
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// NextRunTime is the time of the next scheduled rollout.
+	NextRunTime *metav1.Time `json:"nextRunTime,omitempty"`
+	// CompletionTime is the time at which the last rollout completed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Upgraded is the number of hosts that were upgraded by the last rollout.
+	Upgraded int `json:"upgraded,omitempty"`
+	// Failed is the number of hosts on which the upgrade failed.
+	Failed int `json:"failed,omitempty"`
+	// Hosts contains the progress of the last rollout for each host.
+	Hosts []HostUpgradeHostStatus `json:"hosts,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=hostupgrade,path=hostupgradepolicies,singular=hostupgradepolicy
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Upgraded",type=integer,JSONPath=`.status.upgraded`
+//+kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.failed`
+//+kubebuilder:printcolumn:name="Last-Run",type=date,JSONPath=`.status.lastRunTime`
+
+// HostUpgradePolicy is the Schema for the hostupgradepolicies API
+type HostUpgradePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostUpgradePolicySpec   `json:"spec,omitempty"`
+	Status HostUpgradePolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HostUpgradePolicyList contains a list of HostUpgradePolicy
+type HostUpgradePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostUpgradePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostUpgradePolicy{}, &HostUpgradePolicyList{})
+}
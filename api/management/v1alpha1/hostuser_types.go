@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UserState is the desired state of a local user.
+type UserState string
+
+const (
+	// UserStatePresent ensures that a user exists.
+	UserStatePresent UserState = "Present"
+	// UserStateAbsent ensures that a user does not exist.
+	UserStateAbsent UserState = "Absent"
+)
+
+// HostUserSpec defines the desired state of HostUser
+type HostUserSpec struct {
+	// HostSelector selects the hosts in the namespace of
+	// the HostUser on which the user is managed.
+	//+kubebuilder:validation:Required
+	HostSelector metav1.LabelSelector `json:"hostSelector"`
+	// Name is the name of the local user.
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Pattern=`^[a-z_][a-z0-9_-]{0,30}[$]?$`
+	Name string `json:"name"`
+	// State is the desired state of the user. Users are removed
+	// including their home directory if the state is `Absent`.
+	//+kubebuilder:validation:Enum=Present;Absent
+	//+kubebuilder:default=Present
+	State UserState `json:"state,omitempty"`
+	// UID is the numeric ID of the user. It is only used when the user is created.
+	UID *int `json:"uid,omitempty"`
+	// Shell is the login shell of the user. The shell is not changed if not specified.
+	Shell string `json:"shell,omitempty"`
+	// Groups contains the names of the supplementary groups of the user, which are
+	// created if they do not exist. The groups are not changed if not specified.
+	Groups []string `json:"groups,omitempty"`
+	// Sudo contains the sudo rules of the user without the user name,
+	// such as `ALL=(ALL:ALL) ALL`. The rules are validated before they
+	// are written to `/etc/sudoers.d/kraut-<name>`.
+	Sudo []string `json:"sudo,omitempty"`
+	// AuthorizedKeys contains the public SSH keys that may be used to log in as the user.
+	AuthorizedKeys []string `json:"authorizedKeys,omitempty"`
+	// AuthorizedKeysFrom references additional public SSH keys. Each
+	// referenced value may contain multiple keys separated by newlines.
+	AuthorizedKeysFrom []ContentSource `json:"authorizedKeysFrom,omitempty"`
+	// RemoveOnDelete removes the user from the hosts if the HostUser is deleted.
+	RemoveOnDelete bool `json:"removeOnDelete,omitempty"`
+	// Interval is the interval at which the user is checked.
+	//+kubebuilder:default="10m"
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// HostUserHostStatus describes the state of the user on a single host.
+type HostUserHostStatus struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+	// InSync indicates that the user on the host matches the desired state.
+	InSync bool `json:"inSync"`
+	// UID is the numeric ID of the user on the host.
+	UID *int `json:"uid,omitempty"`
+	// Groups contains the supplementary groups of the user on the host.
+	Groups []string `json:"groups,omitempty"`
+	// Error describes why the user could not be synced.
+	Error string `json:"error,omitempty"`
+	// LastSyncTime is the time at which the user was last checked.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// HostUserStatus defines the observed state of HostUser
+type HostUserStatus struct {
+	// ObservedGeneration is the generation of the spec that was last synced.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// InSync is the number of hosts on which the user is in sync.
+	InSync int `json:"inSync,omitempty"`
+	// OutOfSync is the number of hosts on which the user could not be synced.
+	OutOfSync int `json:"outOfSync,omitempty"`
+	// Hosts contains the state of the user for each selected host.
+	Hosts []HostUserHostStatus `json:"hosts,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=hostuser,path=hostusers,singular=hostuser
+//+kubebuilder:printcolumn:name="User",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.spec.state`
+//+kubebuilder:printcolumn:name="In-Sync",type=integer,JSONPath=`.status.inSync`
+//+kubebuilder:printcolumn:name="Out-Of-Sync",type=integer,JSONPath=`.status.outOfSync`
+
+// HostUser is the Schema for the hostusers API
+type HostUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostUserSpec   `json:"spec,omitempty"`
+	Status HostUserStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HostUserList contains a list of HostUser
+type HostUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostUser{}, &HostUserList{})
+}
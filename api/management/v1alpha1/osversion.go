@@ -0,0 +1,286 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OSVersion describes the version of an operating system.
+// The following formats are understood:
+//   - Ubuntu: `YY.MM`, e.g. `22.04`
+//   - Debian: `N`, e.g. `12`
+//   - RHEL and derivatives: `X.Y`, e.g. `9.3`
+//   - Semantic versions with an optional `v` prefix, e.g. `v1.2.3-rc.1`
+//   - Cisco NX-OS: `X.Y(Z)`, e.g. `9.3(10)` or `10.2(3)F`
+type OSVersion string
+
+// parsedOSVersion is the normalized representation of an OSVersion.
+type parsedOSVersion struct {
+	// segments contains the numeric segments of the version.
+	segments []int
+	// prerelease contains the pre-release identifier of
+	// a semantic version, such as `rc.1` in `1.2.3-rc.1`.
+	prerelease string
+	// suffix contains any trailing qualifier that is not a
+	// pre-release, such as the `F` in the NX-OS version `10.2(3)F`.
+	suffix string
+}
+
+// parse parses the version into its numeric segments and qualifiers.
+// Returns false if the version does not start with a numeric segment.
+func (v OSVersion) parse() (*parsedOSVersion, bool) {
+	raw := strings.TrimSpace(string(v))
+	raw = strings.TrimPrefix(strings.TrimPrefix(raw, "v"), "V")
+
+	parsed := &parsedOSVersion{}
+	i := 0
+	for i < len(raw) {
+		start := i
+		for i < len(raw) && raw[i] >= '0' && raw[i] <= '9' {
+			i++
+		}
+		if start == i {
+			break
+		}
+
+		segment, err := strconv.Atoi(raw[start:i])
+		if err != nil {
+			return nil, false
+		}
+		parsed.segments = append(parsed.segments, segment)
+
+		// Segments are separated by dots, while NX-OS wraps
+		// its maintenance release in parentheses.
+		for i < len(raw) && (raw[i] == '.' || raw[i] == '(' || raw[i] == ')') {
+			i++
+		}
+	}
+
+	if len(parsed.segments) == 0 {
+		return nil, false
+	}
+
+	rest := raw[i:]
+	// Build metadata does not have any precedence.
+	if index := strings.Index(rest, "+"); index >= 0 {
+		rest = rest[:index]
+	}
+	if strings.HasPrefix(rest, "-") {
+		parsed.prerelease = rest[1:]
+	} else {
+		parsed.suffix = rest
+	}
+
+	return parsed, true
+}
+
+// segment returns the numeric segment at the given index.
+// Returns -1 if the version could not be parsed or the
+// segment does not exist.
+func (v OSVersion) segment(index int) int {
+	parsed, ok := v.parse()
+	if !ok || len(parsed.segments) <= index {
+		return -1
+	}
+
+	return parsed.segments[index]
+}
+
+// Valid returns true if the version can be parsed.
+func (v OSVersion) Valid() bool {
+	_, ok := v.parse()
+	return ok
+}
+
+// Major returns the major version of the operating system.
+// Returns -1 if the version could not be parsed.
+func (v OSVersion) Major() int {
+	return v.segment(0)
+}
+
+// Minor returns the minor version of the operating system.
+// Returns -1 if the version could not be parsed.
+func (v OSVersion) Minor() int {
+	return v.segment(1)
+}
+
+// Patch returns the patch version of the operating system.
+// Returns -1 if the version could not be parsed.
+func (v OSVersion) Patch() int {
+	return v.segment(2)
+}
+
+// Compare compares the version to another version. The result will be 0 if
+// v == other, -1 if v < other and +1 if v > other. Missing segments are
+// treated as zero, hence `21.04` and `21.4.0` are considered equal. Versions
+// that can not be parsed are ordered before all valid versions.
+func (v OSVersion) Compare(other OSVersion) int {
+	a, aOK := v.parse()
+	b, bOK := other.parse()
+
+	switch {
+	case !aOK && !bOK:
+		return strings.Compare(string(v), string(other))
+	case !aOK:
+		return -1
+	case !bOK:
+		return 1
+	}
+
+	for i := 0; i < len(a.segments) || i < len(b.segments); i++ {
+		x, y := 0, 0
+		if i < len(a.segments) {
+			x = a.segments[i]
+		}
+		if i < len(b.segments) {
+			y = b.segments[i]
+		}
+
+		if x != y {
+			return compareInt(x, y)
+		}
+	}
+
+	// A pre-release has a lower precedence than the release itself.
+	if a.prerelease != b.prerelease {
+		if a.prerelease == "" {
+			return 1
+		}
+		if b.prerelease == "" {
+			return -1
+		}
+		return comparePrerelease(a.prerelease, b.prerelease)
+	}
+
+	// A qualifier, such as a feature release of NX-OS,
+	// has a higher precedence than the plain release.
+	return strings.Compare(a.suffix, b.suffix)
+}
+
+// AtLeast returns true if the version is valid
+// and greater than or equal to the given version.
+func (v OSVersion) AtLeast(minimum OSVersion) bool {
+	return v.Valid() && v.Compare(minimum) >= 0
+}
+
+// Satisfies checks if the version satisfies a constraint, such as `>=21.04`.
+// Multiple constraints may be combined with commas, e.g. `>=9.0, <10`, in
+// which case all of them must be satisfied. The supported operators are
+// `=`, `==`, `!=`, `>`, `>=`, `<` and `<=`. If no operator is given, `=` is
+// assumed. An invalid version never satisfies a constraint.
+func (v OSVersion) Satisfies(constraint string) (bool, error) {
+	expressions := strings.Split(constraint, ",")
+	for _, expression := range expressions {
+		operator, version, err := parseVersionConstraint(expression)
+		if err != nil {
+			return false, err
+		}
+
+		if !v.Valid() {
+			return false, nil
+		}
+
+		result := v.Compare(version)
+		satisfied := false
+		switch operator {
+		case "=", "==":
+			satisfied = result == 0
+		case "!=":
+			satisfied = result != 0
+		case ">":
+			satisfied = result > 0
+		case ">=":
+			satisfied = result >= 0
+		case "<":
+			satisfied = result < 0
+		case "<=":
+			satisfied = result <= 0
+		}
+
+		if !satisfied {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// parseVersionConstraint splits a single constraint into its operator and version.
+func parseVersionConstraint(expression string) (string, OSVersion, error) {
+	expression = strings.TrimSpace(expression)
+
+	operator := "="
+	for _, candidate := range []string{">=", "<=", "==", "!=", ">", "<", "="} {
+		if strings.HasPrefix(expression, candidate) {
+			operator = candidate
+			expression = strings.TrimSpace(strings.TrimPrefix(expression, candidate))
+			break
+		}
+	}
+
+	version := OSVersion(expression)
+	if !version.Valid() {
+		return "", "", fmt.Errorf("invalid version constraint: %q", expression)
+	}
+
+	return operator, version, nil
+}
+
+// comparePrerelease compares two pre-release identifiers as
+// described in the semantic versioning specification.
+func comparePrerelease(a string, b string) int {
+	aFields := strings.Split(a, ".")
+	bFields := strings.Split(b, ".")
+
+	for i := 0; i < len(aFields) && i < len(bFields); i++ {
+		x, xErr := strconv.Atoi(aFields[i])
+		y, yErr := strconv.Atoi(bFields[i])
+
+		switch {
+		case xErr == nil && yErr == nil:
+			if x != y {
+				return compareInt(x, y)
+			}
+		case xErr == nil:
+			// Numeric identifiers have a lower precedence.
+			return -1
+		case yErr == nil:
+			return 1
+		default:
+			if result := strings.Compare(aFields[i], bFields[i]); result != 0 {
+				return result
+			}
+		}
+	}
+
+	return compareInt(len(aFields), len(bFields))
+}
+
+// compareInt compares two integers.
+func compareInt(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
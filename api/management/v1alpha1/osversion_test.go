@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import "testing"
+
+func TestOSVersionSegments(t *testing.T) {
+	tests := []struct {
+		version OSVersion
+		valid   bool
+		major   int
+		minor   int
+		patch   int
+	}{
+		{version: "22.04", valid: true, major: 22, minor: 4, patch: -1},
+		{version: "12", valid: true, major: 12, minor: -1, patch: -1},
+		{version: "9.3", valid: true, major: 9, minor: 3, patch: -1},
+		{version: "v1.2.3-rc.1", valid: true, major: 1, minor: 2, patch: 3},
+		{version: "V1.2.3+build.5", valid: true, major: 1, minor: 2, patch: 3},
+		{version: "9.3(10)", valid: true, major: 9, minor: 3, patch: 10},
+		{version: "10.2(3)F", valid: true, major: 10, minor: 2, patch: 3},
+		{version: " 20.04 ", valid: true, major: 20, minor: 4, patch: -1},
+		{version: "", valid: false, major: -1, minor: -1, patch: -1},
+		{version: "rolling", valid: false, major: -1, minor: -1, patch: -1},
+		{version: "v", valid: false, major: -1, minor: -1, patch: -1},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.version), func(t *testing.T) {
+			if valid := test.version.Valid(); valid != test.valid {
+				t.Errorf("Valid() = %t, want %t", valid, test.valid)
+			}
+			if major := test.version.Major(); major != test.major {
+				t.Errorf("Major() = %d, want %d", major, test.major)
+			}
+			if minor := test.version.Minor(); minor != test.minor {
+				t.Errorf("Minor() = %d, want %d", minor, test.minor)
+			}
+			if patch := test.version.Patch(); patch != test.patch {
+				t.Errorf("Patch() = %d, want %d", patch, test.patch)
+			}
+		})
+	}
+}
+
+func TestOSVersionCompare(t *testing.T) {
+	tests := []struct {
+		a    OSVersion
+		b    OSVersion
+		want int
+	}{
+		// Numeric segments.
+		{a: "22.04", b: "22.04", want: 0},
+		{a: "22.04", b: "20.04", want: 1},
+		{a: "20.04", b: "22.04", want: -1},
+		{a: "9.10", b: "9.9", want: 1},
+		{a: "v1.2.3", b: "1.2.3", want: 0},
+		// Missing segments are treated as zero.
+		{a: "21.04", b: "21.4.0", want: 0},
+		{a: "12", b: "12.0", want: 0},
+		{a: "12", b: "12.1", want: -1},
+		{a: "1.2.3.1", b: "1.2.3", want: 1},
+		// Pre-releases have a lower precedence than the release.
+		{a: "1.0.0-rc.1", b: "1.0.0", want: -1},
+		{a: "1.0.0", b: "1.0.0-rc.1", want: 1},
+		{a: "1.0.0-rc.1", b: "0.9.9", want: 1},
+		// Pre-release ordering as described in the semantic versioning specification.
+		{a: "1.0.0-alpha", b: "1.0.0-alpha.1", want: -1},
+		{a: "1.0.0-alpha.1", b: "1.0.0-alpha.beta", want: -1},
+		{a: "1.0.0-alpha.beta", b: "1.0.0-beta", want: -1},
+		{a: "1.0.0-beta", b: "1.0.0-beta.2", want: -1},
+		{a: "1.0.0-beta.2", b: "1.0.0-beta.11", want: -1},
+		{a: "1.0.0-beta.11", b: "1.0.0-rc.1", want: -1},
+		{a: "1.0.0-rc.1", b: "1.0.0-rc.1", want: 0},
+		// Build metadata does not have any precedence.
+		{a: "1.0.0+build.1", b: "1.0.0+build.2", want: 0},
+		{a: "1.0.0-rc.1+build.1", b: "1.0.0-rc.1", want: 0},
+		// NX-OS versions and their qualifiers.
+		{a: "9.3(10)", b: "9.3(9)", want: 1},
+		{a: "10.2(3)F", b: "10.2(3)", want: 1},
+		{a: "10.2(3)", b: "9.3(10)", want: 1},
+		// Invalid versions are ordered before all valid versions.
+		{a: "rolling", b: "1", want: -1},
+		{a: "1", b: "rolling", want: 1},
+		{a: "rolling", b: "rolling", want: 0},
+		{a: "", b: "rolling", want: -1},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.a)+"_"+string(test.b), func(t *testing.T) {
+			if got := test.a.Compare(test.b); got != test.want {
+				t.Errorf("%q.Compare(%q) = %d, want %d", test.a, test.b, got, test.want)
+			}
+		})
+	}
+}
+
+func TestOSVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version OSVersion
+		minimum OSVersion
+		want    bool
+	}{
+		{version: "22.04", minimum: "20.04", want: true},
+		{version: "20.04", minimum: "20.04", want: true},
+		{version: "18.04", minimum: "20.04", want: false},
+		{version: "", minimum: "20.04", want: false},
+		{version: "rolling", minimum: "rolling", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.version)+"_"+string(test.minimum), func(t *testing.T) {
+			if got := test.version.AtLeast(test.minimum); got != test.want {
+				t.Errorf("%q.AtLeast(%q) = %t, want %t", test.version, test.minimum, got, test.want)
+			}
+		})
+	}
+}
+
+func TestOSVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		version    OSVersion
+		constraint string
+		want       bool
+		wantErr    bool
+	}{
+		// Operators.
+		{version: "22.04", constraint: "22.04", want: true},
+		{version: "22.04", constraint: "=22.04", want: true},
+		{version: "22.04", constraint: "==22.4", want: true},
+		{version: "22.04", constraint: "!=22.04", want: false},
+		{version: "22.04", constraint: "!=20.04", want: true},
+		{version: "22.04", constraint: ">20.04", want: true},
+		{version: "22.04", constraint: ">22.04", want: false},
+		{version: "22.04", constraint: ">=22.04", want: true},
+		{version: "22.04", constraint: "<24.04", want: true},
+		{version: "22.04", constraint: "<22.04", want: false},
+		{version: "22.04", constraint: "<=22.04", want: true},
+		// Whitespace and combined constraints.
+		{version: "9.3", constraint: " >= 9.0 , < 10 ", want: true},
+		{version: "10.0", constraint: ">=9.0, <10", want: false},
+		{version: "8.9", constraint: ">=9.0, <10", want: false},
+		// Pre-releases.
+		{version: "1.0.0-rc.1", constraint: ">=1.0.0", want: false},
+		{version: "1.0.0-rc.1", constraint: ">=1.0.0-rc.1", want: true},
+		// Invalid versions never satisfy a constraint.
+		{version: "rolling", constraint: ">=1", want: false},
+		{version: "", constraint: "!=1", want: false},
+		// Invalid constraints.
+		{version: "22.04", constraint: ">=", wantErr: true},
+		{version: "22.04", constraint: ">=abc", wantErr: true},
+		{version: "22.04", constraint: ">=20.04,", wantErr: true},
+		{version: "rolling", constraint: "~1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.version)+"_"+test.constraint, func(t *testing.T) {
+			got, err := test.version.Satisfies(test.constraint)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("%q.Satisfies(%q) error = %v, want error %t", test.version, test.constraint, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("%q.Satisfies(%q) = %t, want %t", test.version, test.constraint, got, test.want)
+			}
+		})
+	}
+}
+
+func TestComparePrerelease(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "1", b: "2", want: -1},
+		{a: "10", b: "9", want: 1},
+		{a: "1", b: "alpha", want: -1},
+		{a: "alpha", b: "1", want: 1},
+		{a: "alpha", b: "beta", want: -1},
+		{a: "alpha", b: "alpha.1", want: -1},
+		{a: "alpha.1", b: "alpha", want: 1},
+		{a: "rc.1", b: "rc.1", want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+"_"+test.b, func(t *testing.T) {
+			if got := comparePrerelease(test.a, test.b); got != test.want {
+				t.Errorf("comparePrerelease(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+			}
+		})
+	}
+}
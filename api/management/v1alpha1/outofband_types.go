@@ -0,0 +1,231 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OutOfBandProtocol is the protocol used to connect to the BMC of a host.
+type OutOfBandProtocol string
+
+const (
+	// OutOfBandProtocolRedfish is the Redfish protocol over HTTPS.
+	OutOfBandProtocolRedfish OutOfBandProtocol = "Redfish"
+	// OutOfBandProtocolIPMI is the IPMI v2.0 protocol over LAN, also known as RMCP+.
+	OutOfBandProtocolIPMI OutOfBandProtocol = "IPMI"
+)
+
+// RedfishAuthentication is the authentication method of a Redfish service.
+type RedfishAuthentication string
+
+const (
+	// RedfishAuthenticationSession authenticates with a session token,
+	// which is created once per connection.
+	RedfishAuthenticationSession RedfishAuthentication = "Session"
+	// RedfishAuthenticationBasic authenticates each request with HTTP basic authentication.
+	RedfishAuthenticationBasic RedfishAuthentication = "Basic"
+)
+
+// IPMIPrivilege is the privilege level of an IPMI session.
+type IPMIPrivilege string
+
+const (
+	// IPMIPrivilegeOperator allows to control the power of the host.
+	IPMIPrivilegeOperator IPMIPrivilege = "Operator"
+	// IPMIPrivilegeAdministrator allows all operations.
+	IPMIPrivilegeAdministrator IPMIPrivilege = "Administrator"
+)
+
+// PowerState is the power state of a host.
+type PowerState string
+
+const (
+	// PowerStateOn indicates that the host is powered on.
+	PowerStateOn PowerState = "On"
+	// PowerStateOff indicates that the host is powered off.
+	PowerStateOff PowerState = "Off"
+	// PowerStateUnknown indicates that the power state could not be determined.
+	PowerStateUnknown PowerState = "Unknown"
+)
+
+// PowerAction is an action that changes the power state of a host.
+type PowerAction string
+
+const (
+	// PowerActionOn powers the host on.
+	PowerActionOn PowerAction = "On"
+	// PowerActionForceOff powers the host off immediately.
+	PowerActionForceOff PowerAction = "ForceOff"
+	// PowerActionGracefulShutdown asks the operating system to shut down.
+	PowerActionGracefulShutdown PowerAction = "GracefulShutdown"
+	// PowerActionGracefulRestart asks the operating system to restart.
+	PowerActionGracefulRestart PowerAction = "GracefulRestart"
+	// PowerActionForceRestart resets the host immediately.
+	PowerActionForceRestart PowerAction = "ForceRestart"
+	// PowerActionPowerCycle powers the host off and on again.
+	PowerActionPowerCycle PowerAction = "PowerCycle"
+)
+
+// BootDevice is a device that a host boots from.
+type BootDevice string
+
+const (
+	// BootDevicePXE boots from the network.
+	BootDevicePXE BootDevice = "Pxe"
+	// BootDeviceDisk boots from the primary disk.
+	BootDeviceDisk BootDevice = "Hdd"
+	// BootDeviceCD boots from a CD or DVD, e.g. virtual media.
+	BootDeviceCD BootDevice = "Cd"
+	// BootDeviceBIOSSetup boots into the setup of the firmware.
+	BootDeviceBIOSSetup BootDevice = "BiosSetup"
+)
+
+const (
+	// HostAnnotationPowerAction requests a power action via the BMC of a host. The
+	// annotation is removed once the action was submitted to the BMC.
+	HostAnnotationPowerAction = "kraut.nicklasfrahm.dev/power-action"
+	// HostAnnotationBootDevice requests a one-time boot device via the BMC of a
+	// host, which is used on the next boot. The annotation is removed once the
+	// boot device was configured. It is configured before the power action.
+	HostAnnotationBootDevice = "kraut.nicklasfrahm.dev/boot-device"
+)
+
+const (
+	// HostConditionOutOfBandReachable indicates that the BMC of the host accepted a connection.
+	HostConditionOutOfBandReachable = "OutOfBandReachable"
+)
+
+// HostSpecRedfishOptions contains additional Redfish connection options.
+type HostSpecRedfishOptions struct {
+	// Authentication is the authentication method. Defaults to `Session`.
+	//+kubebuilder:validation:Enum=Session;Basic
+	//+kubebuilder:default=Session
+	Authentication RedfishAuthentication `json:"authentication,omitempty"`
+	// SystemID is the ID of the computer system in the Redfish service, e.g.
+	// `System.Embedded.1`. Defaults to the first system of the service.
+	SystemID string `json:"systemID,omitempty"`
+}
+
+// HostSpecIPMIOptions contains additional IPMI connection options.
+type HostSpecIPMIOptions struct {
+	// CipherSuite is the ID of the cipher suite that is used to authenticate and
+	// encrypt the session. Cipher suite `17` uses SHA256 and cipher suite `3` uses
+	// SHA1, which is the only one supported by many older BMCs. Both use AES for
+	// the encryption. Defaults to `17` with a fallback to `3`.
+	//+kubebuilder:validation:Enum=3;17
+	CipherSuite int `json:"cipherSuite,omitempty"`
+	// Privilege is the privilege level of the session. Defaults to `Administrator`.
+	//+kubebuilder:validation:Enum=Operator;Administrator
+	//+kubebuilder:default=Administrator
+	Privilege IPMIPrivilege `json:"privilege,omitempty"`
+	// UEFI requests a UEFI boot if the boot device is overridden.
+	// Otherwise, a legacy BIOS boot is requested.
+	UEFI bool `json:"uefi,omitempty"`
+}
+
+// HostSpecOutOfBand describes the BMC of a host, which manages the
+// hardware of the host independently of its operating system.
+type HostSpecOutOfBand struct {
+	// Protocol is the protocol used to connect to the BMC.
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum=Redfish;IPMI
+	Protocol OutOfBandProtocol `json:"protocol"`
+	// Host is the BMC to connect to.
+	//+kubebuilder:validation:Required
+	Host string `json:"host"`
+	// Port is the port to connect to. Defaults to `443` for Redfish and `623` for IPMI.
+	Port int `json:"port,omitempty"`
+	// User is the user to authenticate as. The password is read from the key
+	// `passwordInsecure` of the Secret.
+	//+kubebuilder:validation:Required
+	User string `json:"user"`
+	// Fingerprint is the SHA256 fingerprint of the TLS certificate of the BMC in
+	// the format `SHA256:{base64}`. If it is specified, the certificate is pinned
+	// instead of being verified against the trusted certificate authorities,
+	// which is useful for the self-signed certificates of most BMCs. It is only
+	// used by Redfish.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// InsecureSkipVerify disables the verification of the TLS certificate of the
+	// BMC, which is vulnerable to PitM attacks. It is only used by Redfish.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// Redfish contains additional Redfish connection options.
+	Redfish HostSpecRedfishOptions `json:"redfish,omitempty"`
+	// IPMI contains additional IPMI connection options.
+	IPMI HostSpecIPMIOptions `json:"ipmi,omitempty"`
+	// SecretRef is the reference to a secret containing the credentials of the
+	// BMC. It defaults to the secret of the host. A secret in another namespace
+	// must grant access to the namespace of the host by listing it in the
+	// annotation `kraut.nicklasfrahm.dev/allowed-namespaces`.
+	SecretRef *corev1.SecretReference `json:"secretRef,omitempty"`
+}
+
+// HostSystemInfo describes the hardware of a host.
+type HostSystemInfo struct {
+	// Manufacturer is the manufacturer of the system.
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// Model is the model of the system.
+	Model string `json:"model,omitempty"`
+	// SerialNumber is the serial number of the system.
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// SKU is the stock-keeping unit of the system.
+	SKU string `json:"sku,omitempty"`
+	// BIOSVersion is the version of the system firmware.
+	BIOSVersion string `json:"biosVersion,omitempty"`
+	// ProcessorCount is the number of processors.
+	ProcessorCount int `json:"processorCount,omitempty"`
+	// MemoryGiB is the total amount of system memory in GiB.
+	MemoryGiB int `json:"memoryGiB,omitempty"`
+	// Health is the health of the system as reported by the BMC, e.g. `OK` or `Critical`.
+	Health string `json:"health,omitempty"`
+}
+
+// HostChassisInfo describes the enclosure of a host.
+type HostChassisInfo struct {
+	// Type is the type of the chassis, e.g. `RackMount` or `Blade`.
+	Type string `json:"type,omitempty"`
+	// Manufacturer is the manufacturer of the chassis.
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// Model is the model of the chassis.
+	Model string `json:"model,omitempty"`
+	// SerialNumber is the serial number of the chassis.
+	SerialNumber string `json:"serialNumber,omitempty"`
+}
+
+// HostBMCInfo describes the BMC of a host.
+type HostBMCInfo struct {
+	// Model is the model of the BMC, e.g. `iDRAC 9`.
+	Model string `json:"model,omitempty"`
+	// FirmwareVersion is the version of the firmware of the BMC.
+	FirmwareVersion string `json:"firmwareVersion,omitempty"`
+}
+
+// HostStatusOutOfBand describes the observed state of the BMC of a host.
+type HostStatusOutOfBand struct {
+	// PowerState is the power state of the host.
+	PowerState PowerState `json:"powerState,omitempty"`
+	// System describes the hardware of the host.
+	System HostSystemInfo `json:"system,omitempty"`
+	// Chassis describes the enclosure of the host.
+	Chassis HostChassisInfo `json:"chassis,omitempty"`
+	// BMC describes the BMC of the host.
+	BMC HostBMCInfo `json:"bmc,omitempty"`
+	// LastEventTime is the creation time of the last entry of the event log of
+	// the BMC that was reported. Newer entries are reported as Kubernetes events.
+	LastEventTime *metav1.Time `json:"lastEventTime,omitempty"`
+}
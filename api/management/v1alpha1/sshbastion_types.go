@@ -0,0 +1,99 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SSHBastionConditionReachable indicates that the bastion accepted a connection.
+	SSHBastionConditionReachable = "Reachable"
+)
+
+// SSHBastionSpec defines the desired state of SSHBastion
+type SSHBastionSpec struct {
+	// Host is the bastion to connect to.
+	//+kubebuilder:validation:Required
+	Host string `json:"host"`
+	// Port is the port to connect to.
+	Port int `json:"port,omitempty"`
+	// User is the SSH user to connect as.
+	User string `json:"user,omitempty"`
+	// Fingerprint is the SSH host key fingerprint in the format `{algorithm}:{hash}`.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// TrustOnFirstUse records the host key fingerprint of the bastion in the
+	// status on the first connection, if it is not specified, and strictly
+	// enforces it afterwards.
+	TrustOnFirstUse bool `json:"trustOnFirstUse,omitempty"`
+	// HostCAKeys contains the public keys of SSH certificate authorities in the
+	// authorized keys format. A host certificate of the bastion that is signed
+	// by one of them is trusted without verifying its fingerprint.
+	HostCAKeys []string `json:"hostCAKeys,omitempty"`
+	// Certificate enables the authentication of the user with short-lived
+	// certificates that are signed by the SSH CA of the operator. This takes
+	// precedence over the credentials in the Secret.
+	Certificate *HostSpecSSHCertificate `json:"certificate,omitempty"`
+	// SecretRef is the reference to a secret containing the credentials of
+	// the bastion in the keys `key`, `passphrase`, `passwordInsecure` and
+	// `certificate`. The namespace defaults to the namespace of the bastion. A
+	// secret in another namespace must grant access to the namespace of the
+	// bastion via the annotation `kraut.nicklasfrahm.dev/allowed-namespaces`.
+	//+kubebuilder:validation:Required
+	SecretRef corev1.SecretReference `json:"secretRef"`
+}
+
+// SSHBastionStatus defines the observed state of SSHBastion
+type SSHBastionStatus struct {
+	// Fingerprint is the SSH host key fingerprint in the format `{algorithm}:{hash}`,
+	// which was trusted on first use.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Conditions describe the current state of the bastion.
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories={mgmt,management},shortName=bastion,path=sshbastions,singular=sshbastion
+//+kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+//+kubebuilder:printcolumn:name="User",type=string,JSONPath=`.spec.user`
+//+kubebuilder:printcolumn:name="Reachable",type=string,JSONPath=`.status.conditions[?(@.type=="Reachable")].status`
+
+// SSHBastion is the Schema for the sshbastions API
+type SSHBastion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SSHBastionSpec   `json:"spec,omitempty"`
+	Status SSHBastionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SSHBastionList contains a list of SSHBastion
+type SSHBastionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SSHBastion `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SSHBastion{}, &SSHBastionList{})
+}
@@ -22,16 +22,44 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContentSource) DeepCopyInto(out *ContentSource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContentSource.
+func (in *ContentSource) DeepCopy() *ContentSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ContentSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Host) DeepCopyInto(out *Host) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Host.
@@ -53,31 +81,104 @@ func (in *Host) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HostList) DeepCopyInto(out *HostList) {
+func (in *HostAction) DeepCopyInto(out *HostAction) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostAction.
+func (in *HostAction) DeepCopy() *HostAction {
+	if in == nil {
+		return nil
+	}
+	out := new(HostAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostAction) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostActionEvent) DeepCopyInto(out *HostActionEvent) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostActionEvent.
+func (in *HostActionEvent) DeepCopy() *HostActionEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(HostActionEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostActionHostStatus) DeepCopyInto(out *HostActionHostStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Timeline != nil {
+		in, out := &in.Timeline, &out.Timeline
+		*out = make([]HostActionEvent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostActionHostStatus.
+func (in *HostActionHostStatus) DeepCopy() *HostActionHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostActionHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostActionList) DeepCopyInto(out *HostActionList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]Host, len(*in))
+		*out = make([]HostAction, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostList.
-func (in *HostList) DeepCopy() *HostList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostActionList.
+func (in *HostActionList) DeepCopy() *HostActionList {
 	if in == nil {
 		return nil
 	}
-	out := new(HostList)
+	out := new(HostActionList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *HostList) DeepCopyObject() runtime.Object {
+func (in *HostActionList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -85,64 +186,1623 @@ func (in *HostList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HostSpec) DeepCopyInto(out *HostSpec) {
+func (in *HostActionSpec) DeepCopyInto(out *HostActionSpec) {
 	*out = *in
-	out.SSH = in.SSH
-	out.SecretRef = in.SecretRef
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpec.
-func (in *HostSpec) DeepCopy() *HostSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostActionSpec.
+func (in *HostActionSpec) DeepCopy() *HostActionSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(HostSpec)
+	out := new(HostActionSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HostSpecSSHOptions) DeepCopyInto(out *HostSpecSSHOptions) {
+func (in *HostActionStatus) DeepCopyInto(out *HostActionStatus) {
 	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]HostActionHostStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecSSHOptions.
-func (in *HostSpecSSHOptions) DeepCopy() *HostSpecSSHOptions {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostActionStatus.
+func (in *HostActionStatus) DeepCopy() *HostActionStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HostSpecSSHOptions)
+	out := new(HostActionStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HostStatus) DeepCopyInto(out *HostStatus) {
+func (in *HostBMCInfo) DeepCopyInto(out *HostBMCInfo) {
 	*out = *in
-	out.OS = in.OS
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostStatus.
-func (in *HostStatus) DeepCopy() *HostStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostBMCInfo.
+func (in *HostBMCInfo) DeepCopy() *HostBMCInfo {
 	if in == nil {
 		return nil
 	}
-	out := new(HostStatus)
+	out := new(HostBMCInfo)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OSInfo) DeepCopyInto(out *OSInfo) {
+func (in *HostCapabilities) DeepCopyInto(out *HostCapabilities) {
 	*out = *in
+	if in.FirewallBackends != nil {
+		in, out := &in.FirewallBackends, &out.FirewallBackends
+		*out = make([]FirewallBackend, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSInfo.
-func (in *OSInfo) DeepCopy() *OSInfo {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostCapabilities.
+func (in *HostCapabilities) DeepCopy() *HostCapabilities {
 	if in == nil {
 		return nil
 	}
-	out := new(OSInfo)
+	out := new(HostCapabilities)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostChassisInfo) DeepCopyInto(out *HostChassisInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostChassisInfo.
+func (in *HostChassisInfo) DeepCopy() *HostChassisInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(HostChassisInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostCommand) DeepCopyInto(out *HostCommand) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostCommand.
+func (in *HostCommand) DeepCopy() *HostCommand {
+	if in == nil {
+		return nil
+	}
+	out := new(HostCommand)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostCommand) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostCommandList) DeepCopyInto(out *HostCommandList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostCommand, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostCommandList.
+func (in *HostCommandList) DeepCopy() *HostCommandList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostCommandList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostCommandList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostCommandResult) DeepCopyInto(out *HostCommandResult) {
+	*out = *in
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int)
+		**out = **in
+	}
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.CompletionTime.DeepCopyInto(&out.CompletionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostCommandResult.
+func (in *HostCommandResult) DeepCopy() *HostCommandResult {
+	if in == nil {
+		return nil
+	}
+	out := new(HostCommandResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostCommandSpec) DeepCopyInto(out *HostCommandSpec) {
+	*out = *in
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostCommandSpec.
+func (in *HostCommandSpec) DeepCopy() *HostCommandSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostCommandSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostCommandStatus) DeepCopyInto(out *HostCommandStatus) {
+	*out = *in
+	if in.LastRunTime != nil {
+		in, out := &in.LastRunTime, &out.LastRunTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRunTime != nil {
+		in, out := &in.NextRunTime, &out.NextRunTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Results != nil {
+		in, out := &in.Results, &out.Results
+		*out = make([]HostCommandResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostCommandStatus.
+func (in *HostCommandStatus) DeepCopy() *HostCommandStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostCommandStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFile) DeepCopyInto(out *HostFile) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFile.
+func (in *HostFile) DeepCopy() *HostFile {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostFile) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFileHostStatus) DeepCopyInto(out *HostFileHostStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastDriftTime != nil {
+		in, out := &in.LastDriftTime, &out.LastDriftTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFileHostStatus.
+func (in *HostFileHostStatus) DeepCopy() *HostFileHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFileHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFileList) DeepCopyInto(out *HostFileList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostFile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFileList.
+func (in *HostFileList) DeepCopy() *HostFileList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFileList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostFileList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFileSpec) DeepCopyInto(out *HostFileSpec) {
+	*out = *in
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.ContentFrom != nil {
+		in, out := &in.ContentFrom, &out.ContentFrom
+		*out = new(ContentSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFileSpec.
+func (in *HostFileSpec) DeepCopy() *HostFileSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFileSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFileStatus) DeepCopyInto(out *HostFileStatus) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]HostFileHostStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFileStatus.
+func (in *HostFileStatus) DeepCopy() *HostFileStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFileStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostInterface) DeepCopyInto(out *HostInterface) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostInterface.
+func (in *HostInterface) DeepCopy() *HostInterface {
+	if in == nil {
+		return nil
+	}
+	out := new(HostInterface)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostKernel) DeepCopyInto(out *HostKernel) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostKernel.
+func (in *HostKernel) DeepCopy() *HostKernel {
+	if in == nil {
+		return nil
+	}
+	out := new(HostKernel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostKernel) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostKernelHostStatus) DeepCopyInto(out *HostKernelHostStatus) {
+	*out = *in
+	if in.Sysctl != nil {
+		in, out := &in.Sysctl, &out.Sysctl
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Modules != nil {
+		in, out := &in.Modules, &out.Modules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostKernelHostStatus.
+func (in *HostKernelHostStatus) DeepCopy() *HostKernelHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostKernelHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostKernelList) DeepCopyInto(out *HostKernelList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostKernel, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostKernelList.
+func (in *HostKernelList) DeepCopy() *HostKernelList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostKernelList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostKernelList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostKernelSpec) DeepCopyInto(out *HostKernelSpec) {
+	*out = *in
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.Sysctl != nil {
+		in, out := &in.Sysctl, &out.Sysctl
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Modules != nil {
+		in, out := &in.Modules, &out.Modules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostKernelSpec.
+func (in *HostKernelSpec) DeepCopy() *HostKernelSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostKernelSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostKernelStatus) DeepCopyInto(out *HostKernelStatus) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]HostKernelHostStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostKernelStatus.
+func (in *HostKernelStatus) DeepCopy() *HostKernelStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostKernelStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostList) DeepCopyInto(out *HostList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Host, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostList.
+func (in *HostList) DeepCopy() *HostList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPackage) DeepCopyInto(out *HostPackage) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPackage.
+func (in *HostPackage) DeepCopy() *HostPackage {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPackage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostPackage) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPackageHostStatus) DeepCopyInto(out *HostPackageHostStatus) {
+	*out = *in
+	if in.Packages != nil {
+		in, out := &in.Packages, &out.Packages
+		*out = make([]InstalledPackage, len(*in))
+		copy(*out, *in)
+	}
+	if in.Held != nil {
+		in, out := &in.Held, &out.Held
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityUpdates != nil {
+		in, out := &in.SecurityUpdates, &out.SecurityUpdates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPackageHostStatus.
+func (in *HostPackageHostStatus) DeepCopy() *HostPackageHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPackageHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPackageList) DeepCopyInto(out *HostPackageList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostPackage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPackageList.
+func (in *HostPackageList) DeepCopy() *HostPackageList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPackageList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostPackageList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPackageSpec) DeepCopyInto(out *HostPackageSpec) {
+	*out = *in
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.Packages != nil {
+		in, out := &in.Packages, &out.Packages
+		*out = make([]HostPackageSpecPackage, len(*in))
+		copy(*out, *in)
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPackageSpec.
+func (in *HostPackageSpec) DeepCopy() *HostPackageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPackageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPackageSpecPackage) DeepCopyInto(out *HostPackageSpecPackage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPackageSpecPackage.
+func (in *HostPackageSpecPackage) DeepCopy() *HostPackageSpecPackage {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPackageSpecPackage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPackageStatus) DeepCopyInto(out *HostPackageStatus) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]HostPackageHostStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPackageStatus.
+func (in *HostPackageStatus) DeepCopy() *HostPackageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPackageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostService) DeepCopyInto(out *HostService) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostService.
+func (in *HostService) DeepCopy() *HostService {
+	if in == nil {
+		return nil
+	}
+	out := new(HostService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostService) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostServiceHostStatus) DeepCopyInto(out *HostServiceHostStatus) {
+	*out = *in
+	if in.ManagedFiles != nil {
+		in, out := &in.ManagedFiles, &out.ManagedFiles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostServiceHostStatus.
+func (in *HostServiceHostStatus) DeepCopy() *HostServiceHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostServiceHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostServiceList) DeepCopyInto(out *HostServiceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostService, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostServiceList.
+func (in *HostServiceList) DeepCopy() *HostServiceList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostServiceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostServiceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostServiceSpec) DeepCopyInto(out *HostServiceSpec) {
+	*out = *in
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostServiceSpec.
+func (in *HostServiceSpec) DeepCopy() *HostServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostServiceStatus) DeepCopyInto(out *HostServiceStatus) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]HostServiceHostStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostServiceStatus.
+func (in *HostServiceStatus) DeepCopy() *HostServiceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostServiceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpec) DeepCopyInto(out *HostSpec) {
+	*out = *in
+	in.SSH.DeepCopyInto(&out.SSH)
+	out.SNMP = in.SNMP
+	if in.OutOfBand != nil {
+		in, out := &in.OutOfBand, &out.OutOfBand
+		*out = new(HostSpecOutOfBand)
+		(*in).DeepCopyInto(*out)
+	}
+	out.SecretRef = in.SecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpec.
+func (in *HostSpec) DeepCopy() *HostSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpecIPMIOptions) DeepCopyInto(out *HostSpecIPMIOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecIPMIOptions.
+func (in *HostSpecIPMIOptions) DeepCopy() *HostSpecIPMIOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpecIPMIOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpecOutOfBand) DeepCopyInto(out *HostSpecOutOfBand) {
+	*out = *in
+	out.Redfish = in.Redfish
+	out.IPMI = in.IPMI
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecOutOfBand.
+func (in *HostSpecOutOfBand) DeepCopy() *HostSpecOutOfBand {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpecOutOfBand)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpecRedfishOptions) DeepCopyInto(out *HostSpecRedfishOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecRedfishOptions.
+func (in *HostSpecRedfishOptions) DeepCopy() *HostSpecRedfishOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpecRedfishOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpecSNMPOptions) DeepCopyInto(out *HostSpecSNMPOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecSNMPOptions.
+func (in *HostSpecSNMPOptions) DeepCopy() *HostSpecSNMPOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpecSNMPOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpecSSHCertificate) DeepCopyInto(out *HostSpecSSHCertificate) {
+	*out = *in
+	if in.Principals != nil {
+		in, out := &in.Principals, &out.Principals
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Validity != nil {
+		in, out := &in.Validity, &out.Validity
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecSSHCertificate.
+func (in *HostSpecSSHCertificate) DeepCopy() *HostSpecSSHCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpecSSHCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpecSSHJumpHost) DeepCopyInto(out *HostSpecSSHJumpHost) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecSSHJumpHost.
+func (in *HostSpecSSHJumpHost) DeepCopy() *HostSpecSSHJumpHost {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpecSSHJumpHost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpecSSHKeyRotation) DeepCopyInto(out *HostSpecSSHKeyRotation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecSSHKeyRotation.
+func (in *HostSpecSSHKeyRotation) DeepCopy() *HostSpecSSHKeyRotation {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpecSSHKeyRotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSpecSSHOptions) DeepCopyInto(out *HostSpecSSHOptions) {
+	*out = *in
+	if in.BastionRef != nil {
+		in, out := &in.BastionRef, &out.BastionRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.JumpHosts != nil {
+		in, out := &in.JumpHosts, &out.JumpHosts
+		*out = make([]HostSpecSSHJumpHost, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HostCAKeys != nil {
+		in, out := &in.HostCAKeys, &out.HostCAKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Certificate != nil {
+		in, out := &in.Certificate, &out.Certificate
+		*out = new(HostSpecSSHCertificate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KeyRotation != nil {
+		in, out := &in.KeyRotation, &out.KeyRotation
+		*out = new(HostSpecSSHKeyRotation)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSpecSSHOptions.
+func (in *HostSpecSSHOptions) DeepCopy() *HostSpecSSHOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSpecSSHOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostStatus) DeepCopyInto(out *HostStatus) {
+	*out = *in
+	in.OS.DeepCopyInto(&out.OS)
+	in.Capabilities.DeepCopyInto(&out.Capabilities)
+	in.SSH.DeepCopyInto(&out.SSH)
+	if in.Interfaces != nil {
+		in, out := &in.Interfaces, &out.Interfaces
+		*out = make([]HostInterface, len(*in))
+		copy(*out, *in)
+	}
+	if in.OutOfBand != nil {
+		in, out := &in.OutOfBand, &out.OutOfBand
+		*out = new(HostStatusOutOfBand)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostStatus.
+func (in *HostStatus) DeepCopy() *HostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostStatusOutOfBand) DeepCopyInto(out *HostStatusOutOfBand) {
+	*out = *in
+	out.System = in.System
+	out.Chassis = in.Chassis
+	out.BMC = in.BMC
+	if in.LastEventTime != nil {
+		in, out := &in.LastEventTime, &out.LastEventTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostStatusOutOfBand.
+func (in *HostStatusOutOfBand) DeepCopy() *HostStatusOutOfBand {
+	if in == nil {
+		return nil
+	}
+	out := new(HostStatusOutOfBand)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostStatusSSH) DeepCopyInto(out *HostStatusSSH) {
+	*out = *in
+	if in.JumpHostFingerprints != nil {
+		in, out := &in.JumpHostFingerprints, &out.JumpHostFingerprints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastKeyRotationTime != nil {
+		in, out := &in.LastKeyRotationTime, &out.LastKeyRotationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextKeyRotationTime != nil {
+		in, out := &in.NextKeyRotationTime, &out.NextKeyRotationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostStatusSSH.
+func (in *HostStatusSSH) DeepCopy() *HostStatusSSH {
+	if in == nil {
+		return nil
+	}
+	out := new(HostStatusSSH)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSystemInfo) DeepCopyInto(out *HostSystemInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSystemInfo.
+func (in *HostSystemInfo) DeepCopy() *HostSystemInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSystemInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUpgradeCheck) DeepCopyInto(out *HostUpgradeCheck) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUpgradeCheck.
+func (in *HostUpgradeCheck) DeepCopy() *HostUpgradeCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUpgradeCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUpgradeHostStatus) DeepCopyInto(out *HostUpgradeHostStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.UpgradeTime != nil {
+		in, out := &in.UpgradeTime, &out.UpgradeTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RebootTime != nil {
+		in, out := &in.RebootTime, &out.RebootTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUpgradeHostStatus.
+func (in *HostUpgradeHostStatus) DeepCopy() *HostUpgradeHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUpgradeHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUpgradePolicy) DeepCopyInto(out *HostUpgradePolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUpgradePolicy.
+func (in *HostUpgradePolicy) DeepCopy() *HostUpgradePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUpgradePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostUpgradePolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUpgradePolicyList) DeepCopyInto(out *HostUpgradePolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostUpgradePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUpgradePolicyList.
+func (in *HostUpgradePolicyList) DeepCopy() *HostUpgradePolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUpgradePolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostUpgradePolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUpgradePolicySpec) DeepCopyInto(out *HostUpgradePolicySpec) {
+	*out = *in
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.PreCheck != nil {
+		in, out := &in.PreCheck, &out.PreCheck
+		*out = new(HostUpgradeCheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostCheck != nil {
+		in, out := &in.PostCheck, &out.PostCheck
+		*out = new(HostUpgradeCheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUpgradePolicySpec.
+func (in *HostUpgradePolicySpec) DeepCopy() *HostUpgradePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUpgradePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUpgradePolicyStatus) DeepCopyInto(out *HostUpgradePolicyStatus) {
+	*out = *in
+	if in.LastRunTime != nil {
+		in, out := &in.LastRunTime, &out.LastRunTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRunTime != nil {
+		in, out := &in.NextRunTime, &out.NextRunTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]HostUpgradeHostStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUpgradePolicyStatus.
+func (in *HostUpgradePolicyStatus) DeepCopy() *HostUpgradePolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUpgradePolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUser) DeepCopyInto(out *HostUser) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUser.
+func (in *HostUser) DeepCopy() *HostUser {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostUser) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUserHostStatus) DeepCopyInto(out *HostUserHostStatus) {
+	*out = *in
+	if in.UID != nil {
+		in, out := &in.UID, &out.UID
+		*out = new(int)
+		**out = **in
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUserHostStatus.
+func (in *HostUserHostStatus) DeepCopy() *HostUserHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUserHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUserList) DeepCopyInto(out *HostUserList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostUser, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUserList.
+func (in *HostUserList) DeepCopy() *HostUserList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUserList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostUserList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUserSpec) DeepCopyInto(out *HostUserSpec) {
+	*out = *in
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.UID != nil {
+		in, out := &in.UID, &out.UID
+		*out = new(int)
+		**out = **in
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Sudo != nil {
+		in, out := &in.Sudo, &out.Sudo
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AuthorizedKeys != nil {
+		in, out := &in.AuthorizedKeys, &out.AuthorizedKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AuthorizedKeysFrom != nil {
+		in, out := &in.AuthorizedKeysFrom, &out.AuthorizedKeysFrom
+		*out = make([]ContentSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUserSpec.
+func (in *HostUserSpec) DeepCopy() *HostUserSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUserSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostUserStatus) DeepCopyInto(out *HostUserStatus) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]HostUserHostStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostUserStatus.
+func (in *HostUserStatus) DeepCopy() *HostUserStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostUserStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstalledPackage) DeepCopyInto(out *InstalledPackage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstalledPackage.
+func (in *InstalledPackage) DeepCopy() *InstalledPackage {
+	if in == nil {
+		return nil
+	}
+	out := new(InstalledPackage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSInfo) DeepCopyInto(out *OSInfo) {
+	*out = *in
+	if in.BootTime != nil {
+		in, out := &in.BootTime, &out.BootTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSInfo.
+func (in *OSInfo) DeepCopy() *OSInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(OSInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHBastion) DeepCopyInto(out *SSHBastion) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHBastion.
+func (in *SSHBastion) DeepCopy() *SSHBastion {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHBastion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SSHBastion) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHBastionList) DeepCopyInto(out *SSHBastionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SSHBastion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHBastionList.
+func (in *SSHBastionList) DeepCopy() *SSHBastionList {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHBastionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SSHBastionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHBastionSpec) DeepCopyInto(out *SSHBastionSpec) {
+	*out = *in
+	if in.HostCAKeys != nil {
+		in, out := &in.HostCAKeys, &out.HostCAKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Certificate != nil {
+		in, out := &in.Certificate, &out.Certificate
+		*out = new(HostSpecSSHCertificate)
+		(*in).DeepCopyInto(*out)
+	}
+	out.SecretRef = in.SecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHBastionSpec.
+func (in *SSHBastionSpec) DeepCopy() *SSHBastionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHBastionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHBastionStatus) DeepCopyInto(out *SSHBastionStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHBastionStatus.
+func (in *SSHBastionStatus) DeepCopy() *SSHBastionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHBastionStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -19,12 +19,15 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -37,6 +40,9 @@ import (
 	managementv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
 	firewallcontroller "github.com/nicklasfrahm/kraut/internal/controller/firewall"
 	managementcontroller "github.com/nicklasfrahm/kraut/internal/controller/management"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+	"github.com/nicklasfrahm/kraut/pkg/management/ssh"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -59,11 +65,49 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var connectionIdleTimeout time.Duration
+	var dialTimeout time.Duration
+	var handshakeTimeout time.Duration
+	var commandTimeout time.Duration
+	var sshCASecret string
+	var sshCAPrincipals string
+	var credentialSource string
+	var secretKeys string
+	var sshAgentSocket string
+	var credentialsDir string
+	var enableKeyRotation bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&connectionIdleTimeout, "connection-idle-timeout", 5*time.Minute,
+		"The duration after which an unused connection to a host is closed.")
+	flag.DurationVar(&dialTimeout, "dial-timeout", 10*time.Second,
+		"The maximum duration for establishing a network connection to a host.")
+	flag.DurationVar(&handshakeTimeout, "handshake-timeout", 10*time.Second,
+		"The maximum duration for the protocol handshake with a host, including the authentication.")
+	flag.DurationVar(&commandTimeout, "command-timeout", time.Minute,
+		"The maximum duration of a single command on a host.")
+	flag.StringVar(&sshCASecret, "ssh-ca-secret", "",
+		"The Secret in the format <namespace>/<name> that contains the SSH CA, which signs "+
+			"short-lived user certificates. The Secret is created if it does not exist.")
+	flag.StringVar(&sshCAPrincipals, "ssh-ca-principals", "",
+		"The principals for which the SSH CA signs user certificates as a comma-separated list. "+
+			"A principal in the format <namespace>/<principal> is only granted to a single namespace, "+
+			"such as team-a/deploy,root. No certificates are signed if empty.")
+	flag.StringVar(&credentialSource, "credential-source", "secret",
+		"The source of the credentials of hosts. One of secret, agent or file.")
+	flag.StringVar(&secretKeys, "secret-keys", "",
+		"The names of the keys that contain the credentials in Secrets and credential files "+
+			"as a comma-separated list, such as key=ssh-privatekey,passphrase=ssh-passphrase.")
+	flag.StringVar(&sshAgentSocket, "ssh-agent-socket", os.Getenv("SSH_AUTH_SOCK"),
+		"The Unix socket of the SSH agent, which is used by the agent credential source.")
+	flag.StringVar(&credentialsDir, "credentials-dir", "",
+		"The directory of the file credential source, which contains the credentials "+
+			"of the Secret <namespace>/<name> in files named like its keys in <dir>/<namespace>/<name>/.")
+	flag.BoolVar(&enableKeyRotation, "enable-key-rotation", false,
+		"Enable the rotation of the private keys of hosts. This requires the permission to update and patch Secrets in all namespaces.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -100,16 +144,147 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The connection manager shares authenticated connections to hosts between controllers.
+	connectionOptions := []common.Option{
+		common.WithKubernetesClient(mgr.GetClient()),
+		common.WithIdleTimeout(connectionIdleTimeout),
+		common.WithDialTimeout(dialTimeout),
+		common.WithHandshakeTimeout(handshakeTimeout),
+		common.WithCommandTimeout(commandTimeout),
+	}
+	if sshCASecret != "" {
+		namespace, name, ok := strings.Cut(sshCASecret, "/")
+		if !ok || namespace == "" || name == "" {
+			setupLog.Error(nil, "invalid SSH CA Secret, expected format <namespace>/<name>", "secret", sshCASecret)
+			os.Exit(1)
+		}
+		grants, err := ssh.ParsePrincipalGrants(sshCAPrincipals)
+		if err != nil {
+			setupLog.Error(err, "invalid SSH CA principals")
+			os.Exit(1)
+		}
+		ca := ssh.NewCertificateAuthority(mgr.GetClient(), types.NamespacedName{Namespace: namespace, Name: name}, grants)
+		connectionOptions = append(connectionOptions, common.WithCertificateSigner(ca))
+	}
+	keys, err := common.ParseSecretKeys(secretKeys)
+	if err != nil {
+		setupLog.Error(err, "invalid secret keys")
+		os.Exit(1)
+	}
+	switch credentialSource {
+	case "secret":
+		connectionOptions = append(connectionOptions, common.WithCredentialSource(common.NewSecretCredentialSource(mgr.GetClient(), keys)))
+	case "agent":
+		if sshAgentSocket == "" {
+			setupLog.Error(nil, "missing SSH agent socket, please configure --ssh-agent-socket")
+			os.Exit(1)
+		}
+		connectionOptions = append(connectionOptions, common.WithCredentialSource(common.NewAgentCredentialSource(sshAgentSocket)))
+	case "file":
+		if credentialsDir == "" {
+			setupLog.Error(nil, "missing credentials directory, please configure --credentials-dir")
+			os.Exit(1)
+		}
+		connectionOptions = append(connectionOptions, common.WithCredentialSource(common.NewFileCredentialSource(credentialsDir, keys)))
+	default:
+		setupLog.Error(nil, "invalid credential source, expected one of secret, agent or file", "source", credentialSource)
+		os.Exit(1)
+	}
+	connections, err := management.NewManager(connectionOptions...)
+	if err != nil {
+		setupLog.Error(err, "unable to create connection manager")
+		os.Exit(1)
+	}
+	if err := mgr.Add(connections); err != nil {
+		setupLog.Error(err, "unable to add connection manager")
+		os.Exit(1)
+	}
+
 	if err = (&managementcontroller.HostReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+		KeyRotation: enableKeyRotation,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Host")
 		os.Exit(1)
 	}
+	if err = (&managementcontroller.HostCommandReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostCommand")
+		os.Exit(1)
+	}
+	if err = (&managementcontroller.HostFileReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostFile")
+		os.Exit(1)
+	}
+	if err = (&managementcontroller.HostPackageReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostPackage")
+		os.Exit(1)
+	}
+	if err = (&managementcontroller.HostServiceReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostService")
+		os.Exit(1)
+	}
+	if err = (&managementcontroller.HostUserReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostUser")
+		os.Exit(1)
+	}
+	if err = (&managementcontroller.HostKernelReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostKernel")
+		os.Exit(1)
+	}
+	if err = (&managementcontroller.SSHBastionReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SSHBastion")
+		os.Exit(1)
+	}
+	if err = (&managementcontroller.HostActionReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostAction")
+		os.Exit(1)
+	}
+	if err = (&managementcontroller.HostUpgradePolicyReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostUpgradePolicy")
+		os.Exit(1)
+	}
 	if err = (&firewallcontroller.FirewallReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Firewall")
 		os.Exit(1)
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -28,17 +29,22 @@ import (
 
 	fwv1alpha1 "github.com/nicklasfrahm/kraut/api/firewall/v1alpha1"
 	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/system"
 )
 
 const (
 	controllerName = "firewall-controller"
+	// nftablesService is the unit that loads the persisted ruleset during boot.
+	nftablesService = "nftables.service"
 )
 
 // FirewallReconciler reconciles a Firewall object
 type FirewallReconciler struct {
 	client.Client
-	recorder record.EventRecorder
-	Scheme   *runtime.Scheme
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
 }
 
 //+kubebuilder:rbac:groups=firewall.kraut.nicklasfrahm.dev,resources=firewalls,verbs=get;list;watch;create;update;patch;delete
@@ -101,7 +107,14 @@ func (r *FirewallReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	// 6. Filter ruleset to only include relevant for firewall configuration for the host.
 	// 7. Compare the ruleset with the current ruleset on the host.
 	// 8. If there is a difference, apply the ruleset to the host.
-	// 9. Check if the firewall service is enabled and running on the host.
+	// 9. Enable the firewall service once the ruleset is managed.
+
+	for i := range hosts {
+		if err := r.checkFirewallService(ctx, firewall, &hosts[i]); err != nil {
+			r.recorder.Event(firewall, "Warning", "FirewallServiceFailed", err.Error())
+			return ctrl.Result{}, err
+		}
+	}
 
 	// TODO: How do we handle lifecycle of the host if is no longer selected?
 
@@ -117,27 +130,67 @@ func (r *FirewallReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-// verifyHostCompatibility checks if the host is compatible with the currently supported drivers.
+// osRequirements contains the version constraints for the operating
+// systems that are supported by the firewall controller.
+var osRequirements = map[string]string{
+	// Ubuntu started supporting "nftables" in the 21.04 release.
+	// Reference: https://lwn.net/Articles/867185/
+	mgmtv1alpha1.OSUbuntu: ">=21.04",
+}
+
+// checkHostCompatibility checks if the host is compatible with the currently supported drivers.
 // TODO: Implement abstract driver interface as part of "pkg/libintent".
 func (r *FirewallReconciler) checkHostCompatibility(ctx context.Context, host *mgmtv1alpha1.Host) error {
 	hostReference := fmt.Sprintf("%s/%s", host.ObjectMeta.Namespace, host.ObjectMeta.Name)
 
-	// Ubuntu started supporting "nftables" in the 21.04 release.
-	// Reference: https://lwn.net/Articles/867185/
-	// TODO: Improve this, maybe using semver comparison, because this is cursed.
-	if host.Status.OS.Name == mgmtv1alpha1.OSUbuntu {
-		errUbuntu2104Required := fmt.Errorf("failed to detect supported OS version: Ubuntu 21.04 or later is required: %s", hostReference)
+	if !host.Status.Capabilities.HasFirewallBackend(mgmtv1alpha1.FirewallBackendNFTables) {
+		return fmt.Errorf("failed to detect supported firewall backend: %s is required: %s", mgmtv1alpha1.FirewallBackendNFTables, hostReference)
+	}
 
-		if host.Status.OS.Version.Major() < 21 {
-			return errUbuntu2104Required
-		}
+	constraint, ok := osRequirements[host.Status.OS.Name]
+	if !ok {
+		return fmt.Errorf("failed to detect supported OS: %s", hostReference)
+	}
 
-		if host.Status.OS.Version.Major() == 21 && host.Status.OS.Version.Minor() < 4 {
-			return errUbuntu2104Required
-		}
+	satisfied, err := host.Status.OS.Version.Satisfies(constraint)
+	if err != nil {
+		return err
+	}
+	if !satisfied {
+		return fmt.Errorf("failed to detect supported OS version: %s %s is required: %s", host.Status.OS.Name, constraint, hostReference)
+	}
+
+	return nil
+}
+
+// checkFirewallService checks if the firewall service is enabled and running.
+// The service is neither enabled nor started, because this would load a ruleset
+// that is not managed by the controller and may cause a lockout. This must only
+// be done once the controller writes the ruleset itself.
+func (r *FirewallReconciler) checkFirewallService(ctx context.Context, firewall *fwv1alpha1.Firewall, host *mgmtv1alpha1.Host) error {
+	hostReference := fmt.Sprintf("%s/%s", host.ObjectMeta.Namespace, host.ObjectMeta.Name)
 
-		return nil
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %s: %s", hostReference, err)
+	}
+	defer mgmt.Disconnect()
+
+	systemd, err := system.NewSystemd(mgmt, host)
+	if err != nil {
+		return fmt.Errorf("failed to manage firewall service: %s: %s", hostReference, err)
+	}
+
+	status, err := systemd.Status(ctx, nftablesService)
+	if err != nil {
+		return fmt.Errorf("failed to check firewall service: %s: %s", hostReference, err)
+	}
+	if !status.Enabled() {
+		r.recorder.Event(firewall, "Warning", "FirewallServiceDisabled", fmt.Sprintf("%s is %s: %s", nftablesService, status.UnitFileState, hostReference))
+	}
+	if !status.Active() {
+		r.recorder.Event(firewall, "Warning", "FirewallServiceInactive", fmt.Sprintf("%s is %s: %s", nftablesService, status.ActiveState, hostReference))
 	}
 
-	return fmt.Errorf("failed to detect supported OS: %s", hostReference)
+	return nil
 }
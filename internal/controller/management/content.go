@@ -0,0 +1,64 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+// resolveContent reads the content referenced by the source from
+// a ConfigMap or a Secret in the namespace of the referencing resource.
+func resolveContent(ctx context.Context, c client.Client, namespace string, source *mgmtv1alpha1.ContentSource) ([]byte, error) {
+	if (source.ConfigMapKeyRef == nil) == (source.SecretKeyRef == nil) {
+		return nil, fmt.Errorf("exactly one of configMapKeyRef or secretKeyRef must be specified")
+	}
+
+	if ref := source.ConfigMapKeyRef; ref != nil {
+		configMap := new(corev1.ConfigMap)
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, configMap); err != nil {
+			return nil, fmt.Errorf("failed to read ConfigMap: %s/%s: %s", namespace, ref.Name, err)
+		}
+
+		if value, ok := configMap.Data[ref.Key]; ok {
+			return []byte(value), nil
+		}
+		if value, ok := configMap.BinaryData[ref.Key]; ok {
+			return value, nil
+		}
+		return nil, fmt.Errorf("failed to find key in ConfigMap: %s/%s: %s", namespace, ref.Name, ref.Key)
+	}
+
+	ref := source.SecretKeyRef
+	secret := new(corev1.Secret)
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to read Secret: %s/%s: %s", namespace, ref.Name, err)
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("failed to find key in Secret: %s/%s: %s", namespace, ref.Name, ref.Key)
+	}
+
+	return value, nil
+}
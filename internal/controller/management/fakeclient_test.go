@@ -0,0 +1,220 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// fakeFile is a file on a fakeHost.
+type fakeFile struct {
+	content []byte
+	mode    os.FileMode
+	uid     int
+	gid     int
+}
+
+// fakeHost is a management client that simulates a host with an in-memory
+// file system. Commands that are not simulated are recorded and succeed,
+// unless an exit code is configured for them.
+type fakeHost struct {
+	mutex sync.Mutex
+
+	files  map[string]*fakeFile
+	users  map[string]int
+	groups map[string]int
+
+	// exitCodes contains the exit codes of commands.
+	exitCodes map[string]int
+	// commands contains the commands that were run in order.
+	commands []string
+	// uploads is the number of files that were written.
+	uploads int
+}
+
+// newFakeHost creates a fake host that knows the root user and group.
+func newFakeHost() *fakeHost {
+	return &fakeHost{
+		files:     make(map[string]*fakeFile),
+		users:     map[string]int{"root": 0},
+		groups:    map[string]int{"root": 0},
+		exitCodes: make(map[string]int),
+	}
+}
+
+// ran returns how often the command was run.
+func (h *fakeHost) ran(command string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	count := 0
+	for _, candidate := range h.commands {
+		if candidate == command {
+			count++
+		}
+	}
+	return count
+}
+
+func (h *fakeHost) Connect(ctx context.Context) error { return nil }
+func (h *fakeHost) Disconnect() error                 { return nil }
+func (h *fakeHost) Ping(ctx context.Context) error    { return nil }
+
+func (h *fakeHost) OS(ctx context.Context) (*mgmtv1alpha1.OSInfo, error) {
+	return nil, common.ErrNotSupported
+}
+
+func (h *fakeHost) Capabilities(ctx context.Context) (*mgmtv1alpha1.HostCapabilities, error) {
+	return nil, common.ErrNotSupported
+}
+
+func (h *fakeHost) Exec(ctx context.Context, cmd *common.Command) (*common.CommandResult, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.commands = append(h.commands, cmd.Command)
+
+	switch {
+	case strings.Contains(cmd.Command, "sha256sum "):
+		file, ok := h.files[quotedArgument(cmd.Command)]
+		if !ok {
+			return &common.CommandResult{ExitCode: common.ExitCodeNotExist}, nil
+		}
+		sum := sha256.Sum256(file.content)
+		return &common.CommandResult{Stdout: []byte(hex.EncodeToString(sum[:]) + "  file\n")}, nil
+	case strings.HasPrefix(cmd.Command, "getent passwd "), strings.HasPrefix(cmd.Command, "getent group "):
+		name := quotedArgument(cmd.Command)
+		ids := h.users
+		if strings.HasPrefix(cmd.Command, "getent group ") {
+			ids = h.groups
+		}
+		id, ok := ids[name]
+		if !ok {
+			return &common.CommandResult{ExitCode: 2}, nil
+		}
+		return &common.CommandResult{Stdout: []byte(fmt.Sprintf("%s:x:%d:%d::/:/bin/sh\n", name, id, id))}, nil
+	case strings.HasPrefix(cmd.Command, "if [ -e ") && strings.Contains(cmd.Command, "; then cat "):
+		file, ok := h.files[quotedArgument(cmd.Command)]
+		if !ok {
+			return &common.CommandResult{}, nil
+		}
+		return &common.CommandResult{Stdout: file.content}, nil
+	case strings.HasPrefix(cmd.Command, "if [ -e "):
+		path := quotedArgument(cmd.Command)
+		if _, ok := h.files[path]; !ok {
+			return &common.CommandResult{}, nil
+		}
+		delete(h.files, path)
+		return &common.CommandResult{Stdout: []byte("removed\n")}, nil
+	case strings.HasPrefix(cmd.Command, "rm -f "):
+		delete(h.files, quotedArgument(cmd.Command))
+		return &common.CommandResult{}, nil
+	}
+
+	return &common.CommandResult{ExitCode: h.exitCodes[cmd.Command]}, nil
+}
+
+func (h *fakeHost) ExecStream(ctx context.Context, cmd *common.Command, stdout io.Writer, stderr io.Writer) (*common.CommandResult, error) {
+	return h.Exec(ctx, cmd)
+}
+
+func (h *fakeHost) Upload(ctx context.Context, path string, content io.Reader, opts *common.FileOptions) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	file := &fakeFile{content: data, mode: opts.FileMode()}
+	if opts.Owner != "" {
+		if file.uid, err = h.resolve(h.users, opts.Owner); err != nil {
+			return err
+		}
+	}
+	if opts.Group != "" {
+		if file.gid, err = h.resolve(h.groups, opts.Group); err != nil {
+			return err
+		}
+	}
+	h.files[path] = file
+	h.uploads++
+
+	return nil
+}
+
+func (h *fakeHost) Download(ctx context.Context, path string, content io.Writer) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	file, ok := h.files[path]
+	if !ok {
+		return fmt.Errorf("failed to open file: %s: %w", path, os.ErrNotExist)
+	}
+
+	_, err := content.Write(file.content)
+	return err
+}
+
+func (h *fakeHost) Stat(ctx context.Context, path string) (*common.FileInfo, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	file, ok := h.files[path]
+	if !ok {
+		return nil, fmt.Errorf("failed to stat file: %s: %w", path, os.ErrNotExist)
+	}
+
+	return &common.FileInfo{
+		Size: int64(len(file.content)),
+		Mode: file.mode,
+		UID:  file.uid,
+		GID:  file.gid,
+	}, nil
+}
+
+// resolve resolves a name or a numeric ID of a user or a group.
+func (h *fakeHost) resolve(ids map[string]int, name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	id, ok := ids[name]
+	if !ok {
+		return 0, fmt.Errorf("chown: invalid user or group: %s", name)
+	}
+	return id, nil
+}
+
+// quotedArgument returns the last single-quoted argument of a command.
+func quotedArgument(command string) string {
+	end := strings.LastIndex(command, "'")
+	start := strings.LastIndex(command[:end], "'")
+	return command[start+1 : end]
+}
@@ -18,8 +18,13 @@ package management
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -37,21 +42,31 @@ import (
 
 const (
 	secretField    = ".spec.secretRef.name"
+	bastionField   = ".spec.ssh.bastionRef.name"
 	controllerName = "host-controller"
 )
 
 // HostReconciler reconciles a Host object
 type HostReconciler struct {
 	client.Client
-	recorder record.EventRecorder
-	Scheme   *runtime.Scheme
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+	// KeyRotation enables the rotation of private keys, which requires
+	// the permission to update and patch Secrets in all namespaces.
+	KeyRotation bool
 }
 
 //+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=sshbastions,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// The SSH CA of the connection manager creates its Secret in the namespace
+// of the operator on first use. The permission to update Secrets for the key
+// rotation is granted separately, because it can only be granted cluster-wide.
+//+kubebuilder:rbac:groups="",namespace=system,resources=secrets,verbs=create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -67,38 +82,256 @@ func (r *HostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 	conn := new(mgmtv1alpha1.Host)
 	err := r.Get(ctx, req.NamespacedName, conn)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			// Close the pooled connection of a deleted host.
+			r.Connections.Invalidate(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// The BMC is managed first, because it is reachable even if
+	// the operating system of the host is not, e.g. to power it on.
+	outOfBandRequeueAfter, err := r.reconcileOutOfBand(ctx, conn)
 	if err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	mgmt, err := management.NewClient(req.NamespacedName, common.WithKubernetesClient(r.Client))
+	mgmt, err := r.Connections.Get(ctx, req.NamespacedName)
 	if err != nil {
+		var mismatch *common.HostKeyMismatchError
+		if errors.As(err, &mismatch) {
+			return ctrl.Result{RequeueAfter: outOfBandRequeueAfter}, r.reportHostKeyMismatch(ctx, conn, mismatch)
+		}
+		var notPermitted *common.ReferenceNotPermittedError
+		if errors.As(err, &notPermitted) {
+			return ctrl.Result{RequeueAfter: outOfBandRequeueAfter}, r.reportReferenceNotPermitted(ctx, conn, notPermitted)
+		}
+
 		r.recorder.Event(conn, corev1.EventTypeWarning, "ConnectionFailed", err.Error())
 		logger.Error(err, "failed to create management client")
-		return ctrl.Result{}, nil
+		return ctrl.Result{RequeueAfter: outOfBandRequeueAfter}, nil
 	}
 	defer mgmt.Disconnect()
 
+	// Persist trusted host keys immediately, so that they
+	// are enforced even if the probing below fails.
+	modified := r.trustHostKeys(conn, mgmt)
+	if meta.SetStatusCondition(&conn.Status.Conditions, metav1.Condition{
+		Type:               mgmtv1alpha1.HostConditionReferenceNotPermitted,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: conn.Generation,
+		Reason:             "ReferencesPermitted",
+		Message:            "All referenced Secrets are permitted.",
+	}) {
+		modified = true
+	}
+	if modified {
+		if err := r.Status().Update(ctx, conn); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
 	// TODO: Although this is idempotent, we may put excessive load on the API server,
 	// because we trigger a reconciliation for the secret change and the host.
-	conn.Status.OS = *mgmt.OS()
+	osInfo, err := mgmt.OS(ctx)
+	if err != nil {
+		r.recorder.Event(conn, corev1.EventTypeWarning, "ProbeFailed", err.Error())
+		logger.Error(err, "failed to probe operating system")
+		return ctrl.Result{RequeueAfter: outOfBandRequeueAfter}, nil
+	}
+	capabilities, err := mgmt.Capabilities(ctx)
+	if err != nil {
+		r.recorder.Event(conn, corev1.EventTypeWarning, "ProbeFailed", err.Error())
+		logger.Error(err, "failed to probe capabilities")
+		return ctrl.Result{RequeueAfter: outOfBandRequeueAfter}, nil
+	}
+
+	interfaces, err := r.probeInterfaces(ctx, mgmt)
+	if err != nil {
+		r.recorder.Event(conn, corev1.EventTypeWarning, "ProbeFailed", err.Error())
+		logger.Error(err, "failed to probe interfaces")
+		return ctrl.Result{RequeueAfter: outOfBandRequeueAfter}, nil
+	}
+
+	conn.Status.OS = *osInfo
+	conn.Status.Capabilities = *capabilities
+	conn.Status.Interfaces = interfaces
+	requeueAfter := r.reconcileKeyRotation(ctx, conn, mgmt)
 	if err := r.Status().Update(ctx, conn); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 	r.recorder.Event(conn, corev1.EventTypeNormal, "OSProbed", "OS information probed successfully.")
 
-	return ctrl.Result{}, nil
+	if requeueAfter == 0 || (outOfBandRequeueAfter > 0 && outOfBandRequeueAfter < requeueAfter) {
+		requeueAfter = outOfBandRequeueAfter
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// probeInterfaces discovers the network interfaces of the host, if
+// the protocol of the host supports it. Otherwise, none are returned.
+func (r *HostReconciler) probeInterfaces(ctx context.Context, mgmt common.Client) ([]mgmtv1alpha1.HostInterface, error) {
+	reporter, ok := mgmt.(common.InterfaceReporter)
+	if !ok {
+		return nil, nil
+	}
+
+	interfaces, err := reporter.Interfaces(ctx)
+	if errors.Is(err, common.ErrNotSupported) {
+		return nil, nil
+	}
+
+	return interfaces, err
+}
+
+// trustHostKeys records the host key fingerprints that were presented on the
+// first connection if trust on first use is enabled and marks the host keys as
+// unchanged. It returns whether the status was modified.
+func (r *HostReconciler) trustHostKeys(host *mgmtv1alpha1.Host, mgmt common.Client) bool {
+	modified := meta.SetStatusCondition(&host.Status.Conditions, metav1.Condition{
+		Type:               mgmtv1alpha1.HostConditionHostKeyChanged,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: host.Generation,
+		Reason:             "HostKeyAccepted",
+		Message:            "The presented host keys were accepted.",
+	})
+
+	reporter, ok := mgmt.(common.HostKeyReporter)
+	if !ok || !host.Spec.SSH.TrustOnFirstUse {
+		return modified
+	}
+	hostKeys := reporter.HostKeys()
+
+	if host.Spec.SSH.Fingerprint == "" && host.Status.SSH.Fingerprint == "" && hostKeys.Fingerprint != "" {
+		host.Status.SSH.Fingerprint = hostKeys.Fingerprint
+		modified = true
+		r.recorder.Event(host, corev1.EventTypeNormal, "HostKeyTrusted", fmt.Sprintf("Trusted host key %s on first use.", hostKeys.Fingerprint))
+	}
+
+	if host.Spec.SSH.ProxyHost != "" && host.Spec.SSH.ProxyFingerprint == "" && host.Status.SSH.ProxyFingerprint == "" && hostKeys.ProxyFingerprint != "" {
+		host.Status.SSH.ProxyFingerprint = hostKeys.ProxyFingerprint
+		modified = true
+		r.recorder.Event(host, corev1.EventTypeNormal, "HostKeyTrusted", fmt.Sprintf("Trusted proxy host key %s on first use.", hostKeys.ProxyFingerprint))
+	}
+
+	if r.trustJumpHostKeys(host, hostKeys) {
+		modified = true
+	}
+
+	return modified
+}
+
+// trustJumpHostKeys records the host key fingerprints of jump hosts that are not
+// specified and forgets the fingerprints of jump hosts that were removed. It
+// returns whether the status was modified.
+func (r *HostReconciler) trustJumpHostKeys(host *mgmtv1alpha1.Host, hostKeys common.HostKeys) bool {
+	modified := false
+	trusted := make(map[string]string, len(host.Spec.SSH.JumpHosts))
+	for i := range host.Spec.SSH.JumpHosts {
+		jumpHost := &host.Spec.SSH.JumpHosts[i]
+		if jumpHost.Fingerprint != "" {
+			continue
+		}
+
+		address := jumpHost.Address()
+		fingerprint, ok := host.Status.SSH.JumpHostFingerprints[address]
+		if !ok {
+			fingerprint = hostKeys.JumpHostFingerprints[address]
+			if fingerprint != "" {
+				modified = true
+				r.recorder.Event(host, corev1.EventTypeNormal, "HostKeyTrusted", fmt.Sprintf("Trusted host key %s of jump host %s on first use.", fingerprint, address))
+			}
+		}
+		if fingerprint != "" {
+			trusted[address] = fingerprint
+		}
+	}
+
+	if len(trusted) != len(host.Status.SSH.JumpHostFingerprints) {
+		modified = true
+	}
+	if modified {
+		host.Status.SSH.JumpHostFingerprints = trusted
+		if len(trusted) == 0 {
+			host.Status.SSH.JumpHostFingerprints = nil
+		}
+	}
+
+	return modified
+}
+
+// reportHostKeyMismatch raises the HostKeyChanged condition and emits a warning,
+// because the host or a jump host may have been reinstalled or impersonated.
+func (r *HostReconciler) reportHostKeyMismatch(ctx context.Context, host *mgmtv1alpha1.Host, mismatch *common.HostKeyMismatchError) error {
+	subject := "Host"
+	if mismatch.JumpHost {
+		subject = "Jump host"
+	}
+	message := fmt.Sprintf("%s %s presented host key %s, but %s was expected.", subject, mismatch.Address, mismatch.Presented, mismatch.Expected)
+	r.recorder.Event(host, corev1.EventTypeWarning, mgmtv1alpha1.HostConditionHostKeyChanged, message)
+
+	modified := meta.SetStatusCondition(&host.Status.Conditions, metav1.Condition{
+		Type:               mgmtv1alpha1.HostConditionHostKeyChanged,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: host.Generation,
+		Reason:             "FingerprintMismatch",
+		Message:            message,
+	})
+	if !modified {
+		return nil
+	}
+
+	return client.IgnoreNotFound(r.Status().Update(ctx, host))
+}
+
+// reportReferenceNotPermitted raises the ReferenceNotPermitted condition and
+// emits a warning, because the host references a Secret in another namespace
+// that did not grant access to it.
+func (r *HostReconciler) reportReferenceNotPermitted(ctx context.Context, host *mgmtv1alpha1.Host, notPermitted *common.ReferenceNotPermittedError) error {
+	message := fmt.Sprintf("Secret %s does not grant access to namespace %s via the annotation %s.", notPermitted.SecretRef, notPermitted.Namespace, common.AllowedNamespacesAnnotation)
+	r.recorder.Event(host, corev1.EventTypeWarning, mgmtv1alpha1.HostConditionReferenceNotPermitted, message)
+
+	modified := meta.SetStatusCondition(&host.Status.Conditions, metav1.Condition{
+		Type:               mgmtv1alpha1.HostConditionReferenceNotPermitted,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: host.Generation,
+		Reason:             "MissingGrant",
+		Message:            message,
+	})
+	if !modified {
+		return nil
+	}
+
+	return client.IgnoreNotFound(r.Status().Update(ctx, host))
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *HostReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.recorder = mgr.GetEventRecorderFor(controllerName)
 
-	// We need to add an index for the secret name so that we can
-	// trigger a reconciliation if a referenced secret changes.
+	// We need to add an index for the secret names so that we can trigger a
+	// reconciliation if a referenced secret, including those of jump hosts, changes.
 	err := mgr.GetFieldIndexer().IndexField(context.TODO(), &mgmtv1alpha1.Host{}, secretField, func(rawObj client.Object) []string {
 		conn := rawObj.(*mgmtv1alpha1.Host)
-		return []string{conn.Spec.SecretRef.Name}
+		names := []string{}
+		for _, secretRef := range common.SecretReferences(conn) {
+			if !slices.Contains(names, secretRef.Name) {
+				names = append(names, secretRef.Name)
+			}
+		}
+		return names
+	})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.TODO(), &mgmtv1alpha1.Host{}, bastionField, func(rawObj client.Object) []string {
+		conn := rawObj.(*mgmtv1alpha1.Host)
+		if conn.Spec.SSH.BastionRef == nil {
+			return []string{}
+		}
+		return []string{conn.Spec.SSH.BastionRef.Name}
 	})
 	if err != nil {
 		return err
@@ -108,6 +341,8 @@ func (r *HostReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		For(&mgmtv1alpha1.Host{}).
 		// Watch for changes to referenced secrets.
 		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findObjectsForSecret)).
+		// Watch for changes to referenced bastions.
+		Watches(&mgmtv1alpha1.SSHBastion{}, handler.EnqueueRequestsFromMapFunc(enqueueField(r.Client, newHostList, bastionField))).
 		Complete(r)
 }
 
@@ -132,3 +367,8 @@ func (r *HostReconciler) findObjectsForSecret(ctx context.Context, secret client
 	}
 	return requests
 }
+
+// newHostList creates an empty list of Hosts.
+func newHostList() client.ObjectList {
+	return &mgmtv1alpha1.HostList{}
+}
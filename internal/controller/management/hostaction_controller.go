@@ -0,0 +1,453 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+const (
+	hostActionControllerName = "hostaction-controller"
+	// hostActionInterval is the interval at which the progress of a running action is checked.
+	hostActionInterval = 15 * time.Second
+	// defaultHostActionTimeout is the default duration for a host to complete an action.
+	defaultHostActionTimeout = 15 * time.Minute
+)
+
+// HostActionReconciler reconciles a HostAction object
+type HostActionReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostactions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostactions/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostactions/finalizers,verbs=update
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostupgradepolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile performs the action of a HostAction on the selected hosts. The
+// action is performed once for every generation of the spec. Instead of
+// blocking until the hosts have completed the action, the progress is
+// checked periodically and recorded in the status.
+func (r *HostActionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	hostAction := new(mgmtv1alpha1.HostAction)
+	if err := r.Get(ctx, req.NamespacedName, hostAction); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Changes of the spec take effect once the current run has completed,
+	// because aborting it could leave hosts in an unknown state.
+	if hostAction.Status.Phase != mgmtv1alpha1.HostActionPhaseRunning {
+		if hostAction.Status.ObservedGeneration == hostAction.Generation && hostAction.Status.StartTime != nil {
+			return ctrl.Result{}, nil
+		}
+
+		hosts, err := selectHosts(ctx, r.Client, hostAction.Namespace, &hostAction.Spec.HostSelector)
+		if err != nil {
+			r.recorder.Event(hostAction, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+			logger.Error(err, "failed to select hosts")
+			return ctrl.Result{}, nil
+		}
+
+		startTime := metav1.Now()
+		hostAction.Status = mgmtv1alpha1.HostActionStatus{
+			ObservedGeneration: hostAction.Generation,
+			Phase:              mgmtv1alpha1.HostActionPhaseRunning,
+			StartTime:          &startTime,
+			Hosts:              make([]mgmtv1alpha1.HostActionHostStatus, len(hosts)),
+		}
+		for i, host := range hosts {
+			hostAction.Status.Hosts[i] = mgmtv1alpha1.HostActionHostStatus{
+				Host:  host.Name,
+				Phase: mgmtv1alpha1.HostActionPhasePending,
+			}
+		}
+		if err := r.Status().Update(ctx, hostAction); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+
+		r.recorder.Event(hostAction, corev1.EventTypeNormal, "ActionStarted", fmt.Sprintf("Starting %s of %d hosts.", hostAction.Spec.Type, len(hosts)))
+	}
+
+	for i := range hostAction.Status.Hosts {
+		if hostAction.Status.Hosts[i].Phase == mgmtv1alpha1.HostActionPhaseRunning {
+			r.poll(ctx, hostAction, &hostAction.Status.Hosts[i])
+		}
+	}
+
+	if err := r.startPending(ctx, hostAction); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	hostAction.Status.Succeeded = 0
+	hostAction.Status.Failed = 0
+	done := true
+	for _, status := range hostAction.Status.Hosts {
+		switch status.Phase {
+		case mgmtv1alpha1.HostActionPhaseSucceeded:
+			hostAction.Status.Succeeded++
+		case mgmtv1alpha1.HostActionPhaseFailed:
+			hostAction.Status.Failed++
+		case mgmtv1alpha1.HostActionPhasePending, mgmtv1alpha1.HostActionPhaseRunning:
+			done = false
+		}
+	}
+
+	if done {
+		completionTime := metav1.Now()
+		hostAction.Status.CompletionTime = &completionTime
+		hostAction.Status.Phase = mgmtv1alpha1.HostActionPhaseSucceeded
+		if hostAction.Status.Failed > 0 {
+			hostAction.Status.Phase = mgmtv1alpha1.HostActionPhaseFailed
+		}
+	}
+
+	if err := r.Status().Update(ctx, hostAction); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !done {
+		return ctrl.Result{RequeueAfter: hostActionInterval}, nil
+	}
+
+	if hostAction.Status.Failed > 0 {
+		r.recorder.Event(hostAction, corev1.EventTypeWarning, "ActionFailed", fmt.Sprintf("%s failed on %d of %d hosts.", hostAction.Spec.Type, hostAction.Status.Failed, len(hostAction.Status.Hosts)))
+	} else {
+		r.recorder.Event(hostAction, corev1.EventTypeNormal, "ActionSucceeded", fmt.Sprintf("%s succeeded on %d hosts.", hostAction.Spec.Type, hostAction.Status.Succeeded))
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// startPending starts the action on pending hosts, as long as the number of
+// selected hosts with an action in progress is below the concurrency limit.
+// Hosts that are busy with another HostAction or with the upgrade of a
+// HostUpgradePolicy count towards the limit and are not started. Once the
+// action failed on a host, the remaining hosts are skipped.
+func (r *HostActionReconciler) startPending(ctx context.Context, hostAction *mgmtv1alpha1.HostAction) error {
+	hosts := make([]string, len(hostAction.Status.Hosts))
+	for i, status := range hostAction.Status.Hosts {
+		hosts[i] = status.Host
+	}
+	busy, err := busyHosts(ctx, r.Client, hostAction.Namespace, hosts, hostAction)
+	if err != nil {
+		return err
+	}
+
+	active := len(busy)
+	failed := false
+	for _, status := range hostAction.Status.Hosts {
+		switch status.Phase {
+		case mgmtv1alpha1.HostActionPhaseRunning:
+			if !busy[status.Host] {
+				active++
+			}
+		case mgmtv1alpha1.HostActionPhaseFailed:
+			failed = true
+		}
+	}
+
+	concurrency := max(hostAction.Spec.Concurrency, 1)
+	for i := range hostAction.Status.Hosts {
+		status := &hostAction.Status.Hosts[i]
+		if status.Phase != mgmtv1alpha1.HostActionPhasePending {
+			continue
+		}
+
+		if failed {
+			now := metav1.Now()
+			status.Phase = mgmtv1alpha1.HostActionPhaseSkipped
+			status.CompletionTime = &now
+			continue
+		}
+
+		if active >= concurrency || busy[status.Host] {
+			continue
+		}
+
+		// The host is persisted as running before the action is submitted,
+		// which ensures that an action is never submitted to a host twice.
+		now := metav1.Now()
+		status.Phase = mgmtv1alpha1.HostActionPhaseRunning
+		status.StartTime = &now
+		addHostActionEvent(status, mgmtv1alpha1.HostActionStepStarted, fmt.Sprintf("Starting %s.", hostAction.Spec.Type))
+		if err := r.Status().Update(ctx, hostAction); err != nil {
+			return err
+		}
+		active++
+
+		if err := r.submit(ctx, hostAction, status); err != nil {
+			r.fail(hostAction, status, err)
+			failed = true
+		}
+	}
+
+	return nil
+}
+
+// submit records the boot ID of the host and submits the action via the
+// operating system or via the BMC of the host, depending on the method.
+func (r *HostActionReconciler) submit(ctx context.Context, hostAction *mgmtv1alpha1.HostAction, status *mgmtv1alpha1.HostActionHostStatus) error {
+	hostRef := types.NamespacedName{Namespace: hostAction.Namespace, Name: status.Host}
+	host := new(mgmtv1alpha1.Host)
+	if err := r.Get(ctx, hostRef, host); err != nil {
+		return err
+	}
+
+	method := hostAction.Spec.Method
+	if hostAction.Spec.Type == mgmtv1alpha1.HostActionTypePowerCycle {
+		method = mgmtv1alpha1.HostActionMethodOutOfBand
+	}
+
+	// The boot ID is optional for actions via the BMC, because they
+	// are also used to recover hosts whose operating system is unavailable.
+	bootID, err := readBootID(ctx, r.Connections, host)
+	status.BootID = bootID
+
+	if method != mgmtv1alpha1.HostActionMethodOutOfBand {
+		if err == nil {
+			err = r.submitInBand(ctx, hostAction, host)
+		}
+		if err == nil {
+			status.Method = mgmtv1alpha1.HostActionMethodInBand
+			addHostActionEvent(status, mgmtv1alpha1.HostActionStepSubmitted, fmt.Sprintf("Requested %s via the operating system.", hostAction.Spec.Type))
+			r.Connections.Invalidate(hostRef)
+			return nil
+		}
+		if method == mgmtv1alpha1.HostActionMethodInBand || host.Spec.OutOfBand == nil {
+			return err
+		}
+		log.FromContext(ctx).Info("falling back to BMC", "host", host.Name, "reason", err.Error())
+	}
+
+	if host.Spec.OutOfBand == nil {
+		return fmt.Errorf("%w: host %s has no out-of-band endpoint", common.ErrNotSupported, host.Name)
+	}
+
+	powerAction, err := r.submitOutOfBand(ctx, hostAction, host, bootID != "")
+	if err != nil {
+		return err
+	}
+
+	status.Method = mgmtv1alpha1.HostActionMethodOutOfBand
+	addHostActionEvent(status, mgmtv1alpha1.HostActionStepSubmitted, fmt.Sprintf("Submitted power action %s to the BMC.", powerAction))
+	r.Connections.Invalidate(hostRef)
+
+	return nil
+}
+
+// submitInBand requests a reboot or a shutdown via the operating system.
+func (r *HostActionReconciler) submitInBand(ctx context.Context, hostAction *mgmtv1alpha1.HostAction, host *mgmtv1alpha1.Host) error {
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return err
+	}
+	defer mgmt.Disconnect()
+
+	power, err := system.NewPower(mgmt, host)
+	if err != nil {
+		return err
+	}
+
+	if hostAction.Spec.Type == mgmtv1alpha1.HostActionTypeShutdown {
+		return power.Shutdown(ctx)
+	}
+
+	return power.Reboot(ctx)
+}
+
+// submitOutOfBand submits the power action for the action to the BMC. The
+// operating system is asked to reboot or shut down gracefully if it is
+// running, while a host without a running operating system is reset or
+// powered off immediately. It returns the submitted power action.
+func (r *HostActionReconciler) submitOutOfBand(ctx context.Context, hostAction *mgmtv1alpha1.HostAction, host *mgmtv1alpha1.Host, running bool) (mgmtv1alpha1.PowerAction, error) {
+	oob, err := r.Connections.OutOfBand(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	defer oob.Disconnect()
+
+	var powerAction, fallback mgmtv1alpha1.PowerAction
+	switch hostAction.Spec.Type {
+	case mgmtv1alpha1.HostActionTypeReboot:
+		powerAction, fallback = mgmtv1alpha1.PowerActionForceRestart, ""
+		if running {
+			powerAction, fallback = mgmtv1alpha1.PowerActionGracefulRestart, mgmtv1alpha1.PowerActionForceRestart
+		}
+	case mgmtv1alpha1.HostActionTypeShutdown:
+		powerAction, fallback = mgmtv1alpha1.PowerActionForceOff, ""
+		if running {
+			powerAction, fallback = mgmtv1alpha1.PowerActionGracefulShutdown, mgmtv1alpha1.PowerActionForceOff
+		}
+	default:
+		powerAction = mgmtv1alpha1.PowerActionPowerCycle
+	}
+
+	err = oob.Power(ctx, powerAction)
+	if errors.Is(err, common.ErrNotSupported) && fallback != "" {
+		powerAction = fallback
+		err = oob.Power(ctx, powerAction)
+	}
+
+	return powerAction, err
+}
+
+// poll checks if the host has completed the action. A reboot or a power cycle
+// is completed once the host is reachable with a new boot ID, while a shutdown
+// is completed once the host is powered off.
+func (r *HostActionReconciler) poll(ctx context.Context, hostAction *mgmtv1alpha1.HostAction, status *mgmtv1alpha1.HostActionHostStatus) {
+	if !status.HasStep(mgmtv1alpha1.HostActionStepSubmitted) {
+		// Submitting the action again could restart the host twice.
+		r.fail(hostAction, status, errors.New("operator was interrupted while submitting the action"))
+		return
+	}
+
+	timeout := defaultHostActionTimeout
+	if hostAction.Spec.Timeout != nil {
+		timeout = hostAction.Spec.Timeout.Duration
+	}
+	if status.StartTime != nil && time.Since(status.StartTime.Time) > timeout {
+		r.fail(hostAction, status, fmt.Errorf("host did not complete %s within %s", hostAction.Spec.Type, timeout))
+		return
+	}
+
+	host := new(mgmtv1alpha1.Host)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: hostAction.Namespace, Name: status.Host}, host); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.fail(hostAction, status, err)
+		}
+		return
+	}
+
+	if hostAction.Spec.Type == mgmtv1alpha1.HostActionTypeShutdown {
+		r.pollShutdown(ctx, hostAction, host, status)
+		return
+	}
+
+	bootID, err := readBootID(ctx, r.Connections, host)
+	if err != nil {
+		if !status.HasStep(mgmtv1alpha1.HostActionStepHostDown) {
+			addHostActionEvent(status, mgmtv1alpha1.HostActionStepHostDown, err.Error())
+		}
+		return
+	}
+
+	// Without a previous boot ID, the host was unreachable before
+	// the action, so being reachable again suffices.
+	if status.BootID != "" && !bootIDChanged(status.BootID, bootID) {
+		return
+	}
+
+	addHostActionEvent(status, mgmtv1alpha1.HostActionStepHostUp, fmt.Sprintf("Host is reachable with boot ID %s.", bootID))
+	r.succeed(hostAction, status)
+}
+
+// pollShutdown checks if the host was powered off. Without a BMC,
+// the host is considered powered off once it is unreachable.
+func (r *HostActionReconciler) pollShutdown(ctx context.Context, hostAction *mgmtv1alpha1.HostAction, host *mgmtv1alpha1.Host, status *mgmtv1alpha1.HostActionHostStatus) {
+	if host.Spec.OutOfBand == nil {
+		if _, err := readBootID(ctx, r.Connections, host); err != nil {
+			addHostActionEvent(status, mgmtv1alpha1.HostActionStepHostDown, err.Error())
+			r.succeed(hostAction, status)
+		}
+		return
+	}
+
+	oob, err := r.Connections.OutOfBand(ctx, host)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to connect to BMC", "host", host.Name)
+		return
+	}
+	defer oob.Disconnect()
+
+	powerState, err := oob.PowerState(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to read power state", "host", host.Name)
+		return
+	}
+	if powerState != mgmtv1alpha1.PowerStateOff {
+		return
+	}
+
+	addHostActionEvent(status, mgmtv1alpha1.HostActionStepPoweredOff, "BMC reports that the host is powered off.")
+	r.succeed(hostAction, status)
+}
+
+// succeed marks the action on the host as succeeded.
+func (r *HostActionReconciler) succeed(hostAction *mgmtv1alpha1.HostAction, status *mgmtv1alpha1.HostActionHostStatus) {
+	now := metav1.Now()
+	status.Phase = mgmtv1alpha1.HostActionPhaseSucceeded
+	status.CompletionTime = &now
+
+	r.recorder.Event(hostAction, corev1.EventTypeNormal, "HostSucceeded", fmt.Sprintf("Host %s completed %s.", status.Host, hostAction.Spec.Type))
+}
+
+// fail marks the action on the host as failed.
+func (r *HostActionReconciler) fail(hostAction *mgmtv1alpha1.HostAction, status *mgmtv1alpha1.HostActionHostStatus, err error) {
+	now := metav1.Now()
+	status.Phase = mgmtv1alpha1.HostActionPhaseFailed
+	status.Error = err.Error()
+	status.CompletionTime = &now
+	addHostActionEvent(status, mgmtv1alpha1.HostActionStepFailed, err.Error())
+
+	r.recorder.Event(hostAction, corev1.EventTypeWarning, "HostFailed", fmt.Sprintf("Host %s failed to complete %s: %s", status.Host, hostAction.Spec.Type, err))
+}
+
+// addHostActionEvent appends a step to the timeline of the action on the host.
+func addHostActionEvent(status *mgmtv1alpha1.HostActionHostStatus, step mgmtv1alpha1.HostActionStep, message string) {
+	status.Timeline = append(status.Timeline, mgmtv1alpha1.HostActionEvent{
+		Time:    metav1.Now(),
+		Step:    step,
+		Message: message,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HostActionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(hostActionControllerName)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// The progress is checked periodically, so status updates must not trigger it.
+		For(&mgmtv1alpha1.HostAction{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
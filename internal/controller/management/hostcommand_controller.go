@@ -0,0 +1,261 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/cron"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	hostCommandControllerName = "hostcommand-controller"
+	// maxOutputLength is the maximum number of bytes of the standard
+	// output and the standard error that is recorded for each host.
+	maxOutputLength = 4096
+)
+
+// HostCommandReconciler reconciles a HostCommand object
+type HostCommandReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostcommands,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostcommands/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostcommands/finalizers,verbs=update
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile runs the command of a HostCommand on the selected hosts. Commands
+// without a schedule are run once for every generation of the spec, while
+// scheduled commands are run whenever their schedule is due.
+func (r *HostCommandReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	hostCommand := new(mgmtv1alpha1.HostCommand)
+	if err := r.Get(ctx, req.NamespacedName, hostCommand); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if (hostCommand.Spec.Command == "") == (hostCommand.Spec.Script == "") {
+		r.recorder.Event(hostCommand, corev1.EventTypeWarning, "InvalidSpec", "Exactly one of command or script must be specified.")
+		return ctrl.Result{}, nil
+	}
+
+	var schedule *cron.Schedule
+	if hostCommand.Spec.Schedule != "" {
+		var err error
+		schedule, err = cron.Parse(hostCommand.Spec.Schedule)
+		if err != nil {
+			r.recorder.Event(hostCommand, corev1.EventTypeWarning, "InvalidSchedule", err.Error())
+			return ctrl.Result{}, nil
+		}
+
+		if hostCommand.Spec.Suspend {
+			return ctrl.Result{}, nil
+		}
+
+		// Missed runs are not caught up on, which means that
+		// a command is run at most once per reconciliation.
+		lastRun := hostCommand.CreationTimestamp.Time
+		if hostCommand.Status.LastRunTime != nil {
+			lastRun = hostCommand.Status.LastRunTime.Time
+		}
+		next := schedule.Next(lastRun)
+		if next.IsZero() {
+			return ctrl.Result{}, nil
+		}
+
+		now := time.Now()
+		if now.Before(next) {
+			if hostCommand.Status.NextRunTime == nil || !hostCommand.Status.NextRunTime.Time.Equal(next) {
+				hostCommand.Status.NextRunTime = &metav1.Time{Time: next}
+				if err := r.Status().Update(ctx, hostCommand); err != nil {
+					return ctrl.Result{}, client.IgnoreNotFound(err)
+				}
+			}
+			return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+		}
+	} else if hostCommand.Status.LastRunTime != nil && hostCommand.Status.ObservedGeneration == hostCommand.Generation {
+		return ctrl.Result{}, nil
+	}
+
+	hosts, err := selectHosts(ctx, r.Client, hostCommand.Namespace, &hostCommand.Spec.HostSelector)
+	if err != nil {
+		r.recorder.Event(hostCommand, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		logger.Error(err, "failed to select hosts")
+		return ctrl.Result{}, nil
+	}
+
+	startTime := metav1.Now()
+	hostCommand.Status.Phase = mgmtv1alpha1.HostCommandPhaseRunning
+	hostCommand.Status.LastRunTime = &startTime
+	hostCommand.Status.NextRunTime = nil
+	hostCommand.Status.ObservedGeneration = hostCommand.Generation
+	if err := r.Status().Update(ctx, hostCommand); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	results := r.run(ctx, hostCommand, hosts)
+
+	hostCommand.Status.Results = results
+	hostCommand.Status.Succeeded = 0
+	hostCommand.Status.Failed = 0
+	for _, result := range results {
+		if result.Succeeded() {
+			hostCommand.Status.Succeeded++
+		} else {
+			hostCommand.Status.Failed++
+		}
+	}
+	hostCommand.Status.Phase = mgmtv1alpha1.HostCommandPhaseSucceeded
+	if hostCommand.Status.Failed > 0 {
+		hostCommand.Status.Phase = mgmtv1alpha1.HostCommandPhaseFailed
+	}
+
+	result := ctrl.Result{}
+	if schedule != nil {
+		if next := schedule.Next(startTime.Time); !next.IsZero() {
+			hostCommand.Status.NextRunTime = &metav1.Time{Time: next}
+			result.RequeueAfter = time.Until(next)
+		}
+	}
+
+	if err := r.Status().Update(ctx, hostCommand); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if hostCommand.Status.Failed > 0 {
+		r.recorder.Event(hostCommand, corev1.EventTypeWarning, "CommandFailed", fmt.Sprintf("Command failed on %d of %d hosts.", hostCommand.Status.Failed, len(results)))
+	} else {
+		r.recorder.Event(hostCommand, corev1.EventTypeNormal, "CommandSucceeded", fmt.Sprintf("Command succeeded on %d hosts.", len(results)))
+	}
+
+	return result, nil
+}
+
+// run executes the command on the hosts while respecting the
+// concurrency limit. The results are in the order of the hosts.
+func (r *HostCommandReconciler) run(ctx context.Context, hostCommand *mgmtv1alpha1.HostCommand, hosts []mgmtv1alpha1.Host) []mgmtv1alpha1.HostCommandResult {
+	concurrency := hostCommand.Spec.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]mgmtv1alpha1.HostCommandResult, len(hosts))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i := range hosts {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			results[i] = r.runOnHost(ctx, hostCommand, &hosts[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runOnHost executes the command on a single host.
+func (r *HostCommandReconciler) runOnHost(ctx context.Context, hostCommand *mgmtv1alpha1.HostCommand, host *mgmtv1alpha1.Host) (result mgmtv1alpha1.HostCommandResult) {
+	result = mgmtv1alpha1.HostCommandResult{
+		Host:      host.Name,
+		StartTime: metav1.Now(),
+	}
+	defer func() {
+		result.CompletionTime = metav1.Now()
+	}()
+
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer mgmt.Disconnect()
+
+	cmd := &common.Command{
+		Command: hostCommand.Spec.Command,
+		Env:     hostCommand.Spec.Env,
+	}
+	if hostCommand.Spec.Script != "" {
+		cmd.Command = "sh -s"
+		cmd.Stdin = strings.NewReader(hostCommand.Spec.Script)
+	}
+	if hostCommand.Spec.Timeout != nil {
+		cmd.Timeout = hostCommand.Spec.Timeout.Duration
+	}
+
+	commandResult, err := mgmt.Exec(ctx, cmd)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	exitCode := commandResult.ExitCode
+	result.ExitCode = &exitCode
+	result.Stdout = truncateOutput(commandResult.Stdout)
+	result.Stderr = truncateOutput(commandResult.Stderr)
+
+	return result
+}
+
+// truncateOutput keeps the end of the output, which
+// usually contains the most relevant information.
+func truncateOutput(output []byte) string {
+	if len(output) <= maxOutputLength {
+		return string(output)
+	}
+
+	return "[truncated]\n" + strings.ToValidUTF8(string(output[len(output)-maxOutputLength:]), "")
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HostCommandReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(hostCommandControllerName)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// Status updates must not trigger another run of the command.
+		For(&mgmtv1alpha1.HostCommand{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
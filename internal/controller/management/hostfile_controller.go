@@ -0,0 +1,474 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	hostFileControllerName = "hostfile-controller"
+	hostFileFinalizer      = "management.kraut.nicklasfrahm.dev/hostfile"
+	configMapField         = ".spec.contentFrom.configMapKeyRef.name"
+	contentSecretField     = ".spec.contentFrom.secretKeyRef.name"
+	defaultHostFileMode    = 0644
+	defaultHostFileResync  = 10 * time.Minute
+	// hostFileRetryInterval is the interval at which failed
+	// on-change commands are retried at the latest.
+	hostFileRetryInterval = time.Minute
+)
+
+// HostFileReconciler reconciles a HostFile object
+type HostFileReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostfiles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostfiles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostfiles/finalizers,verbs=update
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile ensures that the file of a HostFile exists with the desired content
+// on the selected hosts. The file is only written if its checksum, its mode or
+// its owner differs from the desired state. Changes on the hosts are reported
+// as drift.
+func (r *HostFileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	hostFile := new(mgmtv1alpha1.HostFile)
+	if err := r.Get(ctx, req.NamespacedName, hostFile); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !hostFile.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, hostFile)
+	}
+
+	if hostFile.Spec.RemoveOnDelete {
+		if controllerutil.AddFinalizer(hostFile, hostFileFinalizer) {
+			if err := r.Update(ctx, hostFile); err != nil {
+				return ctrl.Result{}, client.IgnoreNotFound(err)
+			}
+		}
+	} else if controllerutil.RemoveFinalizer(hostFile, hostFileFinalizer) {
+		if err := r.Update(ctx, hostFile); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	mode, err := parseFileMode(hostFile.Spec.Mode)
+	if err != nil {
+		r.recorder.Event(hostFile, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	content, err := r.content(ctx, hostFile)
+	if err != nil {
+		r.recorder.Event(hostFile, corev1.EventTypeWarning, "ContentUnavailable", err.Error())
+		logger.Error(err, "failed to resolve content")
+		return ctrl.Result{}, nil
+	}
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	hosts, err := selectHosts(ctx, r.Client, hostFile.Namespace, &hostFile.Spec.HostSelector)
+	if err != nil {
+		r.recorder.Event(hostFile, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		logger.Error(err, "failed to select hosts")
+		return ctrl.Result{}, nil
+	}
+
+	previous := make(map[string]mgmtv1alpha1.HostFileHostStatus, len(hostFile.Status.Hosts))
+	for _, hostStatus := range hostFile.Status.Hosts {
+		previous[hostStatus.Host] = hostStatus
+	}
+
+	// The previous state is only meaningful if the desired state did not change.
+	unchanged := hostFile.Status.Checksum == checksum && hostFile.Status.ObservedGeneration == hostFile.Generation
+
+	statuses := make([]mgmtv1alpha1.HostFileHostStatus, 0, len(hosts))
+	selected := make(map[string]bool, len(hosts))
+	for i := range hosts {
+		last, known := previous[hosts[i].Name]
+		statuses = append(statuses, r.syncHost(ctx, hostFile, &hosts[i], content, checksum, mode, last, known && unchanged))
+		selected[hosts[i].Name] = true
+	}
+
+	// The file is removed from hosts that no longer match the selector, as it
+	// would otherwise be kept on them even after the HostFile was deleted.
+	if hostFile.Spec.RemoveOnDelete {
+		for _, last := range hostFile.Status.Hosts {
+			if selected[last.Host] {
+				continue
+			}
+
+			if err := r.removeFromHost(ctx, hostFile, last.Host); err != nil {
+				r.recorder.Event(hostFile, corev1.EventTypeWarning, "RemoveFailed", fmt.Sprintf("Failed to remove file %s on host %s: %s", hostFile.Spec.Path, last.Host, err))
+				statuses = append(statuses, mgmtv1alpha1.HostFileHostStatus{
+					Host:  last.Host,
+					Error: fmt.Sprintf("failed to remove file from deselected host: %s", err),
+				})
+			}
+		}
+	}
+
+	inSync, outOfSync := 0, 0
+	reloadPending := false
+	for _, status := range statuses {
+		if status.InSync {
+			inSync++
+		} else {
+			outOfSync++
+		}
+		reloadPending = reloadPending || status.ReloadPending
+	}
+
+	hostFile.Status.ObservedGeneration = hostFile.Generation
+	hostFile.Status.Checksum = checksum
+	hostFile.Status.Hosts = statuses
+	hostFile.Status.InSync = inSync
+	hostFile.Status.OutOfSync = outOfSync
+	if err := r.Status().Update(ctx, hostFile); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	interval := defaultHostFileResync
+	if hostFile.Spec.Interval != nil && hostFile.Spec.Interval.Duration > 0 {
+		interval = hostFile.Spec.Interval.Duration
+	}
+	if reloadPending && interval > hostFileRetryInterval {
+		interval = hostFileRetryInterval
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// syncHost ensures that the file on a single host matches the desired state.
+func (r *HostFileReconciler) syncHost(ctx context.Context, hostFile *mgmtv1alpha1.HostFile, host *mgmtv1alpha1.Host, content []byte, checksum string, mode os.FileMode, last mgmtv1alpha1.HostFileHostStatus, known bool) mgmtv1alpha1.HostFileHostStatus {
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return mgmtv1alpha1.HostFileHostStatus{
+			Host:          host.Name,
+			Error:         err.Error(),
+			LastSyncTime:  last.LastSyncTime,
+			LastDriftTime: last.LastDriftTime,
+			ReloadPending: last.ReloadPending,
+		}
+	}
+	defer mgmt.Disconnect()
+
+	return r.syncFile(ctx, mgmt, hostFile, host.Name, content, checksum, mode, last, known)
+}
+
+// syncFile ensures that the file matches the desired state using an
+// established connection to the host. The on-change command is retried
+// until it succeeds, even if the file does not need to be written again.
+func (r *HostFileReconciler) syncFile(ctx context.Context, mgmt common.Client, hostFile *mgmtv1alpha1.HostFile, hostName string, content []byte, checksum string, mode os.FileMode, last mgmtv1alpha1.HostFileHostStatus, known bool) mgmtv1alpha1.HostFileHostStatus {
+	status := mgmtv1alpha1.HostFileHostStatus{
+		Host:          hostName,
+		LastSyncTime:  last.LastSyncTime,
+		LastDriftTime: last.LastDriftTime,
+		ReloadPending: last.ReloadPending && hostFile.Spec.OnChange != "",
+	}
+
+	path := hostFile.Spec.Path
+	var err error
+	status.Checksum, err = common.Checksum(ctx, mgmt, path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		status.Error = err.Error()
+		return status
+	}
+
+	matches := false
+	if status.Checksum == checksum {
+		matches, err = fileAttributesMatch(ctx, mgmt, path, mode, hostFile.Spec.Owner, hostFile.Spec.Group)
+		if err != nil {
+			status.Error = err.Error()
+			return status
+		}
+	}
+
+	if !matches {
+		if known && last.InSync {
+			now := metav1.Now()
+			status.LastDriftTime = &now
+			r.recorder.Event(hostFile, corev1.EventTypeWarning, "DriftDetected", fmt.Sprintf("File %s on host %s deviates from the desired state.", path, hostName))
+		}
+
+		err = mgmt.Upload(ctx, path, bytes.NewReader(content), &common.FileOptions{
+			Mode:   mode,
+			Owner:  hostFile.Spec.Owner,
+			Group:  hostFile.Spec.Group,
+			Atomic: true,
+		})
+		if err != nil {
+			status.Error = err.Error()
+			r.recorder.Event(hostFile, corev1.EventTypeWarning, "WriteFailed", fmt.Sprintf("Failed to write file %s on host %s: %s", path, hostName, err))
+			return status
+		}
+		now := metav1.Now()
+		status.LastSyncTime = &now
+		status.ReloadPending = hostFile.Spec.OnChange != ""
+		r.recorder.Event(hostFile, corev1.EventTypeNormal, "FileWritten", fmt.Sprintf("File %s written on host %s.", path, hostName))
+	}
+
+	if status.ReloadPending {
+		result, err := mgmt.Exec(ctx, &common.Command{Command: hostFile.Spec.OnChange})
+		if err == nil {
+			err = result.Err()
+		}
+		if err != nil {
+			status.Error = fmt.Sprintf("failed to run on-change command: %s", err)
+			r.recorder.Event(hostFile, corev1.EventTypeWarning, "OnChangeFailed", fmt.Sprintf("Failed to run on-change command on host %s: %s", hostName, err))
+			return status
+		}
+		status.ReloadPending = false
+	}
+
+	status.InSync = true
+	return status
+}
+
+// fileAttributesMatch checks if the mode and, if specified, the owner and
+// the group of a file match the desired state. Names are resolved to their
+// numeric IDs on the host.
+func fileAttributesMatch(ctx context.Context, mgmt common.Client, path string, mode os.FileMode, owner string, group string) (bool, error) {
+	info, err := mgmt.Stat(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	if info.Mode.Perm() != mode {
+		return false, nil
+	}
+
+	if owner != "" {
+		uid, err := lookupID(ctx, mgmt, "passwd", owner)
+		if err != nil {
+			return false, err
+		}
+		if info.UID != uid {
+			return false, nil
+		}
+	}
+
+	if group != "" {
+		gid, err := lookupID(ctx, mgmt, "group", group)
+		if err != nil {
+			return false, err
+		}
+		if info.GID != gid {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// lookupID resolves the name of a user or a group to its numeric ID via
+// the given getent database. Numeric IDs are returned as they are.
+func lookupID(ctx context.Context, mgmt common.Client, database string, name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	result, err := mgmt.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf("getent %s %s", database, common.ShellQuote(name)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := result.Err(); err != nil {
+		return 0, fmt.Errorf("failed to look up %s entry: %s: %s", database, name, err)
+	}
+
+	// Both databases contain the numeric ID in the third field.
+	fields := strings.Split(strings.TrimSpace(string(result.Stdout)), ":")
+	if len(fields) < 3 {
+		return 0, fmt.Errorf("failed to parse %s entry: %q", database, result.Stdout)
+	}
+	id, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s entry: %q", database, result.Stdout)
+	}
+
+	return id, nil
+}
+
+// reconcileDelete removes the file from the selected hosts and from the
+// hosts it was previously synced to, and releases the finalizer.
+func (r *HostFileReconciler) reconcileDelete(ctx context.Context, hostFile *mgmtv1alpha1.HostFile) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(hostFile, hostFileFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	hosts, err := selectHosts(ctx, r.Client, hostFile.Namespace, &hostFile.Spec.HostSelector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	hostNames := make([]string, 0, len(hosts)+len(hostFile.Status.Hosts))
+	for i := range hosts {
+		hostNames = append(hostNames, hosts[i].Name)
+	}
+	for _, status := range hostFile.Status.Hosts {
+		if !slices.Contains(hostNames, status.Host) {
+			hostNames = append(hostNames, status.Host)
+		}
+	}
+
+	for _, hostName := range hostNames {
+		if err := r.removeFromHost(ctx, hostFile, hostName); err != nil {
+			r.recorder.Event(hostFile, corev1.EventTypeWarning, "RemoveFailed", fmt.Sprintf("Failed to remove file %s on host %s: %s", hostFile.Spec.Path, hostName, err))
+			return ctrl.Result{}, err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(hostFile, hostFileFinalizer)
+	if err := r.Update(ctx, hostFile); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// removeFromHost removes the file of the HostFile from a host.
+// Hosts that no longer exist are skipped.
+func (r *HostFileReconciler) removeFromHost(ctx context.Context, hostFile *mgmtv1alpha1.HostFile, hostName string) error {
+	host := new(mgmtv1alpha1.Host)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: hostFile.Namespace, Name: hostName}, host); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	return r.removeFile(ctx, host, hostFile.Spec.Path)
+}
+
+// removeFile removes a file from a host.
+func (r *HostFileReconciler) removeFile(ctx context.Context, host *mgmtv1alpha1.Host, path string) error {
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return err
+	}
+	defer mgmt.Disconnect()
+
+	result, err := mgmt.Exec(ctx, &common.Command{Command: fmt.Sprintf("rm -f %s", common.ShellQuote(path))})
+	if err != nil {
+		return err
+	}
+
+	return result.Err()
+}
+
+// content resolves the desired content of the file.
+func (r *HostFileReconciler) content(ctx context.Context, hostFile *mgmtv1alpha1.HostFile) ([]byte, error) {
+	if hostFile.Spec.ContentFrom == nil {
+		return []byte(hostFile.Spec.Content), nil
+	}
+	if hostFile.Spec.Content != "" {
+		return nil, fmt.Errorf("only one of content or contentFrom may be specified")
+	}
+
+	return resolveContent(ctx, r.Client, hostFile.Namespace, hostFile.Spec.ContentFrom)
+}
+
+// parseFileMode parses octal permission bits.
+func parseFileMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return defaultHostFileMode, nil
+	}
+
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || value > 0777 {
+		return 0, fmt.Errorf("invalid file mode: %s", mode)
+	}
+
+	return os.FileMode(value), nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HostFileReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(hostFileControllerName)
+
+	// We need to add indices for the referenced ConfigMaps and Secrets so
+	// that we can trigger a reconciliation if the content changes.
+	err := mgr.GetFieldIndexer().IndexField(context.TODO(), &mgmtv1alpha1.HostFile{}, configMapField, func(rawObj client.Object) []string {
+		hostFile := rawObj.(*mgmtv1alpha1.HostFile)
+		if hostFile.Spec.ContentFrom == nil || hostFile.Spec.ContentFrom.ConfigMapKeyRef == nil {
+			return nil
+		}
+		return []string{hostFile.Spec.ContentFrom.ConfigMapKeyRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.TODO(), &mgmtv1alpha1.HostFile{}, contentSecretField, func(rawObj client.Object) []string {
+		hostFile := rawObj.(*mgmtv1alpha1.HostFile)
+		if hostFile.Spec.ContentFrom == nil || hostFile.Spec.ContentFrom.SecretKeyRef == nil {
+			return nil
+		}
+		return []string{hostFile.Spec.ContentFrom.SecretKeyRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mgmtv1alpha1.HostFile{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Watch for changes to referenced content.
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(enqueueField(r.Client, newHostFileList, configMapField))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(enqueueField(r.Client, newHostFileList, contentSecretField))).
+		// Watch for hosts that start or stop matching a selector.
+		Watches(&mgmtv1alpha1.Host{}, handler.EnqueueRequestsFromMapFunc(enqueueNamespace(r.Client, newHostFileList)), builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}))).
+		Complete(r)
+}
+
+// newHostFileList creates an empty list of HostFiles.
+func newHostFileList() client.ObjectList {
+	return &mgmtv1alpha1.HostFileList{}
+}
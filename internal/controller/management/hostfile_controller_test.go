@@ -0,0 +1,189 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+const (
+	testFilePath    = "/etc/chrony/chrony.conf"
+	testFileContent = "pool pool.ntp.org iburst\n"
+	testOnChange    = "systemctl reload chronyd"
+)
+
+// syncTestFile syncs the test file to the host with the given previous status.
+func syncTestFile(t *testing.T, host *fakeHost, spec mgmtv1alpha1.HostFileSpec, last mgmtv1alpha1.HostFileHostStatus, known bool) mgmtv1alpha1.HostFileHostStatus {
+	t.Helper()
+
+	spec.Path = testFilePath
+	hostFile := &mgmtv1alpha1.HostFile{Spec: spec}
+	mode, err := parseFileMode(spec.Mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(testFileContent))
+	r := &HostFileReconciler{recorder: record.NewFakeRecorder(100)}
+	return r.syncFile(context.Background(), host, hostFile, "node-1", []byte(testFileContent), hex.EncodeToString(sum[:]), mode, last, known)
+}
+
+func TestHostFileSyncWritesMissingFile(t *testing.T) {
+	host := newFakeHost()
+
+	status := syncTestFile(t, host, mgmtv1alpha1.HostFileSpec{OnChange: testOnChange}, mgmtv1alpha1.HostFileHostStatus{}, false)
+	if !status.InSync || status.ReloadPending || status.Error != "" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if string(host.files[testFilePath].content) != testFileContent {
+		t.Errorf("file content = %q, want %q", host.files[testFilePath].content, testFileContent)
+	}
+	if host.files[testFilePath].mode != os.FileMode(defaultHostFileMode) {
+		t.Errorf("file mode = %s, want %s", host.files[testFilePath].mode, os.FileMode(defaultHostFileMode))
+	}
+	if count := host.ran(testOnChange); count != 1 {
+		t.Errorf("on-change command ran %d times, want 1", count)
+	}
+
+	// The file is not written again and the command is not rerun if nothing changed.
+	status = syncTestFile(t, host, mgmtv1alpha1.HostFileSpec{OnChange: testOnChange}, status, true)
+	if !status.InSync || host.uploads != 1 || host.ran(testOnChange) != 1 {
+		t.Errorf("unexpected resync: status %+v, %d uploads, %d on-change runs", status, host.uploads, host.ran(testOnChange))
+	}
+}
+
+func TestHostFileSyncRetriesFailedOnChange(t *testing.T) {
+	host := newFakeHost()
+	host.exitCodes[testOnChange] = 1
+	spec := mgmtv1alpha1.HostFileSpec{OnChange: testOnChange}
+
+	status := syncTestFile(t, host, spec, mgmtv1alpha1.HostFileHostStatus{}, false)
+	if status.InSync || !status.ReloadPending || status.Error == "" {
+		t.Fatalf("expected pending reload, got: %+v", status)
+	}
+
+	// The checksum matches now, but the command must still be retried.
+	status = syncTestFile(t, host, spec, status, true)
+	if status.InSync || !status.ReloadPending {
+		t.Fatalf("expected pending reload, got: %+v", status)
+	}
+	if host.uploads != 1 {
+		t.Errorf("file was written %d times, want 1", host.uploads)
+	}
+
+	host.exitCodes[testOnChange] = 0
+	status = syncTestFile(t, host, spec, status, true)
+	if !status.InSync || status.ReloadPending || status.Error != "" {
+		t.Fatalf("expected completed reload, got: %+v", status)
+	}
+	if count := host.ran(testOnChange); count != 3 {
+		t.Errorf("on-change command ran %d times, want 3", count)
+	}
+
+	// The reload is not repeated once it succeeded.
+	syncTestFile(t, host, spec, status, true)
+	if count := host.ran(testOnChange); count != 3 {
+		t.Errorf("on-change command ran %d times, want 3", count)
+	}
+}
+
+func TestHostFileSyncDropsPendingReloadWithoutOnChange(t *testing.T) {
+	host := newFakeHost()
+	status := syncTestFile(t, host, mgmtv1alpha1.HostFileSpec{}, mgmtv1alpha1.HostFileHostStatus{ReloadPending: true}, false)
+	if !status.InSync || status.ReloadPending {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestHostFileSyncDetectsDrift(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   mgmtv1alpha1.HostFileSpec
+		mutate func(file *fakeFile)
+	}{
+		{
+			name:   "content",
+			mutate: func(file *fakeFile) { file.content = []byte("server 10.0.0.1\n") },
+		},
+		{
+			name:   "mode",
+			spec:   mgmtv1alpha1.HostFileSpec{Mode: "0600"},
+			mutate: func(file *fakeFile) { file.mode = 0644 },
+		},
+		{
+			name:   "owner by name",
+			spec:   mgmtv1alpha1.HostFileSpec{Owner: "chrony"},
+			mutate: func(file *fakeFile) { file.uid = 0 },
+		},
+		{
+			name:   "owner by ID",
+			spec:   mgmtv1alpha1.HostFileSpec{Owner: "112"},
+			mutate: func(file *fakeFile) { file.uid = 0 },
+		},
+		{
+			name:   "group",
+			spec:   mgmtv1alpha1.HostFileSpec{Group: "chrony"},
+			mutate: func(file *fakeFile) { file.gid = 0 },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host := newFakeHost()
+			host.users["chrony"] = 112
+			host.groups["chrony"] = 118
+
+			status := syncTestFile(t, host, test.spec, mgmtv1alpha1.HostFileHostStatus{}, false)
+			if !status.InSync {
+				t.Fatalf("unexpected status: %+v", status)
+			}
+
+			test.mutate(host.files[testFilePath])
+			status = syncTestFile(t, host, test.spec, status, true)
+			if !status.InSync || status.LastDriftTime == nil {
+				t.Fatalf("expected drift to be detected and restored, got: %+v", status)
+			}
+			if host.uploads != 2 {
+				t.Errorf("file was written %d times, want 2", host.uploads)
+			}
+
+			// The restored file is in sync.
+			syncTestFile(t, host, test.spec, status, true)
+			if host.uploads != 2 {
+				t.Errorf("file was written %d times, want 2", host.uploads)
+			}
+		})
+	}
+}
+
+func TestHostFileSyncReportsUnknownOwner(t *testing.T) {
+	host := newFakeHost()
+	host.files[testFilePath] = &fakeFile{content: []byte(testFileContent), mode: defaultHostFileMode}
+
+	status := syncTestFile(t, host, mgmtv1alpha1.HostFileSpec{Owner: "missing"}, mgmtv1alpha1.HostFileHostStatus{}, false)
+	if status.InSync || status.Error == "" {
+		t.Fatalf("expected error, got: %+v", status)
+	}
+}
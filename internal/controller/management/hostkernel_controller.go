@@ -0,0 +1,410 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+const (
+	hostKernelControllerName = "hostkernel-controller"
+	hostKernelFinalizer      = "management.kraut.nicklasfrahm.dev/hostkernel"
+	defaultHostKernelResync  = 10 * time.Minute
+)
+
+// HostKernelReconciler reconciles a HostKernel object
+type HostKernelReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostkernels,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostkernels/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostkernels/finalizers,verbs=update
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile ensures that the kernel parameters and the kernel modules of a
+// HostKernel are persisted and applied at runtime on the selected hosts.
+func (r *HostKernelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	hostKernel := new(mgmtv1alpha1.HostKernel)
+	if err := r.Get(ctx, req.NamespacedName, hostKernel); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !hostKernel.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, hostKernel)
+	}
+
+	if hostKernel.Spec.RemoveOnDelete {
+		if controllerutil.AddFinalizer(hostKernel, hostKernelFinalizer) {
+			if err := r.Update(ctx, hostKernel); err != nil {
+				return ctrl.Result{}, client.IgnoreNotFound(err)
+			}
+		}
+	} else if controllerutil.RemoveFinalizer(hostKernel, hostKernelFinalizer) {
+		if err := r.Update(ctx, hostKernel); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	if err := validateHostKernel(&hostKernel.Spec); err != nil {
+		r.recorder.Event(hostKernel, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	hosts, err := selectHosts(ctx, r.Client, hostKernel.Namespace, &hostKernel.Spec.HostSelector)
+	if err != nil {
+		r.recorder.Event(hostKernel, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		logger.Error(err, "failed to select hosts")
+		return ctrl.Result{}, nil
+	}
+
+	statuses := make([]mgmtv1alpha1.HostKernelHostStatus, 0, len(hosts))
+	selected := make(map[string]bool, len(hosts))
+	for i := range hosts {
+		statuses = append(statuses, r.syncHost(ctx, hostKernel, &hosts[i]))
+		selected[hosts[i].Name] = true
+	}
+
+	// The configuration is removed from hosts that no longer match the selector,
+	// as it would otherwise be kept on them even after the HostKernel was deleted.
+	if hostKernel.Spec.RemoveOnDelete {
+		for _, last := range hostKernel.Status.Hosts {
+			if selected[last.Host] {
+				continue
+			}
+
+			if err := r.removeFromHost(ctx, hostKernel, last.Host); err != nil {
+				r.recorder.Event(hostKernel, corev1.EventTypeWarning, "RemoveFailed", fmt.Sprintf("Failed to remove kernel configuration on host %s: %s", last.Host, err))
+				statuses = append(statuses, mgmtv1alpha1.HostKernelHostStatus{
+					Host:  last.Host,
+					Error: fmt.Sprintf("failed to remove kernel configuration from deselected host: %s", err),
+				})
+			}
+		}
+	}
+
+	inSync, outOfSync := 0, 0
+	for _, status := range statuses {
+		if status.InSync {
+			inSync++
+		} else {
+			outOfSync++
+		}
+	}
+
+	hostKernel.Status.ObservedGeneration = hostKernel.Generation
+	hostKernel.Status.Hosts = statuses
+	hostKernel.Status.InSync = inSync
+	hostKernel.Status.OutOfSync = outOfSync
+	if err := r.Status().Update(ctx, hostKernel); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	interval := defaultHostKernelResync
+	if hostKernel.Spec.Interval != nil && hostKernel.Spec.Interval.Duration > 0 {
+		interval = hostKernel.Spec.Interval.Duration
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// validateHostKernel checks the names of the kernel parameters and modules.
+func validateHostKernel(spec *mgmtv1alpha1.HostKernelSpec) error {
+	for key, value := range spec.Sysctl {
+		if err := system.ValidateSysctlKey(key); err != nil {
+			return err
+		}
+		if strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("value of kernel parameter %s must not contain line breaks", key)
+		}
+	}
+
+	for _, module := range spec.Modules {
+		if err := system.ValidateModuleName(module); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncHost ensures that the kernel of a single host matches the desired state.
+func (r *HostKernelReconciler) syncHost(ctx context.Context, hostKernel *mgmtv1alpha1.HostKernel, host *mgmtv1alpha1.Host) mgmtv1alpha1.HostKernelHostStatus {
+	now := metav1.Now()
+	status := mgmtv1alpha1.HostKernelHostStatus{
+		Host:         host.Name,
+		LastSyncTime: &now,
+	}
+
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer mgmt.Disconnect()
+
+	kernel, err := system.NewKernel(mgmt, host)
+	if err != nil {
+		status.Error = err.Error()
+		r.recorder.Event(hostKernel, corev1.EventTypeWarning, "UnsupportedHost", fmt.Sprintf("Refusing to configure kernel on host %s: %s", host.Name, err))
+		return status
+	}
+
+	changes, err := r.ensureKernel(ctx, kernel, hostKernel)
+	if len(changes) > 0 {
+		r.recorder.Event(hostKernel, corev1.EventTypeNormal, "KernelChanged", fmt.Sprintf("Kernel on host %s: %s", host.Name, strings.Join(changes, ", ")))
+	}
+	if err != nil {
+		status.Error = err.Error()
+		r.recorder.Event(hostKernel, corev1.EventTypeWarning, "SyncFailed", fmt.Sprintf("Failed to configure kernel on host %s: %s", host.Name, err))
+	}
+
+	modules, err := kernel.LoadedModules(ctx, hostKernel.Spec.Modules)
+	if err != nil {
+		if status.Error == "" {
+			status.Error = err.Error()
+		}
+		return status
+	}
+	status.Modules = modules
+
+	values, err := kernel.Sysctl(ctx, sysctlKeys(hostKernel.Spec.Sysctl))
+	if err != nil {
+		if status.Error == "" {
+			status.Error = err.Error()
+		}
+		return status
+	}
+	status.Sysctl = values
+
+	status.InSync = status.Error == "" && len(missingModules(hostKernel.Spec.Modules, modules)) == 0 && len(differingSysctl(hostKernel.Spec.Sysctl, values)) == 0
+
+	return status
+}
+
+// ensureKernel persists the kernel configuration and applies it at runtime.
+// The modules are loaded first, because some kernel parameters, such as
+// `net.bridge.bridge-nf-call-iptables`, are only available once their
+// module is loaded.
+func (r *HostKernelReconciler) ensureKernel(ctx context.Context, kernel *system.Kernel, hostKernel *mgmtv1alpha1.HostKernel) ([]string, error) {
+	changes := make([]string, 0)
+
+	modulesPath := system.ModulesLoadPath(hostKernel.Name)
+	changed, err := kernel.WriteModulesFile(ctx, modulesPath, hostKernel.Spec.Modules)
+	if err != nil {
+		return changes, fmt.Errorf("failed to write file: %s: %s", modulesPath, err)
+	}
+	if changed {
+		changes = append(changes, fileChange(modulesPath, len(hostKernel.Spec.Modules) > 0))
+	}
+
+	loaded, err := kernel.LoadedModules(ctx, hostKernel.Spec.Modules)
+	if err != nil {
+		return changes, err
+	}
+	if missing := missingModules(hostKernel.Spec.Modules, loaded); len(missing) > 0 {
+		if err := kernel.LoadModules(ctx, missing); err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("loaded %s", strings.Join(missing, ", ")))
+	}
+
+	sysctlPath := system.SysctlPath(hostKernel.Name)
+	changed, err = kernel.WriteSysctlFile(ctx, sysctlPath, hostKernel.Spec.Sysctl)
+	if err != nil {
+		return changes, fmt.Errorf("failed to write file: %s: %s", sysctlPath, err)
+	}
+	if changed {
+		changes = append(changes, fileChange(sysctlPath, len(hostKernel.Spec.Sysctl) > 0))
+	}
+
+	values, err := kernel.Sysctl(ctx, sysctlKeys(hostKernel.Spec.Sysctl))
+	if err != nil {
+		return changes, err
+	}
+	if differing := differingSysctl(hostKernel.Spec.Sysctl, values); len(differing) > 0 {
+		if err := kernel.SetSysctl(ctx, differing); err != nil {
+			return changes, err
+		}
+		for _, key := range sysctlKeys(differing) {
+			changes = append(changes, fmt.Sprintf("set %s=%s", key, differing[key]))
+		}
+	}
+
+	return changes, nil
+}
+
+// fileChange describes whether a file was written or removed.
+func fileChange(filePath string, written bool) string {
+	if written {
+		return fmt.Sprintf("wrote %s", filePath)
+	}
+
+	return fmt.Sprintf("removed %s", filePath)
+}
+
+// sysctlKeys returns the sorted keys of kernel parameters.
+func sysctlKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// differingSysctl returns the desired kernel parameters
+// whose effective values differ from the desired values.
+func differingSysctl(desired map[string]string, effective map[string]string) map[string]string {
+	differing := make(map[string]string)
+	for key, value := range desired {
+		if system.NormalizeSysctlValue(value) != effective[key] {
+			differing[key] = value
+		}
+	}
+
+	return differing
+}
+
+// missingModules returns the desired kernel modules that are not loaded.
+func missingModules(desired []string, loaded []string) []string {
+	missing := make([]string, 0)
+	for _, module := range desired {
+		if !slices.Contains(loaded, module) {
+			missing = append(missing, module)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// reconcileDelete removes the persisted configuration from the selected hosts
+// and from the hosts it was previously synced to, and releases the finalizer.
+func (r *HostKernelReconciler) reconcileDelete(ctx context.Context, hostKernel *mgmtv1alpha1.HostKernel) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(hostKernel, hostKernelFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	hosts, err := selectHosts(ctx, r.Client, hostKernel.Namespace, &hostKernel.Spec.HostSelector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	hostNames := make([]string, 0, len(hosts)+len(hostKernel.Status.Hosts))
+	for i := range hosts {
+		hostNames = append(hostNames, hosts[i].Name)
+	}
+	for _, status := range hostKernel.Status.Hosts {
+		if !slices.Contains(hostNames, status.Host) {
+			hostNames = append(hostNames, status.Host)
+		}
+	}
+
+	for _, hostName := range hostNames {
+		if err := r.removeFromHost(ctx, hostKernel, hostName); err != nil {
+			r.recorder.Event(hostKernel, corev1.EventTypeWarning, "RemoveFailed", fmt.Sprintf("Failed to remove kernel configuration on host %s: %s", hostName, err))
+			return ctrl.Result{}, err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(hostKernel, hostKernelFinalizer)
+	if err := r.Update(ctx, hostKernel); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// removeFromHost removes the persisted kernel configuration of the
+// HostKernel from a host. Hosts that no longer exist are skipped.
+func (r *HostKernelReconciler) removeFromHost(ctx context.Context, hostKernel *mgmtv1alpha1.HostKernel, hostName string) error {
+	host := new(mgmtv1alpha1.Host)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: hostKernel.Namespace, Name: hostName}, host); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	return r.removeFiles(ctx, host, hostKernel.Name)
+}
+
+// removeFiles removes the persisted kernel configuration from a host.
+func (r *HostKernelReconciler) removeFiles(ctx context.Context, host *mgmtv1alpha1.Host, name string) error {
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return err
+	}
+	defer mgmt.Disconnect()
+
+	kernel, err := system.NewKernel(mgmt, host)
+	if err != nil {
+		return err
+	}
+
+	for _, filePath := range []string{system.ModulesLoadPath(name), system.SysctlPath(name)} {
+		if _, err := kernel.RemoveFile(ctx, filePath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HostKernelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(hostKernelControllerName)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mgmtv1alpha1.HostKernel{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Watch for hosts that start or stop matching a selector. The discovered
+		// OS of the hosts is relevant as well, because it determines the OS family.
+		// Other status changes are ignored.
+		Watches(
+			&mgmtv1alpha1.Host{},
+			handler.EnqueueRequestsFromMapFunc(enqueueNamespace(r.Client, func() client.ObjectList { return &mgmtv1alpha1.HostKernelList{} })),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, hostDiscoveryChanged())),
+		).
+		Complete(r)
+}
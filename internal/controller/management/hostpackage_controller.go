@@ -0,0 +1,351 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+const (
+	hostPackageControllerName = "hostpackage-controller"
+	defaultHostPackageResync  = time.Hour
+)
+
+// HostPackageReconciler reconciles a HostPackage object
+type HostPackageReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostpackages,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostpackages/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostpackages/finalizers,verbs=update
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile ensures that the packages of a HostPackage are in the desired state
+// on the selected hosts using the package manager that was detected for each host.
+func (r *HostPackageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	hostPackage := new(mgmtv1alpha1.HostPackage)
+	if err := r.Get(ctx, req.NamespacedName, hostPackage); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	for _, pkg := range hostPackage.Spec.Packages {
+		if pkg.Version != "" && pkg.State != "" && pkg.State != mgmtv1alpha1.PackageStatePresent {
+			r.recorder.Event(hostPackage, corev1.EventTypeWarning, "InvalidSpec", fmt.Sprintf("Package %s: a version may only be specified with the state Present.", pkg.Name))
+			return ctrl.Result{}, nil
+		}
+	}
+
+	hosts, err := selectHosts(ctx, r.Client, hostPackage.Namespace, &hostPackage.Spec.HostSelector)
+	if err != nil {
+		r.recorder.Event(hostPackage, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		logger.Error(err, "failed to select hosts")
+		return ctrl.Result{}, nil
+	}
+
+	previous := make(map[string]mgmtv1alpha1.HostPackageHostStatus, len(hostPackage.Status.Hosts))
+	for _, hostStatus := range hostPackage.Status.Hosts {
+		previous[hostStatus.Host] = hostStatus
+	}
+
+	statuses := make([]mgmtv1alpha1.HostPackageHostStatus, len(hosts))
+	inSync, outOfSync := 0, 0
+	for i := range hosts {
+		statuses[i] = r.syncHost(ctx, hostPackage, &hosts[i], previous[hosts[i].Name])
+
+		if statuses[i].InSync {
+			inSync++
+		} else {
+			outOfSync++
+		}
+	}
+
+	hostPackage.Status.ObservedGeneration = hostPackage.Generation
+	hostPackage.Status.Hosts = statuses
+	hostPackage.Status.InSync = inSync
+	hostPackage.Status.OutOfSync = outOfSync
+	if err := r.Status().Update(ctx, hostPackage); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	interval := defaultHostPackageResync
+	if hostPackage.Spec.Interval != nil && hostPackage.Spec.Interval.Duration > 0 {
+		interval = hostPackage.Spec.Interval.Duration
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// syncHost ensures that the packages on a single host match the desired state.
+func (r *HostPackageReconciler) syncHost(ctx context.Context, hostPackage *mgmtv1alpha1.HostPackage, host *mgmtv1alpha1.Host, last mgmtv1alpha1.HostPackageHostStatus) mgmtv1alpha1.HostPackageHostStatus {
+	now := metav1.Now()
+	status := mgmtv1alpha1.HostPackageHostStatus{
+		Host:         host.Name,
+		Held:         last.Held,
+		LastSyncTime: &now,
+	}
+
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer mgmt.Disconnect()
+
+	packageManager, err := system.NewPackageManager(mgmt, host)
+	if err != nil {
+		status.Error = err.Error()
+		r.recorder.Event(hostPackage, corev1.EventTypeWarning, "UnsupportedHost", fmt.Sprintf("Refusing to manage packages on host %s: %s", host.Name, err))
+		return status
+	}
+
+	return r.syncPackages(ctx, packageManager, hostPackage, status)
+}
+
+// syncPackages ensures that the packages managed by the package manager
+// match the desired state. The status contains the packages that were held
+// by the HostPackage before, as only these holds may be released.
+func (r *HostPackageReconciler) syncPackages(ctx context.Context, packageManager system.PackageManager, hostPackage *mgmtv1alpha1.HostPackage, status mgmtv1alpha1.HostPackageHostStatus) mgmtv1alpha1.HostPackageHostStatus {
+	status.PackageManager = packageManager.Name()
+
+	names := make([]string, len(hostPackage.Spec.Packages))
+	for i, pkg := range hostPackage.Spec.Packages {
+		names[i] = pkg.Name
+	}
+
+	// Packages that are no longer part of the spec are released as well,
+	// but only if they are still installed.
+	heldByUs := make(map[string]bool, len(status.Held))
+	for _, name := range status.Held {
+		heldByUs[name] = true
+	}
+	var dropped []string
+	for _, name := range status.Held {
+		if !slices.Contains(names, name) {
+			dropped = append(dropped, name)
+		}
+	}
+
+	if err := packageManager.Refresh(ctx); err != nil {
+		status.Error = fmt.Sprintf("failed to refresh package index: %s", err)
+		return status
+	}
+
+	installed, err := packageManager.Installed(ctx, names)
+	if err != nil {
+		status.Error = fmt.Sprintf("failed to query installed packages: %s", err)
+		return status
+	}
+
+	var install []system.Package
+	var upgrade, remove, hold, unhold []string
+	if len(dropped) > 0 {
+		remaining, err := packageManager.Installed(ctx, dropped)
+		if err != nil {
+			status.Error = fmt.Sprintf("failed to query installed packages: %s", err)
+			return status
+		}
+		for _, name := range dropped {
+			if _, ok := remaining[name]; ok {
+				unhold = append(unhold, name)
+			}
+		}
+	}
+
+	for _, pkg := range hostPackage.Spec.Packages {
+		version, isInstalled := installed[pkg.Name]
+
+		// Only release holds that were placed by this resource.
+		release := heldByUs[pkg.Name] && (!pkg.Hold || pkg.State == mgmtv1alpha1.PackageStateAbsent)
+		if release && isInstalled {
+			unhold = append(unhold, pkg.Name)
+		}
+
+		switch pkg.State {
+		case mgmtv1alpha1.PackageStateAbsent:
+			if isInstalled {
+				remove = append(remove, pkg.Name)
+			}
+			continue
+		case mgmtv1alpha1.PackageStateLatest:
+			if isInstalled {
+				upgrade = append(upgrade, pkg.Name)
+			} else {
+				install = append(install, system.Package{Name: pkg.Name, Held: heldByUs[pkg.Name]})
+			}
+		default:
+			if !isInstalled || (pkg.Version != "" && pkg.Version != version) {
+				install = append(install, system.Package{Name: pkg.Name, Version: pkg.Version, Held: heldByUs[pkg.Name]})
+			}
+		}
+
+		if pkg.Hold {
+			hold = append(hold, pkg.Name)
+		}
+	}
+
+	// Held packages must be released before they can be removed or upgraded.
+	steps := []struct {
+		name string
+		fn   func() error
+	}{
+		{"unhold", func() error { return packageManager.Hold(ctx, unhold, false) }},
+		{"remove", func() error { return packageManager.Remove(ctx, remove) }},
+		{"install", func() error { return packageManager.Install(ctx, install) }},
+		{"upgrade", func() error { return packageManager.Upgrade(ctx, upgrade) }},
+		{"hold", func() error { return packageManager.Hold(ctx, hold, true) }},
+	}
+	for _, step := range steps {
+		if err := step.fn(); err != nil {
+			status.Error = fmt.Sprintf("failed to %s packages: %s", step.name, err)
+			reason := "SyncFailed"
+			if errors.Is(err, system.ErrHeld) {
+				reason = "PackagesHeld"
+			}
+			r.recorder.Event(hostPackage, corev1.EventTypeWarning, reason, fmt.Sprintf("Failed to %s packages on host %s: %s", step.name, status.Host, err))
+			break
+		}
+
+		switch step.name {
+		case "unhold":
+			// Packages that are not installed anymore do not need to be released.
+			status.Held = slices.DeleteFunc(slices.Clone(status.Held), func(name string) bool {
+				return !slices.Contains(hold, name)
+			})
+		case "hold":
+			for _, name := range hold {
+				if !slices.Contains(status.Held, name) {
+					status.Held = append(status.Held, name)
+				}
+			}
+			sort.Strings(status.Held)
+		}
+	}
+
+	previous := installed
+	installed, err = packageManager.Installed(ctx, names)
+	if err != nil {
+		status.Error = fmt.Sprintf("failed to query installed packages: %s", err)
+		return status
+	}
+	if changes := packageChanges(previous, installed); len(changes) > 0 {
+		r.recorder.Event(hostPackage, corev1.EventTypeNormal, "PackagesChanged", fmt.Sprintf("Changed packages on host %s: %s", status.Host, strings.Join(changes, ", ")))
+	}
+
+	for _, name := range names {
+		if version, ok := installed[name]; ok {
+			status.Packages = append(status.Packages, mgmtv1alpha1.InstalledPackage{Name: name, Version: version})
+		}
+	}
+
+	status.SecurityUpdates, err = packageManager.SecurityUpdates(ctx)
+	if err != nil && !errors.Is(err, system.ErrUnsupported) && status.Error == "" {
+		status.Error = fmt.Sprintf("failed to query security updates: %s", err)
+	}
+
+	status.InSync = status.Error == "" && packagesInSync(hostPackage.Spec.Packages, installed)
+
+	return status
+}
+
+// packagesInSync checks if the installed packages match the desired state.
+func packagesInSync(packages []mgmtv1alpha1.HostPackageSpecPackage, installed map[string]string) bool {
+	for _, pkg := range packages {
+		version, isInstalled := installed[pkg.Name]
+
+		if pkg.State == mgmtv1alpha1.PackageStateAbsent {
+			if isInstalled {
+				return false
+			}
+			continue
+		}
+
+		if !isInstalled || (pkg.Version != "" && pkg.Version != version) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// packageChanges describes the differences between two sets of installed packages.
+func packageChanges(previous map[string]string, current map[string]string) []string {
+	changes := make([]string, 0)
+
+	for name, version := range current {
+		previousVersion, ok := previous[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("installed %s %s", name, version))
+		case previousVersion != version:
+			changes = append(changes, fmt.Sprintf("changed %s from %s to %s", name, previousVersion, version))
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			changes = append(changes, fmt.Sprintf("removed %s", name))
+		}
+	}
+	sort.Strings(changes)
+
+	return changes
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HostPackageReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(hostPackageControllerName)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mgmtv1alpha1.HostPackage{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Watch for hosts that start or stop matching a selector. The discovered
+		// OS of the hosts is relevant as well, because it selects the package
+		// manager. Other status changes are ignored.
+		Watches(
+			&mgmtv1alpha1.Host{},
+			handler.EnqueueRequestsFromMapFunc(enqueueNamespace(r.Client, func() client.ObjectList { return &mgmtv1alpha1.HostPackageList{} })),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, hostDiscoveryChanged())),
+		).
+		Complete(r)
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+// fakePackageManager is a package manager that keeps the
+// installed and the held packages of a host in memory.
+type fakePackageManager struct {
+	installed map[string]string
+	held      map[string]bool
+}
+
+func newFakePackageManager() *fakePackageManager {
+	return &fakePackageManager{
+		installed: make(map[string]string),
+		held:      make(map[string]bool),
+	}
+}
+
+func (p *fakePackageManager) Name() mgmtv1alpha1.PackageManager {
+	return mgmtv1alpha1.PackageManagerAPT
+}
+func (p *fakePackageManager) Refresh(ctx context.Context) error    { return nil }
+func (p *fakePackageManager) UpgradeAll(ctx context.Context) error { return nil }
+
+func (p *fakePackageManager) Installed(ctx context.Context, names []string) (map[string]string, error) {
+	installed := make(map[string]string)
+	for _, name := range names {
+		if version, ok := p.installed[name]; ok {
+			installed[name] = version
+		}
+	}
+	return installed, nil
+}
+
+func (p *fakePackageManager) Install(ctx context.Context, packages []system.Package) error {
+	for _, pkg := range packages {
+		if p.held[pkg.Name] && !pkg.Held {
+			return fmt.Errorf("%w: %s", system.ErrHeld, pkg.Name)
+		}
+	}
+	for _, pkg := range packages {
+		version := pkg.Version
+		if version == "" {
+			version = "1.0"
+		}
+		p.installed[pkg.Name] = version
+	}
+	return nil
+}
+
+func (p *fakePackageManager) Upgrade(ctx context.Context, names []string) error { return nil }
+
+func (p *fakePackageManager) RebootRequired(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+func (p *fakePackageManager) Remove(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if p.held[name] {
+			return fmt.Errorf("%w: %s", system.ErrHeld, name)
+		}
+	}
+	for _, name := range names {
+		delete(p.installed, name)
+	}
+	return nil
+}
+
+func (p *fakePackageManager) Hold(ctx context.Context, names []string, hold bool) error {
+	for _, name := range names {
+		p.held[name] = hold
+	}
+	return nil
+}
+
+func (p *fakePackageManager) SecurityUpdates(ctx context.Context) ([]string, error) {
+	return nil, system.ErrUnsupported
+}
+
+// syncTestPackages syncs the packages with the given previous status.
+func syncTestPackages(t *testing.T, packageManager *fakePackageManager, packages []mgmtv1alpha1.HostPackageSpecPackage, last mgmtv1alpha1.HostPackageHostStatus) mgmtv1alpha1.HostPackageHostStatus {
+	t.Helper()
+
+	hostPackage := &mgmtv1alpha1.HostPackage{Spec: mgmtv1alpha1.HostPackageSpec{Packages: packages}}
+	r := &HostPackageReconciler{recorder: record.NewFakeRecorder(100)}
+
+	status := r.syncPackages(context.Background(), packageManager, hostPackage, mgmtv1alpha1.HostPackageHostStatus{Host: "node-1", Held: last.Held})
+	if status.Error != "" || !status.InSync {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	return status
+}
+
+func TestHostPackageSyncReleasesOwnHolds(t *testing.T) {
+	packageManager := newFakePackageManager()
+
+	status := syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "chrony", Hold: true}}, mgmtv1alpha1.HostPackageHostStatus{})
+	if !packageManager.held["chrony"] || !slices.Equal(status.Held, []string{"chrony"}) {
+		t.Fatalf("expected chrony to be held, got %v and status %v", packageManager.held, status.Held)
+	}
+
+	status = syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "chrony"}}, status)
+	if packageManager.held["chrony"] || len(status.Held) != 0 {
+		t.Fatalf("expected chrony to be released, got %v and status %v", packageManager.held, status.Held)
+	}
+}
+
+func TestHostPackageSyncKeepsForeignHolds(t *testing.T) {
+	packageManager := newFakePackageManager()
+	packageManager.installed["linux-image-generic"] = "6.8.0-45"
+	packageManager.held["linux-image-generic"] = true
+
+	status := syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "linux-image-generic"}}, mgmtv1alpha1.HostPackageHostStatus{})
+	if !packageManager.held["linux-image-generic"] || len(status.Held) != 0 {
+		t.Fatalf("expected foreign hold to be kept, got %v and status %v", packageManager.held, status.Held)
+	}
+}
+
+func TestHostPackageSyncReleasesDroppedPackages(t *testing.T) {
+	packageManager := newFakePackageManager()
+
+	status := syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "chrony", Hold: true}, {Name: "telnet", Hold: true}}, mgmtv1alpha1.HostPackageHostStatus{})
+	if !slices.Equal(status.Held, []string{"chrony", "telnet"}) {
+		t.Fatalf("unexpected held packages: %v", status.Held)
+	}
+
+	// A package that is no longer installed does not need to be released.
+	delete(packageManager.installed, "telnet")
+	status = syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "nftables"}}, status)
+	if packageManager.held["chrony"] || len(status.Held) != 0 {
+		t.Fatalf("expected dropped packages to be released, got %v and status %v", packageManager.held, status.Held)
+	}
+	if !packageManager.held["telnet"] {
+		t.Errorf("expected removed package to be left alone")
+	}
+}
+
+func TestHostPackageSyncReleasesHoldBeforeRemoval(t *testing.T) {
+	packageManager := newFakePackageManager()
+
+	status := syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "telnet", Hold: true}}, mgmtv1alpha1.HostPackageHostStatus{})
+	status = syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "telnet", State: mgmtv1alpha1.PackageStateAbsent, Hold: true}}, status)
+	if packageManager.held["telnet"] || len(status.Held) != 0 {
+		t.Fatalf("expected telnet to be released, got %v and status %v", packageManager.held, status.Held)
+	}
+	if _, ok := packageManager.installed["telnet"]; ok {
+		t.Errorf("expected telnet to be removed")
+	}
+}
+
+func TestHostPackageSyncChangesOwnHolds(t *testing.T) {
+	packageManager := newFakePackageManager()
+
+	status := syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "chrony", Version: "4.2", Hold: true}}, mgmtv1alpha1.HostPackageHostStatus{})
+	status = syncTestPackages(t, packageManager, []mgmtv1alpha1.HostPackageSpecPackage{{Name: "chrony", Version: "4.5", Hold: true}}, status)
+	if packageManager.installed["chrony"] != "4.5" || !packageManager.held["chrony"] || !slices.Equal(status.Held, []string{"chrony"}) {
+		t.Fatalf("expected chrony 4.5 to be held, got %v, %v and status %v", packageManager.installed, packageManager.held, status.Held)
+	}
+}
+
+func TestHostPackageSyncRefusesForeignHolds(t *testing.T) {
+	packageManager := newFakePackageManager()
+	packageManager.installed["linux-image-generic"] = "6.8.0-45"
+	packageManager.held["linux-image-generic"] = true
+
+	tests := []struct {
+		name    string
+		pkg     mgmtv1alpha1.HostPackageSpecPackage
+		wantErr string
+	}{
+		{
+			name:    "install",
+			pkg:     mgmtv1alpha1.HostPackageSpecPackage{Name: "linux-image-generic", Version: "6.8.0-47"},
+			wantErr: "failed to install packages: packages are held outside of kraut: linux-image-generic",
+		},
+		{
+			name:    "remove",
+			pkg:     mgmtv1alpha1.HostPackageSpecPackage{Name: "linux-image-generic", State: mgmtv1alpha1.PackageStateAbsent},
+			wantErr: "failed to remove packages: packages are held outside of kraut: linux-image-generic",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(100)
+			r := &HostPackageReconciler{recorder: recorder}
+			hostPackage := &mgmtv1alpha1.HostPackage{Spec: mgmtv1alpha1.HostPackageSpec{Packages: []mgmtv1alpha1.HostPackageSpecPackage{test.pkg}}}
+
+			status := r.syncPackages(context.Background(), packageManager, hostPackage, mgmtv1alpha1.HostPackageHostStatus{Host: "node-1"})
+			if status.Error != test.wantErr || status.InSync {
+				t.Fatalf("syncPackages() error = %q, want %q", status.Error, test.wantErr)
+			}
+			if packageManager.installed["linux-image-generic"] != "6.8.0-45" || !packageManager.held["linux-image-generic"] {
+				t.Errorf("expected foreign hold to be kept, got %v and %v", packageManager.installed, packageManager.held)
+			}
+			if event := <-recorder.Events; !strings.Contains(event, "PackagesHeld") {
+				t.Errorf("unexpected event: %s", event)
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+const (
+	hostServiceControllerName = "hostservice-controller"
+	hostServiceFinalizer      = "management.kraut.nicklasfrahm.dev/hostservice"
+	defaultHostServiceResync  = 5 * time.Minute
+	// hostServiceDropIn is the name of the drop-in file managed by the controller.
+	hostServiceDropIn = "50-kraut.conf"
+	// journalLines is the number of journal lines that are recorded for failed units.
+	journalLines = 20
+)
+
+// HostServiceReconciler reconciles a HostService object
+type HostServiceReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostservices,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostservices/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostservices/finalizers,verbs=update
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile ensures that the systemd unit of a HostService
+// is in the desired state on the selected hosts.
+func (r *HostServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	hostService := new(mgmtv1alpha1.HostService)
+	if err := r.Get(ctx, req.NamespacedName, hostService); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !hostService.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, hostService)
+	}
+
+	// The finalizer is always added, because only the unit
+	// files that were written by the HostService are removed.
+	if controllerutil.AddFinalizer(hostService, hostServiceFinalizer) {
+		if err := r.Update(ctx, hostService); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	if err := system.ValidateUnitName(hostService.Spec.Unit); err != nil {
+		r.recorder.Event(hostService, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	hosts, err := selectHosts(ctx, r.Client, hostService.Namespace, &hostService.Spec.HostSelector)
+	if err != nil {
+		r.recorder.Event(hostService, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		logger.Error(err, "failed to select hosts")
+		return ctrl.Result{}, nil
+	}
+
+	previous := make(map[string]mgmtv1alpha1.HostServiceHostStatus, len(hostService.Status.Hosts))
+	for _, hostStatus := range hostService.Status.Hosts {
+		previous[hostStatus.Host] = hostStatus
+	}
+
+	statuses := make([]mgmtv1alpha1.HostServiceHostStatus, 0, len(hosts))
+	selected := make(map[string]bool, len(hosts))
+	for i := range hosts {
+		statuses = append(statuses, r.syncHost(ctx, hostService, &hosts[i], previous[hosts[i].Name]))
+		selected[hosts[i].Name] = true
+	}
+
+	// The unit files are removed from hosts that no longer match the selector, as
+	// they would otherwise be kept on them even after the HostService was deleted.
+	for _, last := range hostService.Status.Hosts {
+		if selected[last.Host] || len(last.ManagedFiles) == 0 {
+			continue
+		}
+
+		remaining, err := r.removeFromHost(ctx, hostService, last.Host, last.ManagedFiles)
+		if err != nil {
+			r.recorder.Event(hostService, corev1.EventTypeWarning, "RemoveFailed", fmt.Sprintf("Failed to remove unit files of %s on host %s: %s", hostService.Spec.Unit, last.Host, err))
+			statuses = append(statuses, mgmtv1alpha1.HostServiceHostStatus{
+				Host:         last.Host,
+				ManagedFiles: remaining,
+				Error:        fmt.Sprintf("failed to remove unit files from deselected host: %s", err),
+			})
+		}
+	}
+
+	inSync, outOfSync := 0, 0
+	for _, status := range statuses {
+		if status.InSync {
+			inSync++
+		} else {
+			outOfSync++
+		}
+	}
+
+	hostService.Status.ObservedGeneration = hostService.Generation
+	hostService.Status.Hosts = statuses
+	hostService.Status.InSync = inSync
+	hostService.Status.OutOfSync = outOfSync
+	if err := r.Status().Update(ctx, hostService); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	interval := defaultHostServiceResync
+	if hostService.Spec.Interval != nil && hostService.Spec.Interval.Duration > 0 {
+		interval = hostService.Spec.Interval.Duration
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// syncHost ensures that the unit on a single host matches the desired state.
+func (r *HostServiceReconciler) syncHost(ctx context.Context, hostService *mgmtv1alpha1.HostService, host *mgmtv1alpha1.Host, last mgmtv1alpha1.HostServiceHostStatus) mgmtv1alpha1.HostServiceHostStatus {
+	now := metav1.Now()
+	status := mgmtv1alpha1.HostServiceHostStatus{
+		Host:         host.Name,
+		ManagedFiles: last.ManagedFiles,
+		LastSyncTime: &now,
+	}
+
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer mgmt.Disconnect()
+
+	systemd, err := system.NewSystemd(mgmt, host)
+	if err != nil {
+		status.Error = err.Error()
+		r.recorder.Event(hostService, corev1.EventTypeWarning, "UnsupportedHost", fmt.Sprintf("Refusing to manage unit on host %s: %s", host.Name, err))
+		return status
+	}
+
+	unit := hostService.Spec.Unit
+	changes, err := r.ensureUnit(ctx, systemd, hostService, &status)
+	if len(changes) > 0 {
+		r.recorder.Event(hostService, corev1.EventTypeNormal, "UnitChanged", fmt.Sprintf("Unit %s on host %s: %s", unit, host.Name, strings.Join(changes, ", ")))
+	}
+	if err != nil {
+		status.Error = err.Error()
+		r.recorder.Event(hostService, corev1.EventTypeWarning, "SyncFailed", fmt.Sprintf("Failed to sync unit %s on host %s: %s", unit, host.Name, err))
+	}
+
+	unitStatus, err := systemd.Status(ctx, unit)
+	if err != nil {
+		if status.Error == "" {
+			status.Error = err.Error()
+		}
+		return status
+	}
+	status.ActiveState = unitStatus.ActiveState
+	status.SubState = unitStatus.SubState
+	status.UnitFileState = unitStatus.UnitFileState
+	status.MainPID = unitStatus.MainPID
+
+	started := hostService.Spec.State == mgmtv1alpha1.ServiceStateStarted
+	if unitStatus.ActiveState == "failed" || (started && !unitStatus.Active()) {
+		if journal, err := systemd.Journal(ctx, unit, journalLines); err == nil {
+			status.Journal = truncateOutput([]byte(journal))
+		}
+	}
+
+	status.InSync = status.Error == "" && unitInSync(&hostService.Spec, unitStatus)
+
+	return status
+}
+
+// ensureUnit writes the unit files and brings the unit into the desired state.
+// The status contains the files that were previously written to the host and
+// is updated with the files that are managed afterwards.
+func (r *HostServiceReconciler) ensureUnit(ctx context.Context, systemd *system.Systemd, hostService *mgmtv1alpha1.HostService, status *mgmtv1alpha1.HostServiceHostStatus) ([]string, error) {
+	unit := hostService.Spec.Unit
+	changes := make([]string, 0)
+
+	files := []struct {
+		path    string
+		content string
+	}{
+		{system.UnitPath(unit), hostService.Spec.UnitFile},
+		{system.DropInPath(unit, hostServiceDropIn), hostService.Spec.DropIn},
+	}
+	desired := make([]string, 0, len(files))
+	filesChanged := false
+	for _, file := range files {
+		if file.content == "" {
+			continue
+		}
+		desired = append(desired, file.path)
+
+		changed, err := systemd.WriteUnitFile(ctx, file.path, []byte(file.content))
+		if err != nil {
+			return changes, fmt.Errorf("failed to write unit file: %s: %s", file.path, err)
+		}
+		if !slices.Contains(status.ManagedFiles, file.path) {
+			status.ManagedFiles = append(slices.Clone(status.ManagedFiles), file.path)
+			slices.Sort(status.ManagedFiles)
+		}
+		if changed {
+			filesChanged = true
+			changes = append(changes, fmt.Sprintf("wrote %s", file.path))
+		}
+	}
+
+	// Remove the files that were written previously, but are no longer part
+	// of the spec. Files that were not written by the controller are kept.
+	for _, path := range slices.Clone(status.ManagedFiles) {
+		if slices.Contains(desired, path) {
+			continue
+		}
+
+		removed, err := systemd.RemoveUnitFile(ctx, path)
+		if err != nil {
+			return changes, fmt.Errorf("failed to remove unit file: %s: %s", path, err)
+		}
+		status.ManagedFiles = slices.DeleteFunc(slices.Clone(status.ManagedFiles), func(managed string) bool {
+			return managed == path
+		})
+		if removed {
+			filesChanged = true
+			changes = append(changes, fmt.Sprintf("removed %s", path))
+		}
+	}
+
+	if filesChanged {
+		if err := systemd.DaemonReload(ctx); err != nil {
+			return changes, fmt.Errorf("failed to reload systemd: %s", err)
+		}
+	}
+
+	opts := &system.UnitOptions{
+		Enabled: hostService.Spec.Enabled,
+	}
+	switch hostService.Spec.State {
+	case mgmtv1alpha1.ServiceStateStarted:
+		active := true
+		opts.Active = &active
+	case mgmtv1alpha1.ServiceStateStopped:
+		active := false
+		opts.Active = &active
+	}
+
+	unitChanges, err := systemd.Ensure(ctx, unit, opts)
+	changes = append(changes, unitChanges...)
+	if err != nil {
+		return changes, err
+	}
+
+	// A running unit must be restarted to apply the changed unit files,
+	// unless it was just started with the changed unit files.
+	if filesChanged && !slices.Contains(unitChanges, "started") {
+		unitStatus, err := systemd.Status(ctx, unit)
+		if err != nil {
+			return changes, err
+		}
+		if unitStatus.Active() {
+			if err := systemd.Restart(ctx, unit); err != nil {
+				return changes, fmt.Errorf("failed to restart unit: %s: %s", unit, err)
+			}
+			changes = append(changes, "restarted")
+		}
+	}
+
+	return changes, nil
+}
+
+// reconcileDelete removes the unit files from the hosts they were written
+// to and releases the finalizer. The state of the unit is not changed.
+func (r *HostServiceReconciler) reconcileDelete(ctx context.Context, hostService *mgmtv1alpha1.HostService) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(hostService, hostServiceFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	for _, last := range hostService.Status.Hosts {
+		if len(last.ManagedFiles) == 0 {
+			continue
+		}
+
+		if _, err := r.removeFromHost(ctx, hostService, last.Host, last.ManagedFiles); err != nil {
+			r.recorder.Event(hostService, corev1.EventTypeWarning, "RemoveFailed", fmt.Sprintf("Failed to remove unit files of %s on host %s: %s", hostService.Spec.Unit, last.Host, err))
+			return ctrl.Result{}, err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(hostService, hostServiceFinalizer)
+	if err := r.Update(ctx, hostService); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// removeFromHost removes the unit files that were written to a host. It
+// returns the files that are still managed. Hosts that no longer exist are skipped.
+func (r *HostServiceReconciler) removeFromHost(ctx context.Context, hostService *mgmtv1alpha1.HostService, hostName string, managedFiles []string) ([]string, error) {
+	host := new(mgmtv1alpha1.Host)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: hostService.Namespace, Name: hostName}, host); err != nil {
+		return managedFiles, client.IgnoreNotFound(err)
+	}
+
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return managedFiles, err
+	}
+	defer mgmt.Disconnect()
+
+	systemd, err := system.NewSystemd(mgmt, host)
+	if err != nil {
+		return managedFiles, err
+	}
+
+	return removeUnitFiles(ctx, systemd, managedFiles)
+}
+
+// removeUnitFiles removes unit files and reloads systemd. It returns the
+// files that are still managed, which are kept until systemd was reloaded,
+// so that a failed reload is retried.
+func removeUnitFiles(ctx context.Context, systemd *system.Systemd, managedFiles []string) ([]string, error) {
+	for _, path := range managedFiles {
+		if _, err := systemd.RemoveUnitFile(ctx, path); err != nil {
+			return managedFiles, fmt.Errorf("failed to remove unit file: %s: %s", path, err)
+		}
+	}
+
+	if err := systemd.DaemonReload(ctx); err != nil {
+		return managedFiles, fmt.Errorf("failed to reload systemd: %s", err)
+	}
+
+	return nil, nil
+}
+
+// unitInSync checks if the state of the unit matches the desired state.
+func unitInSync(spec *mgmtv1alpha1.HostServiceSpec, unitStatus *system.UnitStatus) bool {
+	if spec.Enabled != nil && unitStatus.Switchable() && *spec.Enabled != unitStatus.Enabled() {
+		return false
+	}
+
+	switch spec.State {
+	case mgmtv1alpha1.ServiceStateStarted:
+		return unitStatus.Active()
+	case mgmtv1alpha1.ServiceStateStopped:
+		return !unitStatus.Active()
+	}
+
+	return true
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HostServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(hostServiceControllerName)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mgmtv1alpha1.HostService{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Watch for hosts that start or stop matching a selector. The discovered
+		// init system of the hosts is relevant as well. Other status changes
+		// are ignored.
+		Watches(
+			&mgmtv1alpha1.Host{},
+			handler.EnqueueRequestsFromMapFunc(enqueueNamespace(r.Client, func() client.ObjectList { return &mgmtv1alpha1.HostServiceList{} })),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, hostDiscoveryChanged())),
+		).
+		Complete(r)
+}
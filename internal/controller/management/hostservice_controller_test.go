@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+const (
+	testUnit       = "node-exporter.service"
+	testUnitFile   = "[Service]\nExecStart=/usr/local/bin/node_exporter\n"
+	testUnitDropIn = "[Service]\nEnvironment=GOMAXPROCS=1\n"
+)
+
+// ensureTestUnit ensures the test unit on the host with the given previous status.
+func ensureTestUnit(t *testing.T, host *fakeHost, spec mgmtv1alpha1.HostServiceSpec, last mgmtv1alpha1.HostServiceHostStatus) mgmtv1alpha1.HostServiceHostStatus {
+	t.Helper()
+
+	spec.Unit = testUnit
+	hostService := &mgmtv1alpha1.HostService{Spec: spec}
+	systemd, err := system.NewSystemd(host, &mgmtv1alpha1.Host{Status: mgmtv1alpha1.HostStatus{
+		Capabilities: mgmtv1alpha1.HostCapabilities{InitSystem: mgmtv1alpha1.InitSystemSystemd},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := mgmtv1alpha1.HostServiceHostStatus{Host: "node-1", ManagedFiles: last.ManagedFiles}
+	r := &HostServiceReconciler{recorder: record.NewFakeRecorder(100)}
+	if _, err := r.ensureUnit(context.Background(), systemd, hostService, &status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestHostServiceRemovesStaleUnitFiles(t *testing.T) {
+	host := newFakeHost()
+	unitPath := system.UnitPath(testUnit)
+	dropInPath := system.DropInPath(testUnit, hostServiceDropIn)
+
+	status := ensureTestUnit(t, host, mgmtv1alpha1.HostServiceSpec{UnitFile: testUnitFile, DropIn: testUnitDropIn}, mgmtv1alpha1.HostServiceHostStatus{})
+	if want := []string{unitPath, dropInPath}; !slices.Equal(status.ManagedFiles, want) {
+		t.Fatalf("managed files = %v, want %v", status.ManagedFiles, want)
+	}
+	if host.files[unitPath] == nil || host.files[dropInPath] == nil {
+		t.Fatalf("expected unit files to be written")
+	}
+
+	status = ensureTestUnit(t, host, mgmtv1alpha1.HostServiceSpec{UnitFile: testUnitFile}, status)
+	if want := []string{unitPath}; !slices.Equal(status.ManagedFiles, want) {
+		t.Fatalf("managed files = %v, want %v", status.ManagedFiles, want)
+	}
+	if host.files[dropInPath] != nil {
+		t.Errorf("expected drop-in file to be removed")
+	}
+	if host.files[unitPath] == nil {
+		t.Errorf("expected unit file to be kept")
+	}
+	if count := host.ran("systemctl daemon-reload"); count != 2 {
+		t.Errorf("systemd was reloaded %d times, want 2", count)
+	}
+
+	// Nothing changes if the spec is unchanged.
+	ensureTestUnit(t, host, mgmtv1alpha1.HostServiceSpec{UnitFile: testUnitFile}, status)
+	if count := host.ran("systemctl daemon-reload"); count != 2 {
+		t.Errorf("systemd was reloaded %d times, want 2", count)
+	}
+}
+
+func TestHostServiceKeepsForeignUnitFiles(t *testing.T) {
+	host := newFakeHost()
+	unitPath := system.UnitPath(testUnit)
+	host.files[unitPath] = &fakeFile{content: []byte(testUnitFile), mode: 0644}
+
+	status := ensureTestUnit(t, host, mgmtv1alpha1.HostServiceSpec{DropIn: testUnitDropIn}, mgmtv1alpha1.HostServiceHostStatus{})
+	if want := []string{system.DropInPath(testUnit, hostServiceDropIn)}; !slices.Equal(status.ManagedFiles, want) {
+		t.Fatalf("managed files = %v, want %v", status.ManagedFiles, want)
+	}
+
+	status = ensureTestUnit(t, host, mgmtv1alpha1.HostServiceSpec{}, status)
+	if len(status.ManagedFiles) != 0 {
+		t.Fatalf("managed files = %v, want none", status.ManagedFiles)
+	}
+	if host.files[unitPath] == nil {
+		t.Errorf("expected unit file that was not written by the controller to be kept")
+	}
+}
+
+func TestHostServiceRemovesManagedUnitFiles(t *testing.T) {
+	host := newFakeHost()
+	unitPath := system.UnitPath(testUnit)
+	dropInPath := system.DropInPath(testUnit, hostServiceDropIn)
+	foreignPath := system.DropInPath(testUnit, "10-vendor.conf")
+	host.files[foreignPath] = &fakeFile{content: []byte(testUnitDropIn), mode: 0644}
+
+	status := ensureTestUnit(t, host, mgmtv1alpha1.HostServiceSpec{UnitFile: testUnitFile, DropIn: testUnitDropIn}, mgmtv1alpha1.HostServiceHostStatus{})
+	systemd, err := system.NewSystemd(host, &mgmtv1alpha1.Host{Status: mgmtv1alpha1.HostStatus{
+		Capabilities: mgmtv1alpha1.HostCapabilities{InitSystem: mgmtv1alpha1.InitSystemSystemd},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The files are kept in the status until systemd was reloaded.
+	host.exitCodes["systemctl daemon-reload"] = 1
+	remaining, err := removeUnitFiles(context.Background(), systemd, status.ManagedFiles)
+	if err == nil {
+		t.Fatal("expected failed reload to be reported")
+	}
+	if !slices.Equal(remaining, status.ManagedFiles) {
+		t.Errorf("managed files = %v, want %v", remaining, status.ManagedFiles)
+	}
+
+	delete(host.exitCodes, "systemctl daemon-reload")
+	reloads := host.ran("systemctl daemon-reload")
+	remaining, err = removeUnitFiles(context.Background(), systemd, remaining)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("managed files = %v, want none", remaining)
+	}
+	if host.files[unitPath] != nil || host.files[dropInPath] != nil {
+		t.Error("expected managed unit files to be removed")
+	}
+	if host.files[foreignPath] == nil {
+		t.Error("expected unit file that was not written by the controller to be kept")
+	}
+	if count := host.ran("systemctl daemon-reload") - reloads; count != 1 {
+		t.Errorf("systemd was reloaded %d times, want 1", count)
+	}
+}
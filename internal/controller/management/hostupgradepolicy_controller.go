@@ -0,0 +1,676 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/cron"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+const (
+	hostUpgradePolicyControllerName = "hostupgradepolicy-controller"
+	// hostUpgradeInterval is the interval at which the progress of a rollout is checked.
+	hostUpgradeInterval = 30 * time.Second
+	// defaultHostUpgradeTimeout is the default duration for a host to
+	// come back after a reboot and to pass the post-check.
+	defaultHostUpgradeTimeout = 15 * time.Minute
+)
+
+// HostUpgradePolicyReconciler reconciles a HostUpgradePolicy object
+type HostUpgradePolicyReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostupgradepolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostupgradepolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostupgradepolicies/finalizers,verbs=update
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostactions,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile rolls out package upgrades to the selected hosts in batches. A
+// rollout is started once for every generation of the spec or whenever its
+// schedule is due. If the upgrade of a host fails, the rollout is paused
+// until it is retried via an annotation.
+func (r *HostUpgradePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	policy := new(mgmtv1alpha1.HostUpgradePolicy)
+	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if _, ok := policy.Annotations[mgmtv1alpha1.HostUpgradePolicyAnnotationRetry]; ok {
+		if err := r.retry(ctx, policy); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	var schedule *cron.Schedule
+	if policy.Spec.Schedule != "" {
+		var err error
+		schedule, err = cron.Parse(policy.Spec.Schedule)
+		if err != nil {
+			r.recorder.Event(policy, corev1.EventTypeWarning, "InvalidSchedule", err.Error())
+			return ctrl.Result{}, nil
+		}
+	}
+
+	phase := policy.Status.Phase
+	if phase != mgmtv1alpha1.HostUpgradePolicyPhaseRunning && phase != mgmtv1alpha1.HostUpgradePolicyPhasePaused {
+		requeueAfter, due, err := r.due(ctx, policy, schedule)
+		if !due || err != nil {
+			return ctrl.Result{RequeueAfter: requeueAfter}, client.IgnoreNotFound(err)
+		}
+
+		hosts, err := selectHosts(ctx, r.Client, policy.Namespace, &policy.Spec.HostSelector)
+		if err != nil {
+			r.recorder.Event(policy, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+			logger.Error(err, "failed to select hosts")
+			return ctrl.Result{}, nil
+		}
+
+		startTime := metav1.Now()
+		policy.Status = mgmtv1alpha1.HostUpgradePolicyStatus{
+			ObservedGeneration: policy.Generation,
+			Phase:              mgmtv1alpha1.HostUpgradePolicyPhaseRunning,
+			LastRunTime:        &startTime,
+			Hosts:              make([]mgmtv1alpha1.HostUpgradeHostStatus, len(hosts)),
+		}
+		for i, host := range hosts {
+			policy.Status.Hosts[i] = mgmtv1alpha1.HostUpgradeHostStatus{
+				Host:  host.Name,
+				Phase: mgmtv1alpha1.HostUpgradePhasePending,
+			}
+		}
+		if err := r.Status().Update(ctx, policy); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+
+		r.recorder.Event(policy, corev1.EventTypeNormal, "RolloutStarted", fmt.Sprintf("Starting upgrade of %d hosts.", len(hosts)))
+	}
+
+	// The progress is persisted via patches, because upgrades may take long
+	// and must be recorded even if the policy was changed in the meantime.
+	base := policy.DeepCopy()
+
+	maxUnavailable, err := r.maxUnavailable(policy)
+	if err != nil {
+		r.recorder.Event(policy, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	// Hosts that are busy with a HostAction or another rollout are not
+	// rebooted. The hosts in progress cannot become busy in the meantime,
+	// because they are busy for the others.
+	busy, err := busyHosts(ctx, r.Client, policy.Namespace, rolloutHosts(policy), policy)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Hosts that are in progress are advanced first, so
+	// that their slots are available for pending hosts.
+	r.advance(ctx, policy, func(status *mgmtv1alpha1.HostUpgradeHostStatus) bool {
+		return upgradeInProgress(status)
+	})
+
+	if policy.Status.Phase == mgmtv1alpha1.HostUpgradePolicyPhaseRunning && !upgradeFailed(policy) {
+		// Pending hosts may have become busy while the hosts in progress were
+		// advanced, so they are checked again before they are started.
+		available := maxUnavailable
+		pendingBusy, err := busyHosts(ctx, r.Client, policy.Namespace, rolloutHosts(policy), policy)
+		if err != nil {
+			logger.Error(err, "failed to check for busy hosts")
+			available = 0
+		}
+		for i := range policy.Status.Hosts {
+			if upgradeInProgress(&policy.Status.Hosts[i]) || pendingBusy[policy.Status.Hosts[i].Host] {
+				available--
+			}
+		}
+
+		// The hosts are persisted as upgrading before they are upgraded,
+		// so that the batch is visible while the upgrades are running.
+		started := make(map[string]bool)
+		for i := range policy.Status.Hosts {
+			status := &policy.Status.Hosts[i]
+			if available <= 0 {
+				break
+			}
+			if status.Phase != mgmtv1alpha1.HostUpgradePhasePending || pendingBusy[status.Host] {
+				continue
+			}
+
+			now := metav1.Now()
+			status.Phase = mgmtv1alpha1.HostUpgradePhaseUpgrading
+			status.StartTime = &now
+			status.Message = "Waiting for the upgrade to start."
+			available--
+			started[status.Host] = true
+		}
+		if len(started) > 0 {
+			if err := r.Status().Patch(ctx, policy, client.MergeFrom(base)); err != nil {
+				return ctrl.Result{}, client.IgnoreNotFound(err)
+			}
+			base = policy.DeepCopy()
+
+			r.advance(ctx, policy, func(status *mgmtv1alpha1.HostUpgradeHostStatus) bool {
+				return started[status.Host]
+			})
+		}
+	}
+
+	if err := r.rebootPending(ctx, policy, base, busy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Pending hosts of a paused rollout are not upgraded until it is retried.
+	failed := upgradeFailed(policy)
+	policy.Status.Upgraded = 0
+	policy.Status.Failed = 0
+	done := true
+	for i := range policy.Status.Hosts {
+		status := &policy.Status.Hosts[i]
+		switch {
+		case status.Phase == mgmtv1alpha1.HostUpgradePhaseSucceeded:
+			policy.Status.Upgraded++
+		case status.Phase == mgmtv1alpha1.HostUpgradePhaseFailed:
+			policy.Status.Failed++
+		case upgradeInProgress(status), status.Phase == mgmtv1alpha1.HostUpgradePhasePending && !failed:
+			done = false
+		}
+	}
+
+	paused := failed && policy.Status.Phase != mgmtv1alpha1.HostUpgradePolicyPhasePaused
+	if failed {
+		policy.Status.Phase = mgmtv1alpha1.HostUpgradePolicyPhasePaused
+	}
+
+	succeeded := done && !failed
+	result := ctrl.Result{}
+	if succeeded {
+		completionTime := metav1.Now()
+		policy.Status.Phase = mgmtv1alpha1.HostUpgradePolicyPhaseSucceeded
+		policy.Status.CompletionTime = &completionTime
+		if schedule != nil && !policy.Spec.Suspend {
+			if next := schedule.Next(policy.Status.LastRunTime.Time); !next.IsZero() {
+				policy.Status.NextRunTime = &metav1.Time{Time: next}
+				result.RequeueAfter = time.Until(next)
+			}
+		}
+	} else if !done {
+		result.RequeueAfter = hostUpgradeInterval
+	}
+
+	if err := r.Status().Patch(ctx, policy, client.MergeFrom(base)); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if paused {
+		r.recorder.Event(policy, corev1.EventTypeWarning, "RolloutPaused", fmt.Sprintf("Upgrade failed on %d hosts. Annotate with %s to retry.", policy.Status.Failed, mgmtv1alpha1.HostUpgradePolicyAnnotationRetry))
+	}
+	if succeeded {
+		r.recorder.Event(policy, corev1.EventTypeNormal, "RolloutSucceeded", fmt.Sprintf("Upgraded %d hosts.", policy.Status.Upgraded))
+	}
+
+	return result, nil
+}
+
+// due checks if a rollout is due. Without a schedule, a rollout is due once for
+// every generation of the spec. Missed scheduled rollouts are not caught up on.
+func (r *HostUpgradePolicyReconciler) due(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, schedule *cron.Schedule) (time.Duration, bool, error) {
+	if schedule == nil {
+		return 0, policy.Status.LastRunTime == nil || policy.Status.ObservedGeneration != policy.Generation, nil
+	}
+	if policy.Spec.Suspend {
+		return 0, false, nil
+	}
+
+	lastRun := policy.CreationTimestamp.Time
+	if policy.Status.LastRunTime != nil {
+		lastRun = policy.Status.LastRunTime.Time
+	}
+	next := schedule.Next(lastRun)
+	if next.IsZero() {
+		return 0, false, nil
+	}
+
+	now := time.Now()
+	if !now.Before(next) {
+		return 0, true, nil
+	}
+
+	if policy.Status.NextRunTime == nil || !policy.Status.NextRunTime.Time.Equal(next) {
+		policy.Status.NextRunTime = &metav1.Time{Time: next}
+		if err := r.Status().Update(ctx, policy); err != nil {
+			return 0, false, err
+		}
+	}
+
+	return next.Sub(now), false, nil
+}
+
+// retry removes the retry annotation and resets the failed hosts of a paused
+// rollout, which resumes it. The rollout adopts the current spec, so that a
+// fixed spec does not start another rollout once it has completed.
+func (r *HostUpgradePolicyReconciler) retry(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy) error {
+	patch := client.MergeFrom(policy.DeepCopy())
+	delete(policy.Annotations, mgmtv1alpha1.HostUpgradePolicyAnnotationRetry)
+	if err := r.Patch(ctx, policy, patch); err != nil {
+		return err
+	}
+
+	if policy.Status.Phase != mgmtv1alpha1.HostUpgradePolicyPhasePaused {
+		return nil
+	}
+
+	for i, status := range policy.Status.Hosts {
+		if status.Phase == mgmtv1alpha1.HostUpgradePhaseFailed {
+			policy.Status.Hosts[i] = mgmtv1alpha1.HostUpgradeHostStatus{
+				Host:  status.Host,
+				Phase: mgmtv1alpha1.HostUpgradePhasePending,
+			}
+		}
+	}
+	policy.Status.Phase = mgmtv1alpha1.HostUpgradePolicyPhaseRunning
+	policy.Status.ObservedGeneration = policy.Generation
+	policy.Status.CompletionTime = nil
+	if err := r.Status().Update(ctx, policy); err != nil {
+		return err
+	}
+
+	r.recorder.Event(policy, corev1.EventTypeNormal, "RolloutResumed", "Retrying the upgrade of the failed hosts.")
+
+	return nil
+}
+
+// maxUnavailable returns the number of hosts that may be upgraded at the same time.
+func (r *HostUpgradePolicyReconciler) maxUnavailable(policy *mgmtv1alpha1.HostUpgradePolicy) (int, error) {
+	value := intstr.FromInt(1)
+	if policy.Spec.MaxUnavailable != nil {
+		value = *policy.Spec.MaxUnavailable
+	}
+
+	maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(&value, len(policy.Status.Hosts), false)
+	if err != nil {
+		return 0, fmt.Errorf("invalid maxUnavailable: %s", err)
+	}
+
+	return max(maxUnavailable, 1), nil
+}
+
+// advance advances the upgrade of the matching hosts concurrently.
+func (r *HostUpgradePolicyReconciler) advance(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, match func(*mgmtv1alpha1.HostUpgradeHostStatus) bool) {
+	var wg sync.WaitGroup
+	for i := range policy.Status.Hosts {
+		status := &policy.Status.Hosts[i]
+		if !match(status) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.advanceHost(ctx, policy, status)
+		}()
+	}
+	wg.Wait()
+}
+
+// advanceHost advances the upgrade of a host until it has to wait for the
+// host, e.g. to reboot, or until the upgrade succeeded or failed.
+func (r *HostUpgradePolicyReconciler) advanceHost(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, status *mgmtv1alpha1.HostUpgradeHostStatus) {
+	host := new(mgmtv1alpha1.Host)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: status.Host}, host); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.failUpgrade(policy, status, err)
+		}
+		return
+	}
+
+	for {
+		phase := status.Phase
+		switch phase {
+		case mgmtv1alpha1.HostUpgradePhaseUpgrading:
+			r.upgrade(ctx, policy, host, status)
+		case mgmtv1alpha1.HostUpgradePhaseRebooting:
+			r.awaitReboot(ctx, policy, host, status)
+		case mgmtv1alpha1.HostUpgradePhaseVerifying:
+			r.verify(ctx, policy, host, status)
+		}
+		if status.Phase == phase {
+			return
+		}
+	}
+}
+
+// upgrade runs the pre-check and upgrades all packages. If the package
+// manager reports that a reboot is required, the boot ID is recorded and the
+// reboot is left to rebootPending. If the controller is interrupted, the
+// upgrade is run again from the start on a possibly partially upgraded host.
+func (r *HostUpgradePolicyReconciler) upgrade(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, host *mgmtv1alpha1.Host, status *mgmtv1alpha1.HostUpgradeHostStatus) {
+	hostRef := types.NamespacedName{Namespace: host.Namespace, Name: host.Name}
+	mgmt, err := r.Connections.Get(ctx, hostRef)
+	if err != nil {
+		r.failUpgrade(policy, status, err)
+		return
+	}
+	defer mgmt.Disconnect()
+
+	packageManager, err := system.NewPackageManager(mgmt, host)
+	if err != nil {
+		r.failUpgrade(policy, status, err)
+		return
+	}
+
+	if policy.Spec.PreCheck != nil {
+		if err := runCheck(ctx, mgmt, policy.Spec.PreCheck); err != nil {
+			r.failUpgrade(policy, status, fmt.Errorf("pre-check failed: %s", err))
+			return
+		}
+	}
+
+	status.PreviousOSVersion = host.Status.OS.Version
+	status.PreviousKernelVersion = host.Status.OS.KernelVersion
+
+	if err := packageManager.Refresh(ctx); err != nil {
+		r.failUpgrade(policy, status, fmt.Errorf("failed to refresh package index: %s", err))
+		return
+	}
+	if err := packageManager.UpgradeAll(ctx); err != nil {
+		r.failUpgrade(policy, status, fmt.Errorf("failed to upgrade packages: %s", err))
+		return
+	}
+	now := metav1.Now()
+	status.UpgradeTime = &now
+
+	rebootRequired, err := packageManager.RebootRequired(ctx)
+	if err != nil {
+		if !errors.Is(err, system.ErrUnsupported) {
+			r.failUpgrade(policy, status, fmt.Errorf("failed to check if a reboot is required: %s", err))
+			return
+		}
+		log.FromContext(ctx).Info("skipping reboot", "host", host.Name, "reason", err.Error())
+	}
+	status.RebootRequired = rebootRequired
+
+	if !rebootRequired {
+		status.Phase = mgmtv1alpha1.HostUpgradePhaseVerifying
+		status.Message = fmt.Sprintf("Upgraded packages via %s without reboot.", packageManager.Name())
+		return
+	}
+
+	bootID, err := readBootID(ctx, r.Connections, host)
+	if err != nil {
+		r.failUpgrade(policy, status, err)
+		return
+	}
+
+	status.BootID = bootID
+	status.RebootTime = nil
+	status.Phase = mgmtv1alpha1.HostUpgradePhaseRebooting
+	status.Message = fmt.Sprintf("Upgraded packages via %s and waiting for the reboot.", packageManager.Name())
+}
+
+// rebootPending reboots the upgraded hosts that wait for their reboot, unless
+// they are busy. The reboot is persisted
+// before it is requested, which ensures that a host is never rebooted twice.
+// If the controller is interrupted in between, the host does not come back
+// with a new boot ID and its upgrade fails once the timeout expires.
+func (r *HostUpgradePolicyReconciler) rebootPending(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, base *mgmtv1alpha1.HostUpgradePolicy, busy map[string]bool) error {
+	for i := range policy.Status.Hosts {
+		status := &policy.Status.Hosts[i]
+		if status.Phase != mgmtv1alpha1.HostUpgradePhaseRebooting || status.RebootTime != nil {
+			continue
+		}
+		if busy[status.Host] {
+			status.Message = "Waiting for the host to be idle before rebooting it."
+			continue
+		}
+
+		now := metav1.Now()
+		status.RebootTime = &now
+		status.Message = "Requesting a reboot."
+		if err := r.Status().Patch(ctx, policy, client.MergeFrom(base)); err != nil {
+			return err
+		}
+		policy.DeepCopyInto(base)
+
+		if err := r.reboot(ctx, policy, status); err != nil {
+			r.failUpgrade(policy, status, fmt.Errorf("failed to reboot: %s", err))
+			continue
+		}
+		status.Message = "Requested a reboot to complete the upgrade."
+	}
+
+	return nil
+}
+
+// reboot requests a reboot of the host via its operating system.
+func (r *HostUpgradePolicyReconciler) reboot(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, status *mgmtv1alpha1.HostUpgradeHostStatus) error {
+	hostRef := types.NamespacedName{Namespace: policy.Namespace, Name: status.Host}
+	host := new(mgmtv1alpha1.Host)
+	if err := r.Get(ctx, hostRef, host); err != nil {
+		return err
+	}
+
+	mgmt, err := r.Connections.Get(ctx, hostRef)
+	if err != nil {
+		return err
+	}
+	defer mgmt.Disconnect()
+
+	power, err := system.NewPower(mgmt, host)
+	if err != nil {
+		return err
+	}
+	if err := power.Reboot(ctx); err != nil {
+		return err
+	}
+	r.Connections.Invalidate(hostRef)
+
+	return nil
+}
+
+// awaitReboot waits for the host to come back with a new boot ID.
+func (r *HostUpgradePolicyReconciler) awaitReboot(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, host *mgmtv1alpha1.Host, status *mgmtv1alpha1.HostUpgradeHostStatus) {
+	// The reboot has not been requested yet.
+	if status.RebootTime == nil {
+		return
+	}
+
+	timeout := upgradeTimeout(policy)
+	if time.Since(status.RebootTime.Time) > timeout {
+		r.failUpgrade(policy, status, fmt.Errorf("host did not come back within %s", timeout))
+		return
+	}
+
+	bootID, err := readBootID(ctx, r.Connections, host)
+	if err != nil {
+		status.Message = fmt.Sprintf("Waiting for the host to come back: %s", err)
+		return
+	}
+	if !bootIDChanged(status.BootID, bootID) {
+		status.Message = "Waiting for the host to reboot."
+		return
+	}
+
+	status.Phase = mgmtv1alpha1.HostUpgradePhaseVerifying
+	status.Message = fmt.Sprintf("Host is reachable with boot ID %s.", bootID)
+}
+
+// verify runs the post-check and probes the operating system of the host,
+// which updates the host status. Failures are retried until the timeout
+// expires, because services may still be starting after a reboot.
+func (r *HostUpgradePolicyReconciler) verify(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, host *mgmtv1alpha1.Host, status *mgmtv1alpha1.HostUpgradeHostStatus) {
+	since := status.UpgradeTime
+	if status.RebootTime != nil {
+		since = status.RebootTime
+	}
+	timeout := upgradeTimeout(policy)
+	expired := since == nil || time.Since(since.Time) > timeout
+
+	err := r.verifyHost(ctx, policy, host, status)
+	if err == nil {
+		now := metav1.Now()
+		status.Phase = mgmtv1alpha1.HostUpgradePhaseSucceeded
+		status.CompletionTime = &now
+		status.Message = fmt.Sprintf("Upgraded to %s with kernel %s.", status.OSVersion, status.KernelVersion)
+		r.recorder.Event(policy, corev1.EventTypeNormal, "HostUpgraded", fmt.Sprintf("Upgraded host %s to %s with kernel %s.", host.Name, status.OSVersion, status.KernelVersion))
+		return
+	}
+
+	if expired {
+		r.failUpgrade(policy, status, fmt.Errorf("%s within %s", err, timeout))
+		return
+	}
+	status.Message = fmt.Sprintf("Retrying verification: %s", err)
+}
+
+// verifyHost runs the post-check and updates the operating system of the host.
+func (r *HostUpgradePolicyReconciler) verifyHost(ctx context.Context, policy *mgmtv1alpha1.HostUpgradePolicy, host *mgmtv1alpha1.Host, status *mgmtv1alpha1.HostUpgradeHostStatus) error {
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return err
+	}
+	defer mgmt.Disconnect()
+
+	if policy.Spec.PostCheck != nil {
+		if err := runCheck(ctx, mgmt, policy.Spec.PostCheck); err != nil {
+			return fmt.Errorf("post-check failed: %s", err)
+		}
+	}
+
+	osInfo, err := mgmt.OS(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to probe operating system: %s", err)
+	}
+
+	// The status is patched, because the host controller updates it concurrently.
+	patch := client.MergeFrom(host.DeepCopy())
+	host.Status.OS = *osInfo
+	if err := r.Status().Patch(ctx, host, patch); err != nil {
+		return err
+	}
+
+	status.OSVersion = osInfo.Version
+	status.KernelVersion = osInfo.KernelVersion
+
+	return nil
+}
+
+// failUpgrade marks the upgrade of the host as failed.
+func (r *HostUpgradePolicyReconciler) failUpgrade(policy *mgmtv1alpha1.HostUpgradePolicy, status *mgmtv1alpha1.HostUpgradeHostStatus, err error) {
+	now := metav1.Now()
+	status.Phase = mgmtv1alpha1.HostUpgradePhaseFailed
+	status.Error = err.Error()
+	status.Message = ""
+	status.CompletionTime = &now
+
+	r.recorder.Event(policy, corev1.EventTypeWarning, "HostUpgradeFailed", fmt.Sprintf("Failed to upgrade host %s: %s", status.Host, err))
+}
+
+// runCheck runs a health check command on the host.
+func runCheck(ctx context.Context, mgmt common.Client, check *mgmtv1alpha1.HostUpgradeCheck) error {
+	cmd := &common.Command{Command: check.Command}
+	if check.Timeout != nil {
+		cmd.Timeout = check.Timeout.Duration
+	}
+
+	result, err := mgmt.Exec(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	return result.Err()
+}
+
+// upgradeTimeout returns the duration for a host to come back after a reboot and to pass the post-check.
+func upgradeTimeout(policy *mgmtv1alpha1.HostUpgradePolicy) time.Duration {
+	if policy.Spec.Timeout != nil {
+		return policy.Spec.Timeout.Duration
+	}
+
+	return defaultHostUpgradeTimeout
+}
+
+// upgradeInProgress checks if the upgrade of the host has been started but has not completed yet.
+func upgradeInProgress(status *mgmtv1alpha1.HostUpgradeHostStatus) bool {
+	switch status.Phase {
+	case mgmtv1alpha1.HostUpgradePhaseUpgrading, mgmtv1alpha1.HostUpgradePhaseRebooting, mgmtv1alpha1.HostUpgradePhaseVerifying:
+		return true
+	default:
+		return false
+	}
+}
+
+// rolloutHosts returns the names of the hosts of the rollout.
+func rolloutHosts(policy *mgmtv1alpha1.HostUpgradePolicy) []string {
+	hosts := make([]string, len(policy.Status.Hosts))
+	for i, status := range policy.Status.Hosts {
+		hosts[i] = status.Host
+	}
+
+	return hosts
+}
+
+// upgradeFailed checks if the upgrade failed on any host of the rollout.
+func upgradeFailed(policy *mgmtv1alpha1.HostUpgradePolicy) bool {
+	for _, status := range policy.Status.Hosts {
+		if status.Phase == mgmtv1alpha1.HostUpgradePhaseFailed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HostUpgradePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(hostUpgradePolicyControllerName)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// Status updates must not trigger a rollout, but the retry annotation must.
+		For(&mgmtv1alpha1.HostUpgradePolicy{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Complete(r)
+}
@@ -0,0 +1,482 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+const (
+	hostUserControllerName = "hostuser-controller"
+	hostUserFinalizer      = "management.kraut.nicklasfrahm.dev/hostuser"
+	keysConfigMapField     = ".spec.authorizedKeysFrom.configMapKeyRef.name"
+	keysSecretField        = ".spec.authorizedKeysFrom.secretKeyRef.name"
+	defaultHostUserResync  = 10 * time.Minute
+	// defaultLoginUser is the user that is used to connect to a host if none is configured.
+	defaultLoginUser = "root"
+)
+
+// HostUserReconciler reconciles a HostUser object
+type HostUserReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostusers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostusers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hostusers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=hosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile ensures that the local user of a HostUser is in the desired state
+// on the selected hosts. The user that is used to connect to a host is never
+// removed, and its shell and authorized keys are never modified to prevent a
+// lockout.
+func (r *HostUserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	hostUser := new(mgmtv1alpha1.HostUser)
+	if err := r.Get(ctx, req.NamespacedName, hostUser); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !hostUser.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, hostUser)
+	}
+
+	if hostUser.Spec.RemoveOnDelete {
+		if controllerutil.AddFinalizer(hostUser, hostUserFinalizer) {
+			if err := r.Update(ctx, hostUser); err != nil {
+				return ctrl.Result{}, client.IgnoreNotFound(err)
+			}
+		}
+	} else if controllerutil.RemoveFinalizer(hostUser, hostUserFinalizer) {
+		if err := r.Update(ctx, hostUser); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	if err := r.validate(hostUser); err != nil {
+		r.recorder.Event(hostUser, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	keys, err := r.authorizedKeys(ctx, hostUser)
+	if err != nil {
+		r.recorder.Event(hostUser, corev1.EventTypeWarning, "ContentUnavailable", err.Error())
+		logger.Error(err, "failed to resolve authorized keys")
+		return ctrl.Result{}, nil
+	}
+
+	hosts, err := selectHosts(ctx, r.Client, hostUser.Namespace, &hostUser.Spec.HostSelector)
+	if err != nil {
+		r.recorder.Event(hostUser, corev1.EventTypeWarning, "InvalidSpec", err.Error())
+		logger.Error(err, "failed to select hosts")
+		return ctrl.Result{}, nil
+	}
+
+	statuses := make([]mgmtv1alpha1.HostUserHostStatus, len(hosts))
+	inSync, outOfSync := 0, 0
+	for i := range hosts {
+		statuses[i] = r.syncHost(ctx, hostUser, &hosts[i], keys)
+
+		if statuses[i].InSync {
+			inSync++
+		} else {
+			outOfSync++
+		}
+	}
+
+	hostUser.Status.ObservedGeneration = hostUser.Generation
+	hostUser.Status.Hosts = statuses
+	hostUser.Status.InSync = inSync
+	hostUser.Status.OutOfSync = outOfSync
+	if err := r.Status().Update(ctx, hostUser); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	interval := defaultHostUserResync
+	if hostUser.Spec.Interval != nil && hostUser.Spec.Interval.Duration > 0 {
+		interval = hostUser.Spec.Interval.Duration
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// validate checks the names of the user and its groups.
+func (r *HostUserReconciler) validate(hostUser *mgmtv1alpha1.HostUser) error {
+	if err := system.ValidateUserName(hostUser.Spec.Name); err != nil {
+		return err
+	}
+
+	for _, group := range hostUser.Spec.Groups {
+		if err := system.ValidateUserName(group); err != nil {
+			return fmt.Errorf("invalid group name: %q", group)
+		}
+	}
+
+	for _, rule := range hostUser.Spec.Sudo {
+		if strings.ContainsAny(rule, "\n\r") {
+			return fmt.Errorf("sudo rules must not contain line breaks")
+		}
+	}
+
+	return nil
+}
+
+// syncHost ensures that the user on a single host matches the desired state.
+func (r *HostUserReconciler) syncHost(ctx context.Context, hostUser *mgmtv1alpha1.HostUser, host *mgmtv1alpha1.Host, keys []string) mgmtv1alpha1.HostUserHostStatus {
+	now := metav1.Now()
+	status := mgmtv1alpha1.HostUserHostStatus{
+		Host:         host.Name,
+		LastSyncTime: &now,
+	}
+
+	name := hostUser.Spec.Name
+	if hostUser.Spec.State == mgmtv1alpha1.UserStateAbsent && isProtectedUser(host, name) {
+		status.Error = fmt.Sprintf("refusing to remove user %s, which is required to connect to the host", name)
+		r.recorder.Event(hostUser, corev1.EventTypeWarning, "ProtectedUser", fmt.Sprintf("Refusing to remove user %s on host %s.", name, host.Name))
+		return status
+	}
+
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer mgmt.Disconnect()
+
+	users, err := system.NewUsers(mgmt, host)
+	if err != nil {
+		status.Error = err.Error()
+		r.recorder.Event(hostUser, corev1.EventTypeWarning, "UnsupportedHost", fmt.Sprintf("Refusing to manage user on host %s: %s", host.Name, err))
+		return status
+	}
+
+	if hostUser.Spec.State == mgmtv1alpha1.UserStateAbsent {
+		removed, err := users.Remove(ctx, name)
+		if err != nil {
+			status.Error = err.Error()
+			r.recorder.Event(hostUser, corev1.EventTypeWarning, "SyncFailed", fmt.Sprintf("Failed to remove user %s on host %s: %s", name, host.Name, err))
+			return status
+		}
+		if removed {
+			r.recorder.Event(hostUser, corev1.EventTypeNormal, "UserChanged", fmt.Sprintf("User %s on host %s: removed user", name, host.Name))
+		}
+
+		status.InSync = true
+		return status
+	}
+
+	changes, err := r.ensureUser(ctx, users, hostUser, host, keys)
+	if len(changes) > 0 {
+		r.recorder.Event(hostUser, corev1.EventTypeNormal, "UserChanged", fmt.Sprintf("User %s on host %s: %s", name, host.Name, strings.Join(changes, ", ")))
+	}
+	if err != nil {
+		status.Error = err.Error()
+		r.recorder.Event(hostUser, corev1.EventTypeWarning, "SyncFailed", fmt.Sprintf("Failed to sync user %s on host %s: %s", name, host.Name, err))
+	}
+
+	user, err := users.Lookup(ctx, name)
+	if err != nil || user == nil {
+		if status.Error == "" && err != nil {
+			status.Error = err.Error()
+		}
+		return status
+	}
+	uid := user.UID
+	status.UID = &uid
+	status.Groups = user.Groups
+
+	status.InSync = status.Error == ""
+
+	return status
+}
+
+// ensureUser creates or modifies the user and writes its sudo rules and
+// authorized keys. It returns a description of the changes that were made.
+// The shell of a protected user is never changed, and its groups and sudo
+// rules are only ever extended, because this could lock kraut out of the host.
+func (r *HostUserReconciler) ensureUser(ctx context.Context, users *system.Users, hostUser *mgmtv1alpha1.HostUser, host *mgmtv1alpha1.Host, keys []string) ([]string, error) {
+	name := hostUser.Spec.Name
+	protected := isProtectedUser(host, name)
+
+	opts := &system.UserOptions{
+		UID:          hostUser.Spec.UID,
+		Shell:        hostUser.Spec.Shell,
+		Groups:       hostUser.Spec.Groups,
+		AppendGroups: protected,
+	}
+
+	var refused error
+	if protected && opts.Shell != "" {
+		user, err := users.Lookup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil && user.Shell != opts.Shell {
+			r.recorder.Event(hostUser, corev1.EventTypeWarning, "ProtectedUser", fmt.Sprintf("Refusing to change the shell of user %s on host %s.", name, host.Name))
+			refused = fmt.Errorf("refusing to change the shell of user %s, which is required to connect to the host", name)
+		}
+		opts.Shell = ""
+	}
+
+	changes, err := users.Ensure(ctx, name, opts)
+	if err != nil {
+		return changes, err
+	}
+
+	rules := hostUser.Spec.Sudo
+	if protected {
+		rules, err = r.protectedSudoRules(ctx, users, hostUser, host)
+		if err != nil {
+			return changes, err
+		}
+	}
+	if rules != nil || !protected {
+		changed, err := users.SetSudoRules(ctx, name, rules)
+		if err != nil {
+			return changes, err
+		}
+		if changed {
+			changes = append(changes, "updated sudo rules")
+		}
+	}
+
+	// Authorized keys are only managed if they are specified, because
+	// existing keys of a user would otherwise be removed silently.
+	if len(keys) == 0 {
+		return changes, refused
+	}
+
+	if protected {
+		r.recorder.Event(hostUser, corev1.EventTypeWarning, "ProtectedUser", fmt.Sprintf("Refusing to manage authorized keys of user %s on host %s.", name, host.Name))
+		return changes, fmt.Errorf("refusing to manage authorized keys of user %s, which is required to connect to the host", name)
+	}
+
+	user, err := users.Lookup(ctx, name)
+	if err != nil {
+		return changes, err
+	}
+	if user == nil {
+		return changes, fmt.Errorf("failed to find user: %s", name)
+	}
+
+	changed, err := users.SetAuthorizedKeys(ctx, user, keys)
+	if err != nil {
+		return changes, fmt.Errorf("failed to write authorized keys: %s", err)
+	}
+	if changed {
+		changes = append(changes, "updated authorized keys")
+	}
+
+	return changes, refused
+}
+
+// protectedSudoRules returns the sudo rules of a protected user, which
+// contain the existing rules and the desired rules, because removing a rule
+// could revoke privileges that kraut depends on. Nil is returned if the
+// sudo rules must not be changed.
+func (r *HostUserReconciler) protectedSudoRules(ctx context.Context, users *system.Users, hostUser *mgmtv1alpha1.HostUser, host *mgmtv1alpha1.Host) ([]string, error) {
+	if len(hostUser.Spec.Sudo) == 0 {
+		return nil, nil
+	}
+
+	rules, err := users.SudoRules(ctx, hostUser.Spec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := len(rules)
+	for _, rule := range hostUser.Spec.Sudo {
+		if slices.Contains(rules, rule) {
+			kept--
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	if kept > 0 {
+		r.recorder.Event(hostUser, corev1.EventTypeWarning, "ProtectedUser", fmt.Sprintf("Keeping %d existing sudo rules of user %s on host %s.", kept, hostUser.Spec.Name, host.Name))
+	}
+
+	return rules, nil
+}
+
+// authorizedKeys collects the inline and the referenced authorized keys.
+func (r *HostUserReconciler) authorizedKeys(ctx context.Context, hostUser *mgmtv1alpha1.HostUser) ([]string, error) {
+	keys := make([]string, 0, len(hostUser.Spec.AuthorizedKeys))
+	for _, key := range hostUser.Spec.AuthorizedKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	for i := range hostUser.Spec.AuthorizedKeysFrom {
+		content, err := resolveContent(ctx, r.Client, hostUser.Namespace, &hostUser.Spec.AuthorizedKeysFrom[i])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				keys = append(keys, line)
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// reconcileDelete removes the user from the selected hosts and releases the finalizer.
+func (r *HostUserReconciler) reconcileDelete(ctx context.Context, hostUser *mgmtv1alpha1.HostUser) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(hostUser, hostUserFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	hosts, err := selectHosts(ctx, r.Client, hostUser.Namespace, &hostUser.Spec.HostSelector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	name := hostUser.Spec.Name
+	for i := range hosts {
+		if isProtectedUser(&hosts[i], name) {
+			r.recorder.Event(hostUser, corev1.EventTypeWarning, "ProtectedUser", fmt.Sprintf("Refusing to remove user %s on host %s.", name, hosts[i].Name))
+			continue
+		}
+
+		if err := r.removeUser(ctx, &hosts[i], name); err != nil {
+			r.recorder.Event(hostUser, corev1.EventTypeWarning, "RemoveFailed", fmt.Sprintf("Failed to remove user %s on host %s: %s", name, hosts[i].Name, err))
+			return ctrl.Result{}, err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(hostUser, hostUserFinalizer)
+	if err := r.Update(ctx, hostUser); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// removeUser removes a user from a host.
+func (r *HostUserReconciler) removeUser(ctx context.Context, host *mgmtv1alpha1.Host, name string) error {
+	mgmt, err := r.Connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return err
+	}
+	defer mgmt.Disconnect()
+
+	users, err := system.NewUsers(mgmt, host)
+	if err != nil {
+		return err
+	}
+
+	_, err = users.Remove(ctx, name)
+	return err
+}
+
+// isProtectedUser checks if a user must not be removed or locked out,
+// because it is the superuser or the user used to connect to the host.
+func isProtectedUser(host *mgmtv1alpha1.Host, name string) bool {
+	loginUser := host.Spec.SSH.User
+	if loginUser == "" {
+		loginUser = defaultLoginUser
+	}
+
+	return name == loginUser || name == defaultLoginUser
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HostUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(hostUserControllerName)
+
+	// We need to add indices for the referenced ConfigMaps and Secrets so
+	// that we can trigger a reconciliation if the authorized keys change.
+	err := mgr.GetFieldIndexer().IndexField(context.TODO(), &mgmtv1alpha1.HostUser{}, keysConfigMapField, func(rawObj client.Object) []string {
+		hostUser := rawObj.(*mgmtv1alpha1.HostUser)
+		names := make([]string, 0)
+		for _, source := range hostUser.Spec.AuthorizedKeysFrom {
+			if source.ConfigMapKeyRef != nil {
+				names = append(names, source.ConfigMapKeyRef.Name)
+			}
+		}
+		return names
+	})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.TODO(), &mgmtv1alpha1.HostUser{}, keysSecretField, func(rawObj client.Object) []string {
+		hostUser := rawObj.(*mgmtv1alpha1.HostUser)
+		names := make([]string, 0)
+		for _, source := range hostUser.Spec.AuthorizedKeysFrom {
+			if source.SecretKeyRef != nil {
+				names = append(names, source.SecretKeyRef.Name)
+			}
+		}
+		return names
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mgmtv1alpha1.HostUser{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Watch for changes to referenced authorized keys.
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(enqueueField(r.Client, newHostUserList, keysConfigMapField))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(enqueueField(r.Client, newHostUserList, keysSecretField))).
+		// Watch for hosts that start or stop matching a selector. The discovered
+		// OS family of the hosts is relevant as well. The login user is part of
+		// the spec, which determines the protected users. Other status changes
+		// are ignored.
+		Watches(
+			&mgmtv1alpha1.Host{},
+			handler.EnqueueRequestsFromMapFunc(enqueueNamespace(r.Client, newHostUserList)),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, hostDiscoveryChanged())),
+		).
+		Complete(r)
+}
+
+// newHostUserList creates an empty list of HostUsers.
+func newHostUserList() client.ObjectList {
+	return &mgmtv1alpha1.HostUserList{}
+}
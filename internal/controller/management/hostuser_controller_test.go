@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+const (
+	testLoginUser   = "admin"
+	testSudoersFile = "/etc/sudoers.d/kraut-admin"
+	testSudoersTemp = "/etc/sudoers.d/.kraut-admin.tmp"
+	testSudoRule    = "ALL=(ALL) NOPASSWD: ALL"
+)
+
+// ensureTestUser ensures the user on a host that uses the test login user.
+func ensureTestUser(t *testing.T, host *fakeHost, spec mgmtv1alpha1.HostUserSpec) error {
+	t.Helper()
+
+	managedHost := &mgmtv1alpha1.Host{
+		Spec: mgmtv1alpha1.HostSpec{SSH: mgmtv1alpha1.HostSpecSSHOptions{User: testLoginUser}},
+		Status: mgmtv1alpha1.HostStatus{
+			OS: mgmtv1alpha1.OSInfo{Family: mgmtv1alpha1.OSFamilyDebian},
+		},
+	}
+	users, err := system.NewUsers(host, managedHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &HostUserReconciler{recorder: record.NewFakeRecorder(100)}
+	_, err = r.ensureUser(context.Background(), users, &mgmtv1alpha1.HostUser{Spec: spec}, managedHost, nil)
+	return err
+}
+
+// ranPrefix returns the commands of the host that start with the prefix.
+func ranPrefix(host *fakeHost, prefix string) []string {
+	commands := make([]string, 0)
+	for _, command := range host.commands {
+		if strings.HasPrefix(command, prefix) {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+func TestHostUserRefusesShellOfProtectedUser(t *testing.T) {
+	host := newFakeHost()
+	host.users[testLoginUser] = 1000
+
+	err := ensureTestUser(t, host, mgmtv1alpha1.HostUserSpec{Name: testLoginUser, Shell: "/usr/sbin/nologin"})
+	if err == nil {
+		t.Fatal("expected shell change to be refused")
+	}
+	if commands := ranPrefix(host, "usermod"); len(commands) != 0 {
+		t.Errorf("unexpected commands: %v", commands)
+	}
+}
+
+func TestHostUserAppendsGroupsOfProtectedUser(t *testing.T) {
+	tests := []struct {
+		name string
+		user string
+		want string
+	}{
+		{name: "protected", user: testLoginUser, want: "usermod --append --groups 'docker' -- 'admin'"},
+		{name: "unprotected", user: "alice", want: "usermod --groups 'docker' -- 'alice'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host := newFakeHost()
+			host.users[test.user] = 1000
+			host.groups["docker"] = 999
+
+			if err := ensureTestUser(t, host, mgmtv1alpha1.HostUserSpec{Name: test.user, Groups: []string{"docker"}}); err != nil {
+				t.Fatal(err)
+			}
+			if count := host.ran(test.want); count != 1 {
+				t.Errorf("%q ran %d times, want 1: %v", test.want, count, host.commands)
+			}
+		})
+	}
+}
+
+func TestHostUserKeepsSudoRulesOfProtectedUser(t *testing.T) {
+	host := newFakeHost()
+	host.users[testLoginUser] = 1000
+	host.files[testSudoersFile] = &fakeFile{content: []byte("# This file is managed by kraut.\nadmin " + testSudoRule + "\n"), mode: 0440}
+
+	if err := ensureTestUser(t, host, mgmtv1alpha1.HostUserSpec{Name: testLoginUser}); err != nil {
+		t.Fatal(err)
+	}
+	if host.files[testSudoersFile] == nil {
+		t.Fatal("expected sudo rules of protected user to be kept")
+	}
+
+	if err := ensureTestUser(t, host, mgmtv1alpha1.HostUserSpec{Name: testLoginUser, Sudo: []string{"ALL=(ALL) /usr/bin/systemctl"}}); err != nil {
+		t.Fatal(err)
+	}
+	file := host.files[testSudoersTemp]
+	if file == nil {
+		t.Fatal("expected sudo rules to be written")
+	}
+	want := "# This file is managed by kraut.\nadmin " + testSudoRule + "\nadmin ALL=(ALL) /usr/bin/systemctl\n"
+	if string(file.content) != want {
+		t.Errorf("sudo rules = %q, want %q", file.content, want)
+	}
+}
+
+func TestHostUserRemovesSudoRulesOfUnprotectedUser(t *testing.T) {
+	host := newFakeHost()
+	host.users["alice"] = 1000
+	host.files["/etc/sudoers.d/kraut-alice"] = &fakeFile{content: []byte("alice " + testSudoRule + "\n"), mode: 0440}
+
+	if err := ensureTestUser(t, host, mgmtv1alpha1.HostUserSpec{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if host.files["/etc/sudoers.d/kraut-alice"] != nil {
+		t.Error("expected sudo rules to be removed")
+	}
+}
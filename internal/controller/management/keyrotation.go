@@ -0,0 +1,308 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/cron"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	// keyRotationRetryInterval is the interval at which a failed key rotation is retried.
+	keyRotationRetryInterval = 10 * time.Minute
+	// authorizedKeysFile is the file of the user that contains the authorized public keys.
+	authorizedKeysFile = "~/.ssh/authorized_keys"
+	// lastKeyRotationAnnotation is the annotation of a Secret that contains the
+	// time at which its private key was last rotated in the RFC 3339 format.
+	lastKeyRotationAnnotation = "kraut.nicklasfrahm.dev/last-key-rotation"
+)
+
+// reconcileKeyRotation rotates the private key of the host if the rotation is
+// due. It returns the duration until the next rotation, which is zero if the
+// rotation is disabled.
+func (r *HostReconciler) reconcileKeyRotation(ctx context.Context, host *mgmtv1alpha1.Host, mgmt common.Client) time.Duration {
+	logger := log.FromContext(ctx)
+
+	rotation := host.Spec.SSH.KeyRotation
+	if rotation == nil {
+		host.Status.SSH.NextKeyRotationTime = nil
+		return 0
+	}
+
+	if !r.KeyRotation {
+		host.Status.SSH.NextKeyRotationTime = nil
+		r.recorder.Event(host, corev1.EventTypeWarning, "KeyRotationDisabled", "Key rotation is disabled, start the operator with --enable-key-rotation")
+		return 0
+	}
+
+	schedule, err := cron.Parse(rotation.Schedule)
+	if err != nil {
+		r.recorder.Event(host, corev1.EventTypeWarning, "InvalidSpec", fmt.Sprintf("Invalid key rotation schedule: %s", err))
+		return 0
+	}
+
+	last := host.CreationTimestamp.Time
+	if host.Status.SSH.LastKeyRotationTime != nil {
+		last = host.Status.SSH.LastKeyRotationTime.Time
+	}
+	// The time is recorded on the Secret together with the new key, because
+	// the status may not be persisted if the update of the host fails.
+	if rotated := r.lastKeyRotation(ctx, host); rotated.After(last) {
+		last = rotated
+		host.Status.SSH.LastKeyRotationTime = &metav1.Time{Time: rotated}
+	}
+
+	now := time.Now()
+	next := schedule.Next(last)
+	if next.IsZero() {
+		host.Status.SSH.NextKeyRotationTime = nil
+		return 0
+	}
+	if next.After(now) {
+		host.Status.SSH.NextKeyRotationTime = &metav1.Time{Time: next}
+		return next.Sub(now)
+	}
+
+	if err := r.rotateKey(ctx, host, mgmt, now); err != nil {
+		r.recorder.Event(host, corev1.EventTypeWarning, "KeyRotationFailed", err.Error())
+		logger.Error(err, "failed to rotate private key")
+		return keyRotationRetryInterval
+	}
+
+	host.Status.SSH.LastKeyRotationTime = &metav1.Time{Time: now}
+	host.Status.SSH.NextKeyRotationTime = nil
+	next = schedule.Next(now)
+	if next.IsZero() {
+		return 0
+	}
+	host.Status.SSH.NextKeyRotationTime = &metav1.Time{Time: next}
+
+	return next.Sub(now)
+}
+
+// lastKeyRotation returns the time at which the private key in the Secret of
+// the host was last rotated. The zero time is returned if it is unknown.
+func (r *HostReconciler) lastKeyRotation(ctx context.Context, host *mgmtv1alpha1.Host) time.Time {
+	secretRef := common.SecretReference(host)
+	if secretRef.Namespace != host.Namespace {
+		return time.Time{}
+	}
+
+	secret := new(corev1.Secret)
+	if err := r.Get(ctx, secretRef, secret); err != nil {
+		return time.Time{}
+	}
+
+	rotated, err := time.Parse(time.RFC3339, secret.Annotations[lastKeyRotationAnnotation])
+	if err != nil {
+		return time.Time{}
+	}
+
+	return rotated
+}
+
+// rotateKey replaces the private key of the host. The public key of a new key
+// pair is authorized over the existing connection and a login with the new key
+// is verified, before the Secret is updated and the old key is revoked. The
+// time of the rotation is recorded in the same update of the Secret.
+func (r *HostReconciler) rotateKey(ctx context.Context, host *mgmtv1alpha1.Host, mgmt common.Client, now time.Time) error {
+	// Only private keys in Secrets can be rotated.
+	source, ok := r.Connections.CredentialSource().(*common.SecretCredentialSource)
+	if !ok {
+		return fmt.Errorf("key rotation requires credentials from Secrets")
+	}
+	keys := source.Keys
+
+	// A grant only permits reading a Secret in another namespace.
+	secretRef := common.SecretReference(host)
+	if secretRef.Namespace != host.Namespace {
+		return fmt.Errorf("refusing to rotate private key in Secret %s, which is in another namespace", secretRef)
+	}
+	secret := new(corev1.Secret)
+	if err := r.Get(ctx, secretRef, secret); err != nil {
+		return fmt.Errorf("failed to read Secret: %s: %w", secretRef, err)
+	}
+
+	if err := r.ensureExclusiveSecret(ctx, host, secretRef); err != nil {
+		return err
+	}
+
+	passphrase := secret.Data[keys.Passphrase]
+	oldKey, err := parsePrivateKey(secret.Data[keys.Key], passphrase)
+	if err != nil {
+		return err
+	}
+	if len(secret.Data[keys.Certificate]) > 0 {
+		return fmt.Errorf("refusing to rotate private key with certificate in Secret: %s", secretRef)
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	newKey, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	// Encrypted keys remain encrypted with the same passphrase.
+	comment := fmt.Sprintf("kraut@%s/%s", host.Namespace, host.Name)
+	var block *pem.Block
+	if len(passphrase) > 0 {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, comment, passphrase)
+	} else {
+		block, err = ssh.MarshalPrivateKey(privateKey, comment)
+	}
+	if err != nil {
+		return err
+	}
+	encodedKey := pem.EncodeToMemory(block)
+
+	if err := authorizeKey(ctx, mgmt, newKey.PublicKey(), comment); err != nil {
+		return fmt.Errorf("failed to authorize new key: %w", err)
+	}
+
+	hostRef := types.NamespacedName{Namespace: host.Namespace, Name: host.Name}
+	verified, err := r.Connections.Dial(ctx, hostRef, common.WithCredentials(&common.Credentials{
+		Key:        string(encodedKey),
+		Passphrase: string(passphrase),
+	}))
+	if err != nil {
+		revokeKey(ctx, mgmt, newKey.PublicKey())
+		return fmt.Errorf("failed to verify login with new key: %w", err)
+	}
+	defer verified.Disconnect()
+
+	secret.Data[keys.Key] = encodedKey
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[lastKeyRotationAnnotation] = now.UTC().Format(time.RFC3339)
+	if err := r.Update(ctx, secret); err != nil {
+		revokeKey(ctx, mgmt, newKey.PublicKey())
+		return fmt.Errorf("failed to update Secret: %s: %w", secretRef, err)
+	}
+
+	// The old key is revoked using the new key, which proves once more
+	// that the new key works. The rotation is complete at this point.
+	if err := revokeKey(ctx, verified, oldKey.PublicKey()); err != nil {
+		r.recorder.Event(host, corev1.EventTypeWarning, "KeyRevocationFailed", fmt.Sprintf("Failed to revoke old key %s: %s", ssh.FingerprintSHA256(oldKey.PublicKey()), err))
+	}
+
+	r.recorder.Event(host, corev1.EventTypeNormal, "KeyRotated", fmt.Sprintf("Rotated private key to %s.", ssh.FingerprintSHA256(newKey.PublicKey())))
+
+	return nil
+}
+
+// ensureExclusiveSecret checks that the Secret is not used by other hosts, by
+// the jump hosts of the host or by bastions, which would become unreachable if
+// the private key were rotated.
+func (r *HostReconciler) ensureExclusiveSecret(ctx context.Context, host *mgmtv1alpha1.Host, secretRef types.NamespacedName) error {
+	for i := range host.Spec.SSH.JumpHosts {
+		jumpHost := &host.Spec.SSH.JumpHosts[i]
+		if common.JumpHostSecretReference(host, jumpHost) == secretRef {
+			return fmt.Errorf("refusing to rotate private key in Secret %s, which is shared with jump host %s", secretRef, jumpHost.Address())
+		}
+	}
+
+	hostList := &mgmtv1alpha1.HostList{}
+	if err := r.List(ctx, hostList, client.MatchingFields{secretField: secretRef.Name}); err != nil {
+		return err
+	}
+
+	for i := range hostList.Items {
+		other := &hostList.Items[i]
+		if other.UID != host.UID && slices.Contains(common.SecretReferences(other), secretRef) {
+			return fmt.Errorf("refusing to rotate private key in Secret %s, which is shared with Host %s/%s", secretRef, other.Namespace, other.Name)
+		}
+	}
+
+	bastionList := &mgmtv1alpha1.SSHBastionList{}
+	if err := r.List(ctx, bastionList, client.MatchingFields{bastionSecretField: secretRef.Name}); err != nil {
+		return err
+	}
+
+	for i := range bastionList.Items {
+		bastion := &bastionList.Items[i]
+		if common.BastionSecretReference(bastion) == secretRef {
+			return fmt.Errorf("refusing to rotate private key in Secret %s, which is shared with SSHBastion %s/%s", secretRef, bastion.Namespace, bastion.Name)
+		}
+	}
+
+	return nil
+}
+
+// parsePrivateKey parses a private key, which may be encrypted.
+func parsePrivateKey(key []byte, passphrase []byte) (ssh.Signer, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key rotation requires a private key in the Secret")
+	}
+
+	if len(passphrase) > 0 {
+		return ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	}
+
+	return ssh.ParsePrivateKey(key)
+}
+
+// authorizeKey appends a public key to the authorized keys of the user.
+func authorizeKey(ctx context.Context, mgmt common.Client, key ssh.PublicKey, comment string) error {
+	line := fmt.Sprintf("%s %s", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))), comment)
+
+	// A missing line break at the end of the file would corrupt the last key.
+	result, err := mgmt.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf(`umask 077 && mkdir -p ~/.ssh && touch %[1]s && { [ -z "$(tail -c 1 %[1]s)" ] || echo >> %[1]s; } && printf '%%s\n' %[2]s >> %[1]s`, authorizedKeysFile, common.ShellQuote(line)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return result.Err()
+}
+
+// revokeKey removes a public key from the authorized keys of the user. The
+// file is rewritten in place to preserve its permissions and its owner.
+func revokeKey(ctx context.Context, mgmt common.Client, key ssh.PublicKey) error {
+	blob := base64.StdEncoding.EncodeToString(key.Marshal())
+
+	result, err := mgmt.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf(`umask 077 && { grep -v -F -- %[2]s %[1]s > %[1]s.kraut || true; } && cat %[1]s.kraut > %[1]s && rm -f %[1]s.kraut`, authorizedKeysFile, common.ShellQuote(blob)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return result.Err()
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+// newKeyRotationTest creates a host with a yearly key rotation
+// and a reconciler that knows the given Secrets.
+func newKeyRotationTest(t *testing.T, secrets ...*corev1.Secret) (*HostReconciler, *mgmtv1alpha1.Host) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, secret := range secrets {
+		if secret != nil {
+			builder = builder.WithObjects(secret)
+		}
+	}
+
+	host := &mgmtv1alpha1.Host{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "node-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-24 * time.Hour)),
+		},
+		Spec: mgmtv1alpha1.HostSpec{
+			SecretRef: corev1.SecretReference{Name: "node-1"},
+			SSH: mgmtv1alpha1.HostSpecSSHOptions{
+				KeyRotation: &mgmtv1alpha1.HostSpecSSHKeyRotation{Schedule: "@yearly"},
+			},
+		},
+	}
+
+	return &HostReconciler{Client: builder.Build(), recorder: record.NewFakeRecorder(100), KeyRotation: true}, host
+}
+
+// newRotatedSecret creates the Secret of the test host with the annotation.
+func newRotatedSecret(namespace string, rotated string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        "node-1",
+			Annotations: map[string]string{lastKeyRotationAnnotation: rotated},
+		},
+	}
+}
+
+func TestLastKeyRotation(t *testing.T) {
+	rotated := time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		secret *corev1.Secret
+		want   time.Time
+	}{
+		{name: "annotated", secret: newRotatedSecret("default", rotated.Format(time.RFC3339)), want: rotated},
+		{name: "invalid annotation", secret: newRotatedSecret("default", "yesterday")},
+		{name: "missing annotation", secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "node-1"}}},
+		{name: "missing Secret"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, host := newKeyRotationTest(t, test.secret)
+			if got := r.lastKeyRotation(context.Background(), host); !got.Equal(test.want) {
+				t.Errorf("lastKeyRotation() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLastKeyRotationIgnoresOtherNamespaces(t *testing.T) {
+	r, host := newKeyRotationTest(t, newRotatedSecret("platform", time.Now().Format(time.RFC3339)))
+	host.Spec.SecretRef.Namespace = "platform"
+
+	if got := r.lastKeyRotation(context.Background(), host); !got.IsZero() {
+		t.Errorf("lastKeyRotation() = %s, want zero", got)
+	}
+}
+
+func TestReconcileKeyRotationRecoversLostStatus(t *testing.T) {
+	// The key was rotated, but the status of the host was not persisted.
+	rotated := time.Now().Truncate(time.Second)
+	r, host := newKeyRotationTest(t, newRotatedSecret("default", rotated.Format(time.RFC3339)))
+
+	// The rotation would be due according to the status and would panic, because
+	// the reconciler has no connection manager to read the credentials with.
+	requeueAfter := r.reconcileKeyRotation(context.Background(), host, nil)
+	if requeueAfter <= 0 {
+		t.Errorf("requeue after = %s, want the time until the next rotation", requeueAfter)
+	}
+
+	last := host.Status.SSH.LastKeyRotationTime
+	if last == nil || !last.Time.Equal(rotated) {
+		t.Errorf("last key rotation = %v, want %s", last, rotated)
+	}
+	next := host.Status.SSH.NextKeyRotationTime
+	if next == nil || !next.Time.After(rotated) {
+		t.Errorf("next key rotation = %v, want after %s", next, rotated)
+	}
+}
+
+func TestReconcileKeyRotationDisabled(t *testing.T) {
+	r, host := newKeyRotationTest(t, nil)
+	r.KeyRotation = false
+	host.Status.SSH.NextKeyRotationTime = &metav1.Time{Time: time.Now()}
+
+	// The rotation is overdue, but must not be attempted without the permission to update the Secret.
+	if requeueAfter := r.reconcileKeyRotation(context.Background(), host, nil); requeueAfter != 0 {
+		t.Errorf("requeue after = %s, want zero", requeueAfter)
+	}
+	if host.Status.SSH.NextKeyRotationTime != nil {
+		t.Errorf("next key rotation = %v, want none", host.Status.SSH.NextKeyRotationTime)
+	}
+
+	recorder := r.recorder.(*record.FakeRecorder)
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "KeyRotationDisabled") {
+			t.Errorf("event = %q, want KeyRotationDisabled", event)
+		}
+	default:
+		t.Error("expected KeyRotationDisabled event")
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	// outOfBandInterval is the interval at which the BMC of a host is polled.
+	outOfBandInterval = 5 * time.Minute
+	// maxHardwareEvents is the maximum number of entries of the event log
+	// of the BMC that are reported as Kubernetes events per reconciliation.
+	maxHardwareEvents = 20
+)
+
+// reconcileOutOfBand applies requested power actions and boot devices via the
+// BMC of the host and updates the out-of-band status of the host. It runs
+// before the connection to the operating system is established, so that hosts
+// can be managed while their operating system is unavailable. It returns the
+// duration until the BMC should be polled again, which is zero if the host has
+// no out-of-band endpoint.
+func (r *HostReconciler) reconcileOutOfBand(ctx context.Context, host *mgmtv1alpha1.Host) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+
+	if host.Spec.OutOfBand == nil {
+		modified := meta.RemoveStatusCondition(&host.Status.Conditions, mgmtv1alpha1.HostConditionOutOfBandReachable)
+		if host.Status.OutOfBand != nil {
+			host.Status.OutOfBand = nil
+			modified = true
+		}
+		if modified {
+			return 0, r.Status().Update(ctx, host)
+		}
+		return 0, nil
+	}
+
+	oob, err := r.Connections.OutOfBand(ctx, host)
+	if err != nil {
+		// The ReferenceNotPermitted condition is reserved for the connection to the
+		// operating system, which would otherwise reset it on every reconciliation.
+		reason := "ConnectionFailed"
+		var notPermitted *common.ReferenceNotPermittedError
+		if errors.As(err, &notPermitted) {
+			reason = "MissingGrant"
+		}
+
+		logger.Error(err, "failed to connect to BMC")
+		return outOfBandInterval, r.setOutOfBandReachable(ctx, host, metav1.ConditionFalse, reason, err.Error())
+	}
+	defer oob.Disconnect()
+
+	if err := r.setOutOfBandReachable(ctx, host, metav1.ConditionTrue, "Connected", "The BMC accepted the connection."); err != nil {
+		return 0, err
+	}
+
+	if err := r.applyOutOfBandAnnotations(ctx, host, oob); err != nil {
+		return 0, err
+	}
+
+	inventory, err := oob.Inventory(ctx)
+	if err != nil {
+		r.recorder.Event(host, corev1.EventTypeWarning, "ProbeFailed", err.Error())
+		logger.Error(err, "failed to read inventory of BMC")
+		return outOfBandInterval, nil
+	}
+
+	// Only entries that were created since the last reconciliation are reported,
+	// so that the event log is not reported again whenever the BMC is polled.
+	inventory.LastEventTime = r.reportHardwareEvents(ctx, host, oob)
+
+	host.Status.OutOfBand = inventory
+	if err := r.Status().Update(ctx, host); err != nil {
+		return 0, err
+	}
+
+	return outOfBandInterval, nil
+}
+
+// applyOutOfBandAnnotations configures the requested boot device and submits
+// the requested power action. The annotations are removed regardless of the
+// outcome, because retrying a power action may restart the host repeatedly.
+func (r *HostReconciler) applyOutOfBandAnnotations(ctx context.Context, host *mgmtv1alpha1.Host, oob common.OutOfBandClient) error {
+	bootDevice, hasBootDevice := host.Annotations[mgmtv1alpha1.HostAnnotationBootDevice]
+	powerAction, hasPowerAction := host.Annotations[mgmtv1alpha1.HostAnnotationPowerAction]
+	if !hasBootDevice && !hasPowerAction {
+		return nil
+	}
+
+	if hasBootDevice {
+		if err := oob.SetBootDevice(ctx, mgmtv1alpha1.BootDevice(bootDevice)); err != nil {
+			r.recorder.Event(host, corev1.EventTypeWarning, "BootDeviceFailed", fmt.Sprintf("Failed to set boot device %s: %s", bootDevice, err))
+		} else {
+			r.recorder.Event(host, corev1.EventTypeNormal, "BootDeviceSet", fmt.Sprintf("Set boot device %s for the next boot.", bootDevice))
+		}
+	}
+
+	if hasPowerAction {
+		if err := oob.Power(ctx, mgmtv1alpha1.PowerAction(powerAction)); err != nil {
+			r.recorder.Event(host, corev1.EventTypeWarning, "PowerActionFailed", fmt.Sprintf("Failed to submit power action %s: %s", powerAction, err))
+		} else {
+			r.recorder.Event(host, corev1.EventTypeNormal, "PowerActionSubmitted", fmt.Sprintf("Submitted power action %s.", powerAction))
+		}
+	}
+
+	patch := client.MergeFrom(host.DeepCopy())
+	delete(host.Annotations, mgmtv1alpha1.HostAnnotationBootDevice)
+	delete(host.Annotations, mgmtv1alpha1.HostAnnotationPowerAction)
+
+	return r.Patch(ctx, host, patch)
+}
+
+// reportHardwareEvents reports the entries of the event log of the BMC, which
+// were created after the last reported entry, as Kubernetes events. It returns
+// the creation time of the last reported entry.
+func (r *HostReconciler) reportHardwareEvents(ctx context.Context, host *mgmtv1alpha1.Host, oob common.OutOfBandClient) *metav1.Time {
+	logger := log.FromContext(ctx)
+
+	var last *metav1.Time
+	if host.Status.OutOfBand != nil {
+		last = host.Status.OutOfBand.LastEventTime
+	}
+
+	// The first reconciliation only records the time of the
+	// last entry to avoid reporting the entire event log.
+	var since time.Time
+	if last != nil {
+		since = last.Time
+	}
+
+	events, err := oob.Events(ctx, since)
+	if err != nil {
+		if !errors.Is(err, common.ErrNotSupported) {
+			logger.Error(err, "failed to read event log of BMC")
+		}
+		return last
+	}
+	if len(events) == 0 {
+		return last
+	}
+
+	if last != nil {
+		for _, event := range events[max(len(events)-maxHardwareEvents, 0):] {
+			eventType := corev1.EventTypeNormal
+			if event.Severity == "Warning" || event.Severity == "Critical" {
+				eventType = corev1.EventTypeWarning
+			}
+			r.recorder.Event(host, eventType, "HardwareEvent", fmt.Sprintf("%s: %s", event.Severity, event.Message))
+		}
+	}
+
+	return &metav1.Time{Time: events[len(events)-1].Created}
+}
+
+// setOutOfBandReachable sets the OutOfBandReachable condition and
+// emits an event and persists the status if the condition changed.
+func (r *HostReconciler) setOutOfBandReachable(ctx context.Context, host *mgmtv1alpha1.Host, status metav1.ConditionStatus, reason string, message string) error {
+	modified := meta.SetStatusCondition(&host.Status.Conditions, metav1.Condition{
+		Type:               mgmtv1alpha1.HostConditionOutOfBandReachable,
+		Status:             status,
+		ObservedGeneration: host.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if !modified {
+		return nil
+	}
+
+	eventType := corev1.EventTypeNormal
+	if status != metav1.ConditionTrue {
+		eventType = corev1.EventTypeWarning
+	}
+	r.recorder.Event(host, eventType, reason, message)
+
+	return r.Status().Update(ctx, host)
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+	"github.com/nicklasfrahm/kraut/pkg/system"
+)
+
+// bootTimeTolerance is the deviation of the boot time, which is tolerated if it
+// is used instead of the boot ID, as it is derived from the uptime of the host.
+const bootTimeTolerance = time.Minute
+
+// readBootID returns the boot ID of the host. It falls back to the boot
+// time for hosts whose protocol does not support running commands.
+func readBootID(ctx context.Context, connections *management.Manager, host *mgmtv1alpha1.Host) (string, error) {
+	mgmt, err := connections.Get(ctx, types.NamespacedName{Namespace: host.Namespace, Name: host.Name})
+	if err != nil {
+		return "", err
+	}
+	defer mgmt.Disconnect()
+
+	if power, err := system.NewPower(mgmt, host); err == nil {
+		bootID, err := power.BootID(ctx)
+		if !errors.Is(err, common.ErrNotSupported) {
+			return bootID, err
+		}
+	}
+
+	osInfo, err := mgmt.OS(ctx)
+	if err != nil {
+		return "", err
+	}
+	if osInfo.BootTime == nil {
+		return "", fmt.Errorf("%w: host %s reports neither a boot ID nor a boot time", common.ErrNotSupported, host.Name)
+	}
+
+	return osInfo.BootTime.UTC().Format(time.RFC3339), nil
+}
+
+// bootIDChanged checks if the host was booted since the previous boot ID
+// was recorded. Boot times are compared with a tolerance.
+func bootIDChanged(previous string, current string) bool {
+	previousTime, err := time.Parse(time.RFC3339, previous)
+	if err != nil {
+		return previous != current
+	}
+	currentTime, err := time.Parse(time.RFC3339, current)
+	if err != nil {
+		return true
+	}
+
+	return currentTime.Sub(previousTime) > bootTimeTolerance
+}
+
+// busyHosts returns the given hosts in the namespace that may be rebooted by
+// another object, i.e. hosts on which a HostAction is running or which are
+// being upgraded by a HostUpgradePolicy. The owner is excluded, so that
+// HostActions and rollouts only account for the hosts of each other.
+func busyHosts(ctx context.Context, c client.Client, namespace string, hosts []string, owner client.Object) (map[string]bool, error) {
+	selected := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		selected[host] = true
+	}
+
+	hostActionList := &mgmtv1alpha1.HostActionList{}
+	if err := c.List(ctx, hostActionList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	busy := make(map[string]bool)
+	for _, other := range hostActionList.Items {
+		if self, ok := owner.(*mgmtv1alpha1.HostAction); ok && other.Name == self.Name {
+			continue
+		}
+		if other.Status.Phase != mgmtv1alpha1.HostActionPhaseRunning {
+			continue
+		}
+		for _, status := range other.Status.Hosts {
+			if status.Phase == mgmtv1alpha1.HostActionPhaseRunning && selected[status.Host] {
+				busy[status.Host] = true
+			}
+		}
+	}
+
+	policyList := &mgmtv1alpha1.HostUpgradePolicyList{}
+	if err := c.List(ctx, policyList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	// Hosts of paused rollouts are still advanced until their upgrade completed.
+	for _, other := range policyList.Items {
+		if self, ok := owner.(*mgmtv1alpha1.HostUpgradePolicy); ok && other.Name == self.Name {
+			continue
+		}
+		for i := range other.Status.Hosts {
+			if upgradeInProgress(&other.Status.Hosts[i]) && selected[other.Status.Hosts[i].Host] {
+				busy[other.Status.Hosts[i].Host] = true
+			}
+		}
+	}
+
+	return busy, nil
+}
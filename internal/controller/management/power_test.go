@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+func TestBusyHosts(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mgmtv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	reboot := &mgmtv1alpha1.HostAction{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "reboot"},
+		Status: mgmtv1alpha1.HostActionStatus{
+			Phase: mgmtv1alpha1.HostActionPhaseRunning,
+			Hosts: []mgmtv1alpha1.HostActionHostStatus{
+				{Host: "node-1", Phase: mgmtv1alpha1.HostActionPhaseRunning},
+				{Host: "node-2", Phase: mgmtv1alpha1.HostActionPhaseSucceeded},
+			},
+		},
+	}
+	completed := &mgmtv1alpha1.HostAction{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "completed"},
+		Status: mgmtv1alpha1.HostActionStatus{
+			Phase: mgmtv1alpha1.HostActionPhaseFailed,
+			Hosts: []mgmtv1alpha1.HostActionHostStatus{
+				{Host: "node-3", Phase: mgmtv1alpha1.HostActionPhaseRunning},
+			},
+		},
+	}
+	monthly := &mgmtv1alpha1.HostUpgradePolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "monthly"},
+		Status: mgmtv1alpha1.HostUpgradePolicyStatus{
+			// Hosts of a paused rollout still complete their upgrade.
+			Phase: mgmtv1alpha1.HostUpgradePolicyPhasePaused,
+			Hosts: []mgmtv1alpha1.HostUpgradeHostStatus{
+				{Host: "node-4", Phase: mgmtv1alpha1.HostUpgradePhaseRebooting},
+				{Host: "node-5", Phase: mgmtv1alpha1.HostUpgradePhaseFailed},
+			},
+		},
+	}
+	other := &mgmtv1alpha1.HostAction{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "reboot"},
+		Status: mgmtv1alpha1.HostActionStatus{
+			Phase: mgmtv1alpha1.HostActionPhaseRunning,
+			Hosts: []mgmtv1alpha1.HostActionHostStatus{
+				{Host: "node-5", Phase: mgmtv1alpha1.HostActionPhaseRunning},
+			},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(reboot, completed, monthly, other).Build()
+	hosts := []string{"node-1", "node-2", "node-3", "node-4", "node-5"}
+
+	tests := []struct {
+		name  string
+		owner client.Object
+		hosts []string
+		want  []string
+	}{
+		{
+			name:  "new HostAction",
+			owner: &mgmtv1alpha1.HostAction{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "drain"}},
+			hosts: hosts,
+			want:  []string{"node-1", "node-4"},
+		},
+		{
+			name:  "running HostAction",
+			owner: reboot,
+			hosts: hosts,
+			want:  []string{"node-4"},
+		},
+		{
+			name:  "rollout",
+			owner: monthly,
+			hosts: hosts,
+			want:  []string{"node-1"},
+		},
+		{
+			name:  "unselected hosts",
+			owner: monthly,
+			hosts: []string{"node-2", "node-4"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			busy, err := busyHosts(context.Background(), c, "default", test.hosts, test.owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := make(map[string]bool)
+			for _, host := range test.want {
+				want[host] = true
+			}
+			if fmt.Sprint(busy) != fmt.Sprint(want) {
+				t.Errorf("busyHosts() = %v, want %v", busy, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+// selectHosts returns the hosts in the namespace that match the label
+// selector. The hosts are sorted by name to ensure a stable order.
+func selectHosts(ctx context.Context, c client.Client, namespace string, selector *metav1.LabelSelector) ([]mgmtv1alpha1.Host, error) {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse host selector: %s", err)
+	}
+
+	hostList := &mgmtv1alpha1.HostList{}
+	if err := c.List(ctx, hostList, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hostList.Items, func(i, j int) bool {
+		return hostList.Items[i].Name < hostList.Items[j].Name
+	})
+
+	return hostList.Items, nil
+}
+
+// enqueueNamespace returns a map function, which triggers a reconciliation of
+// all objects of a list type in the namespace of the changed object. This is
+// used to reevaluate host selectors if a host changes.
+func enqueueNamespace(c client.Client, newList func() client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		list := newList()
+		if err := c.List(ctx, list, client.InNamespace(obj.GetNamespace())); err != nil {
+			return []reconcile.Request{}
+		}
+
+		return listRequests(list)
+	}
+}
+
+// hostDiscoveryChanged returns a predicate, which only passes updates of hosts
+// whose discovered operating system or capabilities changed. This allows
+// controllers that depend on the discovered OS family to ignore other changes
+// to the status of a host, such as the SSH fingerprints or conditions.
+func hostDiscoveryChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldHost, ok := e.ObjectOld.(*mgmtv1alpha1.Host)
+			if !ok {
+				return false
+			}
+			newHost, ok := e.ObjectNew.(*mgmtv1alpha1.Host)
+			if !ok {
+				return false
+			}
+
+			return !equality.Semantic.DeepEqual(oldHost.Status.OS, newHost.Status.OS) ||
+				!equality.Semantic.DeepEqual(oldHost.Status.Capabilities, newHost.Status.Capabilities)
+		},
+	}
+}
+
+// enqueueField returns a map function, which triggers a reconciliation of all
+// objects of a list type in the namespace of the changed object that reference
+// it via the indexed field. This is used to react to changes of referenced
+// ConfigMaps and Secrets.
+func enqueueField(c client.Client, newList func() client.ObjectList, field string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		list := newList()
+		listOpts := &client.ListOptions{
+			Namespace:     obj.GetNamespace(),
+			FieldSelector: fields.OneTermEqualSelector(field, obj.GetName()),
+		}
+		if err := c.List(ctx, list, listOpts); err != nil {
+			return []reconcile.Request{}
+		}
+
+		return listRequests(list)
+	}
+}
+
+// listRequests creates a reconciliation request for each object of the list.
+func listRequests(list client.ObjectList) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, meta.LenList(list))
+	meta.EachListItem(list, func(item runtime.Object) error {
+		object := item.(client.Object)
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      object.GetName(),
+				Namespace: object.GetNamespace(),
+			},
+		})
+		return nil
+	})
+	return requests
+}
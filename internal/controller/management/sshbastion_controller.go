@@ -0,0 +1,145 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	sshBastionControllerName = "sshbastion-controller"
+	bastionSecretField       = ".spec.secretRef.name"
+)
+
+// SSHBastionReconciler reconciles a SSHBastion object
+type SSHBastionReconciler struct {
+	client.Client
+	recorder    record.EventRecorder
+	Scheme      *runtime.Scheme
+	Connections *management.Manager
+}
+
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=sshbastions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=sshbastions/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.kraut.nicklasfrahm.dev,resources=sshbastions/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile verifies that the shared connection to an SSHBastion can be
+// established and records its host key if trust on first use is enabled.
+func (r *SSHBastionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	bastion := new(mgmtv1alpha1.SSHBastion)
+	if err := r.Get(ctx, req.NamespacedName, bastion); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			// Close the shared connection of a deleted bastion.
+			r.Connections.InvalidateBastion(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	condition := metav1.Condition{
+		Type:               mgmtv1alpha1.SSHBastionConditionReachable,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: bastion.Generation,
+		Reason:             "Connected",
+		Message:            "The bastion accepted the connection.",
+	}
+	modified := false
+
+	hostKeys, err := r.Connections.CheckBastion(ctx, req.NamespacedName)
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ConnectionFailed"
+		condition.Message = err.Error()
+
+		var mismatch *common.HostKeyMismatchError
+		var notPermitted *common.ReferenceNotPermittedError
+		if errors.As(err, &notPermitted) {
+			condition.Reason = mgmtv1alpha1.HostConditionReferenceNotPermitted
+			condition.Message = fmt.Sprintf("Secret %s does not grant access to namespace %s via the annotation %s.", notPermitted.SecretRef, notPermitted.Namespace, common.AllowedNamespacesAnnotation)
+			r.recorder.Event(bastion, corev1.EventTypeWarning, mgmtv1alpha1.HostConditionReferenceNotPermitted, condition.Message)
+		} else if errors.As(err, &mismatch) {
+			condition.Reason = "FingerprintMismatch"
+			condition.Message = fmt.Sprintf("Bastion %s presented host key %s, but %s was expected.", mismatch.Address, mismatch.Presented, mismatch.Expected)
+			r.recorder.Event(bastion, corev1.EventTypeWarning, mgmtv1alpha1.HostConditionHostKeyChanged, condition.Message)
+		} else {
+			r.recorder.Event(bastion, corev1.EventTypeWarning, "ConnectionFailed", err.Error())
+			logger.Error(err, "failed to connect to bastion")
+		}
+	} else if bastion.Spec.TrustOnFirstUse && bastion.Spec.Fingerprint == "" && bastion.Status.Fingerprint == "" && hostKeys.Fingerprint != "" {
+		bastion.Status.Fingerprint = hostKeys.Fingerprint
+		modified = true
+		r.recorder.Event(bastion, corev1.EventTypeNormal, "HostKeyTrusted", fmt.Sprintf("Trusted host key %s on first use.", hostKeys.Fingerprint))
+	}
+
+	if meta.SetStatusCondition(&bastion.Status.Conditions, condition) {
+		modified = true
+	}
+	if modified {
+		if err := r.Status().Update(ctx, bastion); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SSHBastionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(sshBastionControllerName)
+
+	// We need to add an index for the secret name so that we can
+	// trigger a reconciliation if the referenced secret changes.
+	err := mgr.GetFieldIndexer().IndexField(context.TODO(), &mgmtv1alpha1.SSHBastion{}, bastionSecretField, func(rawObj client.Object) []string {
+		bastion := rawObj.(*mgmtv1alpha1.SSHBastion)
+		return []string{bastion.Spec.SecretRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mgmtv1alpha1.SSHBastion{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Watch for changes to referenced secrets.
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(enqueueField(r.Client, newSSHBastionList, bastionSecretField))).
+		Complete(r)
+}
+
+// newSSHBastionList creates an empty list of SSHBastions.
+func newSSHBastionList() client.ObjectList {
+	return &mgmtv1alpha1.SSHBastionList{}
+}
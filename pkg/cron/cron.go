@@ -0,0 +1,213 @@
+// Package cron implements a parser for cron expressions, which
+// allows to compute the activation times of a schedule.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field describes the bounds of a field of a cron expression.
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minutes    = field{name: "minute", min: 0, max: 59}
+	hours      = field{name: "hour", min: 0, max: 23}
+	daysOfMon  = field{name: "day of month", min: 1, max: 31}
+	months     = field{name: "month", min: 1, max: 12, names: monthNames}
+	daysOfWeek = field{name: "day of week", min: 0, max: 6, names: dayNames}
+)
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// macros maps the supported shorthands to their cron expressions.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+
+	// Restricting both the day of the month and the day of the week
+	// means that either of them must match instead of both.
+	dayOfMonthWildcard bool
+	dayOfWeekWildcard  bool
+}
+
+// Parse parses a standard cron expression with five fields, i.e. minute,
+// hour, day of month, month and day of week. Each field supports wildcards,
+// lists, ranges and steps. Months and days of the week may also be specified
+// by their three-letter names. The macros `@yearly`, `@monthly`, `@weekly`,
+// `@daily` and `@hourly` are supported as well.
+func Parse(expression string) (*Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if macro, ok := macros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression: expected 5 fields, got %d: %q", len(fields), expression)
+	}
+
+	schedule := &Schedule{
+		dayOfMonthWildcard: fields[2] == "*" || fields[2] == "?",
+		dayOfWeekWildcard:  fields[4] == "*" || fields[4] == "?",
+	}
+
+	var err error
+	if schedule.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, err
+	}
+	if schedule.dayOfMonth, err = parseField(fields[2], daysOfMon); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseField(fields[3], months); err != nil {
+		return nil, err
+	}
+	// Sunday may be specified as 0 or 7.
+	dayOfWeek := daysOfWeek
+	dayOfWeek.max = 7
+	if schedule.dayOfWeek, err = parseField(fields[4], dayOfWeek); err != nil {
+		return nil, err
+	}
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+
+	return schedule, nil
+}
+
+// parseField parses a single field of a cron expression into a bit set.
+func parseField(expression string, f field) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(expression, ",") {
+		rangeExpression, stepExpression, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepExpression)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid cron expression: invalid step in %s field: %q", f.name, part)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangeExpression != "*" && rangeExpression != "?" {
+			lower, upper, isRange := strings.Cut(rangeExpression, "-")
+
+			var err error
+			if start, err = parseValue(lower, f); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = parseValue(upper, f); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = f.max
+			}
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("invalid cron expression: invalid range in %s field: %q", f.name, part)
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+// parseValue parses a single numeric or named value of a field.
+func parseValue(expression string, f field) (int, error) {
+	if value, ok := f.names[strings.ToLower(expression)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(expression)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("invalid cron expression: invalid value in %s field: %q", f.name, expression)
+	}
+
+	return value, nil
+}
+
+// Next returns the first activation time of the schedule after the given time.
+// The activation times are computed in the location of the given time. A zero
+// time is returned if there is no activation within the next five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// matchesDay checks if the day of the given time matches the schedule.
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if s.dayOfMonthWildcard || s.dayOfWeekWildcard {
+		return dayOfMonth && dayOfWeek
+	}
+
+	return dayOfMonth || dayOfWeek
+}
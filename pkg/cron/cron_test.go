@@ -0,0 +1,182 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{expression: "* * * * *"},
+		{expression: "0 3 * * 6"},
+		{expression: "*/15 * * * *"},
+		{expression: "0 9-17/2 * * mon-fri"},
+		{expression: "0 0 1,15 * *"},
+		{expression: "0 0 * jan,jul sun"},
+		{expression: "0 0 * * 7"},
+		{expression: "0 0 ? * *"},
+		{expression: "  @daily  "},
+		{expression: "@Weekly"},
+		{expression: "", wantErr: true},
+		{expression: "* * * *", wantErr: true},
+		{expression: "* * * * * *", wantErr: true},
+		{expression: "60 * * * *", wantErr: true},
+		{expression: "* 24 * * *", wantErr: true},
+		{expression: "* * 0 * *", wantErr: true},
+		{expression: "* * 32 * *", wantErr: true},
+		{expression: "* * * 13 *", wantErr: true},
+		{expression: "* * * * 8", wantErr: true},
+		{expression: "* * * * foo", wantErr: true},
+		{expression: "5-1 * * * *", wantErr: true},
+		{expression: "*/0 * * * *", wantErr: true},
+		{expression: "*/x * * * *", wantErr: true},
+		{expression: "1,,2 * * * *", wantErr: true},
+		{expression: "@reboot", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := Parse(test.expression)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Parse(%q) error = %v, want error %t", test.expression, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		from       string
+		want       []string
+	}{
+		{
+			name:       "every minute skips the current minute",
+			expression: "* * * * *",
+			from:       "2024-01-01T10:00:30Z",
+			want:       []string{"2024-01-01T10:01:00Z", "2024-01-01T10:02:00Z"},
+		},
+		{
+			name:       "step",
+			expression: "*/20 * * * *",
+			from:       "2024-01-01T10:05:00Z",
+			want:       []string{"2024-01-01T10:20:00Z", "2024-01-01T10:40:00Z", "2024-01-01T11:00:00Z"},
+		},
+		{
+			name:       "step with start",
+			expression: "5/20 * * * *",
+			from:       "2024-01-01T10:00:00Z",
+			want:       []string{"2024-01-01T10:05:00Z", "2024-01-01T10:25:00Z", "2024-01-01T10:45:00Z", "2024-01-01T11:05:00Z"},
+		},
+		{
+			name:       "range with step",
+			expression: "0 9-17/4 * * *",
+			from:       "2024-01-01T10:00:00Z",
+			want:       []string{"2024-01-01T13:00:00Z", "2024-01-01T17:00:00Z", "2024-01-02T09:00:00Z"},
+		},
+		{
+			name:       "list",
+			expression: "30 6,18 * * *",
+			from:       "2024-01-01T07:00:00Z",
+			want:       []string{"2024-01-01T18:30:00Z", "2024-01-02T06:30:00Z"},
+		},
+		{
+			name:       "day of week",
+			expression: "0 3 * * 6",
+			from:       "2024-01-01T00:00:00Z",
+			want:       []string{"2024-01-06T03:00:00Z", "2024-01-13T03:00:00Z"},
+		},
+		{
+			name:       "day of week range by name",
+			expression: "0 8 * * mon-fri",
+			from:       "2024-01-05T09:00:00Z",
+			want:       []string{"2024-01-08T08:00:00Z", "2024-01-09T08:00:00Z"},
+		},
+		{
+			name:       "sunday as 7",
+			expression: "0 0 * * 7",
+			from:       "2024-01-01T00:00:00Z",
+			want:       []string{"2024-01-07T00:00:00Z", "2024-01-14T00:00:00Z"},
+		},
+		{
+			name:       "day of month or day of week",
+			expression: "0 0 13 * fri",
+			from:       "2024-09-01T00:00:00Z",
+			// Fridays and the 13th of the month.
+			want: []string{"2024-09-06T00:00:00Z", "2024-09-13T00:00:00Z", "2024-09-20T00:00:00Z", "2024-09-27T00:00:00Z", "2024-10-04T00:00:00Z", "2024-10-11T00:00:00Z", "2024-10-13T00:00:00Z"},
+		},
+		{
+			name:       "day of month with wildcard day of week",
+			expression: "0 0 15 * *",
+			from:       "2024-01-20T00:00:00Z",
+			want:       []string{"2024-02-15T00:00:00Z", "2024-03-15T00:00:00Z"},
+		},
+		{
+			name:       "month rollover",
+			expression: "0 0 31 * *",
+			from:       "2024-01-31T12:00:00Z",
+			want:       []string{"2024-03-31T00:00:00Z", "2024-05-31T00:00:00Z"},
+		},
+		{
+			name:       "year rollover",
+			expression: "@yearly",
+			from:       "2024-06-15T00:00:00Z",
+			want:       []string{"2025-01-01T00:00:00Z", "2026-01-01T00:00:00Z"},
+		},
+		{
+			name:       "month by name",
+			expression: "0 12 1 jul *",
+			from:       "2024-07-01T12:00:00Z",
+			want:       []string{"2025-07-01T12:00:00Z"},
+		},
+		{
+			name:       "leap day",
+			expression: "0 0 29 2 *",
+			from:       "2024-03-01T00:00:00Z",
+			want:       []string{"2028-02-29T00:00:00Z"},
+		},
+		{
+			name:       "never",
+			expression: "0 0 30 2 *",
+			from:       "2024-01-01T00:00:00Z",
+			want:       []string{"0001-01-01T00:00:00Z"},
+		},
+		{
+			name:       "location",
+			expression: "0 2 * * *",
+			from:       "2024-01-01T03:00:00+01:00",
+			want:       []string{"2024-01-02T02:00:00+01:00"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := Parse(test.expression)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", test.expression, err)
+			}
+
+			current, err := time.Parse(time.RFC3339, test.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, raw := range test.want {
+				want, err := time.Parse(time.RFC3339, raw)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				next := schedule.Next(current)
+				if !next.Equal(want) {
+					t.Fatalf("Next(%s) = %s, want %s", current.Format(time.RFC3339), next.Format(time.RFC3339), raw)
+				}
+				current = next
+			}
+		})
+	}
+}
@@ -9,17 +9,47 @@ import (
 
 	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
 	"github.com/nicklasfrahm/kraut/pkg/management/common"
+	"github.com/nicklasfrahm/kraut/pkg/management/ipmi"
+	"github.com/nicklasfrahm/kraut/pkg/management/netconf"
+	"github.com/nicklasfrahm/kraut/pkg/management/redfish"
+	"github.com/nicklasfrahm/kraut/pkg/management/snmp"
 	"github.com/nicklasfrahm/kraut/pkg/management/ssh"
 )
 
 var clientFactories = map[mgmtv1alpha1.Protocol]common.ClientFactory{
-	mgmtv1alpha1.ProtocolSSH: ssh.NewClient,
-	// TODO: Add support for SNMP.
+	mgmtv1alpha1.ProtocolSSH:     ssh.NewClient,
+	mgmtv1alpha1.ProtocolSNMP:    snmp.NewClient,
+	mgmtv1alpha1.ProtocolNETCONF: netconf.NewClient,
+}
+
+var outOfBandClientFactories = map[mgmtv1alpha1.OutOfBandProtocol]common.OutOfBandClientFactory{
+	mgmtv1alpha1.OutOfBandProtocolRedfish: redfish.NewClient,
+	mgmtv1alpha1.OutOfBandProtocolIPMI:    ipmi.NewClient,
 }
 
 // NewClient returns a new client for the given host. Client will also implicitly
 // connect to the appliance without an explicit call to Connect().
-func NewClient(hostRef types.NamespacedName, options ...common.Option) (common.Client, error) {
+func NewClient(ctx context.Context, hostRef types.NamespacedName, options ...common.Option) (common.Client, error) {
+	opts, err := common.GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.KubernetesClient == nil {
+		return nil, fmt.Errorf("missing required option: WithKubernetesClient()")
+	}
+
+	host, err := getHost(ctx, opts.KubernetesClient, hostRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return connect(ctx, host, opts)
+}
+
+// NewOutOfBandClient returns a new client for the BMC of the given host. Client
+// will also implicitly connect to the BMC without an explicit call to Connect().
+func NewOutOfBandClient(ctx context.Context, hostRef types.NamespacedName, options ...common.Option) (common.OutOfBandClient, error) {
 	opts, err := common.GetDefaultOptions().Apply(options...)
 	if err != nil {
 		return nil, err
@@ -29,8 +59,18 @@ func NewClient(hostRef types.NamespacedName, options ...common.Option) (common.C
 		return nil, fmt.Errorf("missing required option: WithKubernetesClient()")
 	}
 
+	host, err := getHost(ctx, opts.KubernetesClient, hostRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return connectOutOfBand(ctx, host, opts)
+}
+
+// getHost fetches the host from the Kubernetes API.
+func getHost(ctx context.Context, kube client.Client, hostRef types.NamespacedName) (*mgmtv1alpha1.Host, error) {
 	host := new(mgmtv1alpha1.Host)
-	err = opts.KubernetesClient.Get(context.TODO(), hostRef, host)
+	err := kube.Get(ctx, hostRef, host)
 	if err != nil {
 		if client.IgnoreNotFound(err) == nil {
 			return nil, fmt.Errorf("failed to read Host: %s/%s", hostRef.Namespace, hostRef.Name)
@@ -38,19 +78,48 @@ func NewClient(hostRef types.NamespacedName, options ...common.Option) (common.C
 		return nil, err
 	}
 
+	return host, nil
+}
+
+// connect creates a new client for the protocol of the host and connects to it.
+func connect(ctx context.Context, host *mgmtv1alpha1.Host, opts *common.Options) (common.Client, error) {
 	newClient := clientFactories[host.Spec.Protocol]
 	if newClient == nil {
 		return nil, fmt.Errorf("unknown protocol: %s", host.Spec.Protocol)
 	}
 
-	mgmt, err := newClient(host, common.WithKubernetesClient(opts.KubernetesClient))
+	mgmt, err := newClient(ctx, host, common.WithOptions(opts))
 	if err != nil {
 		return nil, err
 	}
 
-	if err := mgmt.Connect(); err != nil {
+	if err := mgmt.Connect(ctx); err != nil {
 		return nil, err
 	}
 
 	return mgmt, nil
 }
+
+// connectOutOfBand creates a new client for the out-of-band
+// protocol of the host and connects to its BMC.
+func connectOutOfBand(ctx context.Context, host *mgmtv1alpha1.Host, opts *common.Options) (common.OutOfBandClient, error) {
+	if host.Spec.OutOfBand == nil {
+		return nil, fmt.Errorf("host has no out-of-band endpoint: %s/%s", host.Namespace, host.Name)
+	}
+
+	newClient := outOfBandClientFactories[host.Spec.OutOfBand.Protocol]
+	if newClient == nil {
+		return nil, fmt.Errorf("unknown out-of-band protocol: %s", host.Spec.OutOfBand.Protocol)
+	}
+
+	oob, err := newClient(ctx, host, common.WithOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := oob.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	return oob, nil
+}
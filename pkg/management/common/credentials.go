@@ -0,0 +1,324 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Credentials contains the credentials that are used to authenticate with a host.
+type Credentials struct {
+	// Key is a private key in the PEM format.
+	Key string
+	// Passphrase is the passphrase of an encrypted private key.
+	Passphrase string
+	// Password is a password, which is only used if no private key is provided.
+	Password string
+	// Certificate is the user certificate of the private key in the authorized keys format.
+	Certificate string
+	// Signers are used for the public key authentication if no private key is
+	// provided, e.g. because the private keys are held by an SSH agent.
+	Signers []ssh.Signer
+	// Community is the SNMPv2c community.
+	Community string
+	// AuthPassphrase is the SNMPv3 authentication passphrase.
+	AuthPassphrase string
+	// PrivPassphrase is the SNMPv3 privacy passphrase.
+	PrivPassphrase string
+}
+
+// CredentialReference identifies the credentials of a host, a bastion or a jump host.
+type CredentialReference struct {
+	// SecretRef is the reference to the Secret that contains the credentials.
+	SecretRef types.NamespacedName
+	// Namespace is the namespace of the object that references the Secret.
+	// References to Secrets in other namespaces must be granted by the Secret.
+	Namespace string
+	// Proxy selects the credentials of the legacy proxy of a host,
+	// which are stored in the keys of ProxySecretKeys.
+	Proxy bool
+	// Optional tolerates missing credentials, e.g. because
+	// short-lived certificates are used for the authentication.
+	Optional bool
+}
+
+// CredentialSource provides the credentials that are used to authenticate with hosts.
+type CredentialSource interface {
+	// Credentials returns the credentials for the reference.
+	Credentials(ctx context.Context, ref CredentialReference) (*Credentials, error)
+}
+
+// ReadCredentials reads credentials from the credential source of the options,
+// which defaults to the Secrets. References to Secrets in other namespaces are
+// refused, unless they are granted, regardless of the credential source.
+func ReadCredentials(ctx context.Context, opts *Options, ref CredentialReference) (Credentials, error) {
+	if err := CheckSecretReference(ctx, opts.KubernetesClient, ref.Namespace, ref.SecretRef); err != nil {
+		return Credentials{}, err
+	}
+
+	source := opts.CredentialSource
+	if source == nil {
+		source = NewSecretCredentialSource(opts.KubernetesClient, DefaultSecretKeys)
+	}
+
+	credentials, err := source.Credentials(ctx, ref)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	return *credentials, nil
+}
+
+// SecretKeys are the names of the keys of a Secret that contain the credentials.
+type SecretKeys struct {
+	// Key is the name of the key that contains the private key.
+	Key string
+	// Passphrase is the name of the key that contains the passphrase of the private key.
+	Passphrase string
+	// Password is the name of the key that contains the password.
+	Password string
+	// Certificate is the name of the key that contains the user certificate.
+	Certificate string
+	// Community is the name of the key that contains the SNMPv2c community.
+	Community string
+	// AuthPassphrase is the name of the key that contains the SNMPv3 authentication passphrase.
+	AuthPassphrase string
+	// PrivPassphrase is the name of the key that contains the SNMPv3 privacy passphrase.
+	PrivPassphrase string
+}
+
+var (
+	// DefaultSecretKeys are the default names of the keys of a Secret that contain the credentials.
+	DefaultSecretKeys = SecretKeys{
+		Key:            "key",
+		Passphrase:     "passphrase",
+		Password:       "passwordInsecure",
+		Certificate:    "certificate",
+		Community:      "community",
+		AuthPassphrase: "authPassphrase",
+		PrivPassphrase: "privPassphrase",
+	}
+	// ProxySecretKeys are the names of the keys of a
+	// Secret that contain the credentials of the legacy proxy.
+	ProxySecretKeys = SecretKeys{
+		Key:         "proxyKey",
+		Passphrase:  "proxyPassphrase",
+		Password:    "proxyPasswordInsecure",
+		Certificate: "proxyCertificate",
+	}
+)
+
+// ParseSecretKeys overrides the default key names with a comma-separated list
+// of assignments in the format `<field>=<key>`, such as `key=ssh-privatekey`.
+// The fields are `key`, `passphrase`, `password`, `certificate`, `community`,
+// `authPassphrase` and `privPassphrase`.
+func ParseSecretKeys(value string) (SecretKeys, error) {
+	keys := DefaultSecretKeys
+	if value == "" {
+		return keys, nil
+	}
+
+	for _, assignment := range strings.Split(value, ",") {
+		field, key, ok := strings.Cut(strings.TrimSpace(assignment), "=")
+		if !ok || key == "" {
+			return keys, fmt.Errorf("invalid secret key assignment: %q", assignment)
+		}
+
+		switch field {
+		case "key":
+			keys.Key = key
+		case "passphrase":
+			keys.Passphrase = key
+		case "password":
+			keys.Password = key
+		case "certificate":
+			keys.Certificate = key
+		case "community":
+			keys.Community = key
+		case "authPassphrase":
+			keys.AuthPassphrase = key
+		case "privPassphrase":
+			keys.PrivPassphrase = key
+		default:
+			return keys, fmt.Errorf("unknown secret key field: %q", field)
+		}
+	}
+
+	return keys, nil
+}
+
+// credentials extracts the credentials from the data of a Secret.
+func (k SecretKeys) credentials(data map[string][]byte) *Credentials {
+	return &Credentials{
+		Key:            string(data[k.Key]),
+		Passphrase:     string(data[k.Passphrase]),
+		Password:       string(data[k.Password]),
+		Certificate:    string(data[k.Certificate]),
+		Community:      string(data[k.Community]),
+		AuthPassphrase: string(data[k.AuthPassphrase]),
+		PrivPassphrase: string(data[k.PrivPassphrase]),
+	}
+}
+
+// SecretCredentialSource reads credentials from Kubernetes Secrets.
+type SecretCredentialSource struct {
+	kube client.Client
+	// Keys are the names of the keys that contain the credentials.
+	Keys SecretKeys
+}
+
+// NewSecretCredentialSource creates a credential source that reads
+// credentials from the keys of Kubernetes Secrets.
+func NewSecretCredentialSource(kube client.Client, keys SecretKeys) *SecretCredentialSource {
+	return &SecretCredentialSource{
+		kube: kube,
+		Keys: keys,
+	}
+}
+
+// Credentials returns the credentials from the referenced Secret.
+func (s *SecretCredentialSource) Credentials(ctx context.Context, ref CredentialReference) (*Credentials, error) {
+	secret := new(corev1.Secret)
+	if err := s.kube.Get(ctx, ref.SecretRef, secret); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		if !ref.Optional {
+			return nil, fmt.Errorf("failed to read Secret: %s/%s", ref.SecretRef.Namespace, ref.SecretRef.Name)
+		}
+	}
+
+	if ref.Proxy {
+		return ProxySecretKeys.credentials(secret.Data), nil
+	}
+
+	return s.Keys.credentials(secret.Data), nil
+}
+
+// FileCredentialSource reads credentials from files, which is useful for local
+// development. The files are laid out like mounted Secrets, i.e. the key `key`
+// of the Secret `<namespace>/<name>` is read from `<directory>/<namespace>/<name>/key`.
+type FileCredentialSource struct {
+	// Directory is the directory that contains the credentials.
+	Directory string
+	// Keys are the names of the files that contain the credentials.
+	Keys SecretKeys
+}
+
+// NewFileCredentialSource creates a credential source that reads credentials from files.
+func NewFileCredentialSource(directory string, keys SecretKeys) *FileCredentialSource {
+	return &FileCredentialSource{
+		Directory: directory,
+		Keys:      keys,
+	}
+}
+
+// Credentials returns the credentials from the files of the referenced Secret.
+func (s *FileCredentialSource) Credentials(ctx context.Context, ref CredentialReference) (*Credentials, error) {
+	directory := filepath.Join(s.Directory, ref.SecretRef.Namespace, ref.SecretRef.Name)
+	if _, err := os.Stat(directory); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if !ref.Optional {
+			return nil, fmt.Errorf("failed to read credentials: %s", directory)
+		}
+	}
+
+	keys := s.Keys
+	if ref.Proxy {
+		keys = ProxySecretKeys
+	}
+
+	data := make(map[string][]byte)
+	for _, key := range []string{keys.Key, keys.Passphrase, keys.Password, keys.Certificate, keys.Community, keys.AuthPassphrase, keys.PrivPassphrase} {
+		// The legacy proxy has no SNMP credentials.
+		if key == "" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(directory, filepath.Base(key)))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		data[key] = content
+	}
+
+	return keys.credentials(data), nil
+}
+
+// AgentCredentialSource provides the keys of an SSH agent, which is reachable
+// via a Unix socket. The private keys never leave the agent. All keys are
+// offered to every host, regardless of the reference.
+type AgentCredentialSource struct {
+	// Socket is the path of the Unix socket of the agent.
+	Socket string
+
+	mutex sync.Mutex
+	conn  net.Conn
+	agent agent.ExtendedAgent
+}
+
+// NewAgentCredentialSource creates a credential source
+// that uses the keys of the SSH agent at the socket.
+func NewAgentCredentialSource(socket string) *AgentCredentialSource {
+	return &AgentCredentialSource{
+		Socket: socket,
+	}
+}
+
+// Credentials returns signers for the keys of the agent.
+func (s *AgentCredentialSource) Credentials(ctx context.Context, ref CredentialReference) (*Credentials, error) {
+	signers, err := s.signers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(signers) == 0 && !ref.Optional {
+		return nil, errors.New("failed to list keys of SSH agent: no keys available")
+	}
+
+	return &Credentials{Signers: signers}, nil
+}
+
+// signers returns signers for the keys of the agent. The connection to the
+// agent is kept open, because the signers forward the signature requests to
+// it. It is reestablished once if the agent was restarted.
+func (s *AgentCredentialSource) signers(ctx context.Context) ([]ssh.Signer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.agent != nil {
+		signers, err := s.agent.Signers()
+		if err == nil {
+			return signers, nil
+		}
+		s.conn.Close()
+		s.conn, s.agent = nil, nil
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "unix", s.Socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH agent: %w", err)
+	}
+	s.conn, s.agent = conn, agent.NewClient(conn)
+
+	signers, err := s.agent.Signers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys of SSH agent: %w", err)
+	}
+
+	return signers, nil
+}
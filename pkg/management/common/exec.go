@@ -0,0 +1,81 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Command describes a command that is executed on a host.
+type Command struct {
+	// Command is the command line that is interpreted by the shell of the host.
+	Command string
+	// Stdin is provided to the command as its standard input, if set.
+	Stdin io.Reader
+	// Env contains additional environment variables for the command.
+	Env map[string]string
+	// Timeout overrides the default command timeout, if set.
+	Timeout time.Duration
+}
+
+// String compiles the command line that is executed on the host. Environment
+// variables are injected via `env`, because SSH servers commonly reject
+// environment variables that are not explicitly allowed.
+func (c *Command) String() string {
+	if len(c.Env) == 0 {
+		return c.Command
+	}
+
+	keys := make([]string, 0, len(c.Env))
+	for key := range c.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	builder.WriteString("env")
+	for _, key := range keys {
+		builder.WriteString(" ")
+		builder.WriteString(ShellQuote(fmt.Sprintf("%s=%s", key, c.Env[key])))
+	}
+	builder.WriteString(" sh -c ")
+	builder.WriteString(ShellQuote(c.Command))
+
+	return builder.String()
+}
+
+// CommandResult contains the result of a command that was executed on a host.
+type CommandResult struct {
+	// Stdout is the standard output of the command. It
+	// is empty if the output was streamed to a writer.
+	Stdout []byte
+	// Stderr is the standard error of the command. It
+	// is empty if the output was streamed to a writer.
+	Stderr []byte
+	// ExitCode is the exit code of the command.
+	ExitCode int
+	// Duration is the time it took to execute the command.
+	Duration time.Duration
+}
+
+// Err returns an error if the command exited with a non-zero exit code.
+func (r *CommandResult) Err() error {
+	if r.ExitCode == 0 {
+		return nil
+	}
+
+	stderr := strings.TrimSpace(string(r.Stderr))
+	if stderr == "" {
+		return fmt.Errorf("command failed with exit code %d", r.ExitCode)
+	}
+
+	return fmt.Errorf("command failed with exit code %d: %s", r.ExitCode, stderr)
+}
+
+// ShellQuote quotes a string such that it is
+// interpreted literally by a POSIX shell.
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ExitCodeNotExist is the exit code used by shell
+// commands to indicate that a file does not exist.
+const ExitCodeNotExist = 3
+
+// FileInfo describes a file on a host.
+type FileInfo struct {
+	// Size is the size of the file in bytes.
+	Size int64
+	// Mode contains the permission bits and the type of the file.
+	Mode os.FileMode
+	// UID is the numeric ID of the user that owns the file.
+	UID int
+	// GID is the numeric ID of the group that owns the file.
+	GID int
+	// ModTime is the time of the last modification.
+	ModTime time.Time
+}
+
+// FileOptions configures how a file is written to a host.
+type FileOptions struct {
+	// Mode contains the permission bits of the file.
+	// Defaults to 0644 if not specified.
+	Mode os.FileMode
+	// Owner is the name or the numeric ID of the user that should own the file.
+	Owner string
+	// Group is the name or the numeric ID of the group that should own the file.
+	Group string
+	// Atomic ensures that the file is written to a temporary file first, which
+	// is then renamed to the destination. This prevents readers from observing
+	// a partially written file.
+	Atomic bool
+}
+
+// FileMode returns the permission bits of the file,
+// including the setuid, setgid and sticky bits.
+func (o *FileOptions) FileMode() os.FileMode {
+	if o == nil || o.Mode == 0 {
+		return 0644
+	}
+
+	return o.Mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// UnixMode converts the permission bits of a file mode, including the setuid,
+// setgid and sticky bits, to the octal representation that chmod expects.
+func UnixMode(mode os.FileMode) uint32 {
+	unixMode := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		unixMode |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		unixMode |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		unixMode |= 01000
+	}
+
+	return unixMode
+}
+
+// Checksum returns the hex encoded SHA256 checksum of a file on the host. The
+// returned error wraps os.ErrNotExist if the file does not exist.
+func Checksum(ctx context.Context, c Client, filePath string) (string, error) {
+	quotedPath := ShellQuote(filePath)
+	result, err := c.Exec(ctx, &Command{
+		Command: fmt.Sprintf("[ -e %s ] || exit %d; sha256sum %s", quotedPath, ExitCodeNotExist, quotedPath),
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode == ExitCodeNotExist {
+		return "", fmt.Errorf("failed to checksum file: %s: %w", filePath, os.ErrNotExist)
+	}
+	if err := result.Err(); err != nil {
+		return "", err
+	}
+
+	checksum, _, _ := strings.Cut(strings.TrimSpace(string(result.Stdout)), " ")
+	if len(checksum) != 64 {
+		return "", fmt.Errorf("failed to parse checksum: %q", result.Stdout)
+	}
+
+	return checksum, nil
+}
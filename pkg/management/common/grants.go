@@ -0,0 +1,59 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AllowedNamespacesAnnotation is the annotation of a Secret that grants
+// objects in other namespaces access to it. Its value is a comma-separated
+// list of namespaces or `*` for all namespaces.
+const AllowedNamespacesAnnotation = "kraut.nicklasfrahm.dev/allowed-namespaces"
+
+// ReferenceNotPermittedError is returned if an object references
+// a Secret in another namespace that did not grant access to it.
+type ReferenceNotPermittedError struct {
+	// Namespace is the namespace of the referencing object.
+	Namespace string
+	// SecretRef is the reference to the Secret.
+	SecretRef types.NamespacedName
+}
+
+// Error implements the error interface.
+func (e *ReferenceNotPermittedError) Error() string {
+	return fmt.Sprintf("reference to Secret %s from namespace %s is not permitted, the Secret must list the namespace in the annotation %s", e.SecretRef, e.Namespace, AllowedNamespacesAnnotation)
+}
+
+// CheckSecretReference verifies that objects in the namespace may use the
+// referenced Secret. References within the same namespace are always permitted.
+// A missing Secret does not grant access to other namespaces.
+func CheckSecretReference(ctx context.Context, kube client.Client, namespace string, secretRef types.NamespacedName) error {
+	if secretRef.Namespace == namespace {
+		return nil
+	}
+
+	secret := new(corev1.Secret)
+	if err := kube.Get(ctx, secretRef, secret); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		return &ReferenceNotPermittedError{Namespace: namespace, SecretRef: secretRef}
+	}
+
+	for _, allowed := range strings.Split(secret.Annotations[AllowedNamespacesAnnotation], ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "" {
+			continue
+		}
+		if allowed == namespace || allowed == "*" {
+			return nil
+		}
+	}
+
+	return &ReferenceNotPermittedError{Namespace: namespace, SecretRef: secretRef}
+}
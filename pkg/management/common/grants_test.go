@@ -0,0 +1,241 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+// newGrantSecret creates a Secret in the platform namespace with the allowed namespaces.
+func newGrantSecret(name string, allowed string) *corev1.Secret {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: name}}
+	if allowed != "" {
+		secret.Annotations = map[string]string{AllowedNamespacesAnnotation: allowed}
+	}
+
+	return secret
+}
+
+// newGrantClient creates a Kubernetes client that knows the given Secrets.
+func newGrantClient(t *testing.T, secrets ...*corev1.Secret) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, secret := range secrets {
+		builder = builder.WithObjects(secret)
+	}
+
+	return builder.Build()
+}
+
+func TestCheckSecretReference(t *testing.T) {
+	kube := newGrantClient(t,
+		newGrantSecret("unannotated", ""),
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: "empty", Annotations: map[string]string{AllowedNamespacesAnnotation: ""}}},
+		newGrantSecret("single", "team-a"),
+		newGrantSecret("list", "team-b , team-a,team-c"),
+		newGrantSecret("wildcard", "*"),
+		newGrantSecret("affixes", "team,team-a-dev,dev-team-a"),
+	)
+
+	tests := []struct {
+		name      string
+		namespace string
+		secretRef types.NamespacedName
+		wantErr   bool
+	}{
+		{
+			name:      "same namespace",
+			namespace: "platform",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "unannotated"},
+		},
+		{
+			name:      "missing Secret in same namespace",
+			namespace: "platform",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "missing"},
+		},
+		{
+			name:      "missing Secret",
+			namespace: "team-a",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "missing"},
+			wantErr:   true,
+		},
+		{
+			name:      "missing annotation",
+			namespace: "team-a",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "unannotated"},
+			wantErr:   true,
+		},
+		{
+			name:      "empty annotation",
+			namespace: "team-a",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "empty"},
+			wantErr:   true,
+		},
+		{
+			name:      "single namespace",
+			namespace: "team-a",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "single"},
+		},
+		{
+			name:      "other namespace",
+			namespace: "team-b",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "single"},
+			wantErr:   true,
+		},
+		{
+			name:      "list with spaces",
+			namespace: "team-a",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "list"},
+		},
+		{
+			name:      "first entry of list with spaces",
+			namespace: "team-b",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "list"},
+		},
+		{
+			name:      "wildcard",
+			namespace: "team-a",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "wildcard"},
+		},
+		{
+			name:      "prefix of allowed namespaces",
+			namespace: "team-a",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "affixes"},
+			wantErr:   true,
+		},
+		{
+			name:      "allowed namespace is prefix",
+			namespace: "team-ab",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "single"},
+			wantErr:   true,
+		},
+		{
+			name:      "allowed namespace is suffix",
+			namespace: "dev-team-a",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "single"},
+			wantErr:   true,
+		},
+		{
+			name:      "empty namespace",
+			namespace: "",
+			secretRef: types.NamespacedName{Namespace: "platform", Name: "empty"},
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckSecretReference(context.Background(), kube, test.namespace, test.secretRef)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CheckSecretReference() error = %v, want error %t", err, test.wantErr)
+			}
+
+			var notPermitted *ReferenceNotPermittedError
+			if test.wantErr && !errors.As(err, &notPermitted) {
+				t.Errorf("CheckSecretReference() error = %v, want %T", err, notPermitted)
+			}
+		})
+	}
+}
+
+// grantedSource is a credential source that provides credentials for any reference.
+type grantedSource struct{}
+
+func (grantedSource) Credentials(ctx context.Context, ref CredentialReference) (*Credentials, error) {
+	return &Credentials{Password: "secret"}, nil
+}
+
+func TestReadCredentialsRefusesUngrantedReferences(t *testing.T) {
+	kube := newGrantClient(t, newGrantSecret("shared", ""), newGrantSecret("granted", "team-a"))
+	opts, err := GetDefaultOptions().Apply(WithKubernetesClient(kube), WithCredentialSource(grantedSource{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared := &corev1.SecretReference{Namespace: "platform", Name: "shared"}
+	granted := &corev1.SecretReference{Namespace: "platform", Name: "granted"}
+	host := func(jumpHostRef *corev1.SecretReference, outOfBandRef *corev1.SecretReference) *mgmtv1alpha1.Host {
+		return &mgmtv1alpha1.Host{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "node-1"},
+			Spec: mgmtv1alpha1.HostSpec{
+				SecretRef: corev1.SecretReference{Name: "node-1"},
+				SSH: mgmtv1alpha1.HostSpecSSHOptions{
+					JumpHosts: []mgmtv1alpha1.HostSpecSSHJumpHost{{Host: "jump.example.com", SecretRef: jumpHostRef}},
+				},
+				OutOfBand: &mgmtv1alpha1.HostSpecOutOfBand{SecretRef: outOfBandRef},
+			},
+		}
+	}
+	bastion := func(secretRef corev1.SecretReference) *mgmtv1alpha1.SSHBastion {
+		return &mgmtv1alpha1.SSHBastion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "bastion"},
+			Spec:       mgmtv1alpha1.SSHBastionSpec{SecretRef: secretRef},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		secretRef types.NamespacedName
+		wantErr   bool
+	}{
+		{
+			name:      "host",
+			secretRef: SecretReference(host(nil, nil)),
+		},
+		{
+			name:      "jump host",
+			secretRef: JumpHostSecretReference(host(shared, nil), &host(shared, nil).Spec.SSH.JumpHosts[0]),
+			wantErr:   true,
+		},
+		{
+			name:      "granted jump host",
+			secretRef: JumpHostSecretReference(host(granted, nil), &host(granted, nil).Spec.SSH.JumpHosts[0]),
+		},
+		{
+			name:      "bastion",
+			secretRef: BastionSecretReference(bastion(*shared)),
+			wantErr:   true,
+		},
+		{
+			name:      "granted bastion",
+			secretRef: BastionSecretReference(bastion(*granted)),
+		},
+		{
+			name:      "out-of-band",
+			secretRef: OutOfBandSecretReference(host(nil, shared)),
+			wantErr:   true,
+		},
+		{
+			name:      "granted out-of-band",
+			secretRef: OutOfBandSecretReference(host(nil, granted)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadCredentials(context.Background(), opts, CredentialReference{SecretRef: test.secretRef, Namespace: "team-a"})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ReadCredentials() error = %v, want error %t", err, test.wantErr)
+			}
+
+			var notPermitted *ReferenceNotPermittedError
+			if test.wantErr && (!errors.As(err, &notPermitted) || notPermitted.SecretRef != test.secretRef) {
+				t.Errorf("ReadCredentials() error = %v, want %T for %s", err, notPermitted, test.secretRef)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"fmt"
+)
+
+// HostKeys contains the fingerprints of the host keys that were
+// presented by a host, its proxy and its jump hosts while connecting.
+type HostKeys struct {
+	// Fingerprint is the host key fingerprint of the host.
+	Fingerprint string
+	// ProxyFingerprint is the host key fingerprint of the proxy, if any.
+	ProxyFingerprint string
+	// JumpHostFingerprints are the host key fingerprints
+	// of the jump hosts, keyed by their network address.
+	JumpHostFingerprints map[string]string
+}
+
+// HostKeyReporter is implemented by clients that
+// verify the identity of a host by its host key.
+type HostKeyReporter interface {
+	// HostKeys returns the fingerprints of the host keys that were presented while connecting.
+	HostKeys() HostKeys
+}
+
+// HostKeyMismatchError is returned if a host presents a
+// host key that does not match the expected fingerprint.
+type HostKeyMismatchError struct {
+	// Address is the network address of the host.
+	Address string
+	// JumpHost indicates that the host is the proxy or a jump host.
+	JumpHost bool
+	// Expected is the expected fingerprint.
+	Expected string
+	// Presented is the fingerprint of the host key that was presented.
+	Presented string
+}
+
+// Error implements the error interface.
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("fingerprint mismatch: %s: server fingerprint: %s", e.Address, e.Presented)
+}
@@ -1,6 +1,8 @@
 package common
 
 import (
+	"time"
+
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -8,6 +10,26 @@ import (
 type Options struct {
 	// KubernetesClient is a Kubernetes client that knows how to talk to the Kubernetes API.
 	KubernetesClient client.Client
+	// IdleTimeout is the duration after which an unused pooled connection is closed.
+	IdleTimeout time.Duration
+	// DialTimeout is the maximum duration for establishing a network connection.
+	DialTimeout time.Duration
+	// HandshakeTimeout is the maximum duration for the protocol
+	// handshake, including the authentication.
+	HandshakeTimeout time.Duration
+	// CommandTimeout is the maximum duration of a single command.
+	CommandTimeout time.Duration
+	// CertificateSigner signs the SSH user certificates of hosts that
+	// are configured to authenticate with a certificate.
+	CertificateSigner CertificateSigner
+	// CredentialSource provides the credentials of hosts, bastions and jump
+	// hosts. Defaults to the Secrets with the default key names.
+	CredentialSource CredentialSource
+	// Credentials override the credentials from the Secret of the host.
+	Credentials *Credentials
+	// Dialer establishes the network connection to the host, e.g. by
+	// tunneling it through a shared bastion connection.
+	Dialer Dialer
 }
 
 // Option applies a configuration option
@@ -29,6 +51,10 @@ func (o *Options) Apply(options ...Option) (*Options, error) {
 func GetDefaultOptions() *Options {
 	return &Options{
 		KubernetesClient: nil,
+		IdleTimeout:      5 * time.Minute,
+		DialTimeout:      10 * time.Second,
+		HandshakeTimeout: 10 * time.Second,
+		CommandTimeout:   time.Minute,
 	}
 }
 
@@ -40,3 +66,82 @@ func WithKubernetesClient(kubernetesClient client.Client) Option {
 		return nil
 	}
 }
+
+// WithIdleTimeout allows to configure the duration
+// after which an unused pooled connection is closed.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(options *Options) error {
+		options.IdleTimeout = timeout
+		return nil
+	}
+}
+
+// WithDialTimeout allows to configure the maximum
+// duration for establishing a network connection.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(options *Options) error {
+		options.DialTimeout = timeout
+		return nil
+	}
+}
+
+// WithHandshakeTimeout allows to configure the maximum duration
+// for the protocol handshake, including the authentication.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(options *Options) error {
+		options.HandshakeTimeout = timeout
+		return nil
+	}
+}
+
+// WithCommandTimeout allows to configure the maximum duration of a single command.
+func WithCommandTimeout(timeout time.Duration) Option {
+	return func(options *Options) error {
+		options.CommandTimeout = timeout
+		return nil
+	}
+}
+
+// WithCertificateSigner allows to provide a signer for short-lived SSH user
+// certificates, which enables the certificate authentication of hosts.
+func WithCertificateSigner(signer CertificateSigner) Option {
+	return func(options *Options) error {
+		options.CertificateSigner = signer
+		return nil
+	}
+}
+
+// WithCredentialSource allows to configure where the credentials of
+// hosts are read from, e.g. from an SSH agent instead of Secrets.
+func WithCredentialSource(source CredentialSource) Option {
+	return func(options *Options) error {
+		options.CredentialSource = source
+		return nil
+	}
+}
+
+// WithCredentials allows to override the credentials from the Secret of the
+// host, e.g. to verify new credentials before they are stored in the Secret.
+func WithCredentials(credentials *Credentials) Option {
+	return func(options *Options) error {
+		options.Credentials = credentials
+		return nil
+	}
+}
+
+// WithDialer allows to provide a dialer for the network connection
+// to the host, e.g. to reuse an existing connection to a bastion.
+func WithDialer(dialer Dialer) Option {
+	return func(options *Options) error {
+		options.Dialer = dialer
+		return nil
+	}
+}
+
+// WithOptions allows to inherit an existing set of options.
+func WithOptions(inherited *Options) Option {
+	return func(options *Options) error {
+		*options = *inherited
+		return nil
+	}
+}
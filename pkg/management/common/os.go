@@ -0,0 +1,148 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+const (
+	// OSReleaseFile is the file that contains the operating system identification data.
+	OSReleaseFile = "/etc/os-release"
+
+	// CapabilitiesProbeCommand is a POSIX shell script that prints the init process
+	// and the management tools that are available on a host. The output can be
+	// parsed with ParseCapabilities().
+	CapabilitiesProbeCommand = `echo "init=$(cat /proc/1/comm 2>/dev/null)"; ` +
+		`for cmd in apt-get dnf yum apk systemctl rc-service nft firewall-cmd iptables; do ` +
+		`command -v "$cmd" >/dev/null 2>&1 && echo "cmd=$cmd"; ` +
+		`done; true`
+)
+
+// osNames maps the `ID` of the os-release file to a normalized name.
+var osNames = map[string]string{
+	"ubuntu":    mgmtv1alpha1.OSUbuntu,
+	"debian":    mgmtv1alpha1.OSDebian,
+	"rhel":      mgmtv1alpha1.OSRHEL,
+	"rocky":     mgmtv1alpha1.OSRocky,
+	"almalinux": mgmtv1alpha1.OSAlma,
+	"centos":    mgmtv1alpha1.OSCentOS,
+	"fedora":    mgmtv1alpha1.OSFedora,
+	"alpine":    mgmtv1alpha1.OSAlpine,
+	"flatcar":   mgmtv1alpha1.OSFlatcar,
+	"nexus":     mgmtv1alpha1.OSNXOS,
+}
+
+// osFamilies maps the `ID` and `ID_LIKE` values of the os-release file to an OS family.
+var osFamilies = map[string]mgmtv1alpha1.OSFamily{
+	"debian":    mgmtv1alpha1.OSFamilyDebian,
+	"ubuntu":    mgmtv1alpha1.OSFamilyDebian,
+	"rhel":      mgmtv1alpha1.OSFamilyRHEL,
+	"fedora":    mgmtv1alpha1.OSFamilyRHEL,
+	"centos":    mgmtv1alpha1.OSFamilyRHEL,
+	"rocky":     mgmtv1alpha1.OSFamilyRHEL,
+	"almalinux": mgmtv1alpha1.OSFamilyRHEL,
+	"alpine":    mgmtv1alpha1.OSFamilyAlpine,
+	"flatcar":   mgmtv1alpha1.OSFamilyFlatcar,
+	"nexus":     mgmtv1alpha1.OSFamilyNXOS,
+}
+
+// ParseOSRelease parses the contents of an os-release file. The name and the
+// family of the operating system are normalized based on the `ID` and `ID_LIKE`
+// keys. If the operating system is unknown, the raw `NAME` is used instead.
+func ParseOSRelease(raw []byte) (*mgmtv1alpha1.OSInfo, error) {
+	osRelease, err := ini.Load(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the INI formatted file.
+	section := osRelease.Section("")
+	if section == nil {
+		return nil, fmt.Errorf("failed to parse file: %s", OSReleaseFile)
+	}
+
+	id := strings.ToLower(section.Key("ID").String())
+	idLike := strings.Fields(strings.ToLower(section.Key("ID_LIKE").String()))
+
+	info := &mgmtv1alpha1.OSInfo{
+		ID:      id,
+		Name:    osNames[id],
+		Family:  mgmtv1alpha1.OSFamilyUnknown,
+		Version: mgmtv1alpha1.OSVersion(section.Key("VERSION_ID").MustString("Unknown")),
+	}
+
+	// Fall back to the raw name if the operating system is unknown.
+	if info.Name == "" {
+		info.Name = section.Key("NAME").MustString("Unknown")
+	}
+
+	for _, candidate := range append([]string{id}, idLike...) {
+		if family, ok := osFamilies[candidate]; ok {
+			info.Family = family
+			break
+		}
+	}
+
+	return info, nil
+}
+
+// ParseCapabilities parses the output of the CapabilitiesProbeCommand.
+func ParseCapabilities(raw []byte) *mgmtv1alpha1.HostCapabilities {
+	capabilities := &mgmtv1alpha1.HostCapabilities{}
+
+	initProcess := ""
+	commands := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "init":
+			initProcess = value
+		case "cmd":
+			commands[value] = true
+		}
+	}
+
+	// The order defines the precedence if multiple package managers are available.
+	switch {
+	case commands["apt-get"]:
+		capabilities.PackageManager = mgmtv1alpha1.PackageManagerAPT
+	case commands["dnf"]:
+		capabilities.PackageManager = mgmtv1alpha1.PackageManagerDNF
+	case commands["yum"]:
+		capabilities.PackageManager = mgmtv1alpha1.PackageManagerYUM
+	case commands["apk"]:
+		capabilities.PackageManager = mgmtv1alpha1.PackageManagerAPK
+	}
+
+	switch {
+	case initProcess == "systemd" && commands["systemctl"]:
+		capabilities.InitSystem = mgmtv1alpha1.InitSystemSystemd
+	case commands["rc-service"]:
+		capabilities.InitSystem = mgmtv1alpha1.InitSystemOpenRC
+	case initProcess == "init":
+		capabilities.InitSystem = mgmtv1alpha1.InitSystemSysVInit
+	}
+
+	if commands["nft"] {
+		capabilities.FirewallBackends = append(capabilities.FirewallBackends, mgmtv1alpha1.FirewallBackendNFTables)
+	}
+	if commands["firewall-cmd"] {
+		capabilities.FirewallBackends = append(capabilities.FirewallBackends, mgmtv1alpha1.FirewallBackendFirewalld)
+	}
+	if commands["iptables"] {
+		capabilities.FirewallBackends = append(capabilities.FirewallBackends, mgmtv1alpha1.FirewallBackendIPTables)
+	}
+
+	return capabilities
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want mgmtv1alpha1.OSInfo
+	}{
+		{
+			name: "ubuntu",
+			raw: `PRETTY_NAME="Ubuntu 22.04.3 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+ID=ubuntu
+ID_LIKE=debian
+`,
+			want: mgmtv1alpha1.OSInfo{ID: "ubuntu", Name: mgmtv1alpha1.OSUbuntu, Family: mgmtv1alpha1.OSFamilyDebian, Version: "22.04"},
+		},
+		{
+			name: "debian",
+			raw: `NAME="Debian GNU/Linux"
+VERSION_ID="12"
+ID=debian
+`,
+			want: mgmtv1alpha1.OSInfo{ID: "debian", Name: mgmtv1alpha1.OSDebian, Family: mgmtv1alpha1.OSFamilyDebian, Version: "12"},
+		},
+		{
+			name: "rocky",
+			raw: `NAME="Rocky Linux"
+VERSION_ID="9.3"
+ID="rocky"
+ID_LIKE="rhel centos fedora"
+`,
+			want: mgmtv1alpha1.OSInfo{ID: "rocky", Name: mgmtv1alpha1.OSRocky, Family: mgmtv1alpha1.OSFamilyRHEL, Version: "9.3"},
+		},
+		{
+			name: "alpine",
+			raw: `NAME="Alpine Linux"
+ID=alpine
+VERSION_ID=3.19.1
+`,
+			want: mgmtv1alpha1.OSInfo{ID: "alpine", Name: mgmtv1alpha1.OSAlpine, Family: mgmtv1alpha1.OSFamilyAlpine, Version: "3.19.1"},
+		},
+		{
+			name: "unknown derivative",
+			raw: `NAME="Linux Mint"
+VERSION_ID="21.2"
+ID=linuxmint
+ID_LIKE="ubuntu debian"
+`,
+			want: mgmtv1alpha1.OSInfo{ID: "linuxmint", Name: "Linux Mint", Family: mgmtv1alpha1.OSFamilyDebian, Version: "21.2"},
+		},
+		{
+			name: "unknown",
+			raw:  "ID=Plan9\n",
+			want: mgmtv1alpha1.OSInfo{ID: "plan9", Name: "Unknown", Family: mgmtv1alpha1.OSFamilyUnknown, Version: "Unknown"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ParseOSRelease([]byte(test.raw))
+			if err != nil {
+				t.Fatalf("ParseOSRelease() error = %v", err)
+			}
+			if !reflect.DeepEqual(*info, test.want) {
+				t.Errorf("ParseOSRelease() = %+v, want %+v", *info, test.want)
+			}
+		})
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want mgmtv1alpha1.HostCapabilities
+	}{
+		{
+			name: "ubuntu",
+			raw:  "init=systemd\ncmd=apt-get\ncmd=systemctl\ncmd=nft\ncmd=iptables\n",
+			want: mgmtv1alpha1.HostCapabilities{
+				PackageManager:   mgmtv1alpha1.PackageManagerAPT,
+				InitSystem:       mgmtv1alpha1.InitSystemSystemd,
+				FirewallBackends: []mgmtv1alpha1.FirewallBackend{mgmtv1alpha1.FirewallBackendNFTables, mgmtv1alpha1.FirewallBackendIPTables},
+			},
+		},
+		{
+			name: "rhel prefers dnf over yum",
+			raw:  "init=systemd\ncmd=dnf\ncmd=yum\ncmd=systemctl\ncmd=nft\ncmd=firewall-cmd\n",
+			want: mgmtv1alpha1.HostCapabilities{
+				PackageManager:   mgmtv1alpha1.PackageManagerDNF,
+				InitSystem:       mgmtv1alpha1.InitSystemSystemd,
+				FirewallBackends: []mgmtv1alpha1.FirewallBackend{mgmtv1alpha1.FirewallBackendNFTables, mgmtv1alpha1.FirewallBackendFirewalld},
+			},
+		},
+		{
+			name: "alpine",
+			raw:  "init=init\ncmd=apk\ncmd=rc-service\n",
+			want: mgmtv1alpha1.HostCapabilities{
+				PackageManager: mgmtv1alpha1.PackageManagerAPK,
+				InitSystem:     mgmtv1alpha1.InitSystemOpenRC,
+			},
+		},
+		{
+			name: "sysvinit",
+			raw:  "init=init\ncmd=yum\n",
+			want: mgmtv1alpha1.HostCapabilities{
+				PackageManager: mgmtv1alpha1.PackageManagerYUM,
+				InitSystem:     mgmtv1alpha1.InitSystemSysVInit,
+			},
+		},
+		{
+			name: "systemctl without systemd",
+			raw:  "init=bash\ncmd=systemctl\n",
+			want: mgmtv1alpha1.HostCapabilities{},
+		},
+		{
+			name: "ignores noise",
+			raw:  "  init=systemd  \nsome banner\n\ncmd=systemctl\n",
+			want: mgmtv1alpha1.HostCapabilities{
+				InitSystem: mgmtv1alpha1.InitSystemSystemd,
+			},
+		},
+		{
+			name: "empty",
+			raw:  "",
+			want: mgmtv1alpha1.HostCapabilities{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			capabilities := ParseCapabilities([]byte(test.raw))
+			if !reflect.DeepEqual(*capabilities, test.want) {
+				t.Errorf("ParseCapabilities() = %+v, want %+v", *capabilities, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+// OutOfBandClientFactory is a function that creates a new out-of-band client.
+type OutOfBandClientFactory func(context.Context, *mgmtv1alpha1.Host, ...Option) (OutOfBandClient, error)
+
+// OutOfBandClient manages the hardware of a host via its BMC,
+// independently of the operating system of the host.
+type OutOfBandClient interface {
+	// Connect connects to the BMC.
+	Connect(ctx context.Context) error
+	// Disconnect disconnects from the BMC.
+	Disconnect() error
+	// Inventory reads information about the system, the chassis and the BMC.
+	Inventory(ctx context.Context) (*mgmtv1alpha1.HostStatusOutOfBand, error)
+	// PowerState returns the power state of the host.
+	PowerState(ctx context.Context) (mgmtv1alpha1.PowerState, error)
+	// Power submits a power action to the BMC. It does not wait
+	// for the power state of the host to change.
+	Power(ctx context.Context, action mgmtv1alpha1.PowerAction) error
+	// SetBootDevice configures the device that the host boots from on the next boot.
+	SetBootDevice(ctx context.Context, device mgmtv1alpha1.BootDevice) error
+	// Events returns the entries of the event log of the BMC, which were
+	// created after the given time, ordered from the oldest to the newest.
+	Events(ctx context.Context, since time.Time) ([]Event, error)
+}
+
+// Event is an entry of the event log of a BMC.
+type Event struct {
+	// ID is the ID of the entry.
+	ID string
+	// Created is the time at which the entry was created.
+	Created time.Time
+	// Severity is the severity of the entry, i.e. `OK`, `Warning` or `Critical`.
+	Severity string
+	// Message describes the event.
+	Message string
+}
+
+// OutOfBandSecretReference returns the reference to the secret containing the
+// credentials of the BMC of the host. It defaults to the secret of the host and
+// the namespace defaults to the namespace of the host.
+func OutOfBandSecretReference(host *mgmtv1alpha1.Host) types.NamespacedName {
+	if host.Spec.OutOfBand == nil || host.Spec.OutOfBand.SecretRef == nil {
+		return SecretReference(host)
+	}
+
+	secretRef := types.NamespacedName{
+		Namespace: host.Spec.OutOfBand.SecretRef.Namespace,
+		Name:      host.Spec.OutOfBand.SecretRef.Name,
+	}
+	if secretRef.Namespace == "" {
+		secretRef.Namespace = host.ObjectMeta.Namespace
+	}
+
+	return secretRef
+}
@@ -1,18 +1,152 @@
 package common
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/types"
+
 	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
 )
 
+// ErrNotSupported is returned if an operation is not supported by
+// the protocol of a host, e.g. running commands on an SNMP host.
+var ErrNotSupported = errors.New("operation not supported by protocol")
+
 // ClientFactory is a function that creates a new client.
-type ClientFactory func(*mgmtv1alpha1.Host, ...Option) (Client, error)
+type ClientFactory func(context.Context, *mgmtv1alpha1.Host, ...Option) (Client, error)
 
 // Client is the interface for a client.
 type Client interface {
 	// Connect connects to the host.
-	Connect() error
+	Connect(ctx context.Context) error
 	// Disconnect disconnects from the host.
 	Disconnect() error
-	// OS returns information about the operating system the host.
-	OS() *mgmtv1alpha1.OSInfo
+	// Ping checks if the connection to the host is still healthy.
+	Ping(ctx context.Context) error
+	// OS probes information about the operating system the host.
+	OS(ctx context.Context) (*mgmtv1alpha1.OSInfo, error)
+	// Capabilities probes information about the tooling available on the host.
+	Capabilities(ctx context.Context) (*mgmtv1alpha1.HostCapabilities, error)
+	// Exec runs a command on the host and waits for it to complete. A non-zero
+	// exit code is not considered an error, but reported in the result.
+	Exec(ctx context.Context, cmd *Command) (*CommandResult, error)
+	// ExecStream runs a command on the host and streams its output to the given
+	// writers while it is running. A non-zero exit code is not considered an
+	// error, but reported in the result.
+	ExecStream(ctx context.Context, cmd *Command, stdout io.Writer, stderr io.Writer) (*CommandResult, error)
+	// Upload writes the content of the reader to a file on the host.
+	Upload(ctx context.Context, path string, content io.Reader, opts *FileOptions) error
+	// Download writes the content of a file on the host to the writer.
+	// The returned error wraps os.ErrNotExist if the file does not exist.
+	Download(ctx context.Context, path string, content io.Writer) error
+	// Stat returns information about a file on the host. The
+	// returned error wraps os.ErrNotExist if the file does not exist.
+	Stat(ctx context.Context, path string) (*FileInfo, error)
+}
+
+// InterfaceReporter is implemented by clients that can
+// discover the network interfaces of a host.
+type InterfaceReporter interface {
+	// Interfaces returns the network interfaces of the host.
+	Interfaces(ctx context.Context) ([]mgmtv1alpha1.HostInterface, error)
+}
+
+// CertificateSigner signs short-lived SSH user certificates.
+type CertificateSigner interface {
+	// SignUserCertificate signs a user certificate for the public key, which is
+	// valid for the given principals and the given duration. The namespace is the
+	// namespace of the resource that requests the certificate.
+	SignUserCertificate(ctx context.Context, namespace string, key ssh.PublicKey, keyID string, principals []string, validity time.Duration) (*ssh.Certificate, error)
+}
+
+// Dialer establishes network connections, e.g. through a bastion.
+type Dialer interface {
+	// DialContext connects to the address on the named network.
+	DialContext(ctx context.Context, network string, address string) (net.Conn, error)
+}
+
+// SecretReference returns the reference to the secret containing the
+// credentials of the host. The namespace defaults to the namespace of the host.
+func SecretReference(host *mgmtv1alpha1.Host) types.NamespacedName {
+	secretRef := types.NamespacedName{
+		Namespace: host.Spec.SecretRef.Namespace,
+		Name:      host.Spec.SecretRef.Name,
+	}
+	if secretRef.Namespace == "" {
+		secretRef.Namespace = host.ObjectMeta.Namespace
+	}
+
+	return secretRef
+}
+
+// JumpHostSecretReference returns the reference to the secret containing the
+// credentials of a jump host. It defaults to the secret of the host and the
+// namespace defaults to the namespace of the host.
+func JumpHostSecretReference(host *mgmtv1alpha1.Host, jumpHost *mgmtv1alpha1.HostSpecSSHJumpHost) types.NamespacedName {
+	if jumpHost.SecretRef == nil {
+		return SecretReference(host)
+	}
+
+	secretRef := types.NamespacedName{
+		Namespace: jumpHost.SecretRef.Namespace,
+		Name:      jumpHost.SecretRef.Name,
+	}
+	if secretRef.Namespace == "" {
+		secretRef.Namespace = host.ObjectMeta.Namespace
+	}
+
+	return secretRef
+}
+
+// SecretReferences returns the references to all secrets containing
+// credentials that are required to connect to the host or its BMC,
+// starting with the secret of the host. Each secret is only returned once.
+func SecretReferences(host *mgmtv1alpha1.Host) []types.NamespacedName {
+	secretRefs := []types.NamespacedName{SecretReference(host)}
+	for i := range host.Spec.SSH.JumpHosts {
+		secretRef := JumpHostSecretReference(host, &host.Spec.SSH.JumpHosts[i])
+		if !slices.Contains(secretRefs, secretRef) {
+			secretRefs = append(secretRefs, secretRef)
+		}
+	}
+	if host.Spec.OutOfBand != nil {
+		secretRef := OutOfBandSecretReference(host)
+		if !slices.Contains(secretRefs, secretRef) {
+			secretRefs = append(secretRefs, secretRef)
+		}
+	}
+
+	return secretRefs
+}
+
+// BastionReference returns the reference to the bastion of the host, if any.
+func BastionReference(host *mgmtv1alpha1.Host) *types.NamespacedName {
+	if host.Spec.SSH.BastionRef == nil {
+		return nil
+	}
+
+	return &types.NamespacedName{
+		Namespace: host.Namespace,
+		Name:      host.Spec.SSH.BastionRef.Name,
+	}
+}
+
+// BastionSecretReference returns the reference to the secret containing the
+// credentials of the bastion. The namespace defaults to the namespace of the bastion.
+func BastionSecretReference(bastion *mgmtv1alpha1.SSHBastion) types.NamespacedName {
+	secretRef := types.NamespacedName{
+		Namespace: bastion.Spec.SecretRef.Namespace,
+		Name:      bastion.Spec.SecretRef.Name,
+	}
+	if secretRef.Namespace == "" {
+		secretRef.Namespace = bastion.ObjectMeta.Namespace
+	}
+
+	return secretRef
 }
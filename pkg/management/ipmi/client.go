@@ -0,0 +1,484 @@
+package ipmi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	// defaultPort is the default port of the IPMI LAN interface.
+	defaultPort = 623
+	// retransmitInterval is the interval after which a request is sent
+	// again, because UDP datagrams may be lost.
+	retransmitInterval = 2 * time.Second
+)
+
+// Network functions of the IPMI commands.
+const (
+	netFnChassis = 0x00
+	netFnApp     = 0x06
+	netFnStorage = 0x0a
+)
+
+// Commands of the application network function.
+const (
+	cmdGetDeviceID                = 0x01
+	cmdGetChannelAuthCapabilities = 0x38
+	cmdSetSessionPrivilegeLevel   = 0x3b
+	cmdCloseSession               = 0x3c
+)
+
+// Commands of the chassis network function.
+const (
+	cmdGetChassisStatus     = 0x01
+	cmdChassisControl       = 0x02
+	cmdSetSystemBootOptions = 0x08
+)
+
+// Commands of the storage network function.
+const (
+	cmdGetFRUInventoryAreaInfo = 0x10
+	cmdReadFRUData             = 0x11
+	cmdGetSELInfo              = 0x40
+	cmdGetSELEntry             = 0x43
+)
+
+const (
+	// channelCurrent selects the channel on which a command is received.
+	channelCurrent = 0x0e
+	// channelExtendedCapabilities requests the IPMI v2.0 authentication capabilities.
+	channelExtendedCapabilities = 0x80
+)
+
+// Parameters of the Set System Boot Options command.
+const (
+	bootParamSetInProgress = 0x00
+	bootParamBootFlags     = 0x05
+	// bootFlagsValid marks the boot flags as valid for the next boot only.
+	bootFlagsValid = 0x80
+	// bootFlagsEFI requests a UEFI boot instead of a legacy BIOS boot.
+	bootFlagsEFI = 0x20
+)
+
+// chassisControls maps the power actions to the parameters of the Chassis Control command.
+var chassisControls = map[mgmtv1alpha1.PowerAction]byte{
+	mgmtv1alpha1.PowerActionForceOff:         0x00,
+	mgmtv1alpha1.PowerActionOn:               0x01,
+	mgmtv1alpha1.PowerActionPowerCycle:       0x02,
+	mgmtv1alpha1.PowerActionForceRestart:     0x03,
+	mgmtv1alpha1.PowerActionGracefulShutdown: 0x05,
+}
+
+// bootDevices maps the boot devices to the boot device selectors of the boot flags.
+var bootDevices = map[mgmtv1alpha1.BootDevice]byte{
+	mgmtv1alpha1.BootDevicePXE:       0x01,
+	mgmtv1alpha1.BootDeviceDisk:      0x02,
+	mgmtv1alpha1.BootDeviceCD:        0x05,
+	mgmtv1alpha1.BootDeviceBIOSSetup: 0x06,
+}
+
+// Client manages the BMC of a host using IPMI v2.0 over LAN, also known as RMCP+.
+type Client struct {
+	host *mgmtv1alpha1.Host
+	opts *common.Options
+
+	// mutex serializes the requests, as the responses are matched by their sequence number.
+	mutex   sync.Mutex
+	conn    net.Conn
+	session *session
+	// tag is the message tag of the session setup and seq is
+	// the sequence number of the last IPMI request.
+	tag byte
+	seq byte
+}
+
+// NewClient creates a new IPMI client for the BMC of a host.
+func NewClient(ctx context.Context, host *mgmtv1alpha1.Host, options ...common.Option) (common.OutOfBandClient, error) {
+	opts, err := common.GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if host.Spec.OutOfBand == nil {
+		return nil, fmt.Errorf("host has no out-of-band endpoint: %s/%s", host.Namespace, host.Name)
+	}
+
+	return &Client{
+		host: host,
+		opts: opts,
+	}, nil
+}
+
+// Connect establishes an authenticated and encrypted session with the BMC.
+func (c *Client) Connect(ctx context.Context) error {
+	options := c.host.Spec.OutOfBand
+
+	credentials, err := common.ReadCredentials(ctx, c.opts, common.CredentialReference{
+		SecretRef: common.OutOfBandSecretReference(c.host),
+		Namespace: c.host.Namespace,
+	})
+	if err != nil {
+		return err
+	}
+
+	privilege, ok := privileges[options.IPMI.Privilege]
+	if options.IPMI.Privilege == "" {
+		privilege, ok = privileges[mgmtv1alpha1.IPMIPrivilegeAdministrator], true
+	}
+	if !ok {
+		return fmt.Errorf("unsupported IPMI privilege level: %s", options.IPMI.Privilege)
+	}
+
+	suites := cipherSuites
+	if options.IPMI.CipherSuite != 0 {
+		suites = nil
+		for _, suite := range cipherSuites {
+			if suite.ID == options.IPMI.CipherSuite {
+				suites = append(suites, suite)
+			}
+		}
+		if len(suites) == 0 {
+			return fmt.Errorf("unsupported IPMI cipher suite: %d", options.IPMI.CipherSuite)
+		}
+	}
+
+	port := options.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	address := net.JoinHostPort(options.Host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{Timeout: c.opts.DialTimeout}
+	c.conn, err = dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.HandshakeTimeout)
+	defer cancel()
+
+	if err := c.activate(ctx, suites, options.User, credentials.Password, privilege); err != nil {
+		c.conn.Close()
+		c.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+// Disconnect closes the session and the connection.
+func (c *Client) Disconnect() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	if c.session != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), retransmitInterval)
+		defer cancel()
+
+		// The session expires on the BMC anyway, so a lost reply is not an error.
+		c.request(ctx, netFnApp, cmdCloseSession, binary.LittleEndian.AppendUint32(nil, c.session.managedID))
+		c.session = nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+
+	return err
+}
+
+// Inventory reads the power state of the host, the version of the
+// firmware of the BMC and the hardware information of the FRU inventory.
+func (c *Client) Inventory(ctx context.Context) (*mgmtv1alpha1.HostStatusOutOfBand, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	status, err := c.request(ctx, netFnChassis, cmdGetChassisStatus, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(status) < 3 {
+		return nil, fmt.Errorf("%w: truncated chassis status", errMalformed)
+	}
+
+	inventory := &mgmtv1alpha1.HostStatusOutOfBand{
+		PowerState: powerState(status[0]),
+	}
+
+	// The chassis reports faults of the power subsystem.
+	inventory.System.Health = "OK"
+	if status[0]&0x1e != 0 {
+		inventory.System.Health = "Critical"
+	}
+
+	device, err := c.request(ctx, netFnApp, cmdGetDeviceID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(device) >= 4 {
+		// The major version is binary and the minor version is BCD-encoded.
+		inventory.BMC.FirmwareVersion = fmt.Sprintf("%d.%02x", device[2]&0x7f, device[3])
+	}
+
+	// Many BMCs do not provide an FRU inventory, so it is optional.
+	fru, err := c.readFRU(ctx)
+	var completion *CompletionError
+	if err != nil && !errors.As(err, &completion) && !errors.Is(err, errMalformed) {
+		return nil, err
+	}
+	if fru != nil {
+		fru.apply(inventory)
+	}
+
+	return inventory, nil
+}
+
+// PowerState returns the power state of the host.
+func (c *Client) PowerState(ctx context.Context) (mgmtv1alpha1.PowerState, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	status, err := c.request(ctx, netFnChassis, cmdGetChassisStatus, nil)
+	if err != nil {
+		return mgmtv1alpha1.PowerStateUnknown, err
+	}
+	if len(status) < 1 {
+		return mgmtv1alpha1.PowerStateUnknown, fmt.Errorf("%w: truncated chassis status", errMalformed)
+	}
+
+	return powerState(status[0]), nil
+}
+
+// Power submits a chassis control command. A graceful restart is not
+// supported, because IPMI can only request a graceful shutdown.
+func (c *Client) Power(ctx context.Context, action mgmtv1alpha1.PowerAction) error {
+	control, ok := chassisControls[action]
+	if !ok {
+		return fmt.Errorf("%w: IPMI does not support power action: %s", common.ErrNotSupported, action)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	_, err := c.request(ctx, netFnChassis, cmdChassisControl, []byte{control})
+
+	return err
+}
+
+// SetBootDevice sets the boot flags of the system for the next boot.
+func (c *Client) SetBootDevice(ctx context.Context, device mgmtv1alpha1.BootDevice) error {
+	selector, ok := bootDevices[device]
+	if !ok {
+		return fmt.Errorf("%w: IPMI does not support boot device: %s", common.ErrNotSupported, device)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	flags := byte(bootFlagsValid)
+	if c.host.Spec.OutOfBand.IPMI.UEFI {
+		flags |= bootFlagsEFI
+	}
+
+	// Some BMCs require the parameters to be locked while they are set,
+	// while others do not support the lock, which is therefore optional.
+	var completion *CompletionError
+	if _, err := c.request(ctx, netFnChassis, cmdSetSystemBootOptions, []byte{bootParamSetInProgress, 0x01}); err != nil && !errors.As(err, &completion) {
+		return err
+	}
+
+	_, err := c.request(ctx, netFnChassis, cmdSetSystemBootOptions, []byte{bootParamBootFlags, flags, selector << 2, 0x00, 0x00, 0x00})
+
+	if _, unlockErr := c.request(ctx, netFnChassis, cmdSetSystemBootOptions, []byte{bootParamSetInProgress, 0x00}); unlockErr != nil && !errors.As(unlockErr, &completion) && err == nil {
+		err = unlockErr
+	}
+
+	return err
+}
+
+// activate opens a session with the first cipher suite that is supported by
+// the BMC and raises the privilege level of the session.
+func (c *Client) activate(ctx context.Context, suites []*cipherSuite, user string, password string, privilege byte) error {
+	// The authentication capabilities are requested outside of
+	// a session to verify that the BMC supports IPMI v2.0.
+	capabilities, err := c.request(ctx, netFnApp, cmdGetChannelAuthCapabilities, []byte{channelExtendedCapabilities | channelCurrent, privilege})
+	if err != nil {
+		return err
+	}
+	if len(capabilities) < 4 || capabilities[1]&0x80 == 0 || capabilities[3]&0x02 == 0 {
+		return errors.New("BMC does not support IPMI v2.0")
+	}
+
+	for i, suite := range suites {
+		c.session, err = c.openSession(ctx, suite, user, password, privilege)
+		var status *StatusError
+		if err == nil || i == len(suites)-1 || !errors.As(err, &status) || !status.unsupportedAlgorithm() {
+			break
+		}
+	}
+	if err != nil {
+		c.session = nil
+		return err
+	}
+
+	// Sessions start with the user privilege level.
+	if _, err := c.request(ctx, netFnApp, cmdSetSessionPrivilegeLevel, []byte{privilege}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// request sends a request to the BMC and returns the data of the response.
+func (c *Client) request(ctx context.Context, netFn byte, cmd byte, data []byte) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected: %s", c.host.Spec.OutOfBand.Host)
+	}
+
+	c.seq = (c.seq + 1) & 0x3f
+	seq := c.seq
+	msg := marshalRequest(netFn, cmd, seq, data)
+
+	var raw []byte
+	if c.session == nil {
+		raw = marshalSessionlessPacket(msg)
+	} else {
+		var err error
+		raw, err = marshalPacket(c.session, payloadTypeIPMI, msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var res *response
+	_, err := c.roundTrip(ctx, raw, c.session, func(p *packet) bool {
+		if p.Type != payloadTypeIPMI {
+			return false
+		}
+		r, err := parseResponse(p.Payload)
+		if err != nil || r.NetFn != netFn+1 || r.Seq != seq || r.Cmd != cmd {
+			return false
+		}
+		res = r
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Completion != 0 {
+		return nil, &CompletionError{NetFn: netFn, Cmd: cmd, Code: res.Completion}
+	}
+
+	return res.Data, nil
+}
+
+// roundTrip sends a packet until a matching packet is received or the context
+// expires. Packets that do not match are discarded, e.g. late responses to
+// earlier requests.
+func (c *Client) roundTrip(ctx context.Context, raw []byte, s *session, match func(*packet) bool) (*packet, error) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		if _, err := c.conn.Write(raw); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(retransmitInterval)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		if err := c.conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+
+		for {
+			n, err := c.conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if !errors.As(err, &netErr) || !netErr.Timeout() {
+					return nil, err
+				}
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("no response from %s: %w", c.host.Spec.OutOfBand.Host, ctx.Err())
+				}
+				break
+			}
+
+			p, err := parsePacket(s, buf[:n])
+			if err != nil {
+				return nil, err
+			}
+			if !match(p) {
+				continue
+			}
+
+			return p, nil
+		}
+	}
+}
+
+// CompletionError is returned if the BMC completes a command with an error.
+type CompletionError struct {
+	// NetFn is the network function of the command.
+	NetFn byte
+	// Cmd is the command.
+	Cmd byte
+	// Code is the completion code.
+	Code byte
+}
+
+// Error returns the error message.
+func (e *CompletionError) Error() string {
+	if reason, ok := completionReasons[e.Code]; ok {
+		return fmt.Sprintf("IPMI command 0x%02x/0x%02x failed: %s", e.NetFn, e.Cmd, reason)
+	}
+
+	return fmt.Sprintf("IPMI command 0x%02x/0x%02x failed with completion code 0x%02x", e.NetFn, e.Cmd, e.Code)
+}
+
+// completionReasons describes the generic completion codes.
+var completionReasons = map[byte]string{
+	0xc0: "node busy",
+	0xc1: "invalid command",
+	0xc2: "command invalid for given LUN",
+	0xc3: "timeout while processing command",
+	0xc4: "out of space",
+	0xc5: "reservation canceled or invalid reservation ID",
+	0xc6: "request data truncated",
+	0xc7: "request data length invalid",
+	0xc8: "request data field length limit exceeded",
+	0xc9: "parameter out of range",
+	0xca: "cannot return number of requested data bytes",
+	0xcb: "requested sensor, data, or record not present",
+	0xcc: "invalid data field in request",
+	0xcd: "command illegal for specified sensor or record type",
+	0xce: "command response could not be provided",
+	0xcf: "cannot execute duplicated request",
+	0xd0: "SDR repository in update mode",
+	0xd1: "device in firmware update mode",
+	0xd2: "BMC initialization in progress",
+	0xd3: "destination unavailable",
+	0xd4: "insufficient privilege level",
+	0xd5: "command not supported in present state",
+	0xd6: "command sub-function has been disabled or is unavailable",
+}
+
+// powerState converts the current power state of the chassis status.
+func powerState(state byte) mgmtv1alpha1.PowerState {
+	if state&0x01 != 0 {
+		return mgmtv1alpha1.PowerStateOn
+	}
+
+	return mgmtv1alpha1.PowerStateOff
+}
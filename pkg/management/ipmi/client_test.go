@@ -0,0 +1,716 @@
+package ipmi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	testUser     = "kraut"
+	testPassword = "calvin"
+	// testManagedID is the ID of the sessions on the test BMC.
+	testManagedID = 0x0200a0f1
+)
+
+// staticCredentials provides the same credentials for all references.
+type staticCredentials common.Credentials
+
+// Credentials returns the credentials.
+func (s staticCredentials) Credentials(ctx context.Context, ref common.CredentialReference) (*common.Credentials, error) {
+	credentials := common.Credentials(s)
+	return &credentials, nil
+}
+
+// testRequest is a request that was received by the test BMC.
+type testRequest struct {
+	NetFn byte
+	Cmd   byte
+	Data  []byte
+}
+
+// testBMC is a BMC, which implements the RMCP+ session setup and
+// the commands of the client. It serves a single remote console.
+type testBMC struct {
+	// suites are the IDs of the supported cipher suites, which defaults to all.
+	suites []int
+	// chassisStatus is the current power state of the chassis status.
+	chassisStatus byte
+	// fru is the FRU inventory, which is not present if it is nil.
+	fru        []byte
+	fruByWords bool
+	// sel contains the entries of the SEL and selAdded is the
+	// timestamp of the last addition.
+	sel      [][]byte
+	selAdded uint32
+
+	mutex    sync.Mutex
+	requests []testRequest
+
+	// The state of the session setup.
+	suite    *cipherSuite
+	remoteID uint32
+	rm       []byte
+	role     byte
+	user     []byte
+	session  *session
+}
+
+var (
+	// testRandom and testGUID are the random number and the GUID of the test BMC.
+	testRandom = bytes.Repeat([]byte{0x5a}, 16)
+	testGUID   = bytes.Repeat([]byte{0xa5}, 16)
+)
+
+// Requests returns the IPMI requests that were received.
+func (b *testBMC) Requests() []testRequest {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return append([]testRequest(nil), b.requests...)
+}
+
+// listen serves the BMC on a UDP socket and returns its address.
+func (b *testBMC) listen(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, maxPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply := b.handle(buf[:n]); reply != nil {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// handle answers a packet of the remote console.
+func (b *testBMC) handle(raw []byte) []byte {
+	p, err := parsePacket(b.session, raw)
+	if err != nil {
+		return nil
+	}
+
+	switch p.Type {
+	case payloadTypeOpenSessionRequest:
+		return b.openSession(p.Payload)
+	case payloadTypeRAKP1:
+		return b.rakp1(p.Payload)
+	case payloadTypeRAKP3:
+		return b.rakp3(p.Payload)
+	case payloadTypeIPMI:
+		return b.command(raw[4] == authTypeNone, p.Payload)
+	default:
+		return nil
+	}
+}
+
+// openSession answers an Open Session Request with the proposed
+// algorithms, if they match a supported cipher suite.
+func (b *testBMC) openSession(req []byte) []byte {
+	if len(req) < 32 {
+		return nil
+	}
+	b.session = nil
+	b.suite = nil
+	b.remoteID = binary.LittleEndian.Uint32(req[4:8])
+	for _, suite := range cipherSuites {
+		if suite.authAlgorithm == req[12] && suite.integrityAlgorithm == req[20] && suite.confidentialityAlgorithm == req[28] && b.supports(suite.ID) {
+			b.suite = suite
+		}
+	}
+
+	reply := []byte{req[0], 0x00, 0x04, 0x00}
+	if b.suite == nil {
+		reply[1] = 0x11
+	}
+	reply = binary.LittleEndian.AppendUint32(reply, b.remoteID)
+	reply = binary.LittleEndian.AppendUint32(reply, testManagedID)
+	reply = append(reply, req[8:32]...)
+
+	return setupPacket(payloadTypeOpenSessionResponse, reply)
+}
+
+// rakp1 answers RAKP message 1 with RAKP message 2.
+func (b *testBMC) rakp1(req []byte) []byte {
+	if len(req) < 28 || len(req) < 28+int(req[27]) {
+		return nil
+	}
+	b.rm = bytes.Clone(req[8:24])
+	b.role = req[24]
+	b.user = bytes.Clone(req[28 : 28+int(req[27])])
+
+	reply := []byte{req[0], 0x00, 0x00, 0x00}
+	if string(b.user) != testUser {
+		reply[1] = 0x0d
+	}
+	reply = binary.LittleEndian.AppendUint32(reply, b.remoteID)
+	reply = append(reply, testRandom...)
+	reply = append(reply, testGUID...)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, b.remoteID)
+	binary.Write(&buf, binary.LittleEndian, uint32(testManagedID))
+	buf.Write(b.rm)
+	buf.Write(testRandom)
+	buf.Write(testGUID)
+	buf.Write([]byte{b.role, byte(len(b.user))})
+	buf.Write(b.user)
+	reply = append(reply, mac(b.suite, []byte(testPassword), buf.Bytes())...)
+
+	return setupPacket(payloadTypeRAKP2, reply)
+}
+
+// rakp3 verifies RAKP message 3, answers it with RAKP message 4 and activates the session.
+func (b *testBMC) rakp3(req []byte) []byte {
+	if len(req) < 8 {
+		return nil
+	}
+	kuid := []byte(testPassword)
+
+	var buf bytes.Buffer
+	buf.Write(testRandom)
+	binary.Write(&buf, binary.LittleEndian, b.remoteID)
+	buf.Write([]byte{b.role, byte(len(b.user))})
+	buf.Write(b.user)
+
+	reply := []byte{req[0], 0x00, 0x00, 0x00}
+	reply = binary.LittleEndian.AppendUint32(reply, b.remoteID)
+	if !bytes.Equal(req[8:], mac(b.suite, kuid, buf.Bytes())) {
+		reply[1] = 0x0f
+		return setupPacket(payloadTypeRAKP4, reply)
+	}
+
+	buf.Reset()
+	buf.Write(b.rm)
+	buf.Write(testRandom)
+	buf.Write([]byte{b.role, byte(len(b.user))})
+	buf.Write(b.user)
+	sik := mac(b.suite, kuid, buf.Bytes())
+
+	buf.Reset()
+	buf.Write(b.rm)
+	binary.Write(&buf, binary.LittleEndian, uint32(testManagedID))
+	buf.Write(testGUID)
+	reply = append(reply, mac(b.suite, sik, buf.Bytes())[:b.suite.integrityLength]...)
+
+	// The packets of the BMC are sent to the session of the remote console.
+	b.session = &session{
+		suite:     b.suite,
+		remoteID:  testManagedID,
+		managedID: b.remoteID,
+		k1:        mac(b.suite, sik, bytes.Repeat([]byte{0x01}, 20)),
+		k2:        mac(b.suite, sik, bytes.Repeat([]byte{0x02}, 20)),
+		active:    true,
+	}
+
+	return setupPacket(payloadTypeRAKP4, reply)
+}
+
+// command executes an IPMI request and returns the response in a packet.
+func (b *testBMC) command(sessionless bool, msg []byte) []byte {
+	if len(msg) < 7 {
+		return nil
+	}
+	netFn := msg[1] >> 2
+	seq := msg[4] >> 2
+	cmd := msg[5]
+	data := bytes.Clone(msg[6 : len(msg)-1])
+
+	b.mutex.Lock()
+	b.requests = append(b.requests, testRequest{NetFn: netFn, Cmd: cmd, Data: data})
+	b.mutex.Unlock()
+
+	completion, res := b.execute(netFn, cmd, data)
+	msg = marshalTestResponse(netFn, cmd, seq, completion, res)
+	if sessionless {
+		return marshalSessionlessPacket(msg)
+	}
+
+	raw, _ := marshalPacket(b.session, payloadTypeIPMI, msg)
+	return raw
+}
+
+// execute returns the completion code and the data of the response to a request.
+func (b *testBMC) execute(netFn byte, cmd byte, data []byte) (byte, []byte) {
+	switch [2]byte{netFn, cmd} {
+	case [2]byte{netFnApp, cmdGetChannelAuthCapabilities}:
+		return 0x00, []byte{0x01, 0x80, 0x04, 0x02, 0x00, 0x00, 0x00, 0x00}
+	case [2]byte{netFnApp, cmdSetSessionPrivilegeLevel}:
+		return 0x00, data[:1]
+	case [2]byte{netFnApp, cmdCloseSession}:
+		return 0x00, nil
+	case [2]byte{netFnApp, cmdGetDeviceID}:
+		return 0x00, []byte{0x20, 0x81, 0x02, 0x45, 0x02, 0xbf, 0xa2, 0x02, 0x00, 0x00, 0x01}
+	case [2]byte{netFnChassis, cmdGetChassisStatus}:
+		return 0x00, []byte{b.chassisStatus, 0x00, 0x40}
+	case [2]byte{netFnChassis, cmdChassisControl}, [2]byte{netFnChassis, cmdSetSystemBootOptions}:
+		return 0x00, nil
+	case [2]byte{netFnStorage, cmdGetFRUInventoryAreaInfo}:
+		if b.fru == nil {
+			return 0xcb, nil
+		}
+		info := binary.LittleEndian.AppendUint16(nil, uint16(len(b.fru)))
+		if b.fruByWords {
+			return 0x00, append(info, 0x01)
+		}
+		return 0x00, append(info, 0x00)
+	case [2]byte{netFnStorage, cmdReadFRUData}:
+		position := int(binary.LittleEndian.Uint16(data[1:3]))
+		count := int(data[3])
+		if b.fruByWords {
+			position *= 2
+			count *= 2
+		}
+		end := min(position+count, len(b.fru))
+		if position >= end {
+			return 0xc9, nil
+		}
+		return 0x00, append([]byte{data[3]}, b.fru[position:end]...)
+	case [2]byte{netFnStorage, cmdGetSELInfo}:
+		info := binary.LittleEndian.AppendUint16([]byte{0x51}, uint16(len(b.sel)))
+		info = binary.LittleEndian.AppendUint16(info, 0x1000)
+		info = binary.LittleEndian.AppendUint32(info, b.selAdded)
+		info = binary.LittleEndian.AppendUint32(info, 0)
+		return 0x00, append(info, 0x00)
+	case [2]byte{netFnStorage, cmdGetSELEntry}:
+		id := binary.LittleEndian.Uint16(data[2:4])
+		for i, record := range b.sel {
+			if id != selFirstEntry && binary.LittleEndian.Uint16(record[0:2]) != id {
+				continue
+			}
+			next := uint16(selLastEntry)
+			if i+1 < len(b.sel) {
+				next = binary.LittleEndian.Uint16(b.sel[i+1][0:2])
+			}
+			return 0x00, append(binary.LittleEndian.AppendUint16(nil, next), record...)
+		}
+		return 0xcb, nil
+	default:
+		return 0xc1, nil
+	}
+}
+
+// supports returns true if the cipher suite is supported.
+func (b *testBMC) supports(id int) bool {
+	if len(b.suites) == 0 {
+		return true
+	}
+	for _, suite := range b.suites {
+		if suite == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setupPacket encodes a message of the session setup.
+func setupPacket(typ payloadType, payload []byte) []byte {
+	raw, _ := marshalPacket(nil, typ, payload)
+	return raw
+}
+
+// marshalTestResponse encodes the response of the BMC to a request.
+func marshalTestResponse(netFn byte, cmd byte, seq byte, completion byte, data []byte) []byte {
+	msg := []byte{remoteConsoleAddress, (netFn + 1) << 2, 0}
+	msg[2] = checksum(msg[:2])
+
+	body := append([]byte{bmcAddress, seq << 2, cmd, completion}, data...)
+	msg = append(msg, body...)
+
+	return append(msg, checksum(body))
+}
+
+// newTestClient starts the BMC and creates a client for it.
+func newTestClient(t *testing.T, bmc *testBMC, options mgmtv1alpha1.HostSpecIPMIOptions, password string) *Client {
+	t.Helper()
+
+	return newHostClient(t, bmc.listen(t), testUser, options, password)
+}
+
+// newHostClient creates a client for the BMC at the address.
+func newHostClient(t *testing.T, address string, user string, options mgmtv1alpha1.HostSpecIPMIOptions, password string) *Client {
+	t.Helper()
+
+	host, rawPort, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgmt, err := NewClient(context.Background(), &mgmtv1alpha1.Host{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "node-1"},
+		Spec: mgmtv1alpha1.HostSpec{
+			OutOfBand: &mgmtv1alpha1.HostSpecOutOfBand{
+				Protocol: mgmtv1alpha1.OutOfBandProtocolIPMI,
+				Host:     host,
+				Port:     port,
+				User:     user,
+				IPMI:     options,
+			},
+		},
+	}, common.WithCredentialSource(staticCredentials{Password: password}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mgmt.(*Client)
+}
+
+// connectTestClient starts the BMC and connects a client to it.
+func connectTestClient(t *testing.T, bmc *testBMC) *Client {
+	t.Helper()
+
+	client := newTestClient(t, bmc, mgmtv1alpha1.HostSpecIPMIOptions{}, testPassword)
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+
+	return client
+}
+
+func TestClientConnect(t *testing.T) {
+	tests := []struct {
+		name          string
+		privilege     mgmtv1alpha1.IPMIPrivilege
+		wantPrivilege byte
+	}{
+		{name: "default privilege", wantPrivilege: 0x04},
+		{name: "operator", privilege: mgmtv1alpha1.IPMIPrivilegeOperator, wantPrivilege: 0x03},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bmc := &testBMC{}
+			client := newTestClient(t, bmc, mgmtv1alpha1.HostSpecIPMIOptions{Privilege: test.privilege}, testPassword)
+
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Disconnect(); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []testRequest{
+				{NetFn: netFnApp, Cmd: cmdGetChannelAuthCapabilities, Data: []byte{0x8e, test.wantPrivilege}},
+				{NetFn: netFnApp, Cmd: cmdSetSessionPrivilegeLevel, Data: []byte{test.wantPrivilege}},
+				{NetFn: netFnApp, Cmd: cmdCloseSession, Data: binary.LittleEndian.AppendUint32(nil, testManagedID)},
+			}
+			if requests := bmc.Requests(); fmt.Sprint(requests) != fmt.Sprint(want) {
+				t.Errorf("requests = %v, want %v", requests, want)
+			}
+		})
+	}
+}
+
+func TestClientConnectInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  mgmtv1alpha1.HostSpecIPMIOptions
+		user     string
+		password string
+	}{
+		{name: "unsupported cipher suite", options: mgmtv1alpha1.HostSpecIPMIOptions{CipherSuite: 1}, user: testUser, password: testPassword},
+		{name: "unsupported privilege", options: mgmtv1alpha1.HostSpecIPMIOptions{Privilege: "User"}, user: testUser, password: testPassword},
+		{name: "long user name", user: "administrator-of-the-bmc", password: testPassword},
+		{name: "long password", user: testUser, password: "a-password-with-more-than-20-characters"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bmc := &testBMC{}
+			client := newHostClient(t, bmc.listen(t), test.user, test.options, test.password)
+
+			if err := client.Connect(context.Background()); err == nil {
+				client.Disconnect()
+				t.Fatal("expected connection to fail")
+			}
+			if client.conn != nil {
+				t.Error("expected connection to be closed")
+			}
+		})
+	}
+}
+
+func TestClientRequestNotConnected(t *testing.T) {
+	client := newHostClient(t, "127.0.0.1:623", testUser, mgmtv1alpha1.HostSpecIPMIOptions{}, testPassword)
+
+	if _, err := client.PowerState(context.Background()); err == nil {
+		t.Error("expected request without connection to fail")
+	}
+	if err := client.Disconnect(); err != nil {
+		t.Errorf("Disconnect() error = %v", err)
+	}
+}
+
+func TestClientInventory(t *testing.T) {
+	tests := []struct {
+		name          string
+		chassisStatus byte
+		wantState     mgmtv1alpha1.PowerState
+		wantHealth    string
+	}{
+		{name: "on", chassisStatus: 0x01, wantState: mgmtv1alpha1.PowerStateOn, wantHealth: "OK"},
+		{name: "off", chassisStatus: 0x00, wantState: mgmtv1alpha1.PowerStateOff, wantHealth: "OK"},
+		{name: "power fault", chassisStatus: 0x09, wantState: mgmtv1alpha1.PowerStateOn, wantHealth: "Critical"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := connectTestClient(t, &testBMC{chassisStatus: test.chassisStatus})
+
+			inventory, err := client.Inventory(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inventory.PowerState != test.wantState || inventory.System.Health != test.wantHealth {
+				t.Errorf("Inventory() = power state %s and health %s, want %s and %s", inventory.PowerState, inventory.System.Health, test.wantState, test.wantHealth)
+			}
+			// The FRU inventory of the BMC is not present.
+			if inventory.BMC.FirmwareVersion != "2.45" || inventory.System.Model != "" {
+				t.Errorf("Inventory() = %+v", inventory)
+			}
+
+			state, err := client.PowerState(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != test.wantState {
+				t.Errorf("PowerState() = %s, want %s", state, test.wantState)
+			}
+		})
+	}
+}
+
+func TestClientPower(t *testing.T) {
+	tests := []struct {
+		action      mgmtv1alpha1.PowerAction
+		wantControl byte
+		wantErr     error
+	}{
+		{action: mgmtv1alpha1.PowerActionOn, wantControl: 0x01},
+		{action: mgmtv1alpha1.PowerActionForceOff, wantControl: 0x00},
+		{action: mgmtv1alpha1.PowerActionGracefulShutdown, wantControl: 0x05},
+		{action: mgmtv1alpha1.PowerActionForceRestart, wantControl: 0x03},
+		{action: mgmtv1alpha1.PowerActionPowerCycle, wantControl: 0x02},
+		{action: mgmtv1alpha1.PowerActionGracefulRestart, wantErr: common.ErrNotSupported},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.action), func(t *testing.T) {
+			bmc := &testBMC{}
+			client := connectTestClient(t, bmc)
+
+			err := client.Power(context.Background(), test.action)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Power() error = %v, want %v", err, test.wantErr)
+			}
+
+			var controls [][]byte
+			for _, request := range bmc.Requests() {
+				if request.NetFn == netFnChassis && request.Cmd == cmdChassisControl {
+					controls = append(controls, request.Data)
+				}
+			}
+			if test.wantErr != nil {
+				if len(controls) != 0 {
+					t.Errorf("chassis controls = %v, want none", controls)
+				}
+				return
+			}
+			if len(controls) != 1 || !bytes.Equal(controls[0], []byte{test.wantControl}) {
+				t.Errorf("chassis controls = %v, want [[%d]]", controls, test.wantControl)
+			}
+		})
+	}
+}
+
+func TestClientSetBootDevice(t *testing.T) {
+	tests := []struct {
+		name      string
+		device    mgmtv1alpha1.BootDevice
+		uefi      bool
+		wantFlags []byte
+		wantErr   error
+	}{
+		{name: "PXE", device: mgmtv1alpha1.BootDevicePXE, wantFlags: []byte{0x05, 0x80, 0x04, 0x00, 0x00, 0x00}},
+		{name: "disk with UEFI", device: mgmtv1alpha1.BootDeviceDisk, uefi: true, wantFlags: []byte{0x05, 0xa0, 0x08, 0x00, 0x00, 0x00}},
+		{name: "BIOS setup", device: mgmtv1alpha1.BootDeviceBIOSSetup, wantFlags: []byte{0x05, 0x80, 0x18, 0x00, 0x00, 0x00}},
+		{name: "unsupported device", device: "Usb", wantErr: common.ErrNotSupported},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bmc := &testBMC{}
+			client := newTestClient(t, bmc, mgmtv1alpha1.HostSpecIPMIOptions{UEFI: test.uefi}, testPassword)
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer client.Disconnect()
+
+			err := client.SetBootDevice(context.Background(), test.device)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("SetBootDevice() error = %v, want %v", err, test.wantErr)
+			}
+
+			var options [][]byte
+			for _, request := range bmc.Requests() {
+				if request.NetFn == netFnChassis && request.Cmd == cmdSetSystemBootOptions {
+					options = append(options, request.Data)
+				}
+			}
+			var want [][]byte
+			if test.wantErr == nil {
+				// The boot flags are set while the parameters are locked.
+				want = [][]byte{{0x00, 0x01}, test.wantFlags, {0x00, 0x00}}
+			}
+			if fmt.Sprint(options) != fmt.Sprint(want) {
+				t.Errorf("boot options = %v, want %v", options, want)
+			}
+		})
+	}
+}
+
+func TestClientCompletionError(t *testing.T) {
+	client := connectTestClient(t, &testBMC{})
+
+	_, err := client.request(context.Background(), netFnStorage, cmdGetFRUInventoryAreaInfo, []byte{0x00})
+	var completion *CompletionError
+	if !errors.As(err, &completion) {
+		t.Fatalf("request() error = %v, want a completion error", err)
+	}
+	if want := "IPMI command 0x0a/0x10 failed: requested sensor, data, or record not present"; completion.Error() != want {
+		t.Errorf("Error() = %q, want %q", completion.Error(), want)
+	}
+
+	_, err = client.request(context.Background(), netFnApp, 0x7f, nil)
+	if !errors.As(err, &completion) || completion.Code != 0xc1 {
+		t.Errorf("request() error = %v, want invalid command", err)
+	}
+	// Completion codes that are specific to a command are not described.
+	completion = &CompletionError{NetFn: netFnApp, Cmd: 0x7f, Code: 0x80}
+	if want := "IPMI command 0x06/0x7f failed with completion code 0x80"; completion.Error() != want {
+		t.Errorf("Error() = %q, want %q", completion.Error(), want)
+	}
+}
+
+// ipmiSimConfig is the LAN configuration of the simulator.
+const ipmiSimConfig = `name "kraut"
+set_working_mc 0x20
+  startlan 1
+    addr 127.0.0.1 %d
+    priv_limit admin
+    allowed_auths_callback none md5 msg straight
+    allowed_auths_user none md5 msg straight
+    allowed_auths_operator none md5 msg straight
+    allowed_auths_admin none md5 msg straight
+    guid a123456789abcdefa123456789abcdef
+  endlan
+  user 2 true "%s" "%s" admin 10 none md5 msg straight
+`
+
+// ipmiSimCommands emulates a BMC with a SEL.
+const ipmiSimCommands = `mc_setbmc 0x20
+mc_add 0x20 0 no-device-sdrs 0x23 9 8 0x9f 0x1291 0xf02 persist_sdr
+sel_enable 0x20 1000 0x0a
+mc_enable 0x20
+`
+
+// TestIPMISim tests the client against the BMC simulator of OpenIPMI.
+func TestIPMISim(t *testing.T) {
+	path, err := exec.LookPath("ipmi_sim")
+	if err != nil {
+		t.Skip("ipmi_sim is not installed")
+	}
+
+	// Find a free port for the simulator.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "lan.conf")
+	if err := os.WriteFile(config, []byte(fmt.Sprintf(ipmiSimConfig, port, testUser, testPassword)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	commands := filepath.Join(dir, "ipmisim.emu")
+	if err := os.WriteFile(commands, []byte(ipmiSimCommands), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "-n", "-c", config, "-f", commands, "-s", dir)
+	// The simulator exits if its console is closed.
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+
+	client := newHostClient(t, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), testUser, mgmtv1alpha1.HostSpecIPMIOptions{}, testPassword)
+
+	// Wait until the simulator is ready.
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		err := client.Connect(context.Background())
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	defer client.Disconnect()
+
+	if _, err := client.Inventory(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PowerState(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Events(context.Background(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,251 @@
+package ipmi
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+const (
+	// fruChunkSize is the number of bytes that are read per request, which
+	// is small enough for the message size limits of all known BMCs.
+	fruChunkSize = 16
+	// fruEndOfFields marks the end of the fields of an area.
+	fruEndOfFields = 0xc1
+)
+
+// chassisTypes maps the SMBIOS chassis types of the FRU
+// inventory to the chassis types of the Redfish schema.
+var chassisTypes = map[byte]string{
+	0x03: "StandAlone",
+	0x04: "StandAlone",
+	0x06: "StandAlone",
+	0x07: "StandAlone",
+	0x11: "StandAlone",
+	0x17: "RackMount",
+	0x19: "Enclosure",
+	0x1c: "Blade",
+	0x1d: "Enclosure",
+}
+
+// fru contains the information of the FRU inventory of the BMC, which
+// describes the chassis, the mainboard and the product of the host.
+type fru struct {
+	chassisType   byte
+	chassisPart   string
+	chassisSerial string
+
+	boardManufacturer string
+	boardProduct      string
+	boardSerial       string
+
+	productManufacturer string
+	productName         string
+	productPart         string
+	productSerial       string
+}
+
+// apply adds the information of the FRU inventory to the inventory. Product
+// information takes precedence over the information of the mainboard.
+func (f *fru) apply(inventory *mgmtv1alpha1.HostStatusOutOfBand) {
+	inventory.System.Manufacturer = firstNonEmpty(f.productManufacturer, f.boardManufacturer)
+	inventory.System.Model = firstNonEmpty(f.productName, f.boardProduct)
+	inventory.System.SerialNumber = firstNonEmpty(f.productSerial, f.chassisSerial, f.boardSerial)
+	inventory.System.SKU = f.productPart
+
+	inventory.Chassis.Type = chassisTypes[f.chassisType]
+	inventory.Chassis.Model = f.chassisPart
+	inventory.Chassis.SerialNumber = f.chassisSerial
+}
+
+// readFRU reads the FRU inventory of the BMC as defined in
+// the IPMI Platform Management FRU Information Storage Definition.
+func (c *Client) readFRU(ctx context.Context) (*fru, error) {
+	info, err := c.request(ctx, netFnStorage, cmdGetFRUInventoryAreaInfo, []byte{0x00})
+	if err != nil {
+		return nil, err
+	}
+	if len(info) < 3 {
+		return nil, fmt.Errorf("%w: truncated FRU inventory area info", errMalformed)
+	}
+	size := int(binary.LittleEndian.Uint16(info[0:2]))
+	byWords := info[2]&0x01 != 0
+	if size < 8 {
+		return nil, nil
+	}
+
+	header, err := c.readFRUData(ctx, 0, 8, byWords)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f != 0x01 || checksum(header[:7]) != header[7] {
+		return nil, fmt.Errorf("%w: invalid FRU common header", errMalformed)
+	}
+
+	f := &fru{}
+	if offset := int(header[2]) * 8; offset != 0 {
+		area, err := c.readFRUArea(ctx, offset, size, byWords)
+		if err != nil {
+			return nil, err
+		}
+		if len(area) > 2 {
+			f.chassisType = area[2]
+			fields := fruFields(area[3:])
+			f.chassisPart = field(fields, 0)
+			f.chassisSerial = field(fields, 1)
+		}
+	}
+	if offset := int(header[3]) * 8; offset != 0 {
+		area, err := c.readFRUArea(ctx, offset, size, byWords)
+		if err != nil {
+			return nil, err
+		}
+		if len(area) > 6 {
+			fields := fruFields(area[6:])
+			f.boardManufacturer = field(fields, 0)
+			f.boardProduct = field(fields, 1)
+			f.boardSerial = field(fields, 2)
+		}
+	}
+	if offset := int(header[4]) * 8; offset != 0 {
+		area, err := c.readFRUArea(ctx, offset, size, byWords)
+		if err != nil {
+			return nil, err
+		}
+		if len(area) > 3 {
+			fields := fruFields(area[3:])
+			f.productManufacturer = field(fields, 0)
+			f.productName = field(fields, 1)
+			f.productPart = field(fields, 2)
+			f.productSerial = field(fields, 4)
+		}
+	}
+
+	return f, nil
+}
+
+// readFRUArea reads an area of the FRU inventory and verifies its checksum.
+func (c *Client) readFRUArea(ctx context.Context, offset int, size int, byWords bool) ([]byte, error) {
+	header, err := c.readFRUData(ctx, offset, 2, byWords)
+	if err != nil {
+		return nil, err
+	}
+
+	length := int(header[1]) * 8
+	if length < 2 || offset+length > size {
+		return nil, fmt.Errorf("%w: invalid length of FRU area", errMalformed)
+	}
+
+	area, err := c.readFRUData(ctx, offset, length, byWords)
+	if err != nil {
+		return nil, err
+	}
+	if checksum(area[:length-1]) != area[length-1] {
+		return nil, fmt.Errorf("%w: invalid checksum of FRU area", errMalformed)
+	}
+
+	return area, nil
+}
+
+// readFRUData reads the bytes of the FRU inventory in chunks. Some
+// BMCs address the inventory in words instead of bytes.
+func (c *Client) readFRUData(ctx context.Context, offset int, length int, byWords bool) ([]byte, error) {
+	data := make([]byte, 0, length)
+	for len(data) < length {
+		position := offset + len(data)
+		count := min(length-len(data), fruChunkSize)
+		if byWords {
+			position /= 2
+			count = (count + 1) / 2
+		}
+
+		request := binary.LittleEndian.AppendUint16([]byte{0x00}, uint16(position))
+		request = append(request, byte(count))
+		res, err := c.request(ctx, netFnStorage, cmdReadFRUData, request)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) < 2 {
+			return nil, fmt.Errorf("%w: empty FRU data", errMalformed)
+		}
+
+		data = append(data, res[1:]...)
+	}
+
+	return data[:length], nil
+}
+
+// fruFields decodes the type/length encoded fields of an area.
+func fruFields(data []byte) []string {
+	var fields []string
+	for len(data) > 0 && data[0] != fruEndOfFields {
+		length := int(data[0] & 0x3f)
+		if len(data) < 1+length {
+			break
+		}
+
+		fields = append(fields, decodeFRUField(data[0]>>6, data[1:1+length]))
+		data = data[1+length:]
+	}
+
+	return fields
+}
+
+// decodeFRUField decodes the value of a field according to its type.
+func decodeFRUField(typ byte, value []byte) string {
+	var text string
+	switch typ {
+	case 0x00:
+		text = hex.EncodeToString(value)
+	case 0x01:
+		// BCD plus encodes digits, a space, a dash and a period.
+		var b strings.Builder
+		for _, v := range value {
+			for _, digit := range []byte{v >> 4, v & 0x0f} {
+				b.WriteByte("0123456789 -.???"[digit])
+			}
+		}
+		text = b.String()
+	case 0x02:
+		// 6-bit ASCII packs four characters into three bytes.
+		var b strings.Builder
+		var bits uint32
+		var n uint
+		for _, v := range value {
+			bits |= uint32(v) << n
+			for n += 8; n >= 6; n -= 6 {
+				b.WriteByte(byte(bits&0x3f) + 0x20)
+				bits >>= 6
+			}
+		}
+		text = b.String()
+	default:
+		text = string(value)
+	}
+
+	return strings.TrimSpace(strings.TrimRight(text, "\x00"))
+}
+
+// field returns the field at the index or an empty string if it does not exist.
+func field(fields []string, i int) string {
+	if i >= len(fields) {
+		return ""
+	}
+
+	return fields[i]
+}
+
+// firstNonEmpty returns the first value that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,197 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+// textField encodes a field of type ASCII.
+func textField(value string) []byte {
+	return append([]byte{0xc0 | byte(len(value))}, value...)
+}
+
+// fruArea encodes an area of the FRU inventory with the header fields
+// that follow the length and the type/length encoded fields.
+func fruArea(header []byte, fields ...[]byte) []byte {
+	area := append([]byte{0x01, 0x00}, header...)
+	for _, field := range fields {
+		area = append(area, field...)
+	}
+	area = append(area, fruEndOfFields)
+	for (len(area)+1)%8 != 0 {
+		area = append(area, 0x00)
+	}
+	area[1] = byte((len(area) + 1) / 8)
+
+	return append(area, checksum(area))
+}
+
+// fruImage encodes a FRU inventory with a chassis, a board and a product area.
+func fruImage(chassis []byte, board []byte, product []byte) []byte {
+	header := []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	image := make([]byte, 8)
+	for i, area := range [][]byte{chassis, board, product} {
+		if area == nil {
+			continue
+		}
+		header[2+i] = byte((len(image)) / 8)
+		image = append(image, area...)
+	}
+	copy(image, append(header, checksum(header)))
+
+	return image
+}
+
+func TestDecodeFRUField(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   byte
+		value []byte
+		want  string
+	}{
+		{name: "binary", typ: 0x00, value: []byte{0xde, 0xad}, want: "dead"},
+		{name: "BCD plus", typ: 0x01, value: []byte{0x12, 0x3b, 0xc4}, want: "123-.4"},
+		{name: "6-bit ASCII", typ: 0x02, value: []byte{0x29, 0xdc, 0xa6}, want: "IPMI"},
+		{name: "8-bit ASCII", typ: 0x03, value: []byte("PowerEdge R650"), want: "PowerEdge R650"},
+		{name: "padding", typ: 0x03, value: []byte("R650 \x00\x00"), want: "R650"},
+		{name: "empty", typ: 0x03, want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := decodeFRUField(test.typ, test.value); got != test.want {
+				t.Errorf("decodeFRUField() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFRUFields(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{
+			name: "mixed types",
+			data: append(append(textField("Dell Inc."), 0x83, 0x29, 0xdc, 0xa6, 0x00), fruEndOfFields),
+			want: []string{"Dell Inc.", "IPMI", ""},
+		},
+		{
+			name: "truncated field",
+			data: append(textField("CN7016"), 0xc8, 'A'),
+			want: []string{"CN7016"},
+		},
+		{
+			name: "no fields",
+			data: []byte{fruEndOfFields, 0xc3, 'A', 'B', 'C'},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := fruFields(test.data)
+			if len(got) != len(test.want) {
+				t.Fatalf("fruFields() = %q, want %q", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("fruFields() = %q, want %q", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFRU(t *testing.T) {
+	image := fruImage(
+		fruArea([]byte{0x17}, textField("0JP31P"), textField("CHASSIS01")),
+		fruArea([]byte{0x19, 0x00, 0x00, 0x00}, textField("Dell Inc."), textField("PowerEdge R650 Board"), textField("BOARD01"), textField("0JP31PA00")),
+		fruArea([]byte{0x19}, textField("Dell Inc."), textField("PowerEdge R650"), textField("SKU-R650"), textField("A00"), textField("PRODUCT01")),
+	)
+
+	for _, byWords := range []bool{false, true} {
+		bmc := &testBMC{fru: image, fruByWords: byWords, chassisStatus: 0x01}
+		client := connectTestClient(t, bmc)
+
+		inventory, err := client.Inventory(context.Background())
+		if err != nil {
+			t.Fatalf("Inventory() with word addressing %t failed: %s", byWords, err)
+		}
+
+		want := mgmtv1alpha1.HostStatusOutOfBand{}
+		want.System.Manufacturer = "Dell Inc."
+		want.System.Model = "PowerEdge R650"
+		want.System.SerialNumber = "PRODUCT01"
+		want.System.SKU = "SKU-R650"
+		want.Chassis.Type = "RackMount"
+		want.Chassis.Model = "0JP31P"
+		want.Chassis.SerialNumber = "CHASSIS01"
+		if inventory.System.Manufacturer != want.System.Manufacturer || inventory.System.Model != want.System.Model ||
+			inventory.System.SerialNumber != want.System.SerialNumber || inventory.System.SKU != want.System.SKU ||
+			inventory.Chassis != want.Chassis {
+			t.Errorf("Inventory() with word addressing %t = %+v, want system %+v and chassis %+v", byWords, inventory, want.System, want.Chassis)
+		}
+	}
+}
+
+func TestReadFRUBoardOnly(t *testing.T) {
+	image := fruImage(nil, fruArea([]byte{0x19, 0x00, 0x00, 0x00}, textField("Supermicro"), textField("X12DPi-NT6"), textField("BOARD01")), nil)
+	client := connectTestClient(t, &testBMC{fru: image})
+
+	f, err := client.readFRU(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var inventory mgmtv1alpha1.HostStatusOutOfBand
+	f.apply(&inventory)
+	if inventory.System.Manufacturer != "Supermicro" || inventory.System.Model != "X12DPi-NT6" || inventory.System.SerialNumber != "BOARD01" {
+		t.Errorf("apply() = %+v", inventory.System)
+	}
+	if inventory.Chassis.Type != "" || inventory.System.SKU != "" {
+		t.Errorf("apply() = %+v", inventory)
+	}
+}
+
+func TestReadFRUMalformed(t *testing.T) {
+	valid := fruImage(nil, nil, fruArea([]byte{0x19}, textField("Dell Inc.")))
+
+	invalidHeader := append([]byte(nil), valid...)
+	invalidHeader[7]++
+	invalidArea := append([]byte(nil), valid...)
+	invalidArea[len(invalidArea)-1]++
+	oversizedArea := append([]byte(nil), valid...)
+	oversizedArea[9] = 0x10
+
+	tests := []struct {
+		name string
+		fru  []byte
+	}{
+		{name: "invalid header checksum", fru: invalidHeader},
+		{name: "invalid area checksum", fru: invalidArea},
+		{name: "area exceeds inventory", fru: oversizedArea},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := connectTestClient(t, &testBMC{fru: test.fru})
+
+			if _, err := client.readFRU(context.Background()); !errors.Is(err, errMalformed) {
+				t.Errorf("readFRU() error = %v, want %v", err, errMalformed)
+			}
+
+			// The inventory is still available without the FRU inventory.
+			inventory, err := client.Inventory(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inventory.System.Manufacturer != "" {
+				t.Errorf("Inventory() = %+v", inventory.System)
+			}
+		})
+	}
+}
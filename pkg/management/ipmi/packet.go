@@ -0,0 +1,285 @@
+package ipmi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// rmcpVersion is the version of the RMCP header of IPMI messages.
+	rmcpVersion = 0x06
+	// rmcpNoAck is the RMCP sequence number, which disables acknowledgements.
+	rmcpNoAck = 0xff
+	// rmcpClassIPMI is the RMCP message class of IPMI messages.
+	rmcpClassIPMI = 0x07
+
+	// authTypeNone is the authentication type of messages outside of a session.
+	authTypeNone = 0x00
+	// authTypeRMCPPlus identifies the session header of IPMI v2.0.
+	authTypeRMCPPlus = 0x06
+
+	// payloadEncrypted and payloadAuthenticated are the flags of the payload type.
+	payloadEncrypted     = 0x80
+	payloadAuthenticated = 0x40
+	// payloadTypeMask masks the flags of the payload type.
+	payloadTypeMask = 0x3f
+
+	// nextHeader is the value of the next header field of authenticated packets.
+	nextHeader = 0x07
+
+	// bmcAddress is the address of the BMC on the IPMB.
+	bmcAddress = 0x20
+	// remoteConsoleAddress is the software ID of the remote console.
+	remoteConsoleAddress = 0x81
+
+	// maxPacketSize is the maximum size of a packet.
+	maxPacketSize = 1024
+)
+
+// payloadType is the type of the payload of an RMCP+ packet.
+type payloadType byte
+
+const (
+	payloadTypeIPMI                payloadType = 0x00
+	payloadTypeOpenSessionRequest  payloadType = 0x10
+	payloadTypeOpenSessionResponse payloadType = 0x11
+	payloadTypeRAKP1               payloadType = 0x12
+	payloadTypeRAKP2               payloadType = 0x13
+	payloadTypeRAKP3               payloadType = 0x14
+	payloadTypeRAKP4               payloadType = 0x15
+)
+
+// errMalformed is returned if a packet cannot be decoded.
+var errMalformed = errors.New("malformed IPMI packet")
+
+// packet is a decoded RMCP or RMCP+ packet.
+type packet struct {
+	// Type is the type of the payload, which is always IPMI for IPMI v1.5 packets.
+	Type payloadType
+	// SessionID is the ID of the session of the receiver.
+	SessionID uint32
+	// Payload is the decrypted payload.
+	Payload []byte
+}
+
+// marshalPacket encodes the payload in an RMCP+ packet. The payload is
+// authenticated and encrypted if the session has been activated.
+func marshalPacket(s *session, typ payloadType, payload []byte) ([]byte, error) {
+	flags := byte(0)
+	sessionID := uint32(0)
+	sequence := uint32(0)
+	if s != nil && s.active {
+		flags = payloadEncrypted | payloadAuthenticated
+		sessionID = s.managedID
+		s.sequence++
+		sequence = s.sequence
+
+		var err error
+		payload, err = s.encrypt(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	buf := []byte{rmcpVersion, 0x00, rmcpNoAck, rmcpClassIPMI, authTypeRMCPPlus, flags | byte(typ)}
+	buf = binary.LittleEndian.AppendUint32(buf, sessionID)
+	buf = binary.LittleEndian.AppendUint32(buf, sequence)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(payload)))
+	buf = append(buf, payload...)
+
+	if flags&payloadAuthenticated != 0 {
+		// The integrity pad aligns the authenticated data, which
+		// starts with the authentication type, to four bytes.
+		pad := (4 - (len(buf)-4+2)%4) % 4
+		buf = append(buf, bytes.Repeat([]byte{0xff}, pad)...)
+		buf = append(buf, byte(pad), nextHeader)
+		buf = append(buf, s.authCode(buf[4:])...)
+	}
+
+	return buf, nil
+}
+
+// marshalSessionlessPacket encodes the message in an IPMI v1.5 packet
+// outside of a session, which is used to discover the capabilities of a BMC.
+func marshalSessionlessPacket(msg []byte) []byte {
+	buf := []byte{rmcpVersion, 0x00, rmcpNoAck, rmcpClassIPMI, authTypeNone}
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+	buf = append(buf, byte(len(msg)))
+
+	return append(buf, msg...)
+}
+
+// parsePacket decodes an RMCP or RMCP+ packet. The integrity of authenticated
+// packets is verified and encrypted payloads are decrypted.
+func parsePacket(s *session, buf []byte) (*packet, error) {
+	if len(buf) < 5 || buf[0] != rmcpVersion || buf[3]&0x7f != rmcpClassIPMI {
+		return nil, fmt.Errorf("%w: invalid RMCP header", errMalformed)
+	}
+
+	switch buf[4] {
+	case authTypeNone:
+		if len(buf) < 14 || len(buf) < 14+int(buf[13]) {
+			return nil, fmt.Errorf("%w: truncated IPMI v1.5 packet", errMalformed)
+		}
+
+		return &packet{
+			Type:      payloadTypeIPMI,
+			SessionID: binary.LittleEndian.Uint32(buf[9:13]),
+			Payload:   buf[14 : 14+int(buf[13])],
+		}, nil
+	case authTypeRMCPPlus:
+		if len(buf) < 16 {
+			return nil, fmt.Errorf("%w: truncated RMCP+ packet", errMalformed)
+		}
+
+		flags := buf[5] &^ payloadTypeMask
+		length := int(binary.LittleEndian.Uint16(buf[14:16]))
+		if len(buf) < 16+length {
+			return nil, fmt.Errorf("%w: truncated RMCP+ payload", errMalformed)
+		}
+		p := &packet{
+			Type:      payloadType(buf[5] & payloadTypeMask),
+			SessionID: binary.LittleEndian.Uint32(buf[6:10]),
+			Payload:   buf[16 : 16+length],
+		}
+
+		if flags == 0 {
+			return p, nil
+		}
+		if s == nil || !s.active {
+			return nil, fmt.Errorf("%w: unexpected authenticated packet", errMalformed)
+		}
+
+		if flags&payloadAuthenticated != 0 {
+			size := s.suite.integrityLength
+			if len(buf) < 16+length+2+size {
+				return nil, fmt.Errorf("%w: truncated integrity trailer", errMalformed)
+			}
+			if !hmac.Equal(buf[len(buf)-size:], s.authCode(buf[4:len(buf)-size])) {
+				return nil, errors.New("failed to verify integrity of IPMI packet")
+			}
+		}
+
+		if flags&payloadEncrypted != 0 {
+			payload, err := s.decrypt(p.Payload)
+			if err != nil {
+				return nil, err
+			}
+			p.Payload = payload
+		}
+
+		return p, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported authentication type 0x%02x", errMalformed, buf[4])
+	}
+}
+
+// encrypt encrypts the payload with AES-CBC-128 and prepends the initialization vector.
+func (s *session) encrypt(payload []byte) ([]byte, error) {
+	block, err := aes.NewCipher(s.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	// The confidentiality pad consists of the bytes 1, 2, 3, ..., followed by its length.
+	pad := (aes.BlockSize - (len(payload)+1)%aes.BlockSize) % aes.BlockSize
+	plain := make([]byte, 0, len(payload)+pad+1)
+	plain = append(plain, payload...)
+	for i := 1; i <= pad; i++ {
+		plain = append(plain, byte(i))
+	}
+	plain = append(plain, byte(pad))
+
+	out := make([]byte, aes.BlockSize+len(plain))
+	if _, err := rand.Read(out[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], plain)
+
+	return out, nil
+}
+
+// decrypt decrypts a payload that was encrypted with AES-CBC-128.
+func (s *session) decrypt(payload []byte) ([]byte, error) {
+	if len(payload) < 2*aes.BlockSize || len(payload)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: invalid length of encrypted payload", errMalformed)
+	}
+
+	block, err := aes.NewCipher(s.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]byte, len(payload)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, payload[:aes.BlockSize]).CryptBlocks(plain, payload[aes.BlockSize:])
+
+	pad := int(plain[len(plain)-1])
+	if pad >= len(plain) {
+		return nil, fmt.Errorf("%w: invalid confidentiality pad", errMalformed)
+	}
+
+	return plain[:len(plain)-1-pad], nil
+}
+
+// authCode computes the truncated HMAC of the data with the integrity key.
+func (s *session) authCode(data []byte) []byte {
+	mac := hmac.New(s.suite.hash, s.k1)
+	mac.Write(data)
+
+	return mac.Sum(nil)[:s.suite.integrityLength]
+}
+
+// marshalRequest encodes an IPMI request message to the BMC.
+func marshalRequest(netFn byte, cmd byte, seq byte, data []byte) []byte {
+	msg := []byte{bmcAddress, netFn << 2, 0}
+	msg[2] = checksum(msg[:2])
+
+	body := append([]byte{remoteConsoleAddress, seq << 2, cmd}, data...)
+	msg = append(msg, body...)
+
+	return append(msg, checksum(body))
+}
+
+// response is a decoded IPMI response message.
+type response struct {
+	NetFn      byte
+	Seq        byte
+	Cmd        byte
+	Completion byte
+	Data       []byte
+}
+
+// parseResponse decodes an IPMI response message.
+func parseResponse(msg []byte) (*response, error) {
+	if len(msg) < 8 {
+		return nil, fmt.Errorf("%w: truncated IPMI message", errMalformed)
+	}
+	if checksum(msg[:2]) != msg[2] || checksum(msg[3:len(msg)-1]) != msg[len(msg)-1] {
+		return nil, fmt.Errorf("%w: invalid IPMI message checksum", errMalformed)
+	}
+
+	return &response{
+		NetFn:      msg[1] >> 2,
+		Seq:        msg[4] >> 2,
+		Cmd:        msg[5],
+		Completion: msg[6],
+		Data:       msg[7 : len(msg)-1],
+	}, nil
+}
+
+// checksum computes the two's complement checksum of the data.
+func checksum(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+
+	return -sum
+}
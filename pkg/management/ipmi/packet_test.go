@@ -0,0 +1,246 @@
+package ipmi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// newTestSession creates an active session with fixed keys.
+func newTestSession(suite *cipherSuite) *session {
+	return &session{
+		suite:     suite,
+		remoteID:  0x01020305,
+		managedID: 0x0a0b0c0d,
+		k1:        bytes.Repeat([]byte{0x11}, suite.hash().Size()),
+		k2:        bytes.Repeat([]byte{0x22}, suite.hash().Size()),
+		active:    true,
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want byte
+	}{
+		{data: nil, want: 0x00},
+		{data: []byte{0x20, 0x18}, want: 0xc8},
+		{data: []byte{0x81, 0x04, 0x01}, want: 0x7a},
+		{data: []byte{0xff, 0x01}, want: 0x00},
+		{data: []byte{0x01}, want: 0xff},
+	}
+
+	for _, test := range tests {
+		got := checksum(test.data)
+		if got != test.want {
+			t.Errorf("checksum(% x) = 0x%02x, want 0x%02x", test.data, got, test.want)
+		}
+		// The sum of the data and the checksum is zero.
+		if sum := checksum(append(test.data, got)); sum != 0 {
+			t.Errorf("checksum(% x) does not complement the data", test.data)
+		}
+	}
+}
+
+func TestMarshalRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		netFn byte
+		cmd   byte
+		seq   byte
+		data  []byte
+		want  []byte
+	}{
+		{
+			name:  "Get Device ID",
+			netFn: netFnApp,
+			cmd:   cmdGetDeviceID,
+			seq:   1,
+			want:  []byte{0x20, 0x18, 0xc8, 0x81, 0x04, 0x01, 0x7a},
+		},
+		{
+			name:  "Chassis Control",
+			netFn: netFnChassis,
+			cmd:   cmdChassisControl,
+			seq:   0x3f,
+			data:  []byte{0x03},
+			want:  []byte{0x20, 0x00, 0xe0, 0x81, 0xfc, 0x02, 0x03, 0x7e},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := marshalRequest(test.netFn, test.cmd, test.seq, test.data); !bytes.Equal(got, test.want) {
+				t.Errorf("marshalRequest() = % x, want % x", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	valid := marshalTestResponse(netFnApp, cmdGetDeviceID, 5, 0x00, []byte{0x20, 0x01, 0x02, 0x45})
+
+	res, err := parseResponse(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NetFn != netFnApp+1 || res.Seq != 5 || res.Cmd != cmdGetDeviceID || res.Completion != 0 || !bytes.Equal(res.Data, []byte{0x20, 0x01, 0x02, 0x45}) {
+		t.Errorf("parseResponse() = %+v", res)
+	}
+
+	invalidHeader := bytes.Clone(valid)
+	invalidHeader[2]++
+	invalidBody := bytes.Clone(valid)
+	invalidBody[len(invalidBody)-1]++
+
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{name: "truncated", msg: valid[:7]},
+		{name: "invalid header checksum", msg: invalidHeader},
+		{name: "invalid body checksum", msg: invalidBody},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseResponse(test.msg); !errors.Is(err, errMalformed) {
+				t.Errorf("parseResponse() error = %v, want %v", err, errMalformed)
+			}
+		})
+	}
+}
+
+func TestSessionlessPacket(t *testing.T) {
+	msg := marshalRequest(netFnApp, cmdGetChannelAuthCapabilities, 1, []byte{0x8e, 0x04})
+	raw := marshalSessionlessPacket(msg)
+
+	want := append([]byte{0x06, 0x00, 0xff, 0x07, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, byte(len(msg))}, msg...)
+	if !bytes.Equal(raw, want) {
+		t.Fatalf("marshalSessionlessPacket() = % x, want % x", raw, want)
+	}
+
+	p, err := parsePacket(nil, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != payloadTypeIPMI || p.SessionID != 0 || !bytes.Equal(p.Payload, msg) {
+		t.Errorf("parsePacket() = %+v", p)
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	for _, suite := range cipherSuites {
+		// Payloads of all lengths modulo the block size and the integrity alignment.
+		for length := 0; length <= 33; length++ {
+			s := newTestSession(suite)
+			payload := bytes.Repeat([]byte{byte(length)}, length)
+
+			raw, err := marshalPacket(s, payloadTypeIPMI, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if raw[5] != payloadEncrypted|payloadAuthenticated|byte(payloadTypeIPMI) {
+				t.Fatalf("cipher suite %d: payload type = 0x%02x", suite.ID, raw[5])
+			}
+			// The authenticated data from the authentication type to the
+			// next header is aligned to four bytes.
+			if (len(raw)-4-suite.integrityLength)%4 != 0 {
+				t.Errorf("cipher suite %d with %d bytes: authenticated data is not aligned: %d bytes", suite.ID, length, len(raw)-4-suite.integrityLength)
+			}
+			if s.sequence != 1 {
+				t.Errorf("cipher suite %d: sequence = %d, want 1", suite.ID, s.sequence)
+			}
+
+			p, err := parsePacket(s, raw)
+			if err != nil {
+				t.Fatalf("cipher suite %d with %d bytes: %s", suite.ID, length, err)
+			}
+			if p.SessionID != s.managedID || !bytes.Equal(p.Payload, payload) {
+				t.Errorf("cipher suite %d with %d bytes: parsePacket() = %+v", suite.ID, length, p)
+			}
+		}
+	}
+}
+
+func TestPacketIntegrity(t *testing.T) {
+	s := newTestSession(cipherSuites[0])
+	raw, err := marshalPacket(s, payloadTypeIPMI, []byte("chassis status"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Clone(raw)
+	tampered[20] ^= 0x01
+	if _, err := parsePacket(s, tampered); err == nil || errors.Is(err, errMalformed) {
+		t.Errorf("parsePacket() of tampered packet error = %v, want integrity error", err)
+	}
+
+	other := newTestSession(cipherSuites[0])
+	other.k1 = bytes.Repeat([]byte{0x33}, 32)
+	if _, err := parsePacket(other, raw); err == nil {
+		t.Error("expected packet with another integrity key to be rejected")
+	}
+
+	// Authenticated packets are only accepted by an active session.
+	if _, err := parsePacket(nil, raw); !errors.Is(err, errMalformed) {
+		t.Errorf("parsePacket() without session error = %v, want %v", err, errMalformed)
+	}
+	if _, err := parsePacket(s, raw[:len(raw)-s.suite.integrityLength-3]); !errors.Is(err, errMalformed) {
+		t.Errorf("parsePacket() of truncated packet error = %v, want %v", err, errMalformed)
+	}
+}
+
+func TestPacketSetup(t *testing.T) {
+	// Packets of the session setup are neither authenticated nor encrypted.
+	raw, err := marshalPacket(nil, payloadTypeRAKP1, []byte{0x01, 0x02})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x06, 0x00, 0xff, 0x07, 0x06, 0x12, 0, 0, 0, 0, 0, 0, 0, 0, 0x02, 0x00, 0x01, 0x02}
+	if !bytes.Equal(raw, want) {
+		t.Errorf("marshalPacket() = % x, want % x", raw, want)
+	}
+}
+
+func TestParsePacketMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{name: "empty"},
+		{name: "ASF message", raw: []byte{0x06, 0x00, 0xff, 0x06, 0x00}},
+		{name: "unsupported authentication type", raw: []byte{0x06, 0x00, 0xff, 0x07, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "truncated IPMI v1.5 packet", raw: []byte{0x06, 0x00, 0xff, 0x07, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0x08, 0x20}},
+		{name: "truncated RMCP+ header", raw: []byte{0x06, 0x00, 0xff, 0x07, 0x06, 0x00, 0, 0, 0, 0}},
+		{name: "truncated RMCP+ payload", raw: []byte{0x06, 0x00, 0xff, 0x07, 0x06, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0x04, 0x00, 0x01}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parsePacket(nil, test.raw); !errors.Is(err, errMalformed) {
+				t.Errorf("parsePacket() error = %v, want %v", err, errMalformed)
+			}
+		})
+	}
+}
+
+func TestDecryptMalformed(t *testing.T) {
+	s := newTestSession(cipherSuites[1])
+
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{name: "missing ciphertext", payload: make([]byte, 16)},
+		{name: "partial block", payload: make([]byte, 40)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := s.decrypt(test.payload); !errors.Is(err, errMalformed) {
+				t.Errorf("decrypt() error = %v, want %v", err, errMalformed)
+			}
+		})
+	}
+}
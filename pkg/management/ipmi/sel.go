@@ -0,0 +1,230 @@
+package ipmi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	// selFirstEntry and selLastEntry are the special record IDs of the first and the last entry.
+	selFirstEntry = 0x0000
+	selLastEntry  = 0xffff
+	// selRecordSize is the size of an entry of the SEL.
+	selRecordSize = 16
+	// selMinTimestamp is the smallest absolute timestamp. Smaller timestamps
+	// are relative to the initialization of the BMC and cannot be converted.
+	selMinTimestamp = 0x20000000
+	// selUnspecifiedTimestamp indicates that the time is unknown.
+	selUnspecifiedTimestamp = 0xffffffff
+)
+
+// sensorTypes maps the common sensor types to their names.
+var sensorTypes = map[byte]string{
+	0x01: "Temperature",
+	0x02: "Voltage",
+	0x03: "Current",
+	0x04: "Fan",
+	0x05: "Physical Security",
+	0x06: "Platform Security",
+	0x07: "Processor",
+	0x08: "Power Supply",
+	0x09: "Power Unit",
+	0x0c: "Memory",
+	0x0d: "Drive Slot",
+	0x0f: "System Firmware Progress",
+	0x10: "Event Logging Disabled",
+	0x12: "System Event",
+	0x13: "Critical Interrupt",
+	0x14: "Button/Switch",
+	0x19: "Chipset",
+	0x1d: "System Boot Initiated",
+	0x20: "OS Stop/Shutdown",
+	0x21: "Slot/Connector",
+	0x23: "Watchdog",
+	0x28: "Management Subsystem Health",
+	0x2b: "Version Change",
+}
+
+// thresholdEvents describes the offsets of threshold-based events.
+var thresholdEvents = []string{
+	"Lower Non-critical going low",
+	"Lower Non-critical going high",
+	"Lower Critical going low",
+	"Lower Critical going high",
+	"Lower Non-recoverable going low",
+	"Lower Non-recoverable going high",
+	"Upper Non-critical going low",
+	"Upper Non-critical going high",
+	"Upper Critical going low",
+	"Upper Critical going high",
+	"Upper Non-recoverable going low",
+	"Upper Non-recoverable going high",
+}
+
+// sensorEvent describes an offset of a sensor-specific event.
+type sensorEvent struct {
+	Message  string
+	Severity string
+}
+
+// sensorEvents describes common sensor-specific events by sensor type and offset.
+var sensorEvents = map[[2]byte]sensorEvent{
+	{0x05, 0x00}: {"General chassis intrusion", "Warning"},
+	{0x07, 0x00}: {"IERR", "Critical"},
+	{0x07, 0x01}: {"Thermal trip", "Critical"},
+	{0x07, 0x0a}: {"Throttled", "Warning"},
+	{0x08, 0x00}: {"Presence detected", "OK"},
+	{0x08, 0x01}: {"Power supply failure detected", "Critical"},
+	{0x08, 0x02}: {"Predictive failure", "Warning"},
+	{0x08, 0x03}: {"Power supply input lost", "Critical"},
+	{0x09, 0x00}: {"Power off", "OK"},
+	{0x09, 0x04}: {"AC lost", "Critical"},
+	{0x0c, 0x00}: {"Correctable ECC", "Warning"},
+	{0x0c, 0x01}: {"Uncorrectable ECC", "Critical"},
+	{0x0c, 0x05}: {"Correctable ECC logging limit reached", "Warning"},
+	{0x0d, 0x01}: {"Drive fault", "Critical"},
+	{0x0d, 0x02}: {"Predictive failure", "Warning"},
+	{0x0f, 0x00}: {"System firmware error", "Critical"},
+	{0x10, 0x02}: {"Log area reset/cleared", "OK"},
+	{0x12, 0x00}: {"System reconfigured", "OK"},
+	{0x12, 0x01}: {"OEM system boot event", "OK"},
+	{0x13, 0x04}: {"PCI PERR", "Critical"},
+	{0x13, 0x05}: {"PCI SERR", "Critical"},
+	{0x1d, 0x00}: {"Initiated by power up", "OK"},
+	{0x1d, 0x01}: {"Initiated by hard reset", "OK"},
+	{0x1d, 0x02}: {"Initiated by warm reset", "OK"},
+	{0x20, 0x01}: {"Run-time critical stop", "Critical"},
+	{0x20, 0x02}: {"OS graceful stop", "OK"},
+	{0x23, 0x01}: {"Watchdog hard reset", "Warning"},
+}
+
+// Events reads the entries of the SEL, which were created after the given time.
+func (c *Client) Events(ctx context.Context, since time.Time) ([]common.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	info, err := c.request(ctx, netFnStorage, cmdGetSELInfo, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(info) < 9 {
+		return nil, fmt.Errorf("%w: truncated SEL info", errMalformed)
+	}
+	entries := int(binary.LittleEndian.Uint16(info[1:3]))
+	if entries == 0 {
+		return nil, nil
+	}
+	// Reading the SEL is slow, so it is skipped if no entry was added since.
+	if added, ok := selTime(binary.LittleEndian.Uint32(info[5:9])); ok && !added.After(since) {
+		return nil, nil
+	}
+
+	var events []common.Event
+	id := uint16(selFirstEntry)
+	// The number of reads is bounded, because some BMCs return cyclic record IDs.
+	for i := 0; i <= entries && id != selLastEntry; i++ {
+		request := binary.LittleEndian.AppendUint16([]byte{0x00, 0x00}, id)
+		request = append(request, 0x00, 0xff)
+		res, err := c.request(ctx, netFnStorage, cmdGetSELEntry, request)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) < 2+selRecordSize {
+			return nil, fmt.Errorf("%w: truncated SEL entry", errMalformed)
+		}
+		id = binary.LittleEndian.Uint16(res[0:2])
+
+		event, ok := parseSELRecord(res[2 : 2+selRecordSize])
+		if ok && event.Created.After(since) {
+			events = append(events, event)
+		}
+	}
+
+	slices.SortStableFunc(events, func(a, b common.Event) int {
+		return a.Created.Compare(b.Created)
+	})
+
+	return events, nil
+}
+
+// parseSELRecord decodes an entry of the SEL. Entries without a
+// valid timestamp are skipped, as they cannot be ordered.
+func parseSELRecord(record []byte) (common.Event, bool) {
+	recordType := record[2]
+	event := common.Event{
+		ID: fmt.Sprintf("%04X", binary.LittleEndian.Uint16(record[0:2])),
+	}
+
+	created, ok := selTime(binary.LittleEndian.Uint32(record[3:7]))
+	if !ok {
+		return event, false
+	}
+	event.Created = created
+
+	switch {
+	case recordType == 0x02:
+		event.Severity, event.Message = describeSystemEvent(record)
+	case recordType >= 0xc0 && recordType <= 0xdf:
+		event.Severity = "OK"
+		event.Message = fmt.Sprintf("OEM record 0x%02X", recordType)
+	default:
+		return event, false
+	}
+
+	return event, true
+}
+
+// describeSystemEvent returns the severity and the message of a system event record.
+func describeSystemEvent(record []byte) (string, string) {
+	sensorType := record[10]
+	sensorNumber := record[11]
+	deasserted := record[12]&0x80 != 0
+	readingType := record[12] & 0x7f
+	offset := record[13] & 0x0f
+
+	sensor, ok := sensorTypes[sensorType]
+	if !ok {
+		sensor = fmt.Sprintf("Sensor type 0x%02X", sensorType)
+	}
+
+	severity := "Warning"
+	description := fmt.Sprintf("Event offset 0x%X", offset)
+	switch {
+	case readingType == 0x01 && int(offset) < len(thresholdEvents):
+		description = thresholdEvents[offset]
+		switch offset {
+		case 0x00, 0x01, 0x06, 0x07:
+			severity = "Warning"
+		default:
+			severity = "Critical"
+		}
+	case readingType == 0x6f:
+		if known, ok := sensorEvents[[2]byte{sensorType, offset}]; ok {
+			description = known.Message
+			severity = known.Severity
+		}
+	}
+
+	state := "asserted"
+	if deasserted {
+		state = "deasserted"
+		severity = "OK"
+	}
+
+	return severity, fmt.Sprintf("%s #0x%02X: %s %s", sensor, sensorNumber, description, state)
+}
+
+// selTime converts a timestamp of the SEL. It returns false if the
+// timestamp is unspecified or relative to the initialization of the BMC.
+func selTime(timestamp uint32) (time.Time, bool) {
+	if timestamp < selMinTimestamp || timestamp == selUnspecifiedTimestamp {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(timestamp), 0).UTC(), true
+}
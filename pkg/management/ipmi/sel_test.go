@@ -0,0 +1,153 @@
+package ipmi
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// testTimestamp is the timestamp of the test entries of the SEL.
+const testTimestamp = 0x65a0bc00
+
+// selRecord encodes a system event record of the SEL.
+func selRecord(id uint16, recordType byte, timestamp uint32, sensorType byte, sensorNumber byte, eventType byte, offset byte) []byte {
+	record := binary.LittleEndian.AppendUint16(nil, id)
+	record = append(record, recordType)
+	record = binary.LittleEndian.AppendUint32(record, timestamp)
+	return append(record, 0x20, 0x00, 0x04, sensorType, sensorNumber, eventType, offset, 0xff, 0xff)
+}
+
+func TestParseSELRecord(t *testing.T) {
+	created := time.Unix(testTimestamp, 0).UTC()
+
+	tests := []struct {
+		name   string
+		record []byte
+		want   common.Event
+		wantOK bool
+	}{
+		{
+			name:   "upper critical threshold",
+			record: selRecord(0x0001, 0x02, testTimestamp, 0x01, 0x30, 0x01, 0x09),
+			want:   common.Event{ID: "0001", Created: created, Severity: "Critical", Message: "Temperature #0x30: Upper Critical going high asserted"},
+			wantOK: true,
+		},
+		{
+			name:   "upper non-critical threshold",
+			record: selRecord(0x0002, 0x02, testTimestamp, 0x04, 0x31, 0x01, 0x07),
+			want:   common.Event{ID: "0002", Created: created, Severity: "Warning", Message: "Fan #0x31: Upper Non-critical going high asserted"},
+			wantOK: true,
+		},
+		{
+			name:   "sensor-specific event",
+			record: selRecord(0x00a0, 0x02, testTimestamp, 0x0c, 0x60, 0x6f, 0x01),
+			want:   common.Event{ID: "00A0", Created: created, Severity: "Critical", Message: "Memory #0x60: Uncorrectable ECC asserted"},
+			wantOK: true,
+		},
+		{
+			name:   "deasserted event",
+			record: selRecord(0x00a1, 0x02, testTimestamp, 0x08, 0x70, 0xef, 0x01),
+			want:   common.Event{ID: "00A1", Created: created, Severity: "OK", Message: "Power Supply #0x70: Power supply failure detected deasserted"},
+			wantOK: true,
+		},
+		{
+			name:   "unknown sensor type",
+			record: selRecord(0x1000, 0x02, testTimestamp, 0xc5, 0x01, 0x6f, 0x03),
+			want:   common.Event{ID: "1000", Created: created, Severity: "Warning", Message: "Sensor type 0xC5 #0x01: Event offset 0x3 asserted"},
+			wantOK: true,
+		},
+		{
+			name:   "OEM record",
+			record: selRecord(0x1001, 0xc1, testTimestamp, 0x00, 0x00, 0x00, 0x00),
+			want:   common.Event{ID: "1001", Created: created, Severity: "OK", Message: "OEM record 0xC1"},
+			wantOK: true,
+		},
+		{
+			name:   "relative timestamp",
+			record: selRecord(0x1002, 0x02, 0x00001000, 0x01, 0x30, 0x01, 0x09),
+		},
+		{
+			name:   "unspecified timestamp",
+			record: selRecord(0x1003, 0x02, selUnspecifiedTimestamp, 0x01, 0x30, 0x01, 0x09),
+		},
+		{
+			name:   "non-timestamped OEM record",
+			record: selRecord(0x1004, 0xe0, testTimestamp, 0x00, 0x00, 0x00, 0x00),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parseSELRecord(test.record)
+			if ok != test.wantOK {
+				t.Fatalf("parseSELRecord() ok = %t, want %t", ok, test.wantOK)
+			}
+			if ok && got != test.want {
+				t.Errorf("parseSELRecord() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	bmc := &testBMC{
+		sel: [][]byte{
+			selRecord(0x0010, 0x02, testTimestamp+120, 0x0c, 0x60, 0x6f, 0x01),
+			selRecord(0x0020, 0x02, 0x00001000, 0x01, 0x30, 0x01, 0x09),
+			selRecord(0x0030, 0x02, testTimestamp, 0x1d, 0x50, 0x6f, 0x00),
+			selRecord(0x0040, 0xc1, testTimestamp+60, 0x00, 0x00, 0x00, 0x00),
+		},
+		selAdded: testTimestamp + 120,
+	}
+	client := connectTestClient(t, bmc)
+
+	events, err := client.Events(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The events are ordered by their creation and events without a timestamp are skipped.
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	if len(ids) != 3 || ids[0] != "0030" || ids[1] != "0040" || ids[2] != "0010" {
+		t.Errorf("Events() = %v, want IDs [0030 0040 0010]", events)
+	}
+
+	events, err = client.Events(context.Background(), time.Unix(testTimestamp+60, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != "0010" {
+		t.Errorf("Events() since the second event = %v, want ID 0010", events)
+	}
+
+	// The SEL is not read if no entry was added since.
+	before := len(bmc.Requests())
+	events, err = client.Events(context.Background(), time.Unix(testTimestamp+120, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Events() since the last addition = %v, want none", events)
+	}
+	if requests := bmc.Requests()[before:]; len(requests) != 1 || requests[0].Cmd != cmdGetSELInfo {
+		t.Errorf("requests = %v, want only the SEL info", requests)
+	}
+}
+
+func TestEventsEmptySEL(t *testing.T) {
+	bmc := &testBMC{}
+	client := connectTestClient(t, bmc)
+
+	events, err := client.Events(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Events() = %v, want none", events)
+	}
+}
@@ -0,0 +1,261 @@
+package ipmi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+const (
+	// maxPasswordLength is the maximum length of a password in IPMI v2.0.
+	maxPasswordLength = 20
+	// maxUserLength is the maximum length of a user name.
+	maxUserLength = 16
+	// lookupNameOnly requests to look up the user by its name and privilege level.
+	lookupNameOnly = 0x10
+)
+
+// cipherSuite describes the algorithms of a cipher suite.
+type cipherSuite struct {
+	// ID is the ID of the cipher suite.
+	ID int
+	// authAlgorithm, integrityAlgorithm and confidentialityAlgorithm
+	// are the algorithms proposed when opening a session.
+	authAlgorithm            byte
+	integrityAlgorithm       byte
+	confidentialityAlgorithm byte
+	// hash is the hash function of the HMACs.
+	hash func() hash.Hash
+	// integrityLength is the length of the truncated HMACs of the
+	// packets and of the integrity check value of RAKP message 4.
+	integrityLength int
+}
+
+// cipherSuites contains the supported cipher suites, which all use AES-CBC-128
+// for the confidentiality, in the order in which they are tried by default.
+var cipherSuites = []*cipherSuite{
+	{ID: 17, authAlgorithm: 0x03, integrityAlgorithm: 0x04, confidentialityAlgorithm: 0x01, hash: sha256.New, integrityLength: 16},
+	{ID: 3, authAlgorithm: 0x01, integrityAlgorithm: 0x01, confidentialityAlgorithm: 0x01, hash: sha1.New, integrityLength: 12},
+}
+
+// privileges maps the privilege levels to their values.
+var privileges = map[mgmtv1alpha1.IPMIPrivilege]byte{
+	mgmtv1alpha1.IPMIPrivilegeOperator:      0x03,
+	mgmtv1alpha1.IPMIPrivilegeAdministrator: 0x04,
+}
+
+// session contains the state of an RMCP+ session.
+type session struct {
+	suite *cipherSuite
+	// remoteID is the ID of the session on the remote console, which is
+	// used by the BMC, while managedID is the ID of the session on the BMC.
+	remoteID  uint32
+	managedID uint32
+	sequence  uint32
+	// k1 is the key of the integrity algorithm and k2 is
+	// the key of the confidentiality algorithm.
+	k1     []byte
+	k2     []byte
+	active bool
+}
+
+// StatusError is returned if the BMC refuses to open a session.
+type StatusError struct {
+	// Message is the message of the handshake that was refused.
+	Message string
+	// Status is the RMCP+ status code.
+	Status byte
+}
+
+// Error returns the error message.
+func (e *StatusError) Error() string {
+	if reason, ok := statusReasons[e.Status]; ok {
+		return fmt.Sprintf("IPMI session refused at %s: %s", e.Message, reason)
+	}
+
+	return fmt.Sprintf("IPMI session refused at %s with status 0x%02x", e.Message, e.Status)
+}
+
+// unsupportedAlgorithm returns true if the cipher suite is not supported by the BMC.
+func (e *StatusError) unsupportedAlgorithm() bool {
+	switch e.Status {
+	case 0x04, 0x05, 0x06, 0x07, 0x10, 0x11:
+		return true
+	default:
+		return false
+	}
+}
+
+// statusReasons describes the RMCP+ status codes.
+var statusReasons = map[byte]string{
+	0x01: "insufficient resources to create a session",
+	0x02: "invalid session ID",
+	0x03: "invalid payload type",
+	0x04: "invalid authentication algorithm",
+	0x05: "invalid integrity algorithm",
+	0x06: "no matching authentication payload",
+	0x07: "no matching integrity payload",
+	0x08: "inactive session ID",
+	0x09: "invalid role",
+	0x0a: "unauthorized role or privilege level requested",
+	0x0b: "insufficient resources to create a session at the requested role",
+	0x0c: "invalid name length",
+	0x0d: "unauthorized name",
+	0x0e: "unauthorized GUID",
+	0x0f: "invalid integrity check value",
+	0x10: "invalid confidentiality algorithm",
+	0x11: "no cipher suite match with proposed security algorithms",
+	0x12: "illegal parameter",
+}
+
+// openSession establishes an RMCP+ session using the RAKP handshake as defined
+// in section 13 of the IPMI v2.0 specification. The password is used as the
+// BMC key, as BMCs rarely have a dedicated key.
+func (c *Client) openSession(ctx context.Context, suite *cipherSuite, user string, password string, privilege byte) (*session, error) {
+	if len(user) > maxUserLength {
+		return nil, fmt.Errorf("user name exceeds %d characters", maxUserLength)
+	}
+	if len(password) > maxPasswordLength {
+		return nil, fmt.Errorf("password exceeds %d characters", maxPasswordLength)
+	}
+	kuid := []byte(password)
+
+	s := &session{suite: suite}
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	s.remoteID = binary.LittleEndian.Uint32(id[:]) | 1
+
+	// Open the session and negotiate the algorithms.
+	c.tag++
+	request := []byte{c.tag, privilege, 0x00, 0x00}
+	request = binary.LittleEndian.AppendUint32(request, s.remoteID)
+	request = append(request, 0x00, 0x00, 0x00, 0x08, suite.authAlgorithm, 0x00, 0x00, 0x00)
+	request = append(request, 0x01, 0x00, 0x00, 0x08, suite.integrityAlgorithm, 0x00, 0x00, 0x00)
+	request = append(request, 0x02, 0x00, 0x00, 0x08, suite.confidentialityAlgorithm, 0x00, 0x00, 0x00)
+
+	reply, err := c.handshake(ctx, s, payloadTypeOpenSessionRequest, payloadTypeOpenSessionResponse, request, 12, "Open Session Request")
+	if err != nil {
+		return nil, err
+	}
+	s.managedID = binary.LittleEndian.Uint32(reply[8:12])
+
+	// Exchange random numbers and prove the knowledge of the password.
+	role := privilege | lookupNameOnly
+	var rm [16]byte
+	if _, err := rand.Read(rm[:]); err != nil {
+		return nil, err
+	}
+
+	c.tag++
+	request = []byte{c.tag, 0x00, 0x00, 0x00}
+	request = binary.LittleEndian.AppendUint32(request, s.managedID)
+	request = append(request, rm[:]...)
+	request = append(request, role, 0x00, 0x00, byte(len(user)))
+	request = append(request, user...)
+
+	reply, err = c.handshake(ctx, s, payloadTypeRAKP1, payloadTypeRAKP2, request, 40+suite.hash().Size(), "RAKP Message 1")
+	if err != nil {
+		return nil, err
+	}
+	rc := reply[8:24]
+	guid := reply[24:40]
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, s.remoteID)
+	binary.Write(&buf, binary.LittleEndian, s.managedID)
+	buf.Write(rm[:])
+	buf.Write(rc)
+	buf.Write(guid)
+	buf.Write([]byte{role, byte(len(user))})
+	buf.WriteString(user)
+	if !hmac.Equal(reply[40:40+suite.hash().Size()], mac(suite, kuid, buf.Bytes())) {
+		return nil, errors.New("IPMI session refused: invalid password")
+	}
+
+	// Derive the session integrity key and the keys of the algorithms.
+	buf.Reset()
+	buf.Write(rm[:])
+	buf.Write(rc)
+	buf.Write([]byte{role, byte(len(user))})
+	buf.WriteString(user)
+	sik := mac(suite, kuid, buf.Bytes())
+	s.k1 = mac(suite, sik, bytes.Repeat([]byte{0x01}, 20))
+	s.k2 = mac(suite, sik, bytes.Repeat([]byte{0x02}, 20))
+
+	buf.Reset()
+	buf.Write(rc)
+	binary.Write(&buf, binary.LittleEndian, s.remoteID)
+	buf.Write([]byte{role, byte(len(user))})
+	buf.WriteString(user)
+
+	c.tag++
+	request = []byte{c.tag, 0x00, 0x00, 0x00}
+	request = binary.LittleEndian.AppendUint32(request, s.managedID)
+	request = append(request, mac(suite, kuid, buf.Bytes())...)
+
+	reply, err = c.handshake(ctx, s, payloadTypeRAKP3, payloadTypeRAKP4, request, 8+suite.integrityLength, "RAKP Message 3")
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Reset()
+	buf.Write(rm[:])
+	binary.Write(&buf, binary.LittleEndian, s.managedID)
+	buf.Write(guid)
+	if !hmac.Equal(reply[8:8+suite.integrityLength], mac(suite, sik, buf.Bytes())[:suite.integrityLength]) {
+		return nil, errors.New("IPMI session refused: invalid integrity check value of BMC")
+	}
+
+	s.active = true
+
+	return s, nil
+}
+
+// handshake sends a message of the session setup and waits for the reply with
+// the same message tag. It verifies the status and the length of the reply.
+func (c *Client) handshake(ctx context.Context, s *session, requestType payloadType, replyType payloadType, request []byte, length int, name string) ([]byte, error) {
+	raw, err := marshalPacket(nil, requestType, request)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := c.tag
+	p, err := c.roundTrip(ctx, raw, nil, func(p *packet) bool {
+		return p.Type == replyType && len(p.Payload) >= 2 && p.Payload[0] == tag
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reply := p.Payload
+	if reply[1] != 0 {
+		return nil, &StatusError{Message: name, Status: reply[1]}
+	}
+	if len(reply) < length {
+		return nil, fmt.Errorf("%w: truncated reply to %s", errMalformed, name)
+	}
+	if binary.LittleEndian.Uint32(reply[4:8]) != s.remoteID {
+		return nil, fmt.Errorf("%w: session ID mismatch in reply to %s", errMalformed, name)
+	}
+
+	return reply, nil
+}
+
+// mac computes the HMAC of the data with the hash function of the cipher suite.
+func mac(suite *cipherSuite, key []byte, data []byte) []byte {
+	h := hmac.New(suite.hash, key)
+	h.Write(data)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,134 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+func TestOpenSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		supported   []int
+		cipherSuite int
+		wantSuite   int
+	}{
+		{name: "cipher suite 17", cipherSuite: 17, wantSuite: 17},
+		{name: "cipher suite 3", cipherSuite: 3, wantSuite: 3},
+		{name: "default", wantSuite: 17},
+		{name: "fallback", supported: []int{3}, wantSuite: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bmc := &testBMC{suites: test.supported, chassisStatus: 0x01}
+			client := newTestClient(t, bmc, mgmtv1alpha1.HostSpecIPMIOptions{CipherSuite: test.cipherSuite}, testPassword)
+
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer client.Disconnect()
+
+			if !client.session.active || client.session.managedID != testManagedID {
+				t.Fatalf("session = %+v", client.session)
+			}
+			if client.session.suite.ID != test.wantSuite {
+				t.Errorf("cipher suite = %d, want %d", client.session.suite.ID, test.wantSuite)
+			}
+
+			// The keys of both sides must match to exchange encrypted messages.
+			state, err := client.PowerState(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != mgmtv1alpha1.PowerStateOn {
+				t.Errorf("PowerState() = %s, want %s", state, mgmtv1alpha1.PowerStateOn)
+			}
+		})
+	}
+}
+
+func TestOpenSessionRefused(t *testing.T) {
+	tests := []struct {
+		name        string
+		supported   []int
+		cipherSuite int
+		user        string
+		password    string
+		wantStatus  byte
+		wantErr     string
+	}{
+		{
+			name:        "no matching cipher suite",
+			supported:   []int{3},
+			cipherSuite: 17,
+			user:        testUser,
+			password:    testPassword,
+			wantStatus:  0x11,
+			wantErr:     "IPMI session refused at Open Session Request: no cipher suite match with proposed security algorithms",
+		},
+		{
+			name:       "unknown user",
+			user:       "root",
+			password:   testPassword,
+			wantStatus: 0x0d,
+			wantErr:    "IPMI session refused at RAKP Message 1: unauthorized name",
+		},
+		{
+			name:     "invalid password",
+			user:     testUser,
+			password: "admin",
+			wantErr:  "IPMI session refused: invalid password",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bmc := &testBMC{suites: test.supported}
+			client := newHostClient(t, bmc.listen(t), test.user, mgmtv1alpha1.HostSpecIPMIOptions{CipherSuite: test.cipherSuite}, test.password)
+
+			err := client.Connect(context.Background())
+			if err == nil {
+				client.Disconnect()
+				t.Fatal("expected session to be refused")
+			}
+			if err.Error() != test.wantErr {
+				t.Errorf("Connect() error = %q, want %q", err, test.wantErr)
+			}
+
+			var status *StatusError
+			if errors.As(err, &status) != (test.wantStatus != 0) || (status != nil && status.Status != test.wantStatus) {
+				t.Errorf("Connect() error = %#v, want status 0x%02x", err, test.wantStatus)
+			}
+			if client.session != nil || client.conn != nil {
+				t.Error("expected session and connection to be closed")
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status          byte
+		wantMessage     string
+		wantUnsupported bool
+	}{
+		{status: 0x01, wantMessage: "IPMI session refused at RAKP Message 3: insufficient resources to create a session"},
+		{status: 0x04, wantMessage: "IPMI session refused at RAKP Message 3: invalid authentication algorithm", wantUnsupported: true},
+		{status: 0x0f, wantMessage: "IPMI session refused at RAKP Message 3: invalid integrity check value"},
+		{status: 0x10, wantMessage: "IPMI session refused at RAKP Message 3: invalid confidentiality algorithm", wantUnsupported: true},
+		{status: 0x80, wantMessage: "IPMI session refused at RAKP Message 3 with status 0x80"},
+	}
+
+	for _, test := range tests {
+		err := &StatusError{Message: "RAKP Message 3", Status: test.status}
+		if err.Error() != test.wantMessage {
+			t.Errorf("Error() = %q, want %q", err.Error(), test.wantMessage)
+		}
+		if err.unsupportedAlgorithm() != test.wantUnsupported {
+			t.Errorf("unsupportedAlgorithm() for status 0x%02x = %t, want %t", test.status, err.unsupportedAlgorithm(), test.wantUnsupported)
+		}
+	}
+}
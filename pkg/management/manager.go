@@ -0,0 +1,670 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+	"github.com/nicklasfrahm/kraut/pkg/management/ssh"
+)
+
+// errManagerClosed is returned if a connection is
+// requested after all pooled connections were closed.
+var errManagerClosed = errors.New("connection manager is closed")
+
+// Manager maintains a pool of authenticated connections to hosts, which can
+// be shared by multiple controllers. A pooled connection is reused as long as
+// neither the specification of the Host nor the referenced Secret changes and
+// the connection passes a health check. Connections that have not been used
+// for the configured idle timeout are closed. Connections to hosts behind the
+// same SSHBastion are tunneled through a single shared connection to it.
+type Manager struct {
+	opts *common.Options
+
+	mutex    sync.Mutex
+	closed   bool
+	slots    map[types.NamespacedName]*slot
+	bastions map[types.NamespacedName]*bastionSlot
+}
+
+// slot holds the current connection to a single host.
+type slot struct {
+	sync.Mutex
+	current *connection
+}
+
+// connection is a pooled connection to a host.
+type connection struct {
+	client   common.Client
+	key      string
+	users    int
+	lastUsed time.Time
+	retired  bool
+}
+
+// bastionSlot holds the current shared connection to a single bastion.
+type bastionSlot struct {
+	sync.Mutex
+	current *bastionConnection
+}
+
+// bastionConnection is a shared connection to a bastion.
+type bastionConnection struct {
+	bastion  *ssh.Bastion
+	key      string
+	users    int
+	lastUsed time.Time
+	retired  bool
+}
+
+// NewManager creates a new connection manager. The option WithKubernetesClient()
+// is required. Please note that idle connections are only closed if the manager
+// is started, e.g. by adding it to a controller-runtime manager.
+func NewManager(options ...common.Option) (*Manager, error) {
+	opts, err := common.GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.KubernetesClient == nil {
+		return nil, fmt.Errorf("missing required option: WithKubernetesClient()")
+	}
+	if opts.CredentialSource == nil {
+		opts.CredentialSource = common.NewSecretCredentialSource(opts.KubernetesClient, common.DefaultSecretKeys)
+	}
+
+	return &Manager{
+		opts:     opts,
+		slots:    make(map[types.NamespacedName]*slot),
+		bastions: make(map[types.NamespacedName]*bastionSlot),
+	}, nil
+}
+
+// Get returns a connected client for the given host. The client must be
+// returned to the pool by calling Disconnect() once it is no longer used.
+func (m *Manager) Get(ctx context.Context, hostRef types.NamespacedName) (common.Client, error) {
+	host, err := getHost(ctx, m.opts.KubernetesClient, hostRef)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := m.connectionKey(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock of the slot is not held while the connection is checked or
+	// established, so that a slow host does not block other callers.
+	s := m.slot(hostRef)
+	if conn := s.acquire(key); conn != nil {
+		if err := conn.client.Ping(ctx); err == nil {
+			return newPooledClient(s, conn), nil
+		}
+		s.discard(conn)
+	}
+
+	mgmt, err := m.connect(ctx, host, m.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if m.isClosed() {
+		mgmt.Disconnect()
+		return nil, errManagerClosed
+	}
+
+	// Another caller may have established a connection in the meantime.
+	if s.current != nil && s.current.key == key {
+		mgmt.Disconnect()
+	} else {
+		s.retire()
+		s.current = &connection{
+			client: mgmt,
+			key:    key,
+		}
+	}
+
+	conn := s.current
+	conn.users++
+	conn.lastUsed = time.Now()
+
+	return newPooledClient(s, conn), nil
+}
+
+// Dial returns a new client for the given host, which is not pooled. The options
+// override the options of the manager. The client must be closed by calling
+// Disconnect() once it is no longer used.
+func (m *Manager) Dial(ctx context.Context, hostRef types.NamespacedName, options ...common.Option) (common.Client, error) {
+	opts, err := common.GetDefaultOptions().Apply(append([]common.Option{common.WithOptions(m.opts)}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := getHost(ctx, opts.KubernetesClient, hostRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.connect(ctx, host, opts)
+}
+
+// OutOfBand returns a new client for the BMC of the given host, which is not
+// pooled. The client must be closed by calling Disconnect() once it is no
+// longer used.
+func (m *Manager) OutOfBand(ctx context.Context, host *mgmtv1alpha1.Host) (common.OutOfBandClient, error) {
+	return connectOutOfBand(ctx, host, m.opts)
+}
+
+// CredentialSource returns the source of the credentials of the hosts.
+func (m *Manager) CredentialSource() common.CredentialSource {
+	return m.opts.CredentialSource
+}
+
+// CheckBastion connects to the given bastion, unless a healthy shared connection
+// to it exists, and returns the fingerprint of the host key it presented.
+func (m *Manager) CheckBastion(ctx context.Context, bastionRef types.NamespacedName) (common.HostKeys, error) {
+	s, conn, err := m.acquireBastion(ctx, bastionRef)
+	if err != nil {
+		return common.HostKeys{}, err
+	}
+	defer s.release(conn)
+
+	return conn.bastion.HostKeys(), nil
+}
+
+// Invalidate closes the pooled connection to the given host. Connections
+// that are currently in use are closed as soon as they are released. The
+// slot itself is kept, because concurrent callers may already hold it.
+func (m *Manager) Invalidate(hostRef types.NamespacedName) {
+	m.mutex.Lock()
+	s := m.slots[hostRef]
+	m.mutex.Unlock()
+
+	if s != nil {
+		s.Lock()
+		s.retire()
+		s.Unlock()
+	}
+}
+
+// InvalidateBastion closes the shared connection to the given bastion. It is
+// closed as soon as all connections that are tunneled through it are closed.
+func (m *Manager) InvalidateBastion(bastionRef types.NamespacedName) {
+	m.mutex.Lock()
+	s := m.bastions[bastionRef]
+	m.mutex.Unlock()
+
+	if s != nil {
+		s.Lock()
+		s.retire()
+		s.Unlock()
+	}
+}
+
+// Start closes idle connections periodically until the context
+// is cancelled. Afterwards all pooled connections are closed.
+func (m *Manager) Start(ctx context.Context) error {
+	interval := m.opts.IdleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.closeAll()
+			return nil
+		case <-ticker.C:
+			m.closeIdle()
+		}
+	}
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, which
+// ensures that idle connections are also closed if the manager is not the leader.
+func (m *Manager) NeedLeaderElection() bool {
+	return false
+}
+
+// slot returns the slot for the given host and creates it if necessary.
+func (m *Manager) slot(hostRef types.NamespacedName) *slot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s := m.slots[hostRef]
+	if s == nil {
+		s = &slot{}
+		m.slots[hostRef] = s
+	}
+
+	return s
+}
+
+// bastionSlot returns the slot for the given bastion and creates it if necessary.
+func (m *Manager) bastionSlot(bastionRef types.NamespacedName) *bastionSlot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s := m.bastions[bastionRef]
+	if s == nil {
+		s = &bastionSlot{}
+		m.bastions[bastionRef] = s
+	}
+
+	return s
+}
+
+// connect creates a new client for the host. If the host is behind a bastion,
+// its connection is tunneled through the shared connection to the bastion.
+func (m *Manager) connect(ctx context.Context, host *mgmtv1alpha1.Host, opts *common.Options) (common.Client, error) {
+	bastionRef := common.BastionReference(host)
+	if bastionRef == nil {
+		return connect(ctx, host, opts)
+	}
+
+	s, conn, err := m.acquireBastion(ctx, *bastionRef)
+	if err != nil {
+		return nil, err
+	}
+
+	tunneled := *opts
+	tunneled.Dialer = conn.bastion
+	mgmt, err := connect(ctx, host, &tunneled)
+	if err != nil {
+		s.release(conn)
+		return nil, err
+	}
+
+	return &tunneledClient{
+		Client:     mgmt,
+		slot:       s,
+		connection: conn,
+	}, nil
+}
+
+// acquireBastion returns the shared connection to the given bastion and
+// connects to it if necessary. The connection must be released once it is
+// no longer used.
+func (m *Manager) acquireBastion(ctx context.Context, bastionRef types.NamespacedName) (*bastionSlot, *bastionConnection, error) {
+	bastion := new(mgmtv1alpha1.SSHBastion)
+	if err := m.opts.KubernetesClient.Get(ctx, bastionRef, bastion); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return nil, nil, fmt.Errorf("failed to read SSHBastion: %s/%s", bastionRef.Namespace, bastionRef.Name)
+		}
+		return nil, nil, err
+	}
+
+	key, err := m.bastionKey(ctx, bastion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := m.bastionSlot(bastionRef)
+	if conn := s.acquire(key); conn != nil {
+		if err := conn.bastion.Ping(ctx); err == nil {
+			return s, conn, nil
+		}
+		s.discard(conn)
+	}
+
+	bastionConn, err := ssh.DialBastion(ctx, bastion, m.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if m.isClosed() {
+		bastionConn.Close()
+		return nil, nil, errManagerClosed
+	}
+
+	// Another caller may have established a connection in the meantime.
+	if s.current != nil && s.current.key == key {
+		bastionConn.Close()
+	} else {
+		s.retire()
+		s.current = &bastionConnection{
+			bastion: bastionConn,
+			key:     key,
+		}
+	}
+
+	conn := s.current
+	conn.users++
+	conn.lastUsed = time.Now()
+
+	return s, conn, nil
+}
+
+// connectionKey computes a key that changes whenever the host specification
+// or one of the secrets containing the credentials of the host changes.
+func (m *Manager) connectionKey(ctx context.Context, host *mgmtv1alpha1.Host) (string, error) {
+	key := fmt.Sprintf("%s/%d", host.UID, host.Generation)
+	for _, secretRef := range common.SecretReferences(host) {
+		secret := new(corev1.Secret)
+		if err := m.opts.KubernetesClient.Get(ctx, secretRef, secret); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		key += "/" + secret.ResourceVersion
+	}
+
+	// Connections are reestablished if the bastion changes.
+	if bastionRef := common.BastionReference(host); bastionRef != nil {
+		bastion := new(mgmtv1alpha1.SSHBastion)
+		if err := m.opts.KubernetesClient.Get(ctx, *bastionRef, bastion); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		bastionKey, err := m.bastionKey(ctx, bastion)
+		if err != nil {
+			return "", err
+		}
+		key += "/" + bastionKey
+	}
+
+	return key, nil
+}
+
+// bastionKey computes a key that changes whenever the bastion specification
+// or the secret containing the credentials of the bastion changes.
+func (m *Manager) bastionKey(ctx context.Context, bastion *mgmtv1alpha1.SSHBastion) (string, error) {
+	secret := new(corev1.Secret)
+	if err := m.opts.KubernetesClient.Get(ctx, common.BastionSecretReference(bastion), secret); client.IgnoreNotFound(err) != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%d/%s", bastion.UID, bastion.Generation, secret.ResourceVersion), nil
+}
+
+// closeIdle closes all unused connections that exceeded the idle timeout.
+func (m *Manager) closeIdle() {
+	m.mutex.Lock()
+	slots := make([]*slot, 0, len(m.slots))
+	for _, s := range m.slots {
+		slots = append(slots, s)
+	}
+	bastions := make([]*bastionSlot, 0, len(m.bastions))
+	for _, s := range m.bastions {
+		bastions = append(bastions, s)
+	}
+	m.mutex.Unlock()
+
+	for _, s := range slots {
+		s.Lock()
+		if s.current != nil && s.current.users == 0 && time.Since(s.current.lastUsed) > m.opts.IdleTimeout {
+			s.retire()
+		}
+		s.Unlock()
+	}
+
+	// Bastions are only closed after the tunneled connections, which keep them in use.
+	for _, s := range bastions {
+		s.Lock()
+		if s.current != nil && s.current.users == 0 && time.Since(s.current.lastUsed) > m.opts.IdleTimeout {
+			s.retire()
+		}
+		s.Unlock()
+	}
+}
+
+// isClosed checks if all pooled connections were closed, after which no
+// further connections may be added to the pool.
+func (m *Manager) isClosed() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.closed
+}
+
+// closeAll closes all pooled connections. Connections that are established
+// concurrently are rejected, as the pool is marked as closed beforehand.
+func (m *Manager) closeAll() {
+	m.mutex.Lock()
+	m.closed = true
+	slots := make([]*slot, 0, len(m.slots))
+	for _, s := range m.slots {
+		slots = append(slots, s)
+	}
+	bastions := make([]*bastionSlot, 0, len(m.bastions))
+	for _, s := range m.bastions {
+		bastions = append(bastions, s)
+	}
+	m.mutex.Unlock()
+
+	for _, s := range slots {
+		s.Lock()
+		s.retire()
+		s.Unlock()
+	}
+	for _, s := range bastions {
+		s.Lock()
+		s.retire()
+		s.Unlock()
+	}
+}
+
+// acquire marks the current connection as used if it matches the key and
+// returns it. Otherwise the current connection is retired and nil is returned.
+func (s *slot) acquire(key string) *connection {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.current == nil || s.current.key != key {
+		s.retire()
+		return nil
+	}
+
+	s.current.users++
+	s.current.lastUsed = time.Now()
+	return s.current
+}
+
+// discard releases a connection that failed the health check
+// and retires it, unless it was replaced in the meantime.
+func (s *slot) discard(conn *connection) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.current == conn {
+		s.retire()
+	}
+	s.release(conn)
+}
+
+// release marks the connection as unused. The connection is closed if it
+// was retired in the meantime. The caller must hold the lock of the slot.
+func (s *slot) release(conn *connection) error {
+	conn.users--
+	conn.lastUsed = time.Now()
+	if conn.retired && conn.users == 0 {
+		return conn.client.Disconnect()
+	}
+
+	return nil
+}
+
+// retire removes the current connection from the slot and closes it once
+// it is no longer used. The caller must hold the lock of the slot.
+func (s *slot) retire() {
+	if s.current == nil {
+		return
+	}
+
+	s.current.retired = true
+	if s.current.users == 0 {
+		s.current.client.Disconnect()
+	}
+	s.current = nil
+}
+
+// retire removes the current connection from the slot and closes it once
+// it is no longer used. The caller must hold the lock of the slot.
+func (s *bastionSlot) retire() {
+	if s.current == nil {
+		return
+	}
+
+	s.current.retired = true
+	if s.current.users == 0 {
+		s.current.bastion.Close()
+	}
+	s.current = nil
+}
+
+// acquire marks the current connection as used if it matches the key and
+// returns it. Otherwise the current connection is retired and nil is returned.
+func (s *bastionSlot) acquire(key string) *bastionConnection {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.current == nil || s.current.key != key {
+		s.retire()
+		return nil
+	}
+
+	s.current.users++
+	s.current.lastUsed = time.Now()
+	return s.current
+}
+
+// discard releases a connection that failed the health check
+// and retires it, unless it was replaced in the meantime.
+func (s *bastionSlot) discard(conn *bastionConnection) {
+	s.Lock()
+	if s.current == conn {
+		s.retire()
+	}
+	s.Unlock()
+
+	s.release(conn)
+}
+
+// release marks the connection as unused. The connection
+// is closed if it was retired in the meantime.
+func (s *bastionSlot) release(conn *bastionConnection) {
+	s.Lock()
+	defer s.Unlock()
+
+	conn.users--
+	conn.lastUsed = time.Now()
+	if conn.retired && conn.users == 0 {
+		conn.bastion.Close()
+	}
+}
+
+// pooledClient is a client that is returned to the pool on Disconnect().
+type pooledClient struct {
+	common.Client
+
+	once       sync.Once
+	slot       *slot
+	connection *connection
+}
+
+// newPooledClient wraps a connection that was acquired from the slot.
+func newPooledClient(s *slot, conn *connection) *pooledClient {
+	return &pooledClient{
+		Client:     conn.client,
+		slot:       s,
+		connection: conn,
+	}
+}
+
+// Disconnect returns the connection to the pool. The underlying
+// connection is only closed if it was retired in the meantime.
+func (c *pooledClient) Disconnect() error {
+	var err error
+	c.once.Do(func() {
+		c.slot.Lock()
+		defer c.slot.Unlock()
+
+		err = c.slot.release(c.connection)
+	})
+
+	return err
+}
+
+// HostKeys returns the fingerprints of the host keys that were presented
+// while connecting, if the underlying client reports them.
+func (c *pooledClient) HostKeys() common.HostKeys {
+	if reporter, ok := c.connection.client.(common.HostKeyReporter); ok {
+		return reporter.HostKeys()
+	}
+
+	return common.HostKeys{}
+}
+
+// Unwrap returns the underlying client, e.g. to use protocol-specific operations.
+func (c *pooledClient) Unwrap() common.Client {
+	return c.connection.client
+}
+
+// Interfaces returns the network interfaces of the host,
+// if the underlying client can discover them.
+func (c *pooledClient) Interfaces(ctx context.Context) ([]mgmtv1alpha1.HostInterface, error) {
+	if reporter, ok := c.connection.client.(common.InterfaceReporter); ok {
+		return reporter.Interfaces(ctx)
+	}
+
+	return nil, common.ErrNotSupported
+}
+
+// tunneledClient is a client whose connection is tunneled through a shared
+// connection to a bastion, which is released on Disconnect().
+type tunneledClient struct {
+	common.Client
+
+	once       sync.Once
+	slot       *bastionSlot
+	connection *bastionConnection
+}
+
+// Disconnect disconnects from the host and releases the connection to the bastion.
+func (c *tunneledClient) Disconnect() error {
+	err := c.Client.Disconnect()
+	c.once.Do(func() {
+		c.slot.release(c.connection)
+	})
+
+	return err
+}
+
+// HostKeys returns the fingerprints of the host keys that were presented
+// while connecting, if the underlying client reports them.
+func (c *tunneledClient) HostKeys() common.HostKeys {
+	if reporter, ok := c.Client.(common.HostKeyReporter); ok {
+		return reporter.HostKeys()
+	}
+
+	return common.HostKeys{}
+}
+
+// Unwrap returns the underlying client, e.g. to use protocol-specific operations.
+func (c *tunneledClient) Unwrap() common.Client {
+	return c.Client
+}
+
+// Interfaces returns the network interfaces of the host,
+// if the underlying client can discover them.
+func (c *tunneledClient) Interfaces(ctx context.Context) ([]mgmtv1alpha1.HostInterface, error) {
+	if reporter, ok := c.Client.(common.InterfaceReporter); ok {
+		return reporter.Interfaces(ctx)
+	}
+
+	return nil, common.ErrNotSupported
+}
@@ -0,0 +1,419 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// testProtocol is the protocol of hosts that are connected with a testDialer.
+const testProtocol mgmtv1alpha1.Protocol = "test"
+
+var testHostRef = types.NamespacedName{Namespace: "default", Name: "node-1"}
+
+// testDialer creates the clients of hosts with the test protocol.
+type testDialer struct {
+	mutex   sync.Mutex
+	clients []*testClient
+	// pingErr is returned by the health checks of all clients.
+	pingErr error
+}
+
+// dials returns the number of clients that were created.
+func (d *testDialer) dials() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return len(d.clients)
+}
+
+// open returns the number of clients that were not disconnected.
+func (d *testDialer) open() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	open := 0
+	for _, c := range d.clients {
+		if c.disconnects() == 0 {
+			open++
+		}
+	}
+	return open
+}
+
+// setPingErr changes the result of the health checks.
+func (d *testDialer) setPingErr(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.pingErr = err
+}
+
+// testClient is a client that only tracks whether it is connected.
+type testClient struct {
+	common.Client
+
+	dialer      *testDialer
+	mutex       sync.Mutex
+	disconnectN int
+}
+
+func (c *testClient) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (c *testClient) Disconnect() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.disconnectN++
+	return nil
+}
+
+func (c *testClient) Ping(ctx context.Context) error {
+	c.dialer.mutex.Lock()
+	defer c.dialer.mutex.Unlock()
+
+	return c.dialer.pingErr
+}
+
+// disconnects returns how often the client was disconnected.
+func (c *testClient) disconnects() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.disconnectN
+}
+
+// newTestManager creates a manager that connects to hosts with a testDialer.
+// It knows the test host and its Secret.
+func newTestManager(t *testing.T, options ...common.Option) (*Manager, *testDialer, client.Client) {
+	t.Helper()
+
+	dialer := &testDialer{}
+	clientFactories[testProtocol] = func(ctx context.Context, host *mgmtv1alpha1.Host, options ...common.Option) (common.Client, error) {
+		dialer.mutex.Lock()
+		defer dialer.mutex.Unlock()
+
+		c := &testClient{dialer: dialer}
+		dialer.clients = append(dialer.clients, c)
+		return c, nil
+	}
+	t.Cleanup(func() { delete(clientFactories, testProtocol) })
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgmtv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	host := &mgmtv1alpha1.Host{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testHostRef.Namespace, Name: testHostRef.Name, Generation: 1},
+		Spec: mgmtv1alpha1.HostSpec{
+			Host:      "192.0.2.1",
+			Protocol:  testProtocol,
+			SecretRef: corev1.SecretReference{Name: "node-1"},
+			SSH: mgmtv1alpha1.HostSpecSSHOptions{
+				JumpHosts: []mgmtv1alpha1.HostSpecSSHJumpHost{{Host: "192.0.2.2", SecretRef: &corev1.SecretReference{Name: "jump"}}},
+			},
+		},
+	}
+	kube := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(host).WithObjects(
+		host,
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "node-1"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "jump"}},
+	).Build()
+
+	m, err := NewManager(append([]common.Option{common.WithKubernetesClient(kube)}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, dialer, kube
+}
+
+// getTestHost gets a client for the test host from the manager.
+func getTestHost(t *testing.T, m *Manager) common.Client {
+	t.Helper()
+
+	mgmt, err := m.Get(context.Background(), testHostRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mgmt
+}
+
+func TestManagerReusesConnection(t *testing.T) {
+	m, dialer, _ := newTestManager(t)
+
+	first := getTestHost(t, m)
+	// A connection in use is shared.
+	second := getTestHost(t, m)
+	if first.(*pooledClient).Unwrap() != second.(*pooledClient).Unwrap() {
+		t.Error("expected concurrent callers to share the connection")
+	}
+	first.Disconnect()
+	// A repeated Disconnect() must not release the connection twice.
+	first.Disconnect()
+	second.Disconnect()
+
+	third := getTestHost(t, m)
+	defer third.Disconnect()
+
+	if dialer.dials() != 1 {
+		t.Errorf("dials = %d, want 1", dialer.dials())
+	}
+	if dialer.open() != 1 {
+		t.Errorf("open connections = %d, want 1", dialer.open())
+	}
+}
+
+func TestManagerInvalidatesChangedConnection(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     func(ctx context.Context, kube client.Client) error
+		wantReused bool
+	}{
+		{
+			name: "host spec",
+			change: func(ctx context.Context, kube client.Client) error {
+				host := new(mgmtv1alpha1.Host)
+				if err := kube.Get(ctx, testHostRef, host); err != nil {
+					return err
+				}
+				host.Spec.Port = 2222
+				host.Generation++
+				return kube.Update(ctx, host)
+			},
+		},
+		{
+			name: "host status",
+			change: func(ctx context.Context, kube client.Client) error {
+				host := new(mgmtv1alpha1.Host)
+				if err := kube.Get(ctx, testHostRef, host); err != nil {
+					return err
+				}
+				host.Status.SSH.Fingerprint = "SHA256:test"
+				return kube.Status().Update(ctx, host)
+			},
+			wantReused: true,
+		},
+		{
+			name: "host labels",
+			change: func(ctx context.Context, kube client.Client) error {
+				host := new(mgmtv1alpha1.Host)
+				if err := kube.Get(ctx, testHostRef, host); err != nil {
+					return err
+				}
+				host.Labels = map[string]string{"rack": "a1"}
+				return kube.Update(ctx, host)
+			},
+			wantReused: true,
+		},
+		{
+			name:   "host Secret",
+			change: updateSecret("node-1"),
+		},
+		{
+			name:   "jump host Secret",
+			change: updateSecret("jump"),
+		},
+		{
+			name: "deleted Secret",
+			change: func(ctx context.Context, kube client.Client) error {
+				return kube.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "jump"}})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, dialer, kube := newTestManager(t)
+
+			// The connection in use is only closed once it is released.
+			first := getTestHost(t, m)
+			if err := test.change(context.Background(), kube); err != nil {
+				t.Fatal(err)
+			}
+			second := getTestHost(t, m)
+			defer second.Disconnect()
+
+			wantDials := 2
+			if test.wantReused {
+				wantDials = 1
+			}
+			if dialer.dials() != wantDials {
+				t.Fatalf("dials = %d, want %d", dialer.dials(), wantDials)
+			}
+			if dialer.clients[0].disconnects() != 0 {
+				t.Fatal("expected connection in use to be kept open")
+			}
+
+			first.Disconnect()
+			wantDisconnects := 1
+			if test.wantReused {
+				wantDisconnects = 0
+			}
+			if got := dialer.clients[0].disconnects(); got != wantDisconnects {
+				t.Errorf("disconnects = %d, want %d", got, wantDisconnects)
+			}
+		})
+	}
+}
+
+// updateSecret returns a change that updates the data of a Secret.
+func updateSecret(name string) func(ctx context.Context, kube client.Client) error {
+	return func(ctx context.Context, kube client.Client) error {
+		secret := new(corev1.Secret)
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, secret); err != nil {
+			return err
+		}
+		secret.Data = map[string][]byte{"passwordInsecure": []byte("rotated")}
+		return kube.Update(ctx, secret)
+	}
+}
+
+func TestManagerInvalidate(t *testing.T) {
+	m, dialer, _ := newTestManager(t)
+
+	mgmt := getTestHost(t, m)
+	m.Invalidate(testHostRef)
+	if dialer.clients[0].disconnects() != 0 {
+		t.Fatal("expected connection in use to be kept open")
+	}
+	mgmt.Disconnect()
+	if dialer.clients[0].disconnects() != 1 {
+		t.Fatal("expected invalidated connection to be closed once it is released")
+	}
+
+	getTestHost(t, m).Disconnect()
+	if dialer.dials() != 2 {
+		t.Errorf("dials = %d, want 2", dialer.dials())
+	}
+}
+
+func TestManagerClosesIdleConnections(t *testing.T) {
+	m, dialer, _ := newTestManager(t, common.WithIdleTimeout(10*time.Millisecond))
+
+	idle := getTestHost(t, m)
+	idle.Disconnect()
+
+	// Connections in use are never closed.
+	inUse, err := m.Get(context.Background(), testHostRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	m.closeIdle()
+	if dialer.clients[0].disconnects() != 0 {
+		t.Fatal("expected connection in use to be kept open")
+	}
+
+	inUse.Disconnect()
+	m.closeIdle()
+	if dialer.clients[0].disconnects() != 0 {
+		t.Fatal("expected recently used connection to be kept open")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	m.closeIdle()
+	if dialer.clients[0].disconnects() != 1 {
+		t.Fatal("expected idle connection to be closed")
+	}
+
+	getTestHost(t, m).Disconnect()
+	if dialer.dials() != 2 {
+		t.Errorf("dials = %d, want 2", dialer.dials())
+	}
+}
+
+func TestManagerChecksHealth(t *testing.T) {
+	m, dialer, _ := newTestManager(t)
+
+	getTestHost(t, m).Disconnect()
+
+	// The health check is skipped for new connections, but the
+	// pooled connection that fails it is closed and replaced.
+	dialer.setPingErr(errors.New("connection reset by peer"))
+	mgmt := getTestHost(t, m)
+	defer mgmt.Disconnect()
+
+	if dialer.dials() != 2 {
+		t.Fatalf("dials = %d, want 2", dialer.dials())
+	}
+	if dialer.clients[0].disconnects() != 1 {
+		t.Error("expected unhealthy connection to be closed")
+	}
+	if mgmt.(*pooledClient).Unwrap() != dialer.clients[1] {
+		t.Error("expected new connection to be returned")
+	}
+}
+
+func TestManagerConcurrentGet(t *testing.T) {
+	m, dialer, _ := newTestManager(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Invalidations race with the callers that use the connection.
+			if i%10 == 0 {
+				m.Invalidate(testHostRef)
+			}
+
+			mgmt, err := m.Get(context.Background(), testHostRef)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := mgmt.Ping(context.Background()); err != nil {
+				errs <- err
+			}
+			mgmt.Disconnect()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// All connections but the pooled one are closed exactly once.
+	if dialer.open() != 1 {
+		t.Errorf("open connections = %d, want 1", dialer.open())
+	}
+	for i, c := range dialer.clients {
+		if c.disconnects() > 1 {
+			t.Errorf("connection %d was closed %d times", i, c.disconnects())
+		}
+	}
+
+	m.closeAll()
+	if dialer.open() != 0 {
+		t.Errorf("open connections after closing the manager = %d, want 0", dialer.open())
+	}
+	if _, err := m.Get(context.Background(), testHostRef); !errors.Is(err, errManagerClosed) {
+		t.Errorf("Get() error = %v, want %v", err, errManagerClosed)
+	}
+	if dialer.open() != 0 {
+		t.Errorf("open connections after Get() = %d, want 0", dialer.open())
+	}
+}
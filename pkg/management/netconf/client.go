@@ -0,0 +1,390 @@
+package netconf
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+	"github.com/nicklasfrahm/kraut/pkg/management/ssh"
+)
+
+const (
+	// defaultPort is the port of the NETCONF SSH subsystem as defined in RFC 6242.
+	defaultPort = 830
+	// subsystem is the name of the NETCONF SSH subsystem.
+	subsystem = "netconf"
+	// namespace is the XML namespace of the NETCONF base protocol.
+	namespace = "urn:ietf:params:xml:ns:netconf:base:1.0"
+)
+
+// The capabilities of the NETCONF protocol that are used by the client.
+const (
+	CapabilityBase10          = "urn:ietf:params:netconf:base:1.0"
+	CapabilityBase11          = "urn:ietf:params:netconf:base:1.1"
+	CapabilityCandidate       = "urn:ietf:params:netconf:capability:candidate:1.0"
+	CapabilityConfirmedCommit = "urn:ietf:params:netconf:capability:confirmed-commit:1.0"
+	CapabilityConfirmed11     = "urn:ietf:params:netconf:capability:confirmed-commit:1.1"
+	CapabilityStartup         = "urn:ietf:params:netconf:capability:startup:1.0"
+	CapabilityWritableRunning = "urn:ietf:params:netconf:capability:writable-running:1.0"
+	CapabilityValidate        = "urn:ietf:params:netconf:capability:validate:1.0"
+)
+
+// moduleSystem is the namespace of the YANG module ietf-system as defined in RFC 7317.
+const moduleSystem = "urn:ietf:params:xml:ns:yang:ietf-system"
+
+// sshClient is an SSH client that can start the NETCONF subsystem.
+type sshClient interface {
+	common.Client
+	common.HostKeyReporter
+	// Subsystem starts a subsystem in a new session.
+	Subsystem(name string) (*gossh.Session, io.WriteCloser, io.Reader, error)
+}
+
+// Client manages a network device using NETCONF over SSH as defined in
+// RFC 6241 and RFC 6242. The SSH connection is established with the SSH
+// options of the host, so commands and file transfers use SSH.
+type Client struct {
+	sshClient
+
+	host *mgmtv1alpha1.Host
+	opts *common.Options
+
+	// mutex serializes the RPCs, as a session processes them in order.
+	mutex        sync.Mutex
+	session      *gossh.Session
+	stdin        io.WriteCloser
+	transport    *transport
+	sessionID    string
+	capabilities []string
+	messageID    uint64
+	broken       error
+}
+
+// NewClient creates a new client for a host.
+func NewClient(ctx context.Context, host *mgmtv1alpha1.Host, options ...common.Option) (common.Client, error) {
+	opts, err := common.GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The SSH client connects to the port of the NETCONF subsystem.
+	if host.Spec.Port == 0 {
+		host = host.DeepCopy()
+		host.Spec.Port = defaultPort
+	}
+
+	mgmt, err := ssh.NewClient(ctx, host, common.WithOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	transport, ok := mgmt.(sshClient)
+	if !ok {
+		return nil, errors.New("SSH client does not support subsystems")
+	}
+
+	return &Client{
+		sshClient: transport,
+		host:      host,
+		opts:      opts,
+	}, nil
+}
+
+// FromClient returns the NETCONF client of a management client, which
+// may be wrapped, e.g. by the connection pool of the Manager.
+func FromClient(mgmt common.Client) (*Client, error) {
+	for {
+		switch client := mgmt.(type) {
+		case *Client:
+			return client, nil
+		case interface{ Unwrap() common.Client }:
+			mgmt = client.Unwrap()
+		default:
+			return nil, fmt.Errorf("%w: host is not managed via NETCONF", common.ErrNotSupported)
+		}
+	}
+}
+
+// Connect connects to the host, starts the NETCONF subsystem and exchanges
+// the capabilities. Both NETCONF 1.0 and NETCONF 1.1 are supported.
+func (c *Client) Connect(ctx context.Context) error {
+	if err := c.sshClient.Connect(ctx); err != nil {
+		return err
+	}
+
+	session, stdin, stdout, err := c.Subsystem(subsystem)
+	if err != nil {
+		c.sshClient.Disconnect()
+		return err
+	}
+	c.session = session
+	c.stdin = stdin
+	c.transport = &transport{w: stdin, r: bufio.NewReader(stdout)}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.HandshakeTimeout)
+	defer cancel()
+
+	if err := c.hello(ctx); err != nil {
+		c.Disconnect()
+		return fmt.Errorf("failed to exchange NETCONF capabilities: %w", err)
+	}
+
+	return nil
+}
+
+// hello exchanges the capabilities with the server.
+func (c *Client) hello(ctx context.Context) error {
+	var server struct {
+		XMLName      xml.Name `xml:"hello"`
+		Capabilities []string `xml:"capabilities>capability"`
+		SessionID    string   `xml:"session-id"`
+	}
+
+	err := c.withContext(ctx, func() error {
+		message := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><hello xmlns="%s"><capabilities><capability>%s</capability><capability>%s</capability></capabilities></hello>`,
+			namespace, CapabilityBase10, CapabilityBase11)
+		if err := c.transport.writeMessage([]byte(message)); err != nil {
+			return err
+		}
+
+		raw, err := c.transport.readMessage()
+		if err != nil {
+			return err
+		}
+
+		return xml.Unmarshal(raw, &server)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, capability := range server.Capabilities {
+		c.capabilities = append(c.capabilities, strings.TrimSpace(capability))
+	}
+	c.sessionID = server.SessionID
+
+	switch {
+	case c.HasCapability(CapabilityBase11):
+		c.transport.chunked = true
+	case !c.HasCapability(CapabilityBase10):
+		return errors.New("server does not support a common NETCONF version")
+	}
+
+	return nil
+}
+
+// SessionID returns the ID of the NETCONF session that was assigned by the server.
+func (c *Client) SessionID() string {
+	return c.sessionID
+}
+
+// ServerCapabilities returns the capabilities that were announced by the server.
+func (c *Client) ServerCapabilities() []string {
+	return slices.Clone(c.capabilities)
+}
+
+// HasCapability returns whether the server announced the capability. The
+// parameters of a capability, such as `?module=ietf-system`, are ignored.
+func (c *Client) HasCapability(capability string) bool {
+	for _, announced := range c.capabilities {
+		uri, _, _ := strings.Cut(announced, "?")
+		if uri == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Disconnect closes the NETCONF session and disconnects from the host.
+func (c *Client) Disconnect() error {
+	if c.session != nil {
+		c.mutex.Lock()
+		if c.broken == nil {
+			// The reply is not awaited, as the session is closed anyway.
+			c.messageID++
+			c.transport.writeMessage([]byte(fmt.Sprintf(`<rpc message-id="%d" xmlns="%s"><close-session/></rpc>`, c.messageID, namespace)))
+			c.broken = errors.New("NETCONF session closed")
+		}
+		c.stdin.Close()
+		c.session.Close()
+		c.mutex.Unlock()
+	}
+
+	return c.sshClient.Disconnect()
+}
+
+// Ping checks if the SSH connection is still healthy and the NETCONF session is usable.
+func (c *Client) Ping(ctx context.Context) error {
+	c.mutex.Lock()
+	broken := c.broken
+	c.mutex.Unlock()
+	if broken != nil {
+		return broken
+	}
+
+	return c.sshClient.Ping(ctx)
+}
+
+// OS probes information about the operating system of the host via the YANG
+// module ietf-system, if the server supports it, or via the SSH connection.
+func (c *Client) OS(ctx context.Context) (*mgmtv1alpha1.OSInfo, error) {
+	if !c.HasCapability(moduleSystem) {
+		return c.sshClient.OS(ctx)
+	}
+
+	data, err := c.Get(ctx, fmt.Sprintf(`<system-state xmlns="%s"><platform/></system-state>`, moduleSystem))
+	if err != nil {
+		return nil, err
+	}
+
+	var state struct {
+		OSName    string `xml:"system-state>platform>os-name"`
+		OSRelease string `xml:"system-state>platform>os-release"`
+		OSVersion string `xml:"system-state>platform>os-version"`
+	}
+	if err := xml.Unmarshal([]byte("<data>"+data+"</data>"), &state); err != nil {
+		return nil, fmt.Errorf("failed to parse system state: %w", err)
+	}
+
+	info := &mgmtv1alpha1.OSInfo{
+		Name:    state.OSName,
+		ID:      strings.ToLower(state.OSName),
+		Family:  mgmtv1alpha1.OSFamilyUnknown,
+		Version: mgmtv1alpha1.OSVersion(state.OSRelease),
+	}
+	if strings.Contains(state.OSName, "NX-OS") {
+		info.ID = "nexus"
+		info.Name = mgmtv1alpha1.OSNXOS
+		info.Family = mgmtv1alpha1.OSFamilyNXOS
+	}
+	if info.Name == "" {
+		info.Name = "Unknown"
+	}
+
+	return info, nil
+}
+
+// Call sends an RPC with the operation, which is an XML element such as
+// `<get-config>...</get-config>`, and returns the content of the reply. An
+// `rpc-error` with the severity `error` is returned as an *RPCError.
+func (c *Client) Call(ctx context.Context, operation string) (*Reply, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.transport == nil {
+		return nil, fmt.Errorf("not connected: %s", c.host.Spec.Host)
+	}
+	if c.broken != nil {
+		return nil, c.broken
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	c.messageID++
+	messageID := strconv.FormatUint(c.messageID, 10)
+	request := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><rpc message-id="%s" xmlns="%s">%s</rpc>`, messageID, namespace, operation)
+
+	reply := new(Reply)
+	err := c.withContext(ctx, func() error {
+		if err := c.transport.writeMessage([]byte(request)); err != nil {
+			return err
+		}
+
+		raw, err := c.transport.readMessage()
+		if err != nil {
+			return err
+		}
+
+		return xml.Unmarshal(raw, reply)
+	})
+	if err != nil {
+		// The replies are no longer in sync with the requests.
+		c.broken = fmt.Errorf("NETCONF session failed: %w", err)
+		return nil, err
+	}
+	if reply.MessageID != messageID {
+		c.broken = fmt.Errorf("NETCONF session failed: unexpected message ID %q in reply to %q", reply.MessageID, messageID)
+		return nil, c.broken
+	}
+
+	for i := range reply.Errors {
+		if reply.Errors[i].Severity != "warning" {
+			return reply, &reply.Errors[i]
+		}
+	}
+
+	return reply, nil
+}
+
+// withContext runs the function and closes the session if the context
+// expires, which unblocks pending reads and writes.
+func (c *Client) withContext(ctx context.Context, fn func() error) error {
+	stop := context.AfterFunc(ctx, func() {
+		c.session.Close()
+	})
+	defer stop()
+
+	err := fn()
+	if ctx.Err() != nil {
+		return fmt.Errorf("NETCONF request failed: %w", ctx.Err())
+	}
+
+	return err
+}
+
+// Reply is the reply to an RPC.
+type Reply struct {
+	XMLName   xml.Name `xml:"rpc-reply"`
+	MessageID string   `xml:"message-id,attr"`
+	// OK is set if the operation succeeded without returning data.
+	OK *struct{} `xml:"ok"`
+	// Data contains the XML content of the `data` element.
+	Data struct {
+		Content string `xml:",innerxml"`
+	} `xml:"data"`
+	// Errors contains the errors and warnings of the operation.
+	Errors []RPCError `xml:"rpc-error"`
+}
+
+// RPCError is an error that was returned by the server as defined in RFC 6241.
+type RPCError struct {
+	// Type is the layer of the error, e.g. `protocol` or `application`.
+	Type string `xml:"error-type"`
+	// Tag identifies the error, e.g. `lock-denied` or `invalid-value`.
+	Tag string `xml:"error-tag"`
+	// Severity is either `error` or `warning`.
+	Severity string `xml:"error-severity"`
+	// Path is the XPath of the element that caused the error.
+	Path string `xml:"error-path"`
+	// Message describes the error.
+	Message string `xml:"error-message"`
+	// Info contains protocol or data model specific information.
+	Info struct {
+		Content string `xml:",innerxml"`
+	} `xml:"error-info"`
+}
+
+// Error implements the error interface.
+func (e *RPCError) Error() string {
+	message := fmt.Sprintf("NETCONF %s error: %s", e.Type, e.Tag)
+	if e.Path != "" {
+		message += fmt.Sprintf(": %s", strings.TrimSpace(e.Path))
+	}
+	if e.Message != "" {
+		message += fmt.Sprintf(": %s", strings.TrimSpace(e.Message))
+	}
+
+	return message
+}
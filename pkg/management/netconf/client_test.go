@@ -0,0 +1,393 @@
+package netconf
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// testServer is a NETCONF server, which records the operations of the
+// RPCs and answers them with the reply of the handler or with `<ok/>`.
+type testServer struct {
+	// capabilities are announced in the hello message of the server.
+	capabilities []string
+	// handle returns the content of the reply to an operation.
+	handle func(operation string) string
+	// messageID overrides the message ID of the replies.
+	messageID string
+
+	mutex      sync.Mutex
+	operations []string
+}
+
+// Operations returns the operations that were received.
+func (s *testServer) Operations() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string(nil), s.operations...)
+}
+
+// serve runs a NETCONF session on the channel of the subsystem.
+func (s *testServer) serve(channel io.ReadWriteCloser) {
+	defer channel.Close()
+
+	tr := &transport{w: channel, r: bufio.NewReader(channel)}
+
+	var hello strings.Builder
+	fmt.Fprintf(&hello, `<hello xmlns="%s"><capabilities>`, namespace)
+	for _, capability := range s.capabilities {
+		fmt.Fprintf(&hello, "<capability>%s</capability>", escape(capability))
+	}
+	hello.WriteString("</capabilities><session-id>4</session-id></hello>")
+	if err := tr.writeMessage([]byte(hello.String())); err != nil {
+		return
+	}
+
+	raw, err := tr.readMessage()
+	if err != nil {
+		return
+	}
+	var client struct {
+		Capabilities []string `xml:"capabilities>capability"`
+	}
+	if err := xml.Unmarshal(raw, &client); err != nil {
+		return
+	}
+	for _, capability := range client.Capabilities {
+		for _, announced := range s.capabilities {
+			if capability == CapabilityBase11 && announced == CapabilityBase11 {
+				tr.chunked = true
+			}
+		}
+	}
+
+	for {
+		raw, err := tr.readMessage()
+		if err != nil {
+			return
+		}
+		var rpc struct {
+			MessageID string `xml:"message-id,attr"`
+			Operation string `xml:",innerxml"`
+		}
+		if err := xml.Unmarshal(raw, &rpc); err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		s.operations = append(s.operations, rpc.Operation)
+		s.mutex.Unlock()
+
+		content := "<ok/>"
+		if s.handle != nil && rpc.Operation != "<close-session/>" {
+			content = s.handle(rpc.Operation)
+		}
+
+		messageID := rpc.MessageID
+		if s.messageID != "" {
+			messageID = s.messageID
+		}
+		reply := fmt.Sprintf(`<rpc-reply message-id="%s" xmlns="%s">%s</rpc-reply>`, messageID, namespace, content)
+		if err := tr.writeMessage([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// listen starts an SSH server, which serves the NETCONF subsystem, and returns its address.
+func (s *testServer) listen(t *testing.T) string {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &gossh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleConn(conn, config)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// handleConn serves the sessions of an SSH connection.
+func (s *testServer) handleConn(conn net.Conn, config *gossh.ServerConfig) {
+	_, channels, requests, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(gossh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for request := range requests {
+				var payload struct{ Name string }
+				if request.Type != "subsystem" || gossh.Unmarshal(request.Payload, &payload) != nil || payload.Name != subsystem {
+					request.Reply(false, nil)
+					continue
+				}
+				request.Reply(true, nil)
+				go s.serve(channel)
+			}
+		}()
+	}
+}
+
+// pipeClient is an SSH client that only supports subsystems.
+type pipeClient struct {
+	common.Client
+	common.HostKeyReporter
+
+	address string
+	ssh     *gossh.Client
+}
+
+// Connect connects to the SSH server.
+func (p *pipeClient) Connect(ctx context.Context) error {
+	var err error
+	p.ssh, err = gossh.Dial("tcp", p.address, &gossh.ClientConfig{
+		User:            "kraut",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+
+	return err
+}
+
+// Disconnect disconnects from the SSH server.
+func (p *pipeClient) Disconnect() error {
+	return p.ssh.Close()
+}
+
+// Ping checks nothing, as the connection is local.
+func (p *pipeClient) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Subsystem starts a subsystem in a new session.
+func (p *pipeClient) Subsystem(name string) (*gossh.Session, io.WriteCloser, io.Reader, error) {
+	session, err := p.ssh.NewSession()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := session.RequestSubsystem(name); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return session, stdin, stdout, nil
+}
+
+// newTestClient creates a client for the server, which is started.
+func newTestClient(t *testing.T, server *testServer) *Client {
+	t.Helper()
+
+	opts, err := common.GetDefaultOptions().Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Client{
+		sshClient: &pipeClient{address: server.listen(t)},
+		host:      &mgmtv1alpha1.Host{Spec: mgmtv1alpha1.HostSpec{Host: "127.0.0.1"}},
+		opts:      opts,
+	}
+}
+
+// connectTestClient connects a client to the server.
+func connectTestClient(t *testing.T, server *testServer) *Client {
+	t.Helper()
+
+	client := newTestClient(t, server)
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+
+	return client
+}
+
+func TestClientHello(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities []string
+		wantChunked  bool
+		wantErr      bool
+	}{
+		{name: "NETCONF 1.0", capabilities: []string{CapabilityBase10}},
+		{name: "NETCONF 1.1", capabilities: []string{CapabilityBase10, CapabilityBase11}, wantChunked: true},
+		{name: "no common version", capabilities: []string{"urn:ietf:params:netconf:base:2.0"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &testServer{capabilities: append(test.capabilities, CapabilityCandidate+"?module=ietf-netconf")}
+			client := newTestClient(t, server)
+
+			err := client.Connect(context.Background())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Connect() error = %v, want error %t", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer client.Disconnect()
+
+			if client.transport.chunked != test.wantChunked {
+				t.Errorf("chunked framing = %t, want %t", client.transport.chunked, test.wantChunked)
+			}
+			if client.SessionID() != "4" {
+				t.Errorf("SessionID() = %q, want %q", client.SessionID(), "4")
+			}
+			if !client.HasCapability(CapabilityCandidate) {
+				t.Errorf("expected capability with parameters to be announced: %v", client.ServerCapabilities())
+			}
+
+			// The session must be usable with the negotiated framing.
+			if _, err := client.Get(context.Background(), ""); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestClientRPCError(t *testing.T) {
+	server := &testServer{
+		capabilities: []string{CapabilityBase11},
+		handle: func(operation string) string {
+			if strings.HasPrefix(operation, "<lock>") {
+				return "<rpc-error><error-type>protocol</error-type><error-tag>lock-denied</error-tag>" +
+					"<error-severity>error</error-severity><error-message>Lock failed, lock is already held</error-message>" +
+					"<error-info><session-id>7</session-id></error-info></rpc-error>"
+			}
+			return "<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag>" +
+				"<error-severity>warning</error-severity></rpc-error><ok/>"
+		},
+	}
+	client := connectTestClient(t, server)
+
+	err := client.Lock(context.Background(), Running)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("Lock() error = %v, want an RPC error", err)
+	}
+	if rpcErr.Tag != "lock-denied" || rpcErr.Info.Content != "<session-id>7</session-id>" {
+		t.Errorf("RPC error = %+v", rpcErr)
+	}
+	if want := "NETCONF protocol error: lock-denied: Lock failed, lock is already held"; rpcErr.Error() != want {
+		t.Errorf("Error() = %q, want %q", rpcErr.Error(), want)
+	}
+
+	// Warnings do not fail the operation and the session stays usable.
+	if err := client.Unlock(context.Background(), Running); err != nil {
+		t.Errorf("Unlock() error = %v", err)
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+}
+
+func TestClientUnexpectedMessageID(t *testing.T) {
+	server := &testServer{capabilities: []string{CapabilityBase11}, messageID: "999"}
+	client := connectTestClient(t, server)
+
+	if _, err := client.Get(context.Background(), ""); err == nil {
+		t.Fatal("expected reply with unexpected message ID to be rejected")
+	}
+
+	// The replies are out of sync, so the session must not be used again.
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("expected broken session to fail the ping")
+	}
+	if _, err := client.Get(context.Background(), ""); err == nil {
+		t.Error("expected broken session to refuse RPCs")
+	}
+	if operations := server.Operations(); len(operations) != 1 {
+		t.Errorf("operations = %v, want a single RPC", operations)
+	}
+}
+
+func TestClientDisconnectClosesSession(t *testing.T) {
+	server := &testServer{capabilities: []string{CapabilityBase10}}
+	client := connectTestClient(t, server)
+
+	if err := client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(context.Background(), ""); err == nil {
+		t.Error("expected closed session to refuse RPCs")
+	}
+}
+
+func TestClientOS(t *testing.T) {
+	server := &testServer{
+		capabilities: []string{CapabilityBase11, moduleSystem + "?module=ietf-system&revision=2014-08-06"},
+		handle: func(operation string) string {
+			return `<data><system-state xmlns="urn:ietf:params:xml:ns:yang:ietf-system"><platform>` +
+				`<os-name>Cisco NX-OS</os-name><os-release>9.3(10)</os-release><machine>x86_64</machine>` +
+				`</platform></system-state></data>`
+		},
+	}
+	client := connectTestClient(t, server)
+
+	info, err := client.OS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != mgmtv1alpha1.OSNXOS || info.Family != mgmtv1alpha1.OSFamilyNXOS || info.Version != "9.3(10)" {
+		t.Errorf("OS() = %+v", info)
+	}
+
+	want := `<get><filter type="subtree"><system-state xmlns="urn:ietf:params:xml:ns:yang:ietf-system"><platform/></system-state></filter></get>`
+	if operations := server.Operations(); len(operations) != 1 || operations[0] != want {
+		t.Errorf("operations = %v, want %v", operations, []string{want})
+	}
+}
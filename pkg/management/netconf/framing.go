@@ -0,0 +1,109 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// endOfMessage delimits the messages of NETCONF 1.0 as defined in RFC 6242.
+const endOfMessage = "]]>]]>"
+
+// maxChunkSize is the maximum size of a chunk as defined in RFC 6242.
+const maxChunkSize = 4294967295
+
+// errFraming is returned if a message violates the framing.
+var errFraming = errors.New("invalid NETCONF framing")
+
+// transport frames the messages of a NETCONF session. The end-of-message
+// framing of NETCONF 1.0 is used until both peers announced NETCONF 1.1,
+// which uses the chunked framing.
+type transport struct {
+	w       io.Writer
+	r       *bufio.Reader
+	chunked bool
+}
+
+// writeMessage writes a framed message.
+func (t *transport) writeMessage(message []byte) error {
+	var buf bytes.Buffer
+	if t.chunked {
+		fmt.Fprintf(&buf, "\n#%d\n", len(message))
+		buf.Write(message)
+		buf.WriteString("\n##\n")
+	} else {
+		buf.Write(message)
+		buf.WriteString(endOfMessage)
+	}
+
+	_, err := t.w.Write(buf.Bytes())
+
+	return err
+}
+
+// readMessage reads the next framed message.
+func (t *transport) readMessage() ([]byte, error) {
+	if t.chunked {
+		return t.readChunkedMessage()
+	}
+
+	var message []byte
+	for {
+		line, err := t.r.ReadSlice('>')
+		message = append(message, line...)
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		if bytes.HasSuffix(message, []byte(endOfMessage)) {
+			return message[:len(message)-len(endOfMessage)], nil
+		}
+	}
+}
+
+// readChunkedMessage reads a message that consists of one or more chunks.
+func (t *transport) readChunkedMessage() ([]byte, error) {
+	var message []byte
+	for {
+		if err := t.expect("\n#"); err != nil {
+			return nil, err
+		}
+
+		header, err := t.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		header = header[:len(header)-1]
+		if header == "#" {
+			return message, nil
+		}
+
+		size, err := strconv.ParseUint(header, 10, 32)
+		if err != nil || size == 0 || size > maxChunkSize {
+			return nil, fmt.Errorf("%w: invalid chunk size: %q", errFraming, header)
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(t.r, chunk); err != nil {
+			return nil, err
+		}
+		message = append(message, chunk...)
+	}
+}
+
+// expect consumes the given bytes or fails.
+func (t *transport) expect(s string) error {
+	for i := 0; i < len(s); i++ {
+		c, err := t.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c != s[i] {
+			return fmt.Errorf("%w: expected %q", errFraming, s)
+		}
+	}
+
+	return nil
+}
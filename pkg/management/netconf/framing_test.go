@@ -0,0 +1,191 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunked bool
+		want    string
+	}{
+		{name: "end-of-message", want: "<rpc/>]]>]]>"},
+		{name: "chunked", chunked: true, want: "\n#6\n<rpc/>\n##\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tr := &transport{w: &buf, chunked: test.chunked}
+			if err := tr.writeMessage([]byte("<rpc/>")); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("writeMessage() wrote %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadMessageEndOfMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []string
+	}{
+		{
+			name:   "single message",
+			stream: "<hello/>]]>]]>",
+			want:   []string{"<hello/>"},
+		},
+		{
+			name:   "consecutive messages",
+			stream: "<rpc-reply><ok/></rpc-reply>]]>]]><rpc-reply><data/></rpc-reply>]]>]]>",
+			want:   []string{"<rpc-reply><ok/></rpc-reply>", "<rpc-reply><data/></rpc-reply>"},
+		},
+		{
+			// A partial delimiter is part of the message.
+			name:   "partial delimiter",
+			stream: "<data>]]>]]</data>]]>]]>",
+			want:   []string{"<data>]]>]]</data>"},
+		},
+		{
+			name:   "empty message",
+			stream: "]]>]]>",
+			want:   []string{""},
+		},
+		{
+			// Messages without a `>` exceed the buffer of the reader.
+			name:   "exceeds buffer",
+			stream: "<data>" + strings.Repeat("a", 100) + "</data>]]>]]>",
+			want:   []string{"<data>" + strings.Repeat("a", 100) + "</data>"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr := &transport{r: bufio.NewReaderSize(strings.NewReader(test.stream), 16)}
+			for _, want := range test.want {
+				message, err := tr.readMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(message) != want {
+					t.Errorf("readMessage() = %q, want %q", message, want)
+				}
+			}
+			if _, err := tr.readMessage(); !errors.Is(err, io.EOF) {
+				t.Errorf("readMessage() at the end of the stream error = %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestReadMessageChunked(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []string
+	}{
+		{
+			name:   "single chunk",
+			stream: "\n#6\n<rpc/>\n##\n",
+			want:   []string{"<rpc/>"},
+		},
+		{
+			// The example of section 4.2 of RFC 6242.
+			name:   "multiple chunks",
+			stream: "\n#4\n<rpc\n#18\n message-id=\"102\"\n\n#79\n     xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\">\n  <close-session/>\n</rpc>\n##\n",
+			want:   []string{"<rpc message-id=\"102\"\n     xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\">\n  <close-session/>\n</rpc>"},
+		},
+		{
+			// Chunks may contain the delimiters of both framings.
+			name:   "delimiters in chunk",
+			stream: "\n#10\n]]>]]>\n##\n\n##\n",
+			want:   []string{"]]>]]>\n##\n"},
+		},
+		{
+			name:   "consecutive messages",
+			stream: "\n#4\n<a/>\n##\n\n#4\n<b/>\n##\n",
+			want:   []string{"<a/>", "<b/>"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr := &transport{r: bufio.NewReaderSize(strings.NewReader(test.stream), 16), chunked: true}
+			for _, want := range test.want {
+				message, err := tr.readMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(message) != want {
+					t.Errorf("readMessage() = %q, want %q", message, want)
+				}
+			}
+			if _, err := tr.readMessage(); !errors.Is(err, io.EOF) {
+				t.Errorf("readMessage() at the end of the stream error = %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestReadMessageChunkedInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		wantErr error
+	}{
+		{name: "missing newline", stream: "#6\n<rpc/>\n##\n", wantErr: errFraming},
+		{name: "end-of-message framing", stream: "<rpc/>]]>]]>", wantErr: errFraming},
+		{name: "zero size", stream: "\n#0\n\n##\n", wantErr: errFraming},
+		{name: "invalid size", stream: "\n#abc\n", wantErr: errFraming},
+		{name: "negative size", stream: "\n#-1\n", wantErr: errFraming},
+		{name: "oversized chunk", stream: "\n#4294967296\n", wantErr: errFraming},
+		{name: "truncated chunk", stream: "\n#10\n<rpc/>", wantErr: io.ErrUnexpectedEOF},
+		{name: "missing end of chunks", stream: "\n#6\n<rpc/>", wantErr: io.EOF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr := &transport{r: bufio.NewReader(strings.NewReader(test.stream)), chunked: true}
+			if _, err := tr.readMessage(); !errors.Is(err, test.wantErr) {
+				t.Errorf("readMessage() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransportRoundTrip(t *testing.T) {
+	messages := []string{
+		`<rpc message-id="1"><get/></rpc>`,
+		strings.Repeat("<interface/>", 1000),
+	}
+
+	for _, chunked := range []bool{false, true} {
+		var buf bytes.Buffer
+		writer := &transport{w: &buf, chunked: chunked}
+		for _, message := range messages {
+			if err := writer.writeMessage([]byte(message)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		reader := &transport{r: bufio.NewReader(&buf), chunked: chunked}
+		for _, message := range messages {
+			got, err := reader.readMessage()
+			if err != nil {
+				t.Fatalf("readMessage() with chunked framing %t failed: %s", chunked, err)
+			}
+			if string(got) != message {
+				t.Errorf("readMessage() with chunked framing %t = %q, want %q", chunked, got, message)
+			}
+		}
+	}
+}
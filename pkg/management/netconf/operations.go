@@ -0,0 +1,278 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// Datastore is a configuration datastore of a NETCONF server.
+type Datastore string
+
+const (
+	// Running is the datastore that contains the active configuration.
+	Running Datastore = "running"
+	// Candidate is the datastore whose changes are applied to the running
+	// datastore on commit. It requires the candidate capability.
+	Candidate Datastore = "candidate"
+	// Startup is the datastore that is loaded on boot. It requires the startup capability.
+	Startup Datastore = "startup"
+)
+
+// DefaultOperation is the default operation of an edit-config operation.
+type DefaultOperation string
+
+const (
+	// DefaultOperationMerge merges the configuration into the datastore.
+	DefaultOperationMerge DefaultOperation = "merge"
+	// DefaultOperationReplace replaces the configuration of the datastore.
+	DefaultOperationReplace DefaultOperation = "replace"
+	// DefaultOperationNone only applies elements with an explicit operation.
+	DefaultOperationNone DefaultOperation = "none"
+)
+
+// EditOptions configure an edit-config operation.
+type EditOptions struct {
+	// DefaultOperation is the operation for elements without an
+	// explicit operation. Defaults to `merge` on the server.
+	DefaultOperation DefaultOperation
+	// TestOption is either `test-then-set`, `set` or `test-only`.
+	// It requires the validate capability.
+	TestOption string
+	// ErrorOption is either `stop-on-error`, `continue-on-error` or `rollback-on-error`.
+	ErrorOption string
+}
+
+// CommitOptions configure a commit operation.
+type CommitOptions struct {
+	// Confirmed requests a confirmed commit, which is reverted unless it is
+	// confirmed by another commit within the timeout. It requires the
+	// confirmed-commit capability.
+	Confirmed bool
+	// ConfirmTimeout is the timeout of a confirmed commit. Defaults to 600
+	// seconds on the server.
+	ConfirmTimeout time.Duration
+	// Persist makes a confirmed commit survive the end of the session. It
+	// can be confirmed or cancelled in another session with the same ID.
+	Persist string
+	// PersistID confirms a persistent confirmed commit with this ID.
+	PersistID string
+}
+
+// Get retrieves the running configuration and the state data. The filter
+// is a subtree filter, which is omitted if it is empty.
+func (c *Client) Get(ctx context.Context, filter string) (string, error) {
+	reply, err := c.Call(ctx, fmt.Sprintf("<get>%s</get>", subtreeFilter(filter)))
+	if err != nil {
+		return "", err
+	}
+
+	return reply.Data.Content, nil
+}
+
+// GetConfig retrieves the configuration of the datastore. The filter
+// is a subtree filter, which is omitted if it is empty.
+func (c *Client) GetConfig(ctx context.Context, source Datastore, filter string) (string, error) {
+	if err := c.requireDatastore(source); err != nil {
+		return "", err
+	}
+
+	reply, err := c.Call(ctx, fmt.Sprintf("<get-config><source><%s/></source>%s</get-config>", source, subtreeFilter(filter)))
+	if err != nil {
+		return "", err
+	}
+
+	return reply.Data.Content, nil
+}
+
+// EditConfig loads the configuration, which is the content of the `config`
+// element, into the datastore. Writing to the running datastore requires
+// the writable-running capability.
+func (c *Client) EditConfig(ctx context.Context, target Datastore, config string, opts *EditOptions) error {
+	if err := c.requireDatastore(target); err != nil {
+		return err
+	}
+	if target == Running && !c.HasCapability(CapabilityWritableRunning) {
+		return fmt.Errorf("%w: server does not support writing to the running datastore", common.ErrNotSupported)
+	}
+
+	var operation strings.Builder
+	fmt.Fprintf(&operation, "<edit-config><target><%s/></target>", target)
+	if opts != nil {
+		if opts.DefaultOperation != "" {
+			fmt.Fprintf(&operation, "<default-operation>%s</default-operation>", escape(string(opts.DefaultOperation)))
+		}
+		if opts.TestOption != "" {
+			if !c.HasCapability(CapabilityValidate) {
+				return fmt.Errorf("%w: server does not support the validate capability", common.ErrNotSupported)
+			}
+			fmt.Fprintf(&operation, "<test-option>%s</test-option>", escape(opts.TestOption))
+		}
+		if opts.ErrorOption != "" {
+			fmt.Fprintf(&operation, "<error-option>%s</error-option>", escape(opts.ErrorOption))
+		}
+	}
+	fmt.Fprintf(&operation, "<config>%s</config></edit-config>", config)
+
+	_, err := c.Call(ctx, operation.String())
+
+	return err
+}
+
+// CopyConfig replaces the configuration of the target datastore with
+// the configuration of the source datastore, e.g. to persist the
+// running configuration in the startup datastore.
+func (c *Client) CopyConfig(ctx context.Context, target Datastore, source Datastore) error {
+	if err := c.requireDatastore(target); err != nil {
+		return err
+	}
+	if err := c.requireDatastore(source); err != nil {
+		return err
+	}
+
+	_, err := c.Call(ctx, fmt.Sprintf("<copy-config><target><%s/></target><source><%s/></source></copy-config>", target, source))
+
+	return err
+}
+
+// Lock locks the datastore, which prevents other sessions from modifying it.
+func (c *Client) Lock(ctx context.Context, target Datastore) error {
+	if err := c.requireDatastore(target); err != nil {
+		return err
+	}
+
+	_, err := c.Call(ctx, fmt.Sprintf("<lock><target><%s/></target></lock>", target))
+
+	return err
+}
+
+// Unlock releases the lock of the datastore.
+func (c *Client) Unlock(ctx context.Context, target Datastore) error {
+	if err := c.requireDatastore(target); err != nil {
+		return err
+	}
+
+	_, err := c.Call(ctx, fmt.Sprintf("<unlock><target><%s/></target></unlock>", target))
+
+	return err
+}
+
+// Validate validates the configuration of the datastore. It requires the validate capability.
+func (c *Client) Validate(ctx context.Context, source Datastore) error {
+	if err := c.requireDatastore(source); err != nil {
+		return err
+	}
+	if !c.HasCapability(CapabilityValidate) {
+		return fmt.Errorf("%w: server does not support the validate capability", common.ErrNotSupported)
+	}
+
+	_, err := c.Call(ctx, fmt.Sprintf("<validate><source><%s/></source></validate>", source))
+
+	return err
+}
+
+// Commit applies the candidate datastore to the running datastore. A
+// pending confirmed commit of the session is confirmed by a regular commit.
+func (c *Client) Commit(ctx context.Context, opts *CommitOptions) error {
+	if err := c.requireDatastore(Candidate); err != nil {
+		return err
+	}
+
+	var operation strings.Builder
+	operation.WriteString("<commit>")
+	if opts != nil {
+		if opts.Confirmed {
+			if !c.HasCapability(CapabilityConfirmedCommit) && !c.HasCapability(CapabilityConfirmed11) {
+				return fmt.Errorf("%w: server does not support the confirmed-commit capability", common.ErrNotSupported)
+			}
+			operation.WriteString("<confirmed/>")
+			if opts.ConfirmTimeout > 0 {
+				fmt.Fprintf(&operation, "<confirm-timeout>%d</confirm-timeout>", int(opts.ConfirmTimeout.Seconds()))
+			}
+		}
+		if opts.Persist != "" || opts.PersistID != "" {
+			if !c.HasCapability(CapabilityConfirmed11) {
+				return fmt.Errorf("%w: server does not support persistent confirmed commits", common.ErrNotSupported)
+			}
+		}
+		if opts.Persist != "" {
+			fmt.Fprintf(&operation, "<persist>%s</persist>", escape(opts.Persist))
+		}
+		if opts.PersistID != "" {
+			fmt.Fprintf(&operation, "<persist-id>%s</persist-id>", escape(opts.PersistID))
+		}
+	}
+	operation.WriteString("</commit>")
+
+	_, err := c.Call(ctx, operation.String())
+
+	return err
+}
+
+// CancelCommit reverts a pending confirmed commit. The persist ID is
+// required to cancel a persistent confirmed commit of another session.
+func (c *Client) CancelCommit(ctx context.Context, persistID string) error {
+	if !c.HasCapability(CapabilityConfirmed11) {
+		return fmt.Errorf("%w: server does not support cancelling confirmed commits", common.ErrNotSupported)
+	}
+
+	operation := "<cancel-commit/>"
+	if persistID != "" {
+		operation = fmt.Sprintf("<cancel-commit><persist-id>%s</persist-id></cancel-commit>", escape(persistID))
+	}
+	_, err := c.Call(ctx, operation)
+
+	return err
+}
+
+// DiscardChanges reverts the candidate datastore to the running configuration.
+func (c *Client) DiscardChanges(ctx context.Context) error {
+	if err := c.requireDatastore(Candidate); err != nil {
+		return err
+	}
+
+	_, err := c.Call(ctx, "<discard-changes/>")
+
+	return err
+}
+
+// requireDatastore checks that the server supports the datastore.
+func (c *Client) requireDatastore(datastore Datastore) error {
+	switch datastore {
+	case Running:
+		return nil
+	case Candidate:
+		if c.HasCapability(CapabilityCandidate) {
+			return nil
+		}
+	case Startup:
+		if c.HasCapability(CapabilityStartup) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown datastore: %s", datastore)
+	}
+
+	return fmt.Errorf("%w: server does not support the %s datastore", common.ErrNotSupported, datastore)
+}
+
+// subtreeFilter wraps the filter in a subtree filter element.
+func subtreeFilter(filter string) string {
+	if filter == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(`<filter type="subtree">%s</filter>`, filter)
+}
+
+// escape escapes the text for the use in an XML element.
+func escape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+
+	return b.String()
+}
@@ -0,0 +1,276 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// allCapabilities are the capabilities of a server that supports all operations.
+var allCapabilities = []string{
+	CapabilityBase10,
+	CapabilityBase11,
+	CapabilityCandidate,
+	CapabilityConfirmed11,
+	CapabilityStartup,
+	CapabilityWritableRunning,
+	CapabilityValidate,
+}
+
+func TestOperations(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(ctx context.Context, c *Client) error
+		want string
+	}{
+		{
+			name: "get-config",
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.GetConfig(ctx, Running, `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"/>`)
+				return err
+			},
+			want: `<get-config><source><running/></source><filter type="subtree"><interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"/></filter></get-config>`,
+		},
+		{
+			name: "edit-config",
+			call: func(ctx context.Context, c *Client) error {
+				return c.EditConfig(ctx, Candidate, `<system xmlns="urn:ietf:params:xml:ns:yang:ietf-system"><hostname>switch-1</hostname></system>`, &EditOptions{
+					DefaultOperation: DefaultOperationReplace,
+					TestOption:       "test-then-set",
+					ErrorOption:      "rollback-on-error",
+				})
+			},
+			want: `<edit-config><target><candidate/></target><default-operation>replace</default-operation>` +
+				`<test-option>test-then-set</test-option><error-option>rollback-on-error</error-option>` +
+				`<config><system xmlns="urn:ietf:params:xml:ns:yang:ietf-system"><hostname>switch-1</hostname></system></config></edit-config>`,
+		},
+		{
+			name: "copy-config",
+			call: func(ctx context.Context, c *Client) error {
+				return c.CopyConfig(ctx, Startup, Running)
+			},
+			want: `<copy-config><target><startup/></target><source><running/></source></copy-config>`,
+		},
+		{
+			name: "lock",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Lock(ctx, Candidate)
+			},
+			want: `<lock><target><candidate/></target></lock>`,
+		},
+		{
+			name: "unlock",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Unlock(ctx, Candidate)
+			},
+			want: `<unlock><target><candidate/></target></unlock>`,
+		},
+		{
+			name: "validate",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Validate(ctx, Candidate)
+			},
+			want: `<validate><source><candidate/></source></validate>`,
+		},
+		{
+			name: "commit",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Commit(ctx, nil)
+			},
+			want: `<commit></commit>`,
+		},
+		{
+			name: "confirmed commit",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Commit(ctx, &CommitOptions{Confirmed: true, ConfirmTimeout: 2 * time.Minute, Persist: "kraut<1>"})
+			},
+			want: `<commit><confirmed/><confirm-timeout>120</confirm-timeout><persist>kraut&lt;1&gt;</persist></commit>`,
+		},
+		{
+			name: "confirming commit",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Commit(ctx, &CommitOptions{PersistID: "kraut-1"})
+			},
+			want: `<commit><persist-id>kraut-1</persist-id></commit>`,
+		},
+		{
+			name: "cancel-commit",
+			call: func(ctx context.Context, c *Client) error {
+				return c.CancelCommit(ctx, "kraut-1")
+			},
+			want: `<cancel-commit><persist-id>kraut-1</persist-id></cancel-commit>`,
+		},
+		{
+			name: "discard-changes",
+			call: func(ctx context.Context, c *Client) error {
+				return c.DiscardChanges(ctx)
+			},
+			want: `<discard-changes/>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &testServer{capabilities: allCapabilities}
+			client := connectTestClient(t, server)
+
+			if err := test.call(context.Background(), client); err != nil {
+				t.Fatal(err)
+			}
+			if operations := server.Operations(); len(operations) != 1 || operations[0] != test.want {
+				t.Errorf("operations = %v, want %v", operations, []string{test.want})
+			}
+		})
+	}
+}
+
+func TestOperationsReturnData(t *testing.T) {
+	data := `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><interface><name>eth0</name></interface></interfaces>`
+	server := &testServer{
+		capabilities: allCapabilities,
+		handle: func(operation string) string {
+			return "<data>" + data + "</data>"
+		},
+	}
+	client := connectTestClient(t, server)
+
+	got, err := client.Get(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != data {
+		t.Errorf("Get() = %q, want %q", got, data)
+	}
+
+	got, err = client.GetConfig(context.Background(), Startup, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != data {
+		t.Errorf("GetConfig() = %q, want %q", got, data)
+	}
+
+	want := []string{"<get></get>", "<get-config><source><startup/></source></get-config>"}
+	operations := server.Operations()
+	if len(operations) != len(want) || operations[0] != want[0] || operations[1] != want[1] {
+		t.Errorf("operations = %v, want %v", operations, want)
+	}
+}
+
+func TestOperationsRequireCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(ctx context.Context, c *Client) error
+	}{
+		{
+			name: "candidate datastore",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Lock(ctx, Candidate)
+			},
+		},
+		{
+			name: "startup datastore",
+			call: func(ctx context.Context, c *Client) error {
+				return c.CopyConfig(ctx, Startup, Running)
+			},
+		},
+		{
+			name: "writable running datastore",
+			call: func(ctx context.Context, c *Client) error {
+				return c.EditConfig(ctx, Running, "<system/>", nil)
+			},
+		},
+		{
+			name: "validate",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Validate(ctx, Running)
+			},
+		},
+		{
+			name: "commit",
+			call: func(ctx context.Context, c *Client) error {
+				return c.Commit(ctx, nil)
+			},
+		},
+		{
+			name: "discard-changes",
+			call: func(ctx context.Context, c *Client) error {
+				return c.DiscardChanges(ctx)
+			},
+		},
+		{
+			name: "cancel-commit",
+			call: func(ctx context.Context, c *Client) error {
+				return c.CancelCommit(ctx, "")
+			},
+		},
+	}
+
+	server := &testServer{capabilities: []string{CapabilityBase11}}
+	client := connectTestClient(t, server)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.call(context.Background(), client); !errors.Is(err, common.ErrNotSupported) {
+				t.Errorf("error = %v, want %v", err, common.ErrNotSupported)
+			}
+		})
+	}
+
+	// Unsupported operations must not be sent to the server.
+	if operations := server.Operations(); len(operations) != 0 {
+		t.Errorf("operations = %v, want none", operations)
+	}
+}
+
+func TestCommitRequiresConfirmedCommit(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities []string
+		opts         *CommitOptions
+		wantErr      bool
+	}{
+		{
+			name:         "confirmed commit 1.0",
+			capabilities: []string{CapabilityBase11, CapabilityCandidate, CapabilityConfirmedCommit},
+			opts:         &CommitOptions{Confirmed: true},
+		},
+		{
+			name:         "persistent commit 1.0",
+			capabilities: []string{CapabilityBase11, CapabilityCandidate, CapabilityConfirmedCommit},
+			opts:         &CommitOptions{Confirmed: true, Persist: "kraut-1"},
+			wantErr:      true,
+		},
+		{
+			name:         "confirmed commit without capability",
+			capabilities: []string{CapabilityBase11, CapabilityCandidate},
+			opts:         &CommitOptions{Confirmed: true},
+			wantErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := connectTestClient(t, &testServer{capabilities: test.capabilities})
+
+			err := client.Commit(context.Background(), test.opts)
+			if test.wantErr != errors.Is(err, common.ErrNotSupported) {
+				t.Errorf("Commit() error = %v, want not supported %t", err, test.wantErr)
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("Commit() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestUnknownDatastore(t *testing.T) {
+	client := connectTestClient(t, &testServer{capabilities: allCapabilities})
+
+	if _, err := client.GetConfig(context.Background(), "intended", ""); err == nil || errors.Is(err, common.ErrNotSupported) {
+		t.Errorf("GetConfig() error = %v, want unknown datastore", err)
+	}
+}
@@ -0,0 +1,478 @@
+package redfish
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	// defaultPort is the default port of a Redfish service.
+	defaultPort = 443
+	// serviceRoot is the path of the root of a Redfish service.
+	serviceRoot = "/redfish/v1"
+	// requestTimeout is the timeout of a single request, because
+	// some BMCs are notoriously slow to respond.
+	requestTimeout = 60 * time.Second
+)
+
+// Client manages the BMC of a host using the Redfish API.
+type Client struct {
+	host    *mgmtv1alpha1.Host
+	opts    *common.Options
+	base    *url.URL
+	http    *http.Client
+	user    string
+	pass    string
+	token   string
+	session string
+
+	// system, chassis and manager are the paths of the resources of the host.
+	system  string
+	chassis string
+	manager string
+}
+
+// NewClient creates a new Redfish client for the BMC of a host.
+func NewClient(ctx context.Context, host *mgmtv1alpha1.Host, options ...common.Option) (common.OutOfBandClient, error) {
+	opts, err := common.GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if host.Spec.OutOfBand == nil {
+		return nil, fmt.Errorf("host has no out-of-band endpoint: %s/%s", host.Namespace, host.Name)
+	}
+
+	return &Client{
+		host: host,
+		opts: opts,
+	}, nil
+}
+
+// Connect authenticates with the Redfish service and discovers the resources of the host.
+func (c *Client) Connect(ctx context.Context) error {
+	options := c.host.Spec.OutOfBand
+
+	credentials, err := common.ReadCredentials(ctx, c.opts, common.CredentialReference{
+		SecretRef: common.OutOfBandSecretReference(c.host),
+		Namespace: c.host.Namespace,
+	})
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := tlsConfig(options)
+	if err != nil {
+		return err
+	}
+
+	port := options.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	c.base = &url.URL{
+		Scheme: "https",
+		Host:   net.JoinHostPort(options.Host, strconv.Itoa(port)),
+	}
+	c.http = &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}
+	c.user = options.User
+	c.pass = credentials.Password
+
+	if options.Redfish.Authentication != mgmtv1alpha1.RedfishAuthenticationBasic {
+		if err := c.login(ctx); err != nil {
+			c.http.CloseIdleConnections()
+			return err
+		}
+	}
+
+	if err := c.discover(ctx); err != nil {
+		c.Disconnect()
+		return err
+	}
+
+	return nil
+}
+
+// Disconnect deletes the session and closes idle connections.
+func (c *Client) Disconnect() error {
+	if c.http == nil {
+		return nil
+	}
+
+	var err error
+	if c.session != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err = c.do(ctx, http.MethodDelete, c.session, nil, nil)
+		c.session = ""
+		c.token = ""
+	}
+	c.http.CloseIdleConnections()
+
+	return err
+}
+
+// Inventory reads information about the system, the chassis and the BMC.
+func (c *Client) Inventory(ctx context.Context) (*mgmtv1alpha1.HostStatusOutOfBand, error) {
+	var system computerSystem
+	if err := c.get(ctx, c.system, &system); err != nil {
+		return nil, err
+	}
+
+	inventory := &mgmtv1alpha1.HostStatusOutOfBand{
+		PowerState: powerState(system.PowerState),
+		System: mgmtv1alpha1.HostSystemInfo{
+			Manufacturer:   system.Manufacturer,
+			Model:          system.Model,
+			SerialNumber:   system.SerialNumber,
+			SKU:            system.SKU,
+			BIOSVersion:    system.BiosVersion,
+			ProcessorCount: system.ProcessorSummary.Count,
+			MemoryGiB:      int(system.MemorySummary.TotalSystemMemoryGiB),
+			Health:         system.Status.Health,
+		},
+	}
+
+	if c.chassis != "" {
+		var chassis chassis
+		if err := c.get(ctx, c.chassis, &chassis); err != nil {
+			return nil, err
+		}
+
+		inventory.Chassis = mgmtv1alpha1.HostChassisInfo{
+			Type:         chassis.ChassisType,
+			Manufacturer: chassis.Manufacturer,
+			Model:        chassis.Model,
+			SerialNumber: chassis.SerialNumber,
+		}
+	}
+
+	if c.manager != "" {
+		var manager manager
+		if err := c.get(ctx, c.manager, &manager); err != nil {
+			return nil, err
+		}
+
+		inventory.BMC = mgmtv1alpha1.HostBMCInfo{
+			Model:           manager.Model,
+			FirmwareVersion: manager.FirmwareVersion,
+		}
+	}
+
+	return inventory, nil
+}
+
+// PowerState returns the power state of the host.
+func (c *Client) PowerState(ctx context.Context) (mgmtv1alpha1.PowerState, error) {
+	var system computerSystem
+	if err := c.get(ctx, c.system, &system); err != nil {
+		return mgmtv1alpha1.PowerStateUnknown, err
+	}
+
+	return powerState(system.PowerState), nil
+}
+
+// Power submits a reset action to the computer system.
+func (c *Client) Power(ctx context.Context, action mgmtv1alpha1.PowerAction) error {
+	var system computerSystem
+	if err := c.get(ctx, c.system, &system); err != nil {
+		return err
+	}
+
+	reset, ok := system.Actions["#ComputerSystem.Reset"]
+	if !ok || reset.Target == "" {
+		return fmt.Errorf("%w: system does not support reset actions", common.ErrNotSupported)
+	}
+	if len(reset.AllowableValues) > 0 && !slices.Contains(reset.AllowableValues, string(action)) {
+		return fmt.Errorf("%w: system does not support power action: %s", common.ErrNotSupported, action)
+	}
+
+	return c.do(ctx, http.MethodPost, reset.Target, map[string]string{
+		"ResetType": string(action),
+	}, nil)
+}
+
+// SetBootDevice overrides the boot device of the computer system for the next boot.
+func (c *Client) SetBootDevice(ctx context.Context, device mgmtv1alpha1.BootDevice) error {
+	return c.do(ctx, http.MethodPatch, c.system, map[string]any{
+		"Boot": map[string]string{
+			"BootSourceOverrideEnabled": "Once",
+			"BootSourceOverrideTarget":  string(device),
+		},
+	}, nil)
+}
+
+// Events returns the entries of the log services of the
+// computer system, which were created after the given time.
+func (c *Client) Events(ctx context.Context, since time.Time) ([]common.Event, error) {
+	var system computerSystem
+	if err := c.get(ctx, c.system, &system); err != nil {
+		return nil, err
+	}
+	if system.LogServices.ID == "" {
+		return nil, nil
+	}
+
+	var services collection
+	if err := c.get(ctx, system.LogServices.ID, &services); err != nil {
+		return nil, err
+	}
+
+	var events []common.Event
+	for _, member := range services.Members {
+		var service logService
+		if err := c.get(ctx, member.ID, &service); err != nil {
+			return nil, err
+		}
+		if service.Entries.ID == "" {
+			continue
+		}
+
+		var entries logEntries
+		if err := c.get(ctx, service.Entries.ID, &entries); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries.Members {
+			// The time of the last reported entry is persisted with a
+			// precision of seconds, so entries are compared likewise.
+			created, err := time.Parse(time.RFC3339, entry.Created)
+			created = created.Truncate(time.Second)
+			if err != nil || !created.After(since) {
+				continue
+			}
+
+			events = append(events, common.Event{
+				ID:       entry.ID,
+				Created:  created,
+				Severity: entry.Severity,
+				Message:  strings.TrimSpace(entry.Message),
+			})
+		}
+	}
+
+	slices.SortStableFunc(events, func(a, b common.Event) int {
+		return a.Created.Compare(b.Created)
+	})
+
+	return events, nil
+}
+
+// login creates a session and stores its token.
+func (c *Client) login(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{
+		"UserName": c.user,
+		"Password": c.pass,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url(serviceRoot+"/SessionService/Sessions"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return responseError(res)
+	}
+
+	c.token = res.Header.Get("X-Auth-Token")
+	if c.token == "" {
+		return errors.New("failed to create Redfish session: missing token")
+	}
+
+	// The location may be an absolute URL, but requests always use the base URL.
+	if location, err := url.Parse(res.Header.Get("Location")); err == nil {
+		c.session = location.Path
+	}
+
+	return nil
+}
+
+// discover finds the computer system, the chassis and the manager of the host.
+func (c *Client) discover(ctx context.Context) error {
+	var systems collection
+	if err := c.get(ctx, serviceRoot+"/Systems", &systems); err != nil {
+		return err
+	}
+
+	systemID := c.host.Spec.OutOfBand.Redfish.SystemID
+	for _, member := range systems.Members {
+		if systemID == "" || path.Base(member.ID) == systemID {
+			c.system = member.ID
+			break
+		}
+	}
+	if c.system == "" {
+		if systemID != "" {
+			return fmt.Errorf("failed to find Redfish system: %s", systemID)
+		}
+		return errors.New("failed to find Redfish system")
+	}
+
+	var system computerSystem
+	if err := c.get(ctx, c.system, &system); err != nil {
+		return err
+	}
+	if len(system.Links.Chassis) > 0 {
+		c.chassis = system.Links.Chassis[0].ID
+	}
+	if len(system.Links.ManagedBy) > 0 {
+		c.manager = system.Links.ManagedBy[0].ID
+	}
+
+	return nil
+}
+
+// get reads the resource at the path.
+func (c *Client) get(ctx context.Context, resource string, v any) error {
+	return c.do(ctx, http.MethodGet, resource, nil, v)
+}
+
+// do sends a request to the resource and decodes the response into v, if it is not nil.
+func (c *Client) do(ctx context.Context, method string, resource string, body any, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url(resource), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Auth-Token", c.token)
+	} else {
+		req.SetBasicAuth(c.user, c.pass)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return responseError(res)
+	}
+
+	if v == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// url resolves the path of the resource against the base URL of the service.
+func (c *Client) url(resource string) string {
+	u := *c.base
+	u.Path = resource
+
+	return u.String()
+}
+
+// responseError returns an error describing the unsuccessful response.
+func responseError(res *http.Response) error {
+	var body errorResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body); err == nil {
+		if len(body.Error.ExtendedInfo) > 0 && body.Error.ExtendedInfo[0].Message != "" {
+			return fmt.Errorf("redfish request failed: %s: %s", res.Status, body.Error.ExtendedInfo[0].Message)
+		}
+		if body.Error.Message != "" {
+			return fmt.Errorf("redfish request failed: %s: %s", res.Status, body.Error.Message)
+		}
+	}
+
+	return fmt.Errorf("redfish request failed: %s", res.Status)
+}
+
+// tlsConfig returns the TLS configuration for the BMC. A specified
+// fingerprint pins the certificate instead of verifying its chain.
+func tlsConfig(options *mgmtv1alpha1.HostSpecOutOfBand) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: options.Host,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if options.Fingerprint != "" {
+		if !strings.HasPrefix(options.Fingerprint, "SHA256:") {
+			return nil, fmt.Errorf("unsupported fingerprint format: %s", options.Fingerprint)
+		}
+
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("failed to verify certificate: no certificate presented")
+			}
+
+			sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+			presented := "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+			if presented != strings.TrimRight(options.Fingerprint, "=") {
+				return fmt.Errorf("failed to verify certificate: fingerprint mismatch: %s", presented)
+			}
+
+			return nil
+		}
+
+		return config, nil
+	}
+
+	config.InsecureSkipVerify = options.InsecureSkipVerify
+
+	return config, nil
+}
+
+// powerState converts the power state of a computer system.
+func powerState(state string) mgmtv1alpha1.PowerState {
+	switch state {
+	case "On", "PoweringOff":
+		return mgmtv1alpha1.PowerStateOn
+	case "Off", "PoweringOn":
+		return mgmtv1alpha1.PowerStateOff
+	default:
+		return mgmtv1alpha1.PowerStateUnknown
+	}
+}
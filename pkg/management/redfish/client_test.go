@@ -0,0 +1,390 @@
+package redfish
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	testUser     = "root"
+	testPassword = "calvin"
+	testToken    = "0123456789abcdef"
+	testSystem   = "/redfish/v1/Systems/System.Embedded.1"
+	testSession  = "/redfish/v1/SessionService/Sessions/1"
+)
+
+// staticCredentials provides the same credentials for all references.
+type staticCredentials common.Credentials
+
+// Credentials returns the credentials.
+func (s staticCredentials) Credentials(ctx context.Context, ref common.CredentialReference) (*common.Credentials, error) {
+	credentials := common.Credentials(s)
+	return &credentials, nil
+}
+
+// testService is a Redfish service with a single computer system.
+type testService struct {
+	// powerState is the power state of the computer system.
+	powerState string
+	// resetTypes are the allowable values of the reset action.
+	// The action is not offered if it is nil.
+	resetTypes []string
+
+	mutex    sync.Mutex
+	sessions int
+	deleted  []string
+	resets   []string
+	basic    int
+}
+
+// ServeHTTP implements a subset of the Redfish API.
+func (s *testService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if r.Method == http.MethodPost && r.URL.Path == serviceRoot+"/SessionService/Sessions" {
+		var credentials struct {
+			UserName string
+			Password string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil || credentials.UserName != testUser || credentials.Password != testPassword {
+			writeError(w, http.StatusUnauthorized, "The authentication credentials included with this request are missing or invalid.")
+			return
+		}
+		s.sessions++
+		w.Header().Set("X-Auth-Token", testToken)
+		w.Header().Set("Location", "https://"+r.Host+testSession)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	switch user, password, ok := r.BasicAuth(); {
+	case r.Header.Get("X-Auth-Token") == testToken:
+	case ok && user == testUser && password == testPassword:
+		s.basic++
+	default:
+		writeError(w, http.StatusUnauthorized, "")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodDelete && r.URL.Path == testSession:
+		s.deleted = append(s.deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == serviceRoot+"/Systems":
+		writeJSON(w, map[string]any{"Members": []link{{ID: testSystem}}})
+	case r.Method == http.MethodGet && r.URL.Path == testSystem:
+		system := map[string]any{
+			"Manufacturer": "Dell Inc.",
+			"Model":        "PowerEdge R650",
+			"PowerState":   s.powerState,
+			"Links": map[string]any{
+				"Chassis":   []link{{ID: "/redfish/v1/Chassis/System.Embedded.1"}},
+				"ManagedBy": []link{{ID: "/redfish/v1/Managers/iDRAC.Embedded.1"}},
+			},
+		}
+		if s.resetTypes != nil {
+			system["Actions"] = map[string]any{
+				"#ComputerSystem.Reset": map[string]any{
+					"target":                            testSystem + "/Actions/ComputerSystem.Reset",
+					"ResetType@Redfish.AllowableValues": s.resetTypes,
+				},
+			}
+		}
+		writeJSON(w, system)
+	case r.Method == http.MethodPost && r.URL.Path == testSystem+"/Actions/ComputerSystem.Reset":
+		var body struct{ ResetType string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.resets = append(s.resets, body.ResetType)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "")
+	}
+}
+
+// writeJSON writes the value as the body of the response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response with the message in the extended information.
+func writeError(w http.ResponseWriter, code int, message string) {
+	body := map[string]any{"error": map[string]any{"message": "General error"}}
+	if message != "" {
+		body["error"].(map[string]any)["@Message.ExtendedInfo"] = []map[string]string{{"Message": message}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// newTestClient starts the service and creates a client that pins its certificate.
+func newTestClient(t *testing.T, service *testService, options mgmtv1alpha1.HostSpecRedfishOptions, password string) *Client {
+	t.Helper()
+
+	server := httptest.NewTLSServer(service)
+	t.Cleanup(server.Close)
+
+	host, rawPort, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(server.Certificate().Raw)
+
+	mgmt, err := NewClient(context.Background(), &mgmtv1alpha1.Host{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "node-1"},
+		Spec: mgmtv1alpha1.HostSpec{
+			OutOfBand: &mgmtv1alpha1.HostSpecOutOfBand{
+				Protocol:    mgmtv1alpha1.OutOfBandProtocolRedfish,
+				Host:        host,
+				Port:        port,
+				User:        testUser,
+				Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+				Redfish:     options,
+			},
+		},
+	}, common.WithCredentialSource(staticCredentials{Password: password}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mgmt.(*Client)
+}
+
+func TestClientSession(t *testing.T) {
+	service := &testService{powerState: "On"}
+	client := newTestClient(t, service, mgmtv1alpha1.HostSpecRedfishOptions{}, testPassword)
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if client.session != testSession {
+		t.Errorf("session = %q, want %q", client.session, testSession)
+	}
+	if client.system != testSystem || client.manager != "/redfish/v1/Managers/iDRAC.Embedded.1" {
+		t.Errorf("discovered system %q and manager %q", client.system, client.manager)
+	}
+
+	state, err := client.PowerState(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != mgmtv1alpha1.PowerStateOn {
+		t.Errorf("PowerState() = %s, want %s", state, mgmtv1alpha1.PowerStateOn)
+	}
+
+	if err := client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	// The session is only deleted once.
+	if err := client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if service.sessions != 1 || !slices.Equal(service.deleted, []string{testSession}) {
+		t.Errorf("created %d sessions and deleted %v, want one session", service.sessions, service.deleted)
+	}
+	if service.basic != 0 {
+		t.Errorf("%d requests used basic authentication, want none", service.basic)
+	}
+}
+
+func TestClientBasicAuthentication(t *testing.T) {
+	service := &testService{powerState: "Off"}
+	client := newTestClient(t, service, mgmtv1alpha1.HostSpecRedfishOptions{Authentication: mgmtv1alpha1.RedfishAuthenticationBasic}, testPassword)
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if service.sessions != 0 || len(service.deleted) != 0 {
+		t.Errorf("created %d sessions and deleted %v, want none", service.sessions, service.deleted)
+	}
+	if service.basic == 0 {
+		t.Error("expected requests to use basic authentication")
+	}
+}
+
+func TestClientConnectErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  mgmtv1alpha1.HostSpecRedfishOptions
+		password string
+		want     string
+	}{
+		{
+			name:     "wrong password",
+			password: "wrong",
+			want:     "redfish request failed: 401 Unauthorized: The authentication credentials included with this request are missing or invalid.",
+		},
+		{
+			name:     "wrong password with basic authentication",
+			options:  mgmtv1alpha1.HostSpecRedfishOptions{Authentication: mgmtv1alpha1.RedfishAuthenticationBasic},
+			password: "wrong",
+			want:     "redfish request failed: 401 Unauthorized: General error",
+		},
+		{
+			name:     "unknown system",
+			options:  mgmtv1alpha1.HostSpecRedfishOptions{SystemID: "System.Embedded.2"},
+			password: testPassword,
+			want:     "failed to find Redfish system: System.Embedded.2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &testService{powerState: "On"}
+			client := newTestClient(t, service, test.options, test.password)
+
+			err := client.Connect(context.Background())
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("Connect() error = %v, want %q", err, test.want)
+			}
+
+			// A session that was created before the failure must be deleted.
+			service.mutex.Lock()
+			defer service.mutex.Unlock()
+			if service.sessions != len(service.deleted) {
+				t.Errorf("created %d sessions, but deleted %v", service.sessions, service.deleted)
+			}
+		})
+	}
+}
+
+func TestClientFingerprintMismatch(t *testing.T) {
+	client := newTestClient(t, &testService{}, mgmtv1alpha1.HostSpecRedfishOptions{}, testPassword)
+	client.host.Spec.OutOfBand.Fingerprint = "SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"
+
+	if err := client.Connect(context.Background()); err == nil {
+		t.Fatal("expected certificate with another fingerprint to be rejected")
+	}
+}
+
+func TestClientPower(t *testing.T) {
+	allowed := []string{"On", "ForceOff", "GracefulShutdown", "ForceRestart", "PowerCycle"}
+
+	tests := []struct {
+		name       string
+		resetTypes []string
+		action     mgmtv1alpha1.PowerAction
+		wantErr    error
+	}{
+		{name: "allowed", resetTypes: allowed, action: mgmtv1alpha1.PowerActionForceRestart},
+		{name: "unrestricted", resetTypes: []string{}, action: mgmtv1alpha1.PowerActionGracefulRestart},
+		{name: "not allowed", resetTypes: allowed, action: mgmtv1alpha1.PowerActionGracefulRestart, wantErr: common.ErrNotSupported},
+		{name: "no reset action", action: mgmtv1alpha1.PowerActionOn, wantErr: common.ErrNotSupported},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &testService{powerState: "On", resetTypes: test.resetTypes}
+			client := newTestClient(t, service, mgmtv1alpha1.HostSpecRedfishOptions{}, testPassword)
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer client.Disconnect()
+
+			err := client.Power(context.Background(), test.action)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Power() error = %v, want %v", err, test.wantErr)
+			}
+
+			var want []string
+			if test.wantErr == nil {
+				want = []string{string(test.action)}
+			}
+			service.mutex.Lock()
+			defer service.mutex.Unlock()
+			if !slices.Equal(service.resets, want) {
+				t.Errorf("submitted resets %v, want %v", service.resets, want)
+			}
+		})
+	}
+}
+
+func TestClientPowerFallback(t *testing.T) {
+	// A BMC that does not support graceful restarts is asked to force the restart.
+	service := &testService{powerState: "On", resetTypes: []string{"On", "ForceOff", "ForceRestart"}}
+	client := newTestClient(t, service, mgmtv1alpha1.HostSpecRedfishOptions{}, testPassword)
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	err := client.Power(context.Background(), mgmtv1alpha1.PowerActionGracefulRestart)
+	if !errors.Is(err, common.ErrNotSupported) {
+		t.Fatalf("Power() error = %v, want %v", err, common.ErrNotSupported)
+	}
+	if err := client.Power(context.Background(), mgmtv1alpha1.PowerActionForceRestart); err != nil {
+		t.Fatal(err)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if !slices.Equal(service.resets, []string{"ForceRestart"}) {
+		t.Errorf("submitted resets %v, want only the fallback", service.resets)
+	}
+}
+
+func TestPowerState(t *testing.T) {
+	tests := []struct {
+		state string
+		want  mgmtv1alpha1.PowerState
+	}{
+		{state: "On", want: mgmtv1alpha1.PowerStateOn},
+		{state: "PoweringOff", want: mgmtv1alpha1.PowerStateOn},
+		{state: "Off", want: mgmtv1alpha1.PowerStateOff},
+		{state: "PoweringOn", want: mgmtv1alpha1.PowerStateOff},
+		{state: "Paused", want: mgmtv1alpha1.PowerStateUnknown},
+		{state: "", want: mgmtv1alpha1.PowerStateUnknown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.state, func(t *testing.T) {
+			service := &testService{powerState: test.state}
+			client := newTestClient(t, service, mgmtv1alpha1.HostSpecRedfishOptions{Authentication: mgmtv1alpha1.RedfishAuthenticationBasic}, testPassword)
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer client.Disconnect()
+
+			state, err := client.PowerState(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != test.want {
+				t.Errorf("PowerState() = %s, want %s", state, test.want)
+			}
+		})
+	}
+}
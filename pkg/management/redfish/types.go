@@ -0,0 +1,85 @@
+package redfish
+
+// link is a reference to another resource.
+type link struct {
+	ID string `json:"@odata.id"`
+}
+
+// collection is a collection of resources.
+type collection struct {
+	Members []link `json:"Members"`
+}
+
+// status is the status of a resource.
+type status struct {
+	State  string `json:"State"`
+	Health string `json:"Health"`
+}
+
+// action is an action that can be performed on a resource.
+type action struct {
+	Target          string   `json:"target"`
+	AllowableValues []string `json:"ResetType@Redfish.AllowableValues"`
+}
+
+// computerSystem is the ComputerSystem resource.
+type computerSystem struct {
+	Manufacturer     string `json:"Manufacturer"`
+	Model            string `json:"Model"`
+	SerialNumber     string `json:"SerialNumber"`
+	SKU              string `json:"SKU"`
+	BiosVersion      string `json:"BiosVersion"`
+	PowerState       string `json:"PowerState"`
+	Status           status `json:"Status"`
+	ProcessorSummary struct {
+		Count int `json:"Count"`
+	} `json:"ProcessorSummary"`
+	MemorySummary struct {
+		TotalSystemMemoryGiB float64 `json:"TotalSystemMemoryGiB"`
+	} `json:"MemorySummary"`
+	LogServices link `json:"LogServices"`
+	Links       struct {
+		Chassis   []link `json:"Chassis"`
+		ManagedBy []link `json:"ManagedBy"`
+	} `json:"Links"`
+	Actions map[string]action `json:"Actions"`
+}
+
+// chassis is the Chassis resource.
+type chassis struct {
+	ChassisType  string `json:"ChassisType"`
+	Manufacturer string `json:"Manufacturer"`
+	Model        string `json:"Model"`
+	SerialNumber string `json:"SerialNumber"`
+}
+
+// manager is the Manager resource, which describes the BMC.
+type manager struct {
+	Model           string `json:"Model"`
+	FirmwareVersion string `json:"FirmwareVersion"`
+}
+
+// logService is the LogService resource.
+type logService struct {
+	Entries link `json:"Entries"`
+}
+
+// logEntries is the collection of LogEntry resources.
+type logEntries struct {
+	Members []struct {
+		ID       string `json:"Id"`
+		Created  string `json:"Created"`
+		Severity string `json:"Severity"`
+		Message  string `json:"Message"`
+	} `json:"Members"`
+}
+
+// errorResponse is the body of an unsuccessful response.
+type errorResponse struct {
+	Error struct {
+		Message      string `json:"message"`
+		ExtendedInfo []struct {
+			Message string `json:"Message"`
+		} `json:"@Message.ExtendedInfo"`
+	} `json:"error"`
+}
@@ -0,0 +1,392 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The tags of the BER encoded types that are used by SNMP.
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagOID         = 0x06
+	tagSequence    = 0x30
+
+	tagIPAddress = 0x40
+	tagCounter32 = 0x41
+	tagGauge32   = 0x42
+	tagTimeTicks = 0x43
+	tagOpaque    = 0x44
+	tagCounter64 = 0x46
+
+	tagNoSuchObject   = 0x80
+	tagNoSuchInstance = 0x81
+	tagEndOfMibView   = 0x82
+
+	tagGetRequest     = 0xa0
+	tagGetNextRequest = 0xa1
+	tagResponse       = 0xa2
+	tagGetBulkRequest = 0xa5
+	tagReport         = 0xa8
+)
+
+// errMalformed is returned if a message cannot be decoded.
+var errMalformed = errors.New("malformed SNMP message")
+
+// OID is an object identifier, such as `1.3.6.1.2.1.1.1.0`.
+type OID []uint32
+
+// ParseOID parses an object identifier in the dotted notation.
+func ParseOID(raw string) (OID, error) {
+	parts := strings.Split(strings.TrimPrefix(raw, "."), ".")
+	oid := make(OID, len(parts))
+	for i, part := range parts {
+		arc, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object identifier: %s", raw)
+		}
+		oid[i] = uint32(arc)
+	}
+
+	return oid, nil
+}
+
+// mustParseOID parses an object identifier and panics if it is invalid.
+func mustParseOID(raw string) OID {
+	oid, err := ParseOID(raw)
+	if err != nil {
+		panic(err)
+	}
+
+	return oid
+}
+
+// String returns the object identifier in the dotted notation.
+func (o OID) String() string {
+	parts := make([]string, len(o))
+	for i, arc := range o {
+		parts[i] = strconv.FormatUint(uint64(arc), 10)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// HasPrefix returns whether the object identifier is located in the subtree of the prefix.
+func (o OID) HasPrefix(prefix OID) bool {
+	if len(o) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if o[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Compare returns -1, 0 or 1 if the object identifier
+// is lexicographically less, equal or greater than the other.
+func (o OID) Compare(other OID) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		switch {
+		case o[i] < other[i]:
+			return -1
+		case o[i] > other[i]:
+			return 1
+		}
+	}
+
+	switch {
+	case len(o) < len(other):
+		return -1
+	case len(o) > len(other):
+		return 1
+	}
+
+	return 0
+}
+
+// Equal returns whether both object identifiers are identical.
+func (o OID) Equal(other OID) bool {
+	return len(o) == len(other) && o.HasPrefix(other)
+}
+
+// variable is a variable binding of a PDU. The value is kept in
+// its encoded form and is decoded based on its type on demand.
+type variable struct {
+	OID   OID
+	Type  byte
+	Value []byte
+}
+
+// Exists returns whether the agent returned a value for the variable.
+func (v *variable) Exists() bool {
+	return v.Type != tagNoSuchObject && v.Type != tagNoSuchInstance && v.Type != tagEndOfMibView
+}
+
+// Int returns the value of an integer, a counter, a gauge or time ticks.
+func (v *variable) Int() int64 {
+	if v.Type == tagInteger {
+		return parseInt(v.Value)
+	}
+
+	return int64(parseUint(v.Value))
+}
+
+// String returns the value of an octet string.
+func (v *variable) String() string {
+	return string(v.Value)
+}
+
+// ObjectID returns the value of an object identifier.
+func (v *variable) ObjectID() OID {
+	oid, err := parseOID(v.Value)
+	if err != nil {
+		return nil
+	}
+
+	return oid
+}
+
+// pdu is a protocol data unit. For a GetBulkRequest, the error status
+// and the error index contain the non-repeaters and the max-repetitions.
+type pdu struct {
+	Type        byte
+	RequestID   int32
+	ErrorStatus int
+	ErrorIndex  int
+	Variables   []variable
+}
+
+// marshal encodes the PDU.
+func (p *pdu) marshal() []byte {
+	var variables []byte
+	for _, v := range p.Variables {
+		var binding []byte
+		binding = appendOID(binding, v.OID)
+		if v.Type == 0 {
+			binding = appendTLV(binding, tagNull, nil)
+		} else {
+			binding = appendTLV(binding, v.Type, v.Value)
+		}
+		variables = appendTLV(variables, tagSequence, binding)
+	}
+
+	var content []byte
+	content = appendInteger(content, int64(p.RequestID))
+	content = appendInteger(content, int64(p.ErrorStatus))
+	content = appendInteger(content, int64(p.ErrorIndex))
+	content = appendTLV(content, tagSequence, variables)
+
+	return appendTLV(nil, p.Type, content)
+}
+
+// parsePDU decodes a PDU.
+func parsePDU(e element) (*pdu, error) {
+	p := &pdu{Type: e.Tag}
+
+	rest := e.Content
+	var fields [3]element
+	for i := range fields {
+		var err error
+		fields[i], rest, err = readElement(rest, tagInteger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.RequestID = int32(parseInt(fields[0].Content))
+	p.ErrorStatus = int(parseInt(fields[1].Content))
+	p.ErrorIndex = int(parseInt(fields[2].Content))
+
+	list, _, err := readElement(rest, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	rest = list.Content
+	for len(rest) > 0 {
+		var binding element
+		binding, rest, err = readElement(rest, tagSequence)
+		if err != nil {
+			return nil, err
+		}
+
+		name, value, err := readElement(binding.Content, tagOID)
+		if err != nil {
+			return nil, err
+		}
+		oid, err := parseOID(name.Content)
+		if err != nil {
+			return nil, err
+		}
+		val, _, err := readElement(value, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Variables = append(p.Variables, variable{OID: oid, Type: val.Tag, Value: val.Content})
+	}
+
+	return p, nil
+}
+
+// element is a decoded BER element. The content references the decoded buffer.
+type element struct {
+	Tag     byte
+	Content []byte
+}
+
+// readElement decodes the next element of the buffer and returns the remainder.
+// If the expected tag is not zero, elements with other tags are rejected.
+func readElement(b []byte, expected byte) (element, []byte, error) {
+	if len(b) < 2 {
+		return element{}, nil, errMalformed
+	}
+
+	tag := b[0]
+	if expected != 0 && tag != expected {
+		return element{}, nil, fmt.Errorf("%w: expected tag 0x%02x, got 0x%02x", errMalformed, expected, tag)
+	}
+
+	length := int(b[1])
+	offset := 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 || len(b) < 2+n {
+			return element{}, nil, errMalformed
+		}
+		length = 0
+		for _, c := range b[2 : 2+n] {
+			length = length<<8 | int(c)
+		}
+		offset += n
+	}
+	if length < 0 || len(b)-offset < length {
+		return element{}, nil, errMalformed
+	}
+
+	return element{Tag: tag, Content: b[offset : offset+length]}, b[offset+length:], nil
+}
+
+// appendTLV appends an element with the tag and the content to the buffer.
+func appendTLV(b []byte, tag byte, content []byte) []byte {
+	b = append(b, tag)
+
+	length := len(content)
+	switch {
+	case length < 0x80:
+		b = append(b, byte(length))
+	case length <= 0xff:
+		b = append(b, 0x81, byte(length))
+	case length <= 0xffff:
+		b = append(b, 0x82, byte(length>>8), byte(length))
+	default:
+		b = append(b, 0x84, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+	}
+
+	return append(b, content...)
+}
+
+// appendInteger appends an integer in its shortest two's complement encoding.
+func appendInteger(b []byte, v int64) []byte {
+	n := 1
+	for i := v; i > 127 || i < -128; i >>= 8 {
+		n++
+	}
+
+	content := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		content[i] = byte(v)
+		v >>= 8
+	}
+
+	return appendTLV(b, tagInteger, content)
+}
+
+// appendOctetString appends an octet string.
+func appendOctetString(b []byte, s []byte) []byte {
+	return appendTLV(b, tagOctetString, s)
+}
+
+// appendOID appends an object identifier.
+func appendOID(b []byte, oid OID) []byte {
+	var content []byte
+	if len(oid) >= 2 {
+		content = appendBase128(content, oid[0]*40+oid[1])
+		for _, arc := range oid[2:] {
+			content = appendBase128(content, arc)
+		}
+	}
+
+	return appendTLV(b, tagOID, content)
+}
+
+// appendBase128 appends an arc of an object identifier, where the most
+// significant bit of each byte indicates that another byte follows.
+func appendBase128(b []byte, v uint32) []byte {
+	var buf [5]byte
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		buf[i] = byte(v&0x7f) | 0x80
+	}
+
+	return append(b, buf[i:]...)
+}
+
+// parseInt decodes a signed integer.
+func parseInt(content []byte) int64 {
+	var v int64
+	for i, c := range content {
+		if i == 0 && c&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int64(c)
+	}
+
+	return v
+}
+
+// parseUint decodes an unsigned integer, such as a counter.
+func parseUint(content []byte) uint64 {
+	var v uint64
+	for _, c := range content {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
+
+// parseOID decodes an object identifier.
+func parseOID(content []byte) (OID, error) {
+	if len(content) == 0 {
+		return nil, errMalformed
+	}
+
+	var oid OID
+	var arc uint32
+	for i, c := range content {
+		arc = arc<<7 | uint32(c&0x7f)
+		if c&0x80 != 0 {
+			if i == len(content)-1 {
+				return nil, errMalformed
+			}
+			continue
+		}
+
+		if len(oid) == 0 {
+			first := min(arc/40, 2)
+			oid = append(oid, first, arc-first*40)
+		} else {
+			oid = append(oid, arc)
+		}
+		arc = 0
+	}
+
+	return oid, nil
+}
@@ -0,0 +1,267 @@
+package snmp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseOID(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "1.3.6.1.2.1.1.1.0", want: "1.3.6.1.2.1.1.1.0"},
+		{raw: ".1.3.6.1.4.1.8072", want: "1.3.6.1.4.1.8072"},
+		{raw: "1.3.6.1.4.1.4294967295", want: "1.3.6.1.4.1.4294967295"},
+		{raw: "1.3.6.1.4.1.4294967296", wantErr: true},
+		{raw: "1.3..6", wantErr: true},
+		{raw: "iso.3.6", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			oid, err := ParseOID(test.raw)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseOID() error = %v, want error %t", err, test.wantErr)
+			}
+			if err == nil && oid.String() != test.want {
+				t.Errorf("ParseOID() = %s, want %s", oid, test.want)
+			}
+		})
+	}
+}
+
+func TestOIDCompare(t *testing.T) {
+	tests := []struct {
+		a         string
+		b         string
+		want      int
+		hasPrefix bool
+	}{
+		{a: "1.3.6.1", b: "1.3.6.1", want: 0, hasPrefix: true},
+		{a: "1.3.6.1.2", b: "1.3.6.1", want: 1, hasPrefix: true},
+		{a: "1.3.6.1", b: "1.3.6.1.2", want: -1},
+		{a: "1.3.6.2", b: "1.3.6.10", want: -1},
+		{a: "1.3.6.10.1", b: "1.3.6.2", want: 1},
+	}
+
+	for _, test := range tests {
+		a, b := mustParseOID(test.a), mustParseOID(test.b)
+		if got := a.Compare(b); got != test.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", a, b, got, test.want)
+		}
+		if got := a.HasPrefix(b); got != test.hasPrefix {
+			t.Errorf("%s.HasPrefix(%s) = %t, want %t", a, b, got, test.hasPrefix)
+		}
+		if got := a.Equal(b); got != (test.want == 0) {
+			t.Errorf("%s.Equal(%s) = %t, want %t", a, b, got, test.want == 0)
+		}
+	}
+}
+
+func TestAppendInteger(t *testing.T) {
+	tests := []struct {
+		value int64
+		want  []byte
+	}{
+		{value: 0, want: []byte{0x02, 0x01, 0x00}},
+		{value: 127, want: []byte{0x02, 0x01, 0x7f}},
+		{value: 128, want: []byte{0x02, 0x02, 0x00, 0x80}},
+		{value: 256, want: []byte{0x02, 0x02, 0x01, 0x00}},
+		{value: -1, want: []byte{0x02, 0x01, 0xff}},
+		{value: -128, want: []byte{0x02, 0x01, 0x80}},
+		{value: -129, want: []byte{0x02, 0x02, 0xff, 0x7f}},
+		{value: 65507, want: []byte{0x02, 0x03, 0x00, 0xff, 0xe3}},
+		{value: 2147483647, want: []byte{0x02, 0x04, 0x7f, 0xff, 0xff, 0xff}},
+		{value: -2147483648, want: []byte{0x02, 0x04, 0x80, 0x00, 0x00, 0x00}},
+	}
+
+	for _, test := range tests {
+		got := appendInteger(nil, test.value)
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("appendInteger(%d) = % x, want % x", test.value, got, test.want)
+			continue
+		}
+
+		e, rest, err := readElement(got, tagInteger)
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("readElement(% x) = %v, % x, %v", got, e, rest, err)
+		}
+		if v := parseInt(e.Content); v != test.value {
+			t.Errorf("parseInt(% x) = %d, want %d", e.Content, v, test.value)
+		}
+	}
+}
+
+func TestParseUint(t *testing.T) {
+	tests := []struct {
+		content []byte
+		want    uint64
+	}{
+		{content: []byte{0x00}, want: 0},
+		// Unsigned values with the highest bit set are prefixed with a zero byte.
+		{content: []byte{0x00, 0xff, 0xff, 0xff, 0xff}, want: 4294967295},
+		// Some agents omit the zero byte, which must not flip the sign.
+		{content: []byte{0xff, 0xff, 0xff, 0xff}, want: 4294967295},
+		{content: []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, want: 1 << 56},
+	}
+
+	for _, test := range tests {
+		if got := parseUint(test.content); got != test.want {
+			t.Errorf("parseUint(% x) = %d, want %d", test.content, got, test.want)
+		}
+	}
+}
+
+func TestAppendTLVLength(t *testing.T) {
+	tests := []struct {
+		length int
+		header []byte
+	}{
+		{length: 0, header: []byte{0x04, 0x00}},
+		{length: 127, header: []byte{0x04, 0x7f}},
+		{length: 128, header: []byte{0x04, 0x81, 0x80}},
+		{length: 255, header: []byte{0x04, 0x81, 0xff}},
+		{length: 256, header: []byte{0x04, 0x82, 0x01, 0x00}},
+		{length: 65536, header: []byte{0x04, 0x84, 0x00, 0x01, 0x00, 0x00}},
+	}
+
+	for _, test := range tests {
+		content := bytes.Repeat([]byte{0xaa}, test.length)
+		got := appendTLV(nil, tagOctetString, content)
+		if !bytes.Equal(got[:len(test.header)], test.header) || len(got) != len(test.header)+test.length {
+			t.Errorf("appendTLV() with %d bytes has header % x, want % x", test.length, got[:len(test.header)], test.header)
+			continue
+		}
+
+		// The remainder after the element must be preserved.
+		e, rest, err := readElement(append(got, 0x05, 0x00), tagOctetString)
+		if err != nil {
+			t.Fatalf("readElement() with %d bytes failed: %s", test.length, err)
+		}
+		if !bytes.Equal(e.Content, content) || !bytes.Equal(rest, []byte{0x05, 0x00}) {
+			t.Errorf("readElement() with %d bytes returned %d bytes and the remainder % x", test.length, len(e.Content), rest)
+		}
+	}
+}
+
+func TestReadElementMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		b        []byte
+		expected byte
+	}{
+		{name: "empty", b: nil},
+		{name: "missing length", b: []byte{0x04}},
+		{name: "unexpected tag", b: []byte{0x02, 0x01, 0x00}, expected: tagOctetString},
+		{name: "truncated content", b: []byte{0x04, 0x03, 0x61, 0x62}},
+		{name: "truncated long length", b: []byte{0x04, 0x82, 0x01}},
+		{name: "indefinite length", b: []byte{0x30, 0x80, 0x00, 0x00}},
+		{name: "oversized length", b: []byte{0x04, 0x85, 0x00, 0x00, 0x00, 0x00, 0x01, 0x61}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := readElement(test.b, test.expected); !errors.Is(err, errMalformed) {
+				t.Errorf("readElement(% x) error = %v, want %v", test.b, err, errMalformed)
+			}
+		})
+	}
+}
+
+func TestAppendOID(t *testing.T) {
+	tests := []struct {
+		oid  string
+		want []byte
+	}{
+		{oid: "1.3.6.1.2.1.1.3.0", want: []byte{0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00}},
+		{oid: "1.3.6.1.4.1.8072.3.2.10", want: []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0xbf, 0x08, 0x03, 0x02, 0x0a}},
+		{oid: "1.3.6.1.4.1.4294967295", want: []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x8f, 0xff, 0xff, 0xff, 0x7f}},
+		{oid: "2.999.3", want: []byte{0x06, 0x03, 0x88, 0x37, 0x03}},
+	}
+
+	for _, test := range tests {
+		oid := mustParseOID(test.oid)
+		got := appendOID(nil, oid)
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("appendOID(%s) = % x, want % x", oid, got, test.want)
+			continue
+		}
+
+		parsed, err := parseOID(got[2:])
+		if err != nil {
+			t.Fatalf("parseOID(% x) failed: %s", got[2:], err)
+		}
+		if !parsed.Equal(oid) {
+			t.Errorf("parseOID(% x) = %s, want %s", got[2:], parsed, oid)
+		}
+	}
+}
+
+func TestParseOIDMalformed(t *testing.T) {
+	for _, content := range [][]byte{nil, {0x2b, 0x86}} {
+		if _, err := parseOID(content); !errors.Is(err, errMalformed) {
+			t.Errorf("parseOID(% x) error = %v, want %v", content, err, errMalformed)
+		}
+	}
+}
+
+func TestPDURoundTrip(t *testing.T) {
+	request := &pdu{
+		Type:        tagResponse,
+		RequestID:   -42,
+		ErrorStatus: 0,
+		ErrorIndex:  0,
+		Variables: []variable{
+			{OID: oidSysDescr, Type: tagOctetString, Value: []byte("Linux node-1 6.1.0 #1 SMP x86_64")},
+			{OID: oidSysObjectID, Type: tagOID, Value: appendOID(nil, mustParseOID("1.3.6.1.4.1.8072.3.2.10"))[2:]},
+			{OID: oidSysUpTime, Type: tagTimeTicks, Value: []byte{0x00, 0xff, 0xff, 0xff, 0xff}},
+			{OID: mustParseOID("1.3.6.1.2.1.1.9.0"), Type: tagNoSuchInstance},
+		},
+	}
+
+	e, rest, err := readElement(request.marshal(), 0)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("readElement() = %v, % x, %v", e, rest, err)
+	}
+	p, err := parsePDU(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Type != tagResponse || p.RequestID != -42 || len(p.Variables) != len(request.Variables) {
+		t.Fatalf("parsePDU() = %+v", p)
+	}
+	if got := p.Variables[0].String(); got != "Linux node-1 6.1.0 #1 SMP x86_64" {
+		t.Errorf("sysDescr = %q", got)
+	}
+	if got := p.Variables[1].ObjectID().String(); got != "1.3.6.1.4.1.8072.3.2.10" {
+		t.Errorf("sysObjectID = %s", got)
+	}
+	if got := p.Variables[2].Int(); got != 4294967295 {
+		t.Errorf("sysUpTime = %d", got)
+	}
+	if !p.Variables[2].Exists() || p.Variables[3].Exists() {
+		t.Error("expected only the missing instance to not exist")
+	}
+}
+
+func TestPDUMarshalNull(t *testing.T) {
+	request := &pdu{Type: tagGetRequest, RequestID: 1, Variables: []variable{{OID: oidSysUpTime}}}
+
+	want := []byte{
+		0xa0, 0x19,
+		0x02, 0x01, 0x01,
+		0x02, 0x01, 0x00,
+		0x02, 0x01, 0x00,
+		0x30, 0x0e,
+		0x30, 0x0c,
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00,
+		0x05, 0x00,
+	}
+	if got := request.marshal(); !bytes.Equal(got, want) {
+		t.Errorf("marshal() = % x, want % x", got, want)
+	}
+}
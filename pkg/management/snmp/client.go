@@ -0,0 +1,400 @@
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	// defaultPort is the default port of SNMP agents.
+	defaultPort = 161
+	// retransmitInterval is the interval after which a request is sent
+	// again, because UDP datagrams may be lost.
+	retransmitInterval = 2 * time.Second
+	// maxRepetitions is the number of variables that are requested per table walk request.
+	maxRepetitions = 25
+)
+
+var (
+	oidSysDescr    = mustParseOID("1.3.6.1.2.1.1.1.0")
+	oidSysObjectID = mustParseOID("1.3.6.1.2.1.1.2.0")
+	oidSysUpTime   = mustParseOID("1.3.6.1.2.1.1.3.0")
+)
+
+// Client monitors an appliance using SNMP. It only supports read-only
+// operations, so commands and file transfers are not supported.
+type Client struct {
+	host *mgmtv1alpha1.Host
+	opts *common.Options
+
+	// mutex serializes the requests, as the responses are matched by their ID.
+	mutex     sync.Mutex
+	conn      net.Conn
+	community string
+	usm       *usm
+	requestID int32
+}
+
+// NewClient creates a new client for a host.
+func NewClient(ctx context.Context, host *mgmtv1alpha1.Host, options ...common.Option) (common.Client, error) {
+	opts, err := common.GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		host: host,
+		opts: opts,
+	}, nil
+}
+
+// Connect connects to the host. As SNMP is connectionless, the credentials
+// are verified by requesting the uptime of the host.
+func (c *Client) Connect(ctx context.Context) error {
+	options := c.host.Spec.SNMP
+
+	credentials, err := common.ReadCredentials(ctx, c.opts, common.CredentialReference{
+		SecretRef: common.SecretReference(c.host),
+		Namespace: c.host.Namespace,
+	})
+	if err != nil {
+		return err
+	}
+	if override := c.opts.Credentials; override != nil {
+		credentials = *override
+	}
+
+	switch options.Version {
+	case mgmtv1alpha1.SNMPVersion2c, "":
+		if credentials.Community == "" {
+			return errors.New("SNMPv2c requires a community in the Secret")
+		}
+		c.community = credentials.Community
+	case mgmtv1alpha1.SNMPVersion3:
+		c.usm, err = newUSM(&options, credentials.AuthPassphrase, credentials.PrivPassphrase)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported SNMP version: %s", options.Version)
+	}
+
+	port := c.host.Spec.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	address := net.JoinHostPort(c.host.Spec.Host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{Timeout: c.opts.DialTimeout}
+	c.conn, err = dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return err
+	}
+	c.requestID = rand.Int31()
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.HandshakeTimeout)
+	defer cancel()
+
+	if c.usm != nil {
+		if err := c.discoverEngine(ctx); err != nil {
+			c.conn.Close()
+			return err
+		}
+	}
+
+	if _, err := c.get(ctx, oidSysUpTime); err != nil {
+		c.conn.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Disconnect disconnects from the host.
+func (c *Client) Disconnect() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	return c.conn.Close()
+}
+
+// Ping checks if the host still responds to requests.
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	_, err := c.get(ctx, oidSysUpTime)
+
+	return err
+}
+
+// OS probes information about the operating system of the host
+// based on the description, the object ID and the uptime of the system.
+func (c *Client) OS(ctx context.Context) (*mgmtv1alpha1.OSInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	variables, err := c.get(ctx, oidSysDescr, oidSysObjectID, oidSysUpTime)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range variables {
+		if !v.Exists() {
+			return nil, fmt.Errorf("failed to probe system: missing object: %s", v.OID)
+		}
+	}
+
+	// The uptime is measured in hundredths of a second. The boot time is
+	// truncated, so that it does not change with every probe.
+	uptime := time.Duration(variables[2].Int()) * 10 * time.Millisecond
+	bootTime := time.Now().Add(-uptime).Truncate(time.Minute)
+
+	osInfo := parseSystem(variables[0].String(), variables[1].ObjectID())
+	osInfo.BootTime = &metav1.Time{Time: bootTime}
+
+	return osInfo, nil
+}
+
+// Capabilities returns no capabilities, because the host can only be monitored.
+func (c *Client) Capabilities(ctx context.Context) (*mgmtv1alpha1.HostCapabilities, error) {
+	return &mgmtv1alpha1.HostCapabilities{}, nil
+}
+
+// Exec is not supported by SNMP.
+func (c *Client) Exec(ctx context.Context, cmd *common.Command) (*common.CommandResult, error) {
+	return nil, fmt.Errorf("%w: cannot run commands via SNMP", common.ErrNotSupported)
+}
+
+// ExecStream is not supported by SNMP.
+func (c *Client) ExecStream(ctx context.Context, cmd *common.Command, stdout io.Writer, stderr io.Writer) (*common.CommandResult, error) {
+	return nil, fmt.Errorf("%w: cannot run commands via SNMP", common.ErrNotSupported)
+}
+
+// Upload is not supported by SNMP.
+func (c *Client) Upload(ctx context.Context, path string, content io.Reader, opts *common.FileOptions) error {
+	return fmt.Errorf("%w: cannot transfer files via SNMP", common.ErrNotSupported)
+}
+
+// Download is not supported by SNMP.
+func (c *Client) Download(ctx context.Context, path string, content io.Writer) error {
+	return fmt.Errorf("%w: cannot transfer files via SNMP", common.ErrNotSupported)
+}
+
+// Stat is not supported by SNMP.
+func (c *Client) Stat(ctx context.Context, path string) (*common.FileInfo, error) {
+	return nil, fmt.Errorf("%w: cannot transfer files via SNMP", common.ErrNotSupported)
+}
+
+// discoverEngine discovers the engine ID, the engine boots and the engine
+// time of the agent, which are required to authenticate requests.
+func (c *Client) discoverEngine(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.requestID++
+	discovery := &usm{}
+	request, err := discovery.marshalMessage(c.requestID, flagReportable, "", &pdu{Type: tagGetRequest, RequestID: c.requestID})
+	if err != nil {
+		return err
+	}
+
+	msg, err := c.roundTrip(ctx, request, c.requestID, discovery.parseMessage)
+	if err != nil {
+		return fmt.Errorf("failed to discover SNMPv3 engine: %w", err)
+	}
+	if len(msg.Security.EngineID) == 0 {
+		return errors.New("failed to discover SNMPv3 engine: empty engine ID")
+	}
+	c.usm.synchronize(msg.Security)
+
+	return nil
+}
+
+// get requests the values of the variables.
+func (c *Client) get(ctx context.Context, oids ...OID) ([]variable, error) {
+	request := &pdu{Type: tagGetRequest}
+	for _, oid := range oids {
+		request.Variables = append(request.Variables, variable{OID: oid})
+	}
+
+	response, err := c.request(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Variables) != len(oids) {
+		return nil, fmt.Errorf("%w: unexpected number of variables", errMalformed)
+	}
+
+	return response.Variables, nil
+}
+
+// walk calls the function for each variable in the subtree of the root.
+func (c *Client) walk(ctx context.Context, root OID, fn func(v *variable) error) error {
+	next := root
+	for {
+		response, err := c.request(ctx, &pdu{
+			Type:       tagGetBulkRequest,
+			ErrorIndex: maxRepetitions,
+			Variables:  []variable{{OID: next}},
+		})
+		if err != nil {
+			return err
+		}
+		if len(response.Variables) == 0 {
+			return nil
+		}
+
+		for i := range response.Variables {
+			v := &response.Variables[i]
+			if !v.Exists() || !v.OID.HasPrefix(root) {
+				return nil
+			}
+			// Agents must return increasing object identifiers, otherwise the walk would not end.
+			if v.OID.Compare(next) <= 0 {
+				return fmt.Errorf("%w: object identifier %s does not increase", errMalformed, v.OID)
+			}
+			if err := fn(v); err != nil {
+				return err
+			}
+			next = v.OID
+		}
+	}
+}
+
+// request sends a request and waits for the response. SNMPv3 requests
+// are retried once if the engine time of the agent is out of sync.
+func (c *Client) request(ctx context.Context, request *pdu) (*pdu, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected: %s", c.host.Spec.Host)
+	}
+
+	for attempt := 0; ; attempt++ {
+		c.requestID++
+		request.RequestID = c.requestID
+
+		var raw []byte
+		var parse func([]byte) (*message, error)
+		if c.usm == nil {
+			raw = marshalCommunityMessage(c.community, request)
+			parse = parseCommunityMessage
+		} else {
+			var err error
+			raw, err = c.usm.marshalMessage(c.requestID, c.usm.flags(), c.host.Spec.SNMP.ContextName, request)
+			if err != nil {
+				return nil, err
+			}
+			parse = c.usm.parseMessage
+		}
+
+		msg, err := c.roundTrip(ctx, raw, c.requestID, parse)
+		if err != nil {
+			return nil, err
+		}
+
+		response := msg.PDU
+		if response.Type == tagReport {
+			if c.usm != nil && attempt == 0 && len(response.Variables) > 0 && response.Variables[0].OID.Equal(oidNotInTimeWindows) {
+				c.usm.synchronize(msg.Security)
+				continue
+			}
+			return nil, reportError(response)
+		}
+		if response.Type != tagResponse {
+			return nil, fmt.Errorf("%w: unexpected PDU type 0x%02x", errMalformed, response.Type)
+		}
+		if response.ErrorStatus != 0 {
+			return nil, fmt.Errorf("SNMP request failed with error status %d at index %d", response.ErrorStatus, response.ErrorIndex)
+		}
+
+		return response, nil
+	}
+}
+
+// roundTrip sends a message until a response with the message ID is received
+// or the context expires. Responses to earlier requests are discarded.
+func (c *Client) roundTrip(ctx context.Context, raw []byte, id int32, parse func([]byte) (*message, error)) (*message, error) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		if _, err := c.conn.Write(raw); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(retransmitInterval)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		if err := c.conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+
+		for {
+			n, err := c.conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if !errors.As(err, &netErr) || !netErr.Timeout() {
+					return nil, err
+				}
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("no response from %s: %w", c.host.Spec.Host, ctx.Err())
+				}
+				break
+			}
+
+			msg, err := parse(buf[:n])
+			if err != nil {
+				return nil, err
+			}
+			if msg.ID != id {
+				continue
+			}
+
+			return msg, nil
+		}
+	}
+}
+
+// reportError converts a report of an agent into an error.
+func reportError(report *pdu) error {
+	if len(report.Variables) == 0 {
+		return errors.New("SNMP request failed with an empty report")
+	}
+
+	oid := report.Variables[0].OID
+	if reason, ok := reportReasons[oid.String()]; ok {
+		return fmt.Errorf("SNMP request failed: %s", reason)
+	}
+
+	return fmt.Errorf("SNMP request failed with report: %s", oid)
+}
+
+// reportReasons describes the reports of the message processing
+// as defined in RFC 3412, RFC 3413 and RFC 3414.
+var reportReasons = map[string]string{
+	"1.3.6.1.6.3.11.2.1.1.0": "unknown security model",
+	"1.3.6.1.6.3.11.2.1.2.0": "invalid message",
+	"1.3.6.1.6.3.11.2.1.3.0": "unknown PDU handler",
+	"1.3.6.1.6.3.12.1.4.0":   "unavailable context",
+	"1.3.6.1.6.3.12.1.5.0":   "unknown context",
+	"1.3.6.1.6.3.15.1.1.1.0": "unsupported security level",
+	"1.3.6.1.6.3.15.1.1.2.0": "not in time window",
+	"1.3.6.1.6.3.15.1.1.3.0": "unknown user name",
+	"1.3.6.1.6.3.15.1.1.4.0": "unknown engine ID",
+	"1.3.6.1.6.3.15.1.1.5.0": "wrong digest",
+	"1.3.6.1.6.3.15.1.1.6.0": "decryption error",
+}
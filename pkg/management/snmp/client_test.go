@@ -0,0 +1,147 @@
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// staticCredentials provides the same credentials for all references.
+type staticCredentials common.Credentials
+
+// Credentials returns the credentials.
+func (s staticCredentials) Credentials(ctx context.Context, ref common.CredentialReference) (*common.Credentials, error) {
+	credentials := common.Credentials(s)
+	return &credentials, nil
+}
+
+// startSNMPD starts the Net-SNMP agent on a free local port and returns the port.
+// The test is skipped if the agent is not installed.
+func startSNMPD(t *testing.T) int {
+	t.Helper()
+
+	path, err := exec.LookPath("snmpd")
+	if err != nil {
+		t.Skip("snmpd is not installed")
+	}
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.LocalAddr().(*net.UDPAddr).Port
+	listener.Close()
+
+	dir := t.TempDir()
+	config := strings.Join([]string{
+		fmt.Sprintf("agentAddress udp:127.0.0.1:%d", port),
+		"rocommunity public 127.0.0.1",
+		`createUser kraut SHA "authpassword" AES "privpassword"`,
+		"rouser kraut priv",
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(dir, "snmpd.conf"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The agent stores the localized keys of the user in the persistent directory.
+	cmd := exec.Command(path, "-f", "-Lo", "-C", "-c", filepath.Join(dir, "snmpd.conf"))
+	cmd.Env = append(os.Environ(), "SNMP_PERSISTENT_DIR="+dir)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	return port
+}
+
+func TestClientSNMPD(t *testing.T) {
+	port := startSNMPD(t)
+
+	tests := []struct {
+		name        string
+		options     mgmtv1alpha1.HostSpecSNMPOptions
+		credentials staticCredentials
+	}{
+		{
+			name:        "SNMPv2c",
+			options:     mgmtv1alpha1.HostSpecSNMPOptions{Version: mgmtv1alpha1.SNMPVersion2c},
+			credentials: staticCredentials{Community: "public"},
+		},
+		{
+			name:        "SNMPv3",
+			options:     mgmtv1alpha1.HostSpecSNMPOptions{Version: mgmtv1alpha1.SNMPVersion3, User: "kraut"},
+			credentials: staticCredentials{AuthPassphrase: "authpassword", PrivPassphrase: "privpassword"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host := &mgmtv1alpha1.Host{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "snmpd"},
+				Spec: mgmtv1alpha1.HostSpec{
+					Host:     "127.0.0.1",
+					Port:     port,
+					Protocol: mgmtv1alpha1.ProtocolSNMP,
+					SNMP:     test.options,
+				},
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			client, err := NewClient(ctx, host, common.WithCredentialSource(test.credentials))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The agent needs a moment to start listening.
+			for {
+				err = client.Connect(ctx)
+				if err == nil || ctx.Err() != nil {
+					break
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Disconnect()
+
+			osInfo, err := client.OS(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if osInfo.ID != "linux" || osInfo.KernelVersion == "" || osInfo.BootTime == nil {
+				t.Errorf("OS() = %+v", osInfo)
+			}
+
+			interfaces, err := client.(*Client).Interfaces(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, iface := range interfaces {
+				if iface.Type == "softwareLoopback" {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Interfaces() = %+v, want a loopback interface", interfaces)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// versionV2c is the version number of SNMPv2c messages.
+	versionV2c = 1
+	// versionV3 is the version number of SNMPv3 messages.
+	versionV3 = 3
+	// maxMessageSize is the maximum size of a message that can be received.
+	maxMessageSize = 65507
+)
+
+// marshalCommunityMessage encodes an SNMPv2c message.
+func marshalCommunityMessage(community string, p *pdu) []byte {
+	var content []byte
+	content = appendInteger(content, versionV2c)
+	content = appendOctetString(content, []byte(community))
+	content = append(content, p.marshal()...)
+
+	return appendTLV(nil, tagSequence, content)
+}
+
+// marshalMessage encodes an SNMPv3 message with the user-based
+// security model, which is authenticated and encrypted as configured.
+func (s *usm) marshalMessage(msgID int32, flags byte, contextName string, p *pdu) ([]byte, error) {
+	var scoped []byte
+	scoped = appendOctetString(scoped, s.engineID)
+	scoped = appendOctetString(scoped, []byte(contextName))
+	scoped = append(scoped, p.marshal()...)
+	scopedPDU := appendTLV(nil, tagSequence, scoped)
+
+	engineBoots, engineTime := s.time()
+	params := &securityParameters{
+		EngineID:    s.engineID,
+		EngineBoots: engineBoots,
+		EngineTime:  engineTime,
+		User:        s.user,
+	}
+
+	data := scopedPDU
+	if flags&flagPriv != 0 {
+		ciphertext, salt, err := s.encrypt(scopedPDU, engineBoots, engineTime)
+		if err != nil {
+			return nil, err
+		}
+		params.PrivParameters = salt
+		data = appendOctetString(nil, ciphertext)
+	}
+
+	encode := func() []byte {
+		var header []byte
+		header = appendInteger(header, int64(msgID))
+		header = appendInteger(header, maxMessageSize)
+		header = appendOctetString(header, []byte{flags})
+		header = appendInteger(header, securityModelUSM)
+
+		var content []byte
+		content = appendInteger(content, versionV3)
+		content = appendTLV(content, tagSequence, header)
+		content = appendOctetString(content, params.marshal())
+		content = append(content, data...)
+
+		return appendTLV(nil, tagSequence, content)
+	}
+
+	if flags&flagAuth == 0 {
+		return encode(), nil
+	}
+
+	// The message authentication code is computed over the whole message, whose
+	// authentication parameters are filled with zeros of the same length.
+	params.AuthParameters = make([]byte, s.auth.macLength)
+	params.AuthParameters = s.sign(encode())
+
+	return encode(), nil
+}
+
+// message is a decoded response of an agent.
+type message struct {
+	// ID is the message ID of an SNMPv3 message
+	// or the request ID of an SNMPv2c message.
+	ID int32
+	// Flags are the flags of an SNMPv3 message.
+	Flags byte
+	// Security are the security parameters of an SNMPv3 message.
+	Security *securityParameters
+	// PDU is the decrypted PDU.
+	PDU *pdu
+}
+
+// parseCommunityMessage decodes an SNMPv2c message.
+func parseCommunityMessage(b []byte) (*message, error) {
+	seq, _, err := readElement(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	version, rest, err := readElement(seq.Content, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	if parseInt(version.Content) != versionV2c {
+		return nil, fmt.Errorf("%w: unexpected version", errMalformed)
+	}
+	_, rest, err = readElement(rest, tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := readElement(rest, 0)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parsePDU(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// SNMPv2c messages are only identified by the request ID.
+	return &message{ID: p.RequestID, PDU: p}, nil
+}
+
+// parseMessage decodes an SNMPv3 message. Messages with authentication are
+// verified and decrypted, unless they are reports for the engine discovery.
+func (s *usm) parseMessage(b []byte) (*message, error) {
+	seq, _, err := readElement(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	version, rest, err := readElement(seq.Content, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	if parseInt(version.Content) != versionV3 {
+		return nil, fmt.Errorf("%w: unexpected version", errMalformed)
+	}
+
+	header, rest, err := readElement(rest, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	msgID, headerRest, err := readElement(header.Content, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	_, headerRest, err = readElement(headerRest, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	flags, _, err := readElement(headerRest, tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	if len(flags.Content) != 1 {
+		return nil, fmt.Errorf("%w: invalid flags", errMalformed)
+	}
+
+	rawParams, rest, err := readElement(rest, tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	params, err := parseSecurityParameters(rawParams.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &message{
+		ID:       int32(parseInt(msgID.Content)),
+		Flags:    flags.Content[0],
+		Security: params,
+	}
+
+	if msg.Flags&flagAuth != 0 {
+		if s.auth == nil || !s.discovered() {
+			return nil, errors.New("failed to authenticate SNMPv3 message: unexpected authentication")
+		}
+		if err := s.verify(b, params.AuthParameters); err != nil {
+			return nil, err
+		}
+	}
+
+	data, _, err := readElement(rest, 0)
+	if err != nil {
+		return nil, err
+	}
+	scopedPDU := data.Content
+	if msg.Flags&flagPriv != 0 {
+		if data.Tag != tagOctetString || s.privKey == nil {
+			return nil, fmt.Errorf("%w: unexpected encryption", errMalformed)
+		}
+		plaintext, err := s.decrypt(data.Content, params)
+		if err != nil {
+			return nil, err
+		}
+		data, _, err = readElement(plaintext, tagSequence)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt SNMPv3 message: %w", err)
+		}
+		scopedPDU = data.Content
+	} else if data.Tag != tagSequence {
+		return nil, fmt.Errorf("%w: unexpected scoped PDU", errMalformed)
+	}
+
+	_, scopedRest, err := readElement(scopedPDU, tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	_, scopedRest, err = readElement(scopedRest, tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	pduElement, _, err := readElement(scopedRest, 0)
+	if err != nil {
+		return nil, err
+	}
+	msg.PDU, err = parsePDU(pduElement)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,172 @@
+package snmp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+func TestCommunityMessage(t *testing.T) {
+	raw := marshalCommunityMessage("public", &pdu{
+		Type:      tagResponse,
+		RequestID: 1234,
+		Variables: []variable{{OID: oidSysUpTime, Type: tagTimeTicks, Value: []byte{0x01, 0x00}}},
+	})
+
+	msg, err := parseCommunityMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != 1234 || msg.PDU.Type != tagResponse {
+		t.Errorf("parseCommunityMessage() = %+v", msg)
+	}
+	if len(msg.PDU.Variables) != 1 || msg.PDU.Variables[0].Int() != 256 {
+		t.Errorf("variables = %+v", msg.PDU.Variables)
+	}
+}
+
+func TestParseCommunityMessageMalformed(t *testing.T) {
+	valid := marshalCommunityMessage("public", &pdu{Type: tagResponse, RequestID: 1})
+
+	// An SNMPv1 message differs only in the version.
+	var v1 []byte
+	v1 = appendInteger(v1, 0)
+	v1 = appendOctetString(v1, []byte("public"))
+	v1 = append(v1, (&pdu{Type: tagResponse, RequestID: 1}).marshal()...)
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{name: "truncated", raw: valid[:len(valid)-1]},
+		{name: "SNMPv1", raw: appendTLV(nil, tagSequence, v1)},
+		{name: "missing PDU", raw: appendTLV(nil, tagSequence, appendOctetString(appendInteger(nil, versionV2c), []byte("public")))},
+		{name: "not a sequence", raw: appendOctetString(nil, valid)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseCommunityMessage(test.raw); !errors.Is(err, errMalformed) {
+				t.Errorf("parseCommunityMessage() error = %v, want %v", err, errMalformed)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      mgmtv1alpha1.HostSpecSNMPOptions
+		authPassword string
+		privPassword string
+	}{
+		{name: "noAuthNoPriv"},
+		{name: "authNoPriv", options: mgmtv1alpha1.HostSpecSNMPOptions{AuthProtocol: mgmtv1alpha1.SNMPAuthProtocolSHA512}, authPassword: "authpassword"},
+		{name: "authPriv AES", authPassword: "authpassword", privPassword: "privpassword"},
+		{name: "authPriv DES", options: mgmtv1alpha1.HostSpecSNMPOptions{AuthProtocol: mgmtv1alpha1.SNMPAuthProtocolMD5, PrivProtocol: mgmtv1alpha1.SNMPPrivProtocolDES}, authPassword: "authpassword", privPassword: "privpassword"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestUSM(t, test.options, test.authPassword, test.privPassword)
+			request := &pdu{
+				Type:      tagResponse,
+				RequestID: 99,
+				Variables: []variable{{OID: oidSysDescr, Type: tagOctetString, Value: []byte("Cisco NX-OS(tm) nxos.9.3.10.bin")}},
+			}
+
+			raw, err := s.marshalMessage(42, s.flags(), "vrf-management", request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.privPassword != "" && bytes.Contains(raw, []byte("NX-OS")) {
+				t.Error("expected the scoped PDU to be encrypted")
+			}
+
+			msg, err := s.parseMessage(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.ID != 42 || msg.Flags != s.flags() {
+				t.Errorf("message ID = %d, flags = 0x%02x", msg.ID, msg.Flags)
+			}
+			if msg.Security.User != "kraut" || !bytes.Equal(msg.Security.EngineID, testEngineID) || msg.Security.EngineBoots != 7 {
+				t.Errorf("security parameters = %+v", msg.Security)
+			}
+			if msg.PDU.RequestID != 99 || len(msg.PDU.Variables) != 1 || msg.PDU.Variables[0].String() != "Cisco NX-OS(tm) nxos.9.3.10.bin" {
+				t.Errorf("PDU = %+v", msg.PDU)
+			}
+		})
+	}
+}
+
+func TestMessageDiscovery(t *testing.T) {
+	// The agent reports its engine to an unauthenticated request of the discovery.
+	agent := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{}, "", "")
+	report, err := agent.marshalMessage(7, 0, "", &pdu{
+		Type:      tagReport,
+		RequestID: 7,
+		Variables: []variable{{OID: mustParseOID("1.3.6.1.6.3.15.1.1.4.0"), Type: tagCounter32, Value: []byte{0x01}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discovery := &usm{}
+	msg, err := discovery.parseMessage(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg.Security.EngineID, testEngineID) || msg.PDU.Type != tagReport {
+		t.Errorf("parseMessage() = %+v", msg)
+	}
+	if err := reportError(msg.PDU); err == nil || err.Error() != "SNMP request failed: unknown engine ID" {
+		t.Errorf("reportError() = %v", err)
+	}
+
+	// Authenticated messages cannot be verified before the engine is discovered.
+	s := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{}, "authpassword", "")
+	authenticated, err := s.marshalMessage(8, s.flags(), "", &pdu{Type: tagResponse, RequestID: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	undiscovered := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{}, "authpassword", "")
+	undiscovered.engineID = nil
+	if _, err := undiscovered.parseMessage(authenticated); err == nil {
+		t.Error("expected authenticated message to be rejected before the discovery")
+	}
+}
+
+func TestParseMessageMalformed(t *testing.T) {
+	s := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{}, "", "")
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{name: "SNMPv2c", raw: marshalCommunityMessage("public", &pdu{Type: tagResponse})},
+		{name: "empty", raw: nil},
+		{
+			// The privacy flag requires an encrypted scoped PDU.
+			name: "unexpected encryption",
+			raw: func() []byte {
+				raw, err := s.marshalMessage(1, 0, "", &pdu{Type: tagResponse})
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Set the privacy flag, which follows the maximum message size.
+				return bytes.Replace(raw, []byte{0xff, 0xe3, 0x04, 0x01, 0x00}, []byte{0xff, 0xe3, 0x04, 0x01, flagPriv}, 1)
+			}(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := s.parseMessage(test.raw); !errors.Is(err, errMalformed) {
+				t.Errorf("parseMessage() error = %v, want %v", err, errMalformed)
+			}
+		})
+	}
+}
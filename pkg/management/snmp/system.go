@@ -0,0 +1,230 @@
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+var (
+	// oidEnterprises is the prefix of the object IDs that are assigned to vendors.
+	oidEnterprises = mustParseOID("1.3.6.1.4.1")
+	// oidIfEntry is the prefix of the columns of the interface table (IF-MIB::ifEntry).
+	oidIfEntry = mustParseOID("1.3.6.1.2.1.2.2.1")
+	// oidIfXEntry is the prefix of the columns of the extended interface table (IF-MIB::ifXEntry).
+	oidIfXEntry = mustParseOID("1.3.6.1.2.1.31.1.1.1")
+)
+
+// The columns of the interface tables.
+const (
+	ifDescr       = 2
+	ifType        = 3
+	ifMtu         = 4
+	ifSpeed       = 5
+	ifPhysAddress = 6
+	ifAdminStatus = 7
+	ifOperStatus  = 8
+
+	ifName      = 1
+	ifHighSpeed = 15
+	ifAlias     = 18
+)
+
+// versionPattern matches the software version in a system description,
+// such as `Version 9.3(10)` or `Version 15.2(4)E10,`.
+var versionPattern = regexp.MustCompile(`(?i)\bversion:?\s+v?([0-9][^\s,;]*)`)
+
+// vendors maps the private enterprise numbers of common vendors to their names.
+var vendors = map[uint32]string{
+	9:     "Cisco",
+	11:    "HPE",
+	318:   "APC",
+	534:   "Eaton",
+	674:   "Dell",
+	1991:  "Brocade",
+	2011:  "Huawei",
+	2636:  "Juniper",
+	3375:  "F5",
+	6527:  "Nokia",
+	6876:  "VMware",
+	8072:  "Net-SNMP",
+	12356: "Fortinet",
+	14988: "MikroTik",
+	25461: "Palo Alto Networks",
+	30065: "Arista",
+	41112: "Ubiquiti",
+}
+
+// ifTypes maps common IANA interface types to their names.
+var ifTypes = map[int64]string{
+	1:   "other",
+	6:   "ethernetCsmacd",
+	23:  "ppp",
+	24:  "softwareLoopback",
+	53:  "propVirtual",
+	62:  "fastEther",
+	71:  "ieee80211",
+	117: "gigabitEthernet",
+	131: "tunnel",
+	135: "l2vlan",
+	136: "l3ipvlan",
+	161: "ieee8023adLag",
+	166: "mpls",
+	209: "bridge",
+}
+
+// ifStatuses maps the values of the status columns of the interface table to their names.
+var ifStatuses = map[int64]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+	4: "unknown",
+	5: "dormant",
+	6: "notPresent",
+	7: "lowerLayerDown",
+}
+
+// parseSystem derives information about the operating system from the
+// description and the object ID of the system. The family is only detected
+// for NX-OS, as the distribution of other systems cannot be determined.
+func parseSystem(description string, objectID OID) *mgmtv1alpha1.OSInfo {
+	info := &mgmtv1alpha1.OSInfo{
+		Name:        "Unknown",
+		Family:      mgmtv1alpha1.OSFamilyUnknown,
+		Description: description,
+		ObjectID:    objectID.String(),
+	}
+
+	if match := versionPattern.FindStringSubmatch(description); match != nil {
+		info.Version = mgmtv1alpha1.OSVersion(match[1])
+	}
+
+	fields := strings.Fields(description)
+	switch {
+	case strings.Contains(description, "NX-OS"):
+		info.ID = "nexus"
+		info.Name = mgmtv1alpha1.OSNXOS
+		info.Family = mgmtv1alpha1.OSFamilyNXOS
+	case len(fields) >= 3 && fields[0] == "Linux":
+		// The description of Linux hosts is the output of `uname -a`.
+		info.ID = "linux"
+		info.Name = "Linux"
+		info.KernelVersion = fields[2]
+	case objectID.HasPrefix(oidEnterprises) && len(objectID) > len(oidEnterprises):
+		enterprise := objectID[len(oidEnterprises)]
+		info.ID = strconv.FormatUint(uint64(enterprise), 10)
+		if vendor, ok := vendors[enterprise]; ok {
+			info.Name = vendor
+		}
+	}
+
+	return info
+}
+
+// Interfaces returns the network interfaces of the host from the interface
+// tables. Counters are not collected, as they change with every probe.
+func (c *Client) Interfaces(ctx context.Context) ([]mgmtv1alpha1.HostInterface, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
+
+	interfaces := make(map[int]*mgmtv1alpha1.HostInterface)
+	highSpeeds := make(map[int]int64)
+	row := func(index uint32) *mgmtv1alpha1.HostInterface {
+		iface, ok := interfaces[int(index)]
+		if !ok {
+			iface = &mgmtv1alpha1.HostInterface{Index: int(index)}
+			interfaces[int(index)] = iface
+		}
+		return iface
+	}
+
+	// Walking each column separately keeps the responses small.
+	for _, column := range []uint32{ifDescr, ifType, ifMtu, ifSpeed, ifPhysAddress, ifAdminStatus, ifOperStatus} {
+		prefix := append(slices.Clone(oidIfEntry), column)
+		err := c.walk(ctx, prefix, func(v *variable) error {
+			if len(v.OID) != len(prefix)+1 {
+				return nil
+			}
+			iface := row(v.OID[len(prefix)])
+
+			switch column {
+			case ifDescr:
+				iface.Name = v.String()
+			case ifType:
+				iface.Type = ifTypes[v.Int()]
+				if iface.Type == "" {
+					iface.Type = strconv.FormatInt(v.Int(), 10)
+				}
+			case ifMtu:
+				iface.MTU = int(v.Int())
+			case ifSpeed:
+				iface.Speed = v.Int()
+			case ifPhysAddress:
+				if len(v.Value) > 0 {
+					iface.MACAddress = net.HardwareAddr(v.Value).String()
+				}
+			case ifAdminStatus:
+				iface.AdminStatus = ifStatuses[v.Int()]
+			case ifOperStatus:
+				iface.OperStatus = ifStatuses[v.Int()]
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk interface table: %w", err)
+		}
+	}
+
+	// The extended table is optional and only amends known interfaces.
+	for _, column := range []uint32{ifName, ifHighSpeed, ifAlias} {
+		prefix := append(slices.Clone(oidIfXEntry), column)
+		err := c.walk(ctx, prefix, func(v *variable) error {
+			if len(v.OID) != len(prefix)+1 {
+				return nil
+			}
+			iface, ok := interfaces[int(v.OID[len(prefix)])]
+			if !ok {
+				return nil
+			}
+
+			switch column {
+			case ifName:
+				if name := v.String(); name != "" {
+					iface.Name = name
+				}
+			case ifHighSpeed:
+				highSpeeds[iface.Index] = v.Int()
+			case ifAlias:
+				iface.Description = v.String()
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk extended interface table: %w", err)
+		}
+	}
+
+	result := make([]mgmtv1alpha1.HostInterface, 0, len(interfaces))
+	for _, iface := range interfaces {
+		// The speed in bits per second saturates at 4.294 Gbit/s,
+		// so the speed in Mbit/s is used for faster interfaces.
+		if iface.Speed == math.MaxUint32 && highSpeeds[iface.Index] > 0 {
+			iface.Speed = highSpeeds[iface.Index] * 1000000
+		}
+		result = append(result, *iface)
+	}
+	slices.SortFunc(result, func(a, b mgmtv1alpha1.HostInterface) int {
+		return a.Index - b.Index
+	})
+
+	return result, nil
+}
@@ -0,0 +1,379 @@
+package snmp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+const (
+	// securityModelUSM is the identifier of the user-based security model.
+	securityModelUSM = 3
+
+	// The flags of an SNMPv3 message.
+	flagAuth       = 0x01
+	flagPriv       = 0x02
+	flagReportable = 0x04
+)
+
+// oidNotInTimeWindows is reported if the engine time of a request is out of sync.
+var oidNotInTimeWindows = mustParseOID("1.3.6.1.6.3.15.1.1.2.0")
+
+// authProtocol describes an authentication protocol of the user-based
+// security model as defined in RFC 3414 and RFC 7860.
+type authProtocol struct {
+	hash func() hash.Hash
+	// macLength is the length of the truncated message authentication code.
+	macLength int
+}
+
+// authProtocols are the supported authentication protocols.
+var authProtocols = map[mgmtv1alpha1.SNMPAuthProtocol]authProtocol{
+	mgmtv1alpha1.SNMPAuthProtocolMD5:    {hash: md5.New, macLength: 12},
+	mgmtv1alpha1.SNMPAuthProtocolSHA:    {hash: sha1.New, macLength: 12},
+	mgmtv1alpha1.SNMPAuthProtocolSHA224: {hash: sha256.New224, macLength: 16},
+	mgmtv1alpha1.SNMPAuthProtocolSHA256: {hash: sha256.New, macLength: 24},
+	mgmtv1alpha1.SNMPAuthProtocolSHA384: {hash: sha512.New384, macLength: 32},
+	mgmtv1alpha1.SNMPAuthProtocolSHA512: {hash: sha512.New, macLength: 48},
+}
+
+// usm implements the user-based security model of SNMPv3 for a single user
+// and the authoritative engine of the agent.
+type usm struct {
+	user         string
+	auth         *authProtocol
+	authPassword string
+	privProtocol mgmtv1alpha1.SNMPPrivProtocol
+	privPassword string
+
+	engineID    []byte
+	engineBoots int32
+	engineTime  int32
+	// syncedAt is the local time at which the engine time was received.
+	syncedAt time.Time
+
+	authKey []byte
+	privKey []byte
+	salt    uint64
+}
+
+// newUSM creates the security model for a user. Messages are authenticated
+// if an authentication password is given and encrypted if a privacy
+// password is given in addition.
+func newUSM(options *mgmtv1alpha1.HostSpecSNMPOptions, authPassword string, privPassword string) (*usm, error) {
+	if options.User == "" {
+		return nil, errors.New("SNMPv3 requires a user")
+	}
+	if authPassword == "" && privPassword != "" {
+		return nil, errors.New("SNMPv3 privacy requires an authentication passphrase")
+	}
+
+	s := &usm{
+		user:         options.User,
+		authPassword: authPassword,
+		privProtocol: options.PrivProtocol,
+		privPassword: privPassword,
+	}
+
+	if authPassword != "" {
+		name := options.AuthProtocol
+		if name == "" {
+			name = mgmtv1alpha1.SNMPAuthProtocolSHA
+		}
+		auth, ok := authProtocols[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported SNMPv3 authentication protocol: %s", name)
+		}
+		s.auth = &auth
+	}
+
+	if privPassword != "" {
+		switch s.privProtocol {
+		case "":
+			s.privProtocol = mgmtv1alpha1.SNMPPrivProtocolAES
+		case mgmtv1alpha1.SNMPPrivProtocolAES, mgmtv1alpha1.SNMPPrivProtocolDES:
+		default:
+			return nil, fmt.Errorf("unsupported SNMPv3 privacy protocol: %s", s.privProtocol)
+		}
+	}
+
+	// The salt only needs to be unique, so a random start avoids
+	// reusing salts after a reconnect with the same engine boots.
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	s.salt = binary.BigEndian.Uint64(seed[:])
+
+	return s, nil
+}
+
+// flags returns the flags of the messages of the user.
+func (s *usm) flags() byte {
+	flags := byte(flagReportable)
+	if s.auth != nil {
+		flags |= flagAuth
+	}
+	if s.privPassword != "" {
+		flags |= flagPriv
+	}
+
+	return flags
+}
+
+// discovered returns whether the authoritative engine is known.
+func (s *usm) discovered() bool {
+	return len(s.engineID) > 0
+}
+
+// synchronize updates the engine of the agent. The keys are localized
+// on the first discovery, as they depend on the engine ID.
+func (s *usm) synchronize(params *securityParameters) {
+	if string(s.engineID) != string(params.EngineID) {
+		// The parameters reference the receive buffer, which is reused.
+		s.engineID = bytes.Clone(params.EngineID)
+		s.localizeKeys()
+	}
+	s.engineBoots = params.EngineBoots
+	s.engineTime = params.EngineTime
+	s.syncedAt = time.Now()
+}
+
+// localizeKeys derives the keys of the user for the engine of the agent.
+func (s *usm) localizeKeys() {
+	if s.auth == nil {
+		return
+	}
+
+	s.authKey = localizeKey(s.auth.hash, passwordToKey(s.auth.hash, s.authPassword), s.engineID)
+	if s.privPassword != "" {
+		s.privKey = localizeKey(s.auth.hash, passwordToKey(s.auth.hash, s.privPassword), s.engineID)
+	}
+}
+
+// time returns the current engine boots and engine time of the agent.
+func (s *usm) time() (int32, int32) {
+	if s.syncedAt.IsZero() {
+		return s.engineBoots, s.engineTime
+	}
+
+	return s.engineBoots, s.engineTime + int32(time.Since(s.syncedAt)/time.Second)
+}
+
+// securityParameters are the security parameters of an SNMPv3 message.
+type securityParameters struct {
+	EngineID       []byte
+	EngineBoots    int32
+	EngineTime     int32
+	User           string
+	AuthParameters []byte
+	PrivParameters []byte
+}
+
+// marshal encodes the security parameters.
+func (p *securityParameters) marshal() []byte {
+	var content []byte
+	content = appendOctetString(content, p.EngineID)
+	content = appendInteger(content, int64(p.EngineBoots))
+	content = appendInteger(content, int64(p.EngineTime))
+	content = appendOctetString(content, []byte(p.User))
+	content = appendOctetString(content, p.AuthParameters)
+	content = appendOctetString(content, p.PrivParameters)
+
+	return appendTLV(nil, tagSequence, content)
+}
+
+// parseSecurityParameters decodes the security parameters.
+func parseSecurityParameters(b []byte) (*securityParameters, error) {
+	seq, _, err := readElement(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields [6]element
+	rest := seq.Content
+	for i, tag := range []byte{tagOctetString, tagInteger, tagInteger, tagOctetString, tagOctetString, tagOctetString} {
+		fields[i], rest, err = readElement(rest, tag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &securityParameters{
+		EngineID:       fields[0].Content,
+		EngineBoots:    int32(parseInt(fields[1].Content)),
+		EngineTime:     int32(parseInt(fields[2].Content)),
+		User:           string(fields[3].Content),
+		AuthParameters: fields[4].Content,
+		PrivParameters: fields[5].Content,
+	}, nil
+}
+
+// sign computes the message authentication code of a whole message, whose
+// authentication parameters are filled with zeros.
+func (s *usm) sign(message []byte) []byte {
+	mac := hmac.New(s.auth.hash, s.authKey)
+	mac.Write(message)
+
+	return mac.Sum(nil)[:s.auth.macLength]
+}
+
+// verify verifies the message authentication code of a received message.
+// The authentication parameters must reference the message buffer.
+func (s *usm) verify(message []byte, authParameters []byte) error {
+	if len(authParameters) != s.auth.macLength {
+		return errors.New("failed to authenticate SNMPv3 message: invalid authentication parameters")
+	}
+
+	// The authentication parameters are a slice of the message buffer,
+	// so their offset can be derived from the remaining capacity.
+	offset := cap(message) - cap(authParameters)
+	if offset < 0 || offset+len(authParameters) > len(message) {
+		return errors.New("failed to authenticate SNMPv3 message: invalid authentication parameters")
+	}
+	zeroed := make([]byte, len(message))
+	copy(zeroed, message)
+	clear(zeroed[offset : offset+len(authParameters)])
+
+	if !hmac.Equal(s.sign(zeroed), authParameters) {
+		return errors.New("failed to authenticate SNMPv3 message: wrong digest")
+	}
+
+	return nil
+}
+
+// encrypt encrypts a scoped PDU and returns the privacy parameters.
+func (s *usm) encrypt(plaintext []byte, engineBoots int32, engineTime int32) ([]byte, []byte, error) {
+	s.salt++
+
+	switch s.privProtocol {
+	case mgmtv1alpha1.SNMPPrivProtocolDES:
+		block, err := des.NewCipher(s.privKey[:8])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The salt consists of the engine boots and a local counter.
+		salt := make([]byte, 8)
+		binary.BigEndian.PutUint32(salt[:4], uint32(engineBoots))
+		binary.BigEndian.PutUint32(salt[4:], uint32(s.salt))
+		iv := make([]byte, 8)
+		for i := range iv {
+			iv[i] = s.privKey[8+i] ^ salt[i]
+		}
+
+		// The padding is ignored by the receiver, because the scoped PDU is length-prefixed.
+		padded := make([]byte, (len(plaintext)+7)/8*8)
+		copy(padded, plaintext)
+		ciphertext := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+		return ciphertext, salt, nil
+	default:
+		salt := binary.BigEndian.AppendUint64(nil, s.salt)
+		stream, err := s.aesStream(salt, engineBoots, engineTime, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		ciphertext := make([]byte, len(plaintext))
+		stream.XORKeyStream(ciphertext, plaintext)
+
+		return ciphertext, salt, nil
+	}
+}
+
+// decrypt decrypts a scoped PDU with the privacy parameters of the message.
+func (s *usm) decrypt(ciphertext []byte, params *securityParameters) ([]byte, error) {
+	if len(params.PrivParameters) != 8 {
+		return nil, errors.New("failed to decrypt SNMPv3 message: invalid privacy parameters")
+	}
+
+	switch s.privProtocol {
+	case mgmtv1alpha1.SNMPPrivProtocolDES:
+		if len(ciphertext)%8 != 0 {
+			return nil, errors.New("failed to decrypt SNMPv3 message: invalid length")
+		}
+		block, err := des.NewCipher(s.privKey[:8])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, 8)
+		for i := range iv {
+			iv[i] = s.privKey[8+i] ^ params.PrivParameters[i]
+		}
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+		return plaintext, nil
+	default:
+		stream, err := s.aesStream(params.PrivParameters, params.EngineBoots, params.EngineTime, false)
+		if err != nil {
+			return nil, err
+		}
+		plaintext := make([]byte, len(ciphertext))
+		stream.XORKeyStream(plaintext, ciphertext)
+
+		return plaintext, nil
+	}
+}
+
+// aesStream returns the CFB128-AES-128 stream as defined in RFC 3826,
+// whose IV consists of the engine boots, the engine time and the salt.
+func (s *usm) aesStream(salt []byte, engineBoots int32, engineTime int32, encrypt bool) (cipher.Stream, error) {
+	block, err := aes.NewCipher(s.privKey[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, 0, 16)
+	iv = binary.BigEndian.AppendUint32(iv, uint32(engineBoots))
+	iv = binary.BigEndian.AppendUint32(iv, uint32(engineTime))
+	iv = append(iv, salt...)
+
+	if encrypt {
+		return cipher.NewCFBEncrypter(block, iv), nil
+	}
+
+	return cipher.NewCFBDecrypter(block, iv), nil
+}
+
+// passwordToKey derives the key of a user from a password by hashing
+// one megabyte of the repeated password as defined in RFC 3414.
+func passwordToKey(newHash func() hash.Hash, password string) []byte {
+	const length = 1024 * 1024
+
+	h := newHash()
+	chunk := make([]byte, 64)
+	for i := 0; i < length; i += len(chunk) {
+		for j := range chunk {
+			chunk[j] = password[(i+j)%len(password)]
+		}
+		h.Write(chunk)
+	}
+
+	return h.Sum(nil)
+}
+
+// localizeKey binds the key of a user to the engine ID of an agent.
+func localizeKey(newHash func() hash.Hash, key []byte, engineID []byte) []byte {
+	h := newHash()
+	h.Write(key)
+	h.Write(engineID)
+	h.Write(key)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,247 @@
+package snmp
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"testing"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+)
+
+// testEngineID is the engine ID of the key localization examples of RFC 3414.
+var testEngineID = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+
+// newTestUSM creates the security model for a user whose engine has been discovered.
+func newTestUSM(t *testing.T, options mgmtv1alpha1.HostSpecSNMPOptions, authPassword string, privPassword string) *usm {
+	t.Helper()
+
+	options.User = "kraut"
+	s, err := newUSM(&options, authPassword, privPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.synchronize(&securityParameters{EngineID: testEngineID, EngineBoots: 7, EngineTime: 1000})
+
+	return s
+}
+
+func TestPasswordToKey(t *testing.T) {
+	// The examples are taken from appendix A.3 of RFC 3414.
+	tests := []struct {
+		name      string
+		hash      func() hash.Hash
+		key       string
+		localized string
+	}{
+		{
+			name:      "MD5",
+			hash:      md5.New,
+			key:       "9faf3283884e92834ebc9847d8edd963",
+			localized: "526f5eed9fcce26f8964c2930787d82b",
+		},
+		{
+			name:      "SHA",
+			hash:      sha1.New,
+			key:       "9fb5cc0381497b3793528939ff788d5d79145211",
+			localized: "6695febc9288e36282235fc7151f128497b38f3f",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := passwordToKey(test.hash, "maplesyrup")
+			if got := hex.EncodeToString(key); got != test.key {
+				t.Errorf("passwordToKey() = %s, want %s", got, test.key)
+			}
+			if got := hex.EncodeToString(localizeKey(test.hash, key, testEngineID)); got != test.localized {
+				t.Errorf("localizeKey() = %s, want %s", got, test.localized)
+			}
+		})
+	}
+}
+
+func TestNewUSM(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      mgmtv1alpha1.HostSpecSNMPOptions
+		authPassword string
+		privPassword string
+		wantFlags    byte
+		wantErr      bool
+	}{
+		{
+			name:      "no authentication",
+			options:   mgmtv1alpha1.HostSpecSNMPOptions{User: "kraut"},
+			wantFlags: flagReportable,
+		},
+		{
+			name:         "authentication",
+			options:      mgmtv1alpha1.HostSpecSNMPOptions{User: "kraut", AuthProtocol: mgmtv1alpha1.SNMPAuthProtocolSHA256},
+			authPassword: "authpassword",
+			wantFlags:    flagReportable | flagAuth,
+		},
+		{
+			name:         "privacy",
+			options:      mgmtv1alpha1.HostSpecSNMPOptions{User: "kraut"},
+			authPassword: "authpassword",
+			privPassword: "privpassword",
+			wantFlags:    flagReportable | flagAuth | flagPriv,
+		},
+		{
+			name:    "missing user",
+			wantErr: true,
+		},
+		{
+			name:         "privacy without authentication",
+			options:      mgmtv1alpha1.HostSpecSNMPOptions{User: "kraut"},
+			privPassword: "privpassword",
+			wantErr:      true,
+		},
+		{
+			name:         "unsupported authentication protocol",
+			options:      mgmtv1alpha1.HostSpecSNMPOptions{User: "kraut", AuthProtocol: "SHA3"},
+			authPassword: "authpassword",
+			wantErr:      true,
+		},
+		{
+			name:         "unsupported privacy protocol",
+			options:      mgmtv1alpha1.HostSpecSNMPOptions{User: "kraut", PrivProtocol: "AES256"},
+			authPassword: "authpassword",
+			privPassword: "privpassword",
+			wantErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := newUSM(&test.options, test.authPassword, test.privPassword)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("newUSM() error = %v, want error %t", err, test.wantErr)
+			}
+			if err == nil && s.flags() != test.wantFlags {
+				t.Errorf("flags() = 0x%02x, want 0x%02x", s.flags(), test.wantFlags)
+			}
+		})
+	}
+}
+
+func TestUSMLocalizesKeys(t *testing.T) {
+	s := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{AuthProtocol: mgmtv1alpha1.SNMPAuthProtocolMD5}, "maplesyrup", "maplesyrup")
+
+	want := "526f5eed9fcce26f8964c2930787d82b"
+	if got := hex.EncodeToString(s.authKey); got != want {
+		t.Errorf("authentication key = %s, want %s", got, want)
+	}
+	if got := hex.EncodeToString(s.privKey); got != want {
+		t.Errorf("privacy key = %s, want %s", got, want)
+	}
+
+	// The keys must only change with the engine ID.
+	s.synchronize(&securityParameters{EngineID: testEngineID, EngineBoots: 8, EngineTime: 10})
+	if got := hex.EncodeToString(s.authKey); got != want {
+		t.Errorf("authentication key after resynchronization = %s, want %s", got, want)
+	}
+	s.synchronize(&securityParameters{EngineID: []byte{0x80, 0x00, 0x1f, 0x88, 0x04}, EngineBoots: 1, EngineTime: 10})
+	if got := hex.EncodeToString(s.authKey); got == want {
+		t.Error("expected the keys to be localized for the new engine")
+	}
+}
+
+func TestUSMVerify(t *testing.T) {
+	for name := range authProtocols {
+		t.Run(string(name), func(t *testing.T) {
+			s := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{AuthProtocol: name}, "authpassword", "")
+
+			raw, err := s.marshalMessage(1, s.flags(), "", &pdu{Type: tagGetRequest, RequestID: 1, Variables: []variable{{OID: oidSysUpTime}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := s.parseMessage(raw)
+			if err != nil {
+				t.Fatalf("parseMessage() failed: %s", err)
+			}
+			if len(msg.Security.AuthParameters) != s.auth.macLength {
+				t.Errorf("authentication parameters have %d bytes, want %d", len(msg.Security.AuthParameters), s.auth.macLength)
+			}
+
+			// Any change of the message must invalidate the digest.
+			tampered := bytes.Clone(raw)
+			tampered[len(tampered)-3] ^= 0x01
+			if _, err := s.parseMessage(tampered); err == nil {
+				t.Error("expected tampered message to be rejected")
+			}
+
+			// A message of another user must not be accepted.
+			other := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{AuthProtocol: name}, "otherpassword", "")
+			if _, err := other.parseMessage(raw); err == nil {
+				t.Error("expected message with another key to be rejected")
+			}
+		})
+	}
+}
+
+func TestUSMVerifyInvalidParameters(t *testing.T) {
+	s := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{}, "authpassword", "")
+	message := make([]byte, 64)
+
+	tests := []struct {
+		name           string
+		authParameters []byte
+	}{
+		{name: "too short", authParameters: message[10:20]},
+		{name: "wrong digest", authParameters: message[40:52]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := s.verify(message, test.authParameters); err == nil {
+				t.Error("expected authentication parameters to be rejected")
+			}
+		})
+	}
+}
+
+func TestUSMEncryption(t *testing.T) {
+	for _, protocol := range []mgmtv1alpha1.SNMPPrivProtocol{mgmtv1alpha1.SNMPPrivProtocolAES, mgmtv1alpha1.SNMPPrivProtocolDES} {
+		t.Run(string(protocol), func(t *testing.T) {
+			s := newTestUSM(t, mgmtv1alpha1.HostSpecSNMPOptions{PrivProtocol: protocol}, "authpassword", "privpassword")
+			plaintext := appendTLV(nil, tagSequence, appendOctetString(nil, []byte("scoped PDU")))
+
+			ciphertext, salt, err := s.encrypt(plaintext, 7, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(salt) != 8 {
+				t.Fatalf("salt has %d bytes, want 8", len(salt))
+			}
+			if bytes.Contains(ciphertext, []byte("scoped PDU")) {
+				t.Fatal("expected the scoped PDU to be encrypted")
+			}
+
+			decrypted, err := s.decrypt(ciphertext, &securityParameters{EngineBoots: 7, EngineTime: 1000, PrivParameters: salt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// DES pads the plaintext to the block size.
+			if !bytes.HasPrefix(decrypted, plaintext) {
+				t.Errorf("decrypt() = % x, want % x", decrypted, plaintext)
+			}
+
+			// The salt must differ for each message.
+			again, nextSalt, err := s.encrypt(plaintext, 7, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(salt, nextSalt) || bytes.Equal(ciphertext, again) {
+				t.Error("expected each message to use another salt")
+			}
+
+			if _, err := s.decrypt(ciphertext, &securityParameters{PrivParameters: salt[:4]}); err == nil {
+				t.Error("expected invalid privacy parameters to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// Bastion is a connection to an SSH bastion, through which the connections
+// to multiple hosts can be tunneled concurrently.
+type Bastion struct {
+	ssh      *ssh.Client
+	opts     *common.Options
+	hostKeys common.HostKeys
+}
+
+// DialBastion connects to a bastion. The connection is not tunneled
+// through the dialer of the options, if any.
+func DialBastion(ctx context.Context, bastion *mgmtv1alpha1.SSHBastion, opts *common.Options) (*Bastion, error) {
+	spec := bastion.Spec
+
+	credentials, err := common.ReadCredentials(ctx, opts, common.CredentialReference{
+		SecretRef: common.BastionSecretReference(bastion),
+		Namespace: bastion.Namespace,
+		Optional:  spec.Certificate != nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hostCAKeys, err := parseAuthorizedKeys(spec.HostCAKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fingerprints that were trusted on first use are enforced,
+	// unless a fingerprint is specified explicitly.
+	fingerprint := spec.Fingerprint
+	if spec.TrustOnFirstUse && fingerprint == "" {
+		fingerprint = bastion.Status.Fingerprint
+	}
+
+	e := &endpoint{
+		Host:            spec.Host,
+		Port:            spec.Port,
+		Fingerprint:     fingerprint,
+		User:            spec.User,
+		Credentials:     credentials,
+		TrustOnFirstUse: spec.TrustOnFirstUse,
+		HostCAKeys:      hostCAKeys,
+	}
+	if spec.Certificate != nil {
+		e.CertificateSigner, e.CertificateValidity, err = certificateSigner(opts, spec.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		e.Principals = spec.Certificate.Principals
+		e.KeyID = fmt.Sprintf("kraut:%s/%s", bastion.Namespace, bastion.Name)
+		e.Namespace = bastion.Namespace
+	}
+
+	conn, err := dial(ctx, e, nil, opts)
+	if err != nil {
+		var mismatch *common.HostKeyMismatchError
+		if errors.As(err, &mismatch) {
+			mismatch.JumpHost = true
+		}
+		return nil, err
+	}
+
+	return &Bastion{
+		ssh:  conn,
+		opts: opts,
+		hostKeys: common.HostKeys{
+			Fingerprint: e.presented,
+		},
+	}, nil
+}
+
+// DialContext connects to the address through the bastion.
+func (b *Bastion) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	return b.ssh.DialContext(ctx, network, address)
+}
+
+// Ping checks if the connection to the bastion is still healthy.
+func (b *Bastion) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, b.opts.CommandTimeout)
+	defer cancel()
+
+	return wait(ctx, func() error {
+		_, _, err := b.ssh.SendRequest("keepalive@openssh.com", true, nil)
+		return err
+	})
+}
+
+// HostKeys returns the fingerprint of the host key that was presented by the bastion.
+func (b *Bastion) HostKeys() common.HostKeys {
+	return b.hostKeys
+}
+
+// Close closes the connection to the bastion, including all tunneled connections.
+func (b *Bastion) Close() error {
+	return b.ssh.Close()
+}
@@ -0,0 +1,226 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CAPrivateKeyKey is the key of the private key of the CA in its Secret.
+	CAPrivateKeyKey = "ca"
+	// CAPublicKeyKey is the key of the public key of the CA in its Secret, which
+	// is in the authorized keys format, e.g. for `TrustedUserCAKeys` of sshd.
+	CAPublicKeyKey = "ca.pub"
+	// clockSkew is the duration by which certificates are backdated
+	// to tolerate clocks of hosts that are slightly behind.
+	clockSkew = time.Minute
+	// defaultCertificateValidity is the validity of user certificates if none is configured.
+	defaultCertificateValidity = 5 * time.Minute
+)
+
+// allNamespaces is the namespace of principal grants that apply to all namespaces.
+const allNamespaces = "*"
+
+// ErrPrincipalNotGranted is returned if a certificate is requested
+// for a principal that was not granted to the namespace.
+var ErrPrincipalNotGranted = errors.New("principal not granted")
+
+// PrincipalGrants contains the principals for which certificates may be signed,
+// keyed by namespace. The principals of the namespace "*" are granted to all
+// namespaces.
+type PrincipalGrants map[string][]string
+
+// ParsePrincipalGrants parses a comma-separated list of principal grants. A
+// grant is either a principal, which is granted to all namespaces, or a
+// principal for a single namespace in the format <namespace>/<principal>.
+func ParsePrincipalGrants(value string) (PrincipalGrants, error) {
+	grants := make(PrincipalGrants)
+	for _, grant := range strings.Split(value, ",") {
+		grant = strings.TrimSpace(grant)
+		if grant == "" {
+			continue
+		}
+
+		namespace, principal, ok := strings.Cut(grant, "/")
+		if !ok {
+			namespace, principal = allNamespaces, grant
+		}
+		if namespace == "" || principal == "" {
+			return nil, fmt.Errorf("invalid principal grant: %q", grant)
+		}
+		grants[namespace] = append(grants[namespace], principal)
+	}
+
+	return grants, nil
+}
+
+// Allows checks if the principal was granted to the namespace.
+func (g PrincipalGrants) Allows(namespace string, principal string) bool {
+	return slices.Contains(g[namespace], principal) || slices.Contains(g[allNamespaces], principal)
+}
+
+// CertificateAuthority signs short-lived SSH user certificates with a key pair
+// that is stored in a Secret. The Secret is created on first use if it does
+// not exist. Certificates are only signed for the principals that were
+// granted to the namespace of the requesting resource.
+type CertificateAuthority struct {
+	kube      client.Client
+	secretRef types.NamespacedName
+	grants    PrincipalGrants
+
+	mutex sync.Mutex
+	// signer is parsed from the Secret with the resourceVersion.
+	signer          ssh.Signer
+	resourceVersion string
+}
+
+// NewCertificateAuthority creates a new certificate authority whose key pair
+// is stored in the referenced Secret and which only signs certificates for
+// the granted principals.
+func NewCertificateAuthority(kube client.Client, secretRef types.NamespacedName, grants PrincipalGrants) *CertificateAuthority {
+	return &CertificateAuthority{
+		kube:      kube,
+		secretRef: secretRef,
+		grants:    grants,
+	}
+}
+
+// PublicKey returns the public key of the certificate authority.
+func (ca *CertificateAuthority) PublicKey(ctx context.Context) (ssh.PublicKey, error) {
+	signer, err := ca.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.PublicKey(), nil
+}
+
+// SignUserCertificate signs a user certificate for the public key, which is
+// valid for the given principals and the given duration. ErrPrincipalNotGranted
+// is returned if a principal was not granted to the namespace.
+func (ca *CertificateAuthority) SignUserCertificate(ctx context.Context, namespace string, key ssh.PublicKey, keyID string, principals []string, validity time.Duration) (*ssh.Certificate, error) {
+	if len(principals) == 0 {
+		return nil, fmt.Errorf("%w: no principals requested", ErrPrincipalNotGranted)
+	}
+	for _, principal := range principals {
+		if !ca.grants.Allows(namespace, principal) {
+			return nil, fmt.Errorf("%w: %q in namespace %s", ErrPrincipalNotGranted, principal, namespace)
+		}
+	}
+
+	signer, err := ca.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	serial := make([]byte, 8)
+	if _, err := rand.Read(serial); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          binary.BigEndian.Uint64(serial),
+		CertType:        ssh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-clockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(validity).Unix()),
+		Permissions: ssh.Permissions{
+			// Port forwarding is required to use a host as a jump host.
+			Extensions: map[string]string{
+				"permit-port-forwarding": "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// load reads the key pair from the Secret and creates it if necessary. The
+// key pair is parsed again if the Secret changed, e.g. because it was rotated.
+func (ca *CertificateAuthority) load(ctx context.Context) (ssh.Signer, error) {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	secret := new(corev1.Secret)
+	err := ca.kube.Get(ctx, ca.secretRef, secret)
+	if apierrors.IsNotFound(err) {
+		secret, err = ca.create(ctx)
+		if apierrors.IsAlreadyExists(err) {
+			// Another replica of the operator created the Secret concurrently.
+			secret = new(corev1.Secret)
+			err = ca.kube.Get(ctx, ca.secretRef, secret)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH CA Secret: %s: %w", ca.secretRef, err)
+	}
+
+	if ca.signer != nil && secret.ResourceVersion == ca.resourceVersion {
+		return ca.signer, nil
+	}
+
+	signer, err := ssh.ParsePrivateKey(secret.Data[CAPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH CA private key: %s: %w", ca.secretRef, err)
+	}
+	ca.signer = signer
+	ca.resourceVersion = secret.ResourceVersion
+
+	return signer, nil
+}
+
+// create generates a new key pair and stores it in the Secret.
+func (ca *CertificateAuthority) create(ctx context.Context) (*corev1.Secret, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, "kraut")
+	if err != nil {
+		return nil, err
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ca.secretRef.Name,
+			Namespace: ca.secretRef.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			CAPrivateKeyKey: pem.EncodeToMemory(block),
+			CAPublicKeyKey:  ssh.MarshalAuthorizedKey(sshPublicKey),
+		},
+	}
+	if err := ca.kube.Create(ctx, secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
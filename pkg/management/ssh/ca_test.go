@@ -0,0 +1,216 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testCARef = types.NamespacedName{Namespace: "kraut", Name: "ssh-ca"}
+
+// newTestCA creates a certificate authority that uses a fake Kubernetes client.
+func newTestCA(t *testing.T, grants string) (*CertificateAuthority, client.Client) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	kube := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	parsed, err := ParsePrincipalGrants(grants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewCertificateAuthority(kube, testCARef, parsed), kube
+}
+
+// newTestKey generates a key pair and returns its public key.
+func newTestKey(t *testing.T) (ssh.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, privateKey
+}
+
+func TestParsePrincipalGrants(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    PrincipalGrants
+		wantErr bool
+	}{
+		{value: "", want: PrincipalGrants{}},
+		{value: "kraut", want: PrincipalGrants{"*": {"kraut"}}},
+		{value: "kraut, team-a/deploy,team-a/backup", want: PrincipalGrants{"*": {"kraut"}, "team-a": {"deploy", "backup"}}},
+		{value: "/deploy", wantErr: true},
+		{value: "team-a/", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			grants, err := ParsePrincipalGrants(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParsePrincipalGrants() error = %v, want error %t", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(grants) != len(test.want) {
+				t.Fatalf("ParsePrincipalGrants() = %v, want %v", grants, test.want)
+			}
+			for namespace, principals := range test.want {
+				for _, principal := range principals {
+					if !grants.Allows(namespace, principal) {
+						t.Errorf("expected %q to be granted to namespace %s", principal, namespace)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPrincipalGrantsAllows(t *testing.T) {
+	grants := PrincipalGrants{"*": {"kraut"}, "team-a": {"deploy"}}
+
+	tests := []struct {
+		namespace string
+		principal string
+		want      bool
+	}{
+		{namespace: "team-a", principal: "kraut", want: true},
+		{namespace: "team-b", principal: "kraut", want: true},
+		{namespace: "team-a", principal: "deploy", want: true},
+		{namespace: "team-b", principal: "deploy", want: false},
+		{namespace: "team-a", principal: "root", want: false},
+	}
+
+	for _, test := range tests {
+		if got := grants.Allows(test.namespace, test.principal); got != test.want {
+			t.Errorf("Allows(%s, %s) = %t, want %t", test.namespace, test.principal, got, test.want)
+		}
+	}
+}
+
+func TestCertificateAuthoritySignsGrantedPrincipals(t *testing.T) {
+	ca, kube := newTestCA(t, "kraut,team-a/deploy")
+	key, _ := newTestKey(t)
+	ctx := context.Background()
+
+	cert, err := ca.SignUserCertificate(ctx, "team-a", key, "kraut:team-a/node-1", []string{"kraut", "deploy"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.CertType != ssh.UserCert || cert.KeyId != "kraut:team-a/node-1" {
+		t.Errorf("unexpected certificate: type %d, key ID %q", cert.CertType, cert.KeyId)
+	}
+	if !bytes.Equal(cert.Key.Marshal(), key.Marshal()) {
+		t.Error("certificate was signed for another key")
+	}
+
+	// The certificate must be valid for the CA that was stored in the Secret.
+	secret := new(corev1.Secret)
+	if err := kube.Get(ctx, testCARef, secret); err != nil {
+		t.Fatal(err)
+	}
+	caKey, _, _, _, err := ssh.ParseAuthorizedKey(secret.Data[CAPublicKeyKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), caKey.Marshal())
+		},
+	}
+	if err := checker.CheckCert("deploy", cert); err != nil {
+		t.Errorf("certificate is invalid: %s", err)
+	}
+}
+
+func TestCertificateAuthorityRefusesPrincipals(t *testing.T) {
+	ca, _ := newTestCA(t, "kraut,team-a/deploy")
+	key, _ := newTestKey(t)
+
+	tests := []struct {
+		name       string
+		namespace  string
+		principals []string
+	}{
+		{name: "not granted", namespace: "team-a", principals: []string{"root"}},
+		{name: "other namespace", namespace: "team-b", principals: []string{"deploy"}},
+		{name: "partially granted", namespace: "team-a", principals: []string{"kraut", "root"}},
+		{name: "none", namespace: "team-a"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ca.SignUserCertificate(context.Background(), test.namespace, key, "kraut:test", test.principals, time.Minute)
+			if !errors.Is(err, ErrPrincipalNotGranted) {
+				t.Errorf("SignUserCertificate() error = %v, want %v", err, ErrPrincipalNotGranted)
+			}
+		})
+	}
+}
+
+func TestCertificateAuthorityReloadsChangedSecret(t *testing.T) {
+	ca, kube := newTestCA(t, "kraut")
+	ctx := context.Background()
+
+	first, err := ca.PublicKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ca.PublicKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Marshal(), again.Marshal()) {
+		t.Fatal("expected the key pair to be kept")
+	}
+
+	// Rotate the key pair by replacing it in the Secret.
+	_, privateKey := newTestKey(t)
+	block, err := ssh.MarshalPrivateKey(privateKey, "rotated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := new(corev1.Secret)
+	if err := kube.Get(ctx, testCARef, secret); err != nil {
+		t.Fatal(err)
+	}
+	secret.Data[CAPrivateKeyKey] = pem.EncodeToMemory(block)
+	if err := kube.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := ca.PublicKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rotated.Marshal(), want.Marshal()) {
+		t.Error("expected the rotated key pair to be loaded")
+	}
+}
@@ -1,16 +1,19 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"sync"
+	"time"
 
-	"gopkg.in/ini.v1"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/nicklasfrahm/k3se/pkg/sshx"
 	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
 	"github.com/nicklasfrahm/kraut/pkg/management/common"
 )
@@ -18,13 +21,23 @@ import (
 // Client manages an appliance using SSH.
 type Client struct {
 	host *mgmtv1alpha1.Host
-	ssh  *sshx.Client
-	kube client.Client
-	os   *mgmtv1alpha1.OSInfo
+	opts *common.Options
+	ssh  *ssh.Client
+	// jumps are the connections to the jump hosts in the order in which
+	// they are traversed. Each connection is tunneled through its predecessor.
+	jumps []*ssh.Client
+	// bastion is the connection to the bastion, if it is not shared.
+	bastion *Bastion
+
+	hostKeys common.HostKeys
+
+	sftpMutex       sync.Mutex
+	sftp            *sftp.Client
+	sftpUnavailable bool
 }
 
 // NewClient creates a new client for a host.
-func NewClient(host *mgmtv1alpha1.Host, options ...common.Option) (common.Client, error) {
+func NewClient(ctx context.Context, host *mgmtv1alpha1.Host, options ...common.Option) (common.Client, error) {
 	opts, err := common.GetDefaultOptions().Apply(options...)
 	if err != nil {
 		return nil, err
@@ -32,126 +45,444 @@ func NewClient(host *mgmtv1alpha1.Host, options ...common.Option) (common.Client
 
 	return &Client{
 		host: host,
-		kube: opts.KubernetesClient,
+		opts: opts,
 	}, nil
 }
 
 // Connect connects to the host.
-func (c *Client) Connect() error {
-	// Fetch credentials from secret.
-	secretRef := types.NamespacedName{
-		Namespace: c.host.Spec.SecretRef.Namespace,
-		Name:      c.host.Spec.SecretRef.Name,
+func (c *Client) Connect(ctx context.Context) error {
+	options := c.host.Spec.SSH
+
+	// Fetch credentials from the credential source. They are
+	// optional if certificates are used for the authentication.
+	credentials, err := common.ReadCredentials(ctx, c.opts, common.CredentialReference{
+		SecretRef: common.SecretReference(c.host),
+		Namespace: c.host.Namespace,
+		Optional:  options.Certificate != nil,
+	})
+	if err != nil {
+		return err
+	}
+
+	hostCAKeys, err := parseAuthorizedKeys(options.HostCAKeys)
+	if err != nil {
+		return err
+	}
+
+	// Short-lived certificates are signed for each connection.
+	signer, validity, err := certificateSigner(c.opts, options.Certificate)
+	if err != nil {
+		return err
+	}
+	keyID := fmt.Sprintf("kraut:%s/%s", c.host.Namespace, c.host.Name)
+
+	// Fingerprints that were trusted on first use are enforced,
+	// unless a fingerprint is specified explicitly.
+	fingerprint := options.Fingerprint
+	if options.TrustOnFirstUse && fingerprint == "" {
+		fingerprint = c.host.Status.SSH.Fingerprint
+	}
+
+	jumpHosts, err := c.jumpHosts(ctx, credentials)
+	if err != nil {
+		return err
 	}
-	if secretRef.Namespace == "" {
-		secretRef.Namespace = c.host.ObjectMeta.Namespace
+	for _, jumpHost := range jumpHosts {
+		jumpHost.TrustOnFirstUse = options.TrustOnFirstUse
+		jumpHost.HostCAKeys = hostCAKeys
+		if signer != nil {
+			jumpHost.CertificateSigner = signer
+			jumpHost.CertificateValidity = validity
+			jumpHost.KeyID = keyID
+			jumpHost.Namespace = c.host.Namespace
+		}
+	}
+	if err := c.dialBastion(ctx); err != nil {
+		return err
+	}
+	if err := c.dialJumpHosts(ctx, jumpHosts); err != nil {
+		return err
 	}
 
-	secret := new(corev1.Secret)
-	err := c.kube.Get(context.TODO(), secretRef, secret)
+	target := &endpoint{
+		Host:            c.host.Spec.Host,
+		Port:            c.host.Spec.Port,
+		Fingerprint:     fingerprint,
+		User:            options.User,
+		Credentials:     credentials,
+		TrustOnFirstUse: options.TrustOnFirstUse,
+		HostCAKeys:      hostCAKeys,
+	}
+	if override := c.opts.Credentials; override != nil {
+		target.Credentials = *override
+	}
+	if signer != nil {
+		target.CertificateSigner = signer
+		target.Principals = options.Certificate.Principals
+		target.CertificateValidity = validity
+		target.KeyID = keyID
+		target.Namespace = c.host.Namespace
+	}
+	c.ssh, err = dial(ctx, target, c.via(), c.opts)
 	if err != nil {
+		c.closeTunnels()
+		return err
+	}
+	c.hostKeys.Fingerprint = target.presented
+
+	return nil
+}
+
+// jumpHosts returns the endpoints of the jump hosts in the order in which they
+// are traversed. The proxy options are treated as a single jump host, whose
+// credentials are read from dedicated keys of the secret of the host.
+func (c *Client) jumpHosts(ctx context.Context, credentials common.Credentials) ([]*endpoint, error) {
+	options := c.host.Spec.SSH
+
+	if options.ProxyHost != "" {
+		if len(options.JumpHosts) > 0 || options.BastionRef != nil {
+			return nil, errors.New("proxy host must not be combined with jump hosts or a bastion")
+		}
+
+		fingerprint := options.ProxyFingerprint
+		if options.TrustOnFirstUse && fingerprint == "" {
+			fingerprint = c.host.Status.SSH.ProxyFingerprint
+		}
+
+		proxyCredentials, err := common.ReadCredentials(ctx, c.opts, common.CredentialReference{
+			SecretRef: common.SecretReference(c.host),
+			Namespace: c.host.Namespace,
+			Proxy:     true,
+			Optional:  true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return []*endpoint{{
+			Host:        options.ProxyHost,
+			Port:        options.ProxyPort,
+			Fingerprint: fingerprint,
+			User:        options.ProxyUser,
+			Credentials: proxyCredentials,
+		}}, nil
+	}
+
+	endpoints := make([]*endpoint, len(options.JumpHosts))
+	for i := range options.JumpHosts {
+		jumpHost := &options.JumpHosts[i]
+
+		// Jump hosts without a secret reference share the credentials of the host.
+		jumpCredentials := credentials
+		if jumpHost.SecretRef != nil {
+			var err error
+			jumpCredentials, err = common.ReadCredentials(ctx, c.opts, common.CredentialReference{
+				SecretRef: common.JumpHostSecretReference(c.host, jumpHost),
+				Namespace: c.host.Namespace,
+				Optional:  options.Certificate != nil,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		fingerprint := jumpHost.Fingerprint
+		if options.TrustOnFirstUse && fingerprint == "" {
+			fingerprint = c.host.Status.SSH.JumpHostFingerprints[jumpHost.Address()]
+		}
+
+		endpoints[i] = &endpoint{
+			Host:        jumpHost.Host,
+			Port:        jumpHost.Port,
+			Fingerprint: fingerprint,
+			User:        jumpHost.User,
+			Credentials: jumpCredentials,
+		}
+	}
+
+	return endpoints, nil
+}
+
+// dialBastion connects to the bastion of the host, if any. A dialer
+// from the options takes precedence, as it allows to share the
+// connection to the bastion with other hosts.
+func (c *Client) dialBastion(ctx context.Context) error {
+	bastionRef := common.BastionReference(c.host)
+	if bastionRef == nil || c.opts.Dialer != nil {
+		return nil
+	}
+
+	bastion := new(mgmtv1alpha1.SSHBastion)
+	if err := c.opts.KubernetesClient.Get(ctx, *bastionRef, bastion); err != nil {
 		if client.IgnoreNotFound(err) == nil {
-			return fmt.Errorf("failed to read Secret: %s/%s", secretRef.Namespace, secretRef.Name)
+			return fmt.Errorf("failed to read SSHBastion: %s/%s", bastionRef.Namespace, bastionRef.Name)
 		}
 		return err
 	}
 
-	var proxy *sshx.Client
-	if c.host.Spec.SSH.ProxyHost != "" {
-		proxy, err = sshx.NewClient(&sshx.Config{
-			Host:        c.host.Spec.SSH.ProxyHost,
-			Port:        c.host.Spec.SSH.ProxyPort,
-			Fingerprint: c.host.Spec.SSH.ProxyFingerprint,
-			User:        c.host.Spec.SSH.ProxyUser,
-			Key:         string(secret.Data["proxyKey"]),
-			Passphrase:  string(secret.Data["proxyPassphrase"]),
-			Password:    string(secret.Data["proxyPasswordInsecure"]),
-		}, sshx.WithSTFPDisabled())
+	var err error
+	c.bastion, err = DialBastion(ctx, bastion, c.opts)
+
+	return err
+}
+
+// dialJumpHosts connects to the jump hosts in order, tunneling each
+// connection through the previous one, and records their host keys.
+func (c *Client) dialJumpHosts(ctx context.Context, jumpHosts []*endpoint) error {
+	for _, jumpHost := range jumpHosts {
+		conn, err := dial(ctx, jumpHost, c.via(), c.opts)
 		if err != nil {
+			c.closeTunnels()
+			var mismatch *common.HostKeyMismatchError
+			if errors.As(err, &mismatch) {
+				mismatch.JumpHost = true
+			}
 			return err
 		}
+		c.jumps = append(c.jumps, conn)
 	}
 
-	c.ssh, err = sshx.NewClient(&sshx.Config{
-		Host:        c.host.Spec.Host,
-		Port:        c.host.Spec.Port,
-		Fingerprint: c.host.Spec.SSH.Fingerprint,
-		User:        c.host.Spec.SSH.User,
-		Key:         string(secret.Data["key"]),
-		Passphrase:  string(secret.Data["passphrase"]),
-		Password:    string(secret.Data["passwordInsecure"]),
-	}, sshx.WithProxy(proxy), sshx.WithSTFPDisabled())
-	if err != nil {
-		return err
+	if c.host.Spec.SSH.ProxyHost != "" {
+		c.hostKeys.ProxyFingerprint = jumpHosts[0].presented
+		return nil
+	}
+	if len(jumpHosts) > 0 {
+		c.hostKeys.JumpHostFingerprints = make(map[string]string, len(jumpHosts))
+		for _, jumpHost := range jumpHosts {
+			c.hostKeys.JumpHostFingerprints[jumpHost.address()] = jumpHost.presented
+		}
 	}
 
-	// Probe the operating system.
-	c.os, err = c.probeOS()
-	if err != nil {
-		return err
+	return nil
+}
+
+// via returns the dialer for the next connection of the chain, which is
+// either the last jump host, the bastion or none for a direct connection.
+func (c *Client) via() common.Dialer {
+	if len(c.jumps) > 0 {
+		return c.jumps[len(c.jumps)-1]
+	}
+	if c.opts.Dialer != nil {
+		return c.opts.Dialer
+	}
+	if c.bastion != nil {
+		return c.bastion
 	}
 
 	return nil
 }
 
+// HostKeys returns the fingerprints of the host keys that were presented while connecting.
+func (c *Client) HostKeys() common.HostKeys {
+	return c.hostKeys
+}
+
 // Disconnect disconnects from the host.
 func (c *Client) Disconnect() error {
+	// The tunnels must outlive the connection that is tunneled through them.
+	defer c.closeTunnels()
+
+	// The SFTP client piggy-backs on the SSH connection.
+	c.sftpMutex.Lock()
+	if c.sftp != nil {
+		c.sftp.Close()
+		c.sftp = nil
+	}
+	c.sftpMutex.Unlock()
+
+	if c.ssh == nil {
+		return nil
+	}
+
 	return c.ssh.Close()
 }
 
-// OS returns information about the operating system the host.
-func (c *Client) OS() *mgmtv1alpha1.OSInfo {
-	return c.os
+// closeTunnels closes the connections to the jump hosts in reverse
+// order and the connection to the bastion, unless it is shared.
+func (c *Client) closeTunnels() {
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		c.jumps[i].Close()
+	}
+	c.jumps = nil
+
+	if c.bastion != nil {
+		c.bastion.Close()
+		c.bastion = nil
+	}
+}
+
+// certificateSigner returns the signer and the validity of short-lived
+// user certificates, if the certificate authentication is configured.
+func certificateSigner(opts *common.Options, certificate *mgmtv1alpha1.HostSpecSSHCertificate) (common.CertificateSigner, time.Duration, error) {
+	if certificate == nil {
+		return nil, 0, nil
+	}
+	if opts.CertificateSigner == nil {
+		return nil, 0, errors.New("certificate authentication requires an SSH CA, please configure the operator with --ssh-ca-secret")
+	}
+
+	validity := defaultCertificateValidity
+	if certificate.Validity != nil && certificate.Validity.Duration > 0 {
+		validity = certificate.Validity.Duration
+	}
+
+	return opts.CertificateSigner, validity, nil
 }
 
-// probeOS probes the operating system of the host.
-func (c *Client) probeOS() (*mgmtv1alpha1.OSInfo, error) {
-	osReleaseFile := "/etc/os-release"
+// Ping checks if the connection to the host is still healthy.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.ssh == nil {
+		return fmt.Errorf("not connected: %s", c.host.Spec.Host)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CommandTimeout)
+	defer cancel()
 
-	osSession, err := c.ssh.SSH.NewSession()
+	return wait(ctx, func() error {
+		_, _, err := c.ssh.SendRequest("keepalive@openssh.com", true, nil)
+		return err
+	})
+}
+
+// OS probes information about the operating system the host.
+func (c *Client) OS(ctx context.Context) (*mgmtv1alpha1.OSInfo, error) {
+	osReleaseRaw, err := c.output(ctx, fmt.Sprintf("cat %s", common.OSReleaseFile))
+	if err != nil {
+		return nil, err
+	}
+	osInfo, err := common.ParseOSRelease(osReleaseRaw)
 	if err != nil {
 		return nil, err
 	}
-	defer osSession.Close()
 
-	osReleaseRaw, err := osSession.Output(fmt.Sprintf("cat %s", osReleaseFile))
+	kernelVersionRaw, err := c.output(ctx, "uname -r")
 	if err != nil {
 		return nil, err
 	}
-	osRelease, err := ini.Load(osReleaseRaw)
+	osInfo.KernelVersion = strings.TrimSpace(string(kernelVersionRaw))
+
+	return osInfo, nil
+}
+
+// Capabilities probes information about the tooling available on the host.
+func (c *Client) Capabilities(ctx context.Context) (*mgmtv1alpha1.HostCapabilities, error) {
+	output, err := c.output(ctx, common.CapabilitiesProbeCommand)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse the INI formatted file.
-	section := osRelease.Section("")
-	if section == nil {
-		return nil, fmt.Errorf("failed to parse file: %s", osReleaseFile)
+	return common.ParseCapabilities(output), nil
+}
+
+// Exec runs a command on the host and waits for it to complete. A non-zero
+// exit code is not considered an error, but reported in the result.
+func (c *Client) Exec(ctx context.Context, cmd *common.Command) (*common.CommandResult, error) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	result, err := c.ExecStream(ctx, cmd, stdout, stderr)
+	if result != nil {
+		result.Stdout = stdout.Bytes()
+		result.Stderr = stderr.Bytes()
+	}
+
+	return result, err
+}
+
+// ExecStream runs a command on the host and streams its output to the given
+// writers while it is running. The session is terminated if the context is
+// cancelled or the command timeout is exceeded.
+func (c *Client) ExecStream(ctx context.Context, cmd *common.Command, stdout io.Writer, stderr io.Writer) (*common.CommandResult, error) {
+	if c.ssh == nil {
+		return nil, fmt.Errorf("not connected: %s", c.host.Spec.Host)
 	}
-	osNameKey := section.Key("NAME")
-	if osNameKey == nil {
-		return nil, fmt.Errorf("failed to parse OS name from file: %s", osReleaseFile)
+
+	timeout := cmd.Timeout
+	if timeout == 0 {
+		timeout = c.opts.CommandTimeout
 	}
-	osVersionKey := section.Key("VERSION_ID")
-	if osVersionKey == nil {
-		return nil, fmt.Errorf("failed to parse OS version from file: %s", osReleaseFile)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	session, err := c.ssh.NewSession()
+	if err != nil {
+		return nil, err
 	}
+	defer session.Close()
+
+	session.Stdin = cmd.Stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
 
-	kernelSession, err := c.ssh.SSH.NewSession()
+	stop := context.AfterFunc(ctx, func() {
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+	})
+	defer stop()
+
+	start := time.Now()
+	err = session.Run(cmd.String())
+	result := &common.CommandResult{
+		Duration: time.Since(start),
+	}
+
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("failed to run command: %w", ctx.Err())
+	}
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitStatus()
+		return result, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer kernelSession.Close()
 
-	kernelVersionRaw, err := kernelSession.Output("uname -r")
+	return result, nil
+}
+
+// Subsystem starts a subsystem, such as `netconf`, in a new session. It
+// returns the session with its standard input and its standard output.
+func (c *Client) Subsystem(name string) (*ssh.Session, io.WriteCloser, io.Reader, error) {
+	if c.ssh == nil {
+		return nil, nil, nil, fmt.Errorf("not connected: %s", c.host.Spec.Host)
+	}
+
+	session, err := c.ssh.NewSession()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	stdin, err := session.StdinPipe()
 	if err != nil {
+		session.Close()
+		return nil, nil, nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, nil, nil, err
+	}
+
+	if err := session.RequestSubsystem(name); err != nil {
+		session.Close()
+		return nil, nil, nil, fmt.Errorf("failed to start subsystem %s: %w", name, err)
+	}
+
+	return session, stdin, stdout, nil
+}
+
+// output runs a command and returns its standard
+// output. A non-zero exit code is treated as an error.
+func (c *Client) output(ctx context.Context, command string) ([]byte, error) {
+	result, err := c.Exec(ctx, &common.Command{Command: command})
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
 		return nil, err
 	}
 
-	// Return "Unknown" if either key can't be parsed.
-	return &mgmtv1alpha1.OSInfo{
-		Name:          osNameKey.MustString("Unknown"),
-		Version:       mgmtv1alpha1.OSVersion(osVersionKey.MustString("Unknown")),
-		KernelVersion: strings.TrimSpace(string(kernelVersionRaw)),
-	}, nil
+	return result.Stdout, nil
 }
@@ -0,0 +1,271 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// endpoint describes the connection details of an SSH server.
+type endpoint struct {
+	Host        string
+	Port        int
+	User        string
+	Fingerprint string
+	// Credentials are used for the authentication, unless
+	// a certificate signer is configured.
+	common.Credentials
+	// TrustOnFirstUse accepts any host key if no fingerprint is specified.
+	TrustOnFirstUse bool
+	// HostCAKeys are the certificate authorities that are trusted to sign host certificates.
+	HostCAKeys []ssh.PublicKey
+	// CertificateSigner signs short-lived user certificates, which take
+	// precedence over the private key and the password if configured.
+	CertificateSigner common.CertificateSigner
+	// Principals are the principals of the signed user certificates.
+	Principals []string
+	// CertificateValidity is the validity of the signed user certificates.
+	CertificateValidity time.Duration
+	// KeyID identifies the signed user certificates in the logs of the host.
+	KeyID string
+	// Namespace is the namespace of the resource for which the user
+	// certificates are signed, which restricts the allowed principals.
+	Namespace string
+
+	// presented is the fingerprint of the host key that was presented during the handshake.
+	presented string
+}
+
+// address returns the network address of the endpoint.
+func (e *endpoint) address() string {
+	port := e.Port
+	if port == 0 {
+		port = 22
+	}
+
+	return net.JoinHostPort(e.Host, strconv.Itoa(port))
+}
+
+// clientConfig creates the configuration for the SSH client.
+func (e *endpoint) clientConfig(ctx context.Context) (*ssh.ClientConfig, error) {
+	logger := log.FromContext(ctx)
+
+	user := e.User
+	if user == "" {
+		user = "root"
+	}
+
+	// Configure the authentication method, which may either be a
+	// password, a private key, an encrypted private key or the keys
+	// of an SSH agent. Please note that a private key will always
+	// take precedence over the keys of an agent and a password.
+	var authMethod ssh.AuthMethod
+	if e.CertificateSigner != nil {
+		signer, err := e.signCertificate(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		authMethod = ssh.PublicKeys(signer)
+	} else if e.Key != "" {
+		var signer ssh.Signer
+		var err error
+		if e.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(e.Key), []byte(e.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(e.Key))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Certificate != "" {
+			signer, err = withCertificate(signer, e.Certificate)
+			if err != nil {
+				return nil, err
+			}
+		}
+		authMethod = ssh.PublicKeys(signer)
+	} else if len(e.Signers) > 0 {
+		authMethod = ssh.PublicKeys(e.Signers...)
+	} else if e.Password != "" {
+		logger.Info("Using password authentication is insecure, please consider using public key authentication", "host", e.Host)
+		authMethod = ssh.Password(e.Password)
+	} else {
+		return nil, errors.New("no authentication method specified")
+	}
+
+	// Configure host key verification. The presented fingerprint is
+	// recorded, so that it can be trusted on first use.
+	hostKeyCallback := func(hostname string, remote net.Addr, pubKey ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(pubKey)
+		e.presented = fingerprint
+		if e.Fingerprint != "" && e.Fingerprint != fingerprint {
+			return &common.HostKeyMismatchError{
+				Address:   e.address(),
+				Expected:  e.Fingerprint,
+				Presented: fingerprint,
+			}
+		}
+		if e.Fingerprint == "" && !e.TrustOnFirstUse && len(e.HostCAKeys) > 0 {
+			return fmt.Errorf("host key of %s is not signed by a trusted host CA", e.address())
+		}
+		return nil
+	}
+
+	// Host certificates are verified against the host CAs. Plain host
+	// keys are still accepted if they match the fingerprint.
+	if len(e.HostCAKeys) > 0 {
+		checker := &ssh.CertChecker{
+			IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+				for _, key := range e.HostCAKeys {
+					if bytes.Equal(key.Marshal(), auth.Marshal()) {
+						return true
+					}
+				}
+				return false
+			},
+			HostKeyFallback: hostKeyCallback,
+		}
+		hostKeyCallback = checker.CheckHostKey
+	}
+
+	if e.Fingerprint == "" && len(e.HostCAKeys) == 0 {
+		if e.TrustOnFirstUse {
+			logger.Info("Trusting host key on first use", "host", e.Host)
+		} else {
+			logger.Info("Skipping host key verification is insecure, please consider using fingerprint verification", "host", e.Host)
+		}
+	}
+
+	return &ssh.ClientConfig{
+		Auth:            []ssh.AuthMethod{authMethod},
+		HostKeyCallback: hostKeyCallback,
+		User:            user,
+	}, nil
+}
+
+// signCertificate creates an ephemeral key pair and
+// signs a short-lived user certificate for it.
+func (e *endpoint) signCertificate(ctx context.Context, user string) (ssh.Signer, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	principals := e.Principals
+	if len(principals) == 0 {
+		principals = []string{user}
+	}
+
+	cert, err := e.CertificateSigner.SignUserCertificate(ctx, e.Namespace, signer.PublicKey(), e.KeyID, principals, e.CertificateValidity)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// withCertificate combines a private key with its user certificate.
+func withCertificate(signer ssh.Signer, certificate string) (ssh.Signer, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certificate))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("failed to parse certificate: not a certificate")
+	}
+
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// parseAuthorizedKeys parses public keys in the authorized keys format.
+func parseAuthorizedKeys(keys []string) ([]ssh.PublicKey, error) {
+	parsed := make([]ssh.PublicKey, len(keys))
+	for i, key := range keys {
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		parsed[i] = publicKey
+	}
+
+	return parsed, nil
+}
+
+// dial connects to the endpoint. If a dialer is provided, the connection is
+// tunneled through it. Both the dial and the handshake are aborted if the
+// context is cancelled or the configured timeouts are exceeded.
+func dial(ctx context.Context, e *endpoint, via common.Dialer, opts *common.Options) (*ssh.Client, error) {
+	config, err := e.clientConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	address := e.address()
+
+	dialCtx, cancelDial := context.WithTimeout(ctx, opts.DialTimeout)
+	defer cancelDial()
+
+	var conn net.Conn
+	if via != nil {
+		conn, err = via.DialContext(dialCtx, "tcp", address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(dialCtx, "tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", address, err)
+	}
+
+	// Tunneled connections do not support deadlines, which
+	// is why the connection is closed to abort the handshake.
+	handshakeCtx, cancelHandshake := context.WithTimeout(ctx, opts.HandshakeTimeout)
+	defer cancelHandshake()
+	stop := context.AfterFunc(handshakeCtx, func() {
+		conn.Close()
+	})
+
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
+	if !stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		return nil, fmt.Errorf("failed to perform handshake with %s: %w", address, handshakeCtx.Err())
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(sshConn, channels, requests), nil
+}
+
+// wait blocks until the function returns or the context is cancelled.
+func wait(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
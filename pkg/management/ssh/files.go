@@ -0,0 +1,343 @@
+package ssh
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// uploadMode is the mode of a file while it is written.
+const uploadMode os.FileMode = 0600
+
+// sftpClient returns an SFTP client for the connection. The client is created
+// lazily and nil is returned if the server does not provide an SFTP subsystem,
+// such as the bash shell of NX-OS.
+func (c *Client) sftpClient(ctx context.Context) *sftp.Client {
+	c.sftpMutex.Lock()
+	defer c.sftpMutex.Unlock()
+
+	if c.sftp != nil || c.sftpUnavailable {
+		return c.sftp
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.HandshakeTimeout)
+	defer cancel()
+
+	var sftpClient *sftp.Client
+	err := wait(ctx, func() error {
+		var err error
+		sftpClient, err = sftp.NewClient(c.ssh)
+		return err
+	})
+	if err != nil {
+		// A timeout is transient, so the subsystem is only considered
+		// unavailable if the negotiation of the subsystem failed.
+		if ctx.Err() == nil {
+			c.sftpUnavailable = true
+		}
+		return nil
+	}
+
+	c.sftp = sftpClient
+	return c.sftp
+}
+
+// Upload writes the content of the reader to a file on the host.
+func (c *Client) Upload(ctx context.Context, filePath string, content io.Reader, opts *common.FileOptions) error {
+	if c.ssh == nil {
+		return fmt.Errorf("not connected: %s", c.host.Spec.Host)
+	}
+	if opts == nil {
+		opts = &common.FileOptions{}
+	}
+
+	if !opts.Atomic {
+		return c.write(ctx, filePath, content, opts)
+	}
+
+	target := temporaryPath(filePath)
+	err := c.write(ctx, target, content, opts)
+	if err == nil {
+		err = c.rename(ctx, target, filePath)
+	}
+	if err != nil {
+		c.remove(target)
+	}
+
+	return err
+}
+
+// write writes the content of the reader to a file, which is only accessible
+// by the user until it was written, and changes its owner and its mode.
+func (c *Client) write(ctx context.Context, filePath string, content io.Reader, opts *common.FileOptions) error {
+	sftpClient := c.sftpClient(ctx)
+
+	var err error
+	if sftpClient != nil {
+		err = c.uploadSFTP(ctx, sftpClient, filePath, content)
+	} else {
+		err = c.uploadExec(ctx, filePath, content)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The mode is changed last, because changing the
+	// owner clears the setuid and the setgid bits.
+	if err := c.chown(ctx, filePath, opts); err != nil {
+		return err
+	}
+
+	return c.chmod(ctx, sftpClient, filePath, opts.FileMode())
+}
+
+// remove removes a temporary file on a best-effort basis. It uses a separate
+// context, because the context of the upload may already have expired.
+func (c *Client) remove(filePath string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.CommandTimeout)
+	defer cancel()
+
+	c.Exec(ctx, &common.Command{Command: fmt.Sprintf("rm -f %s", common.ShellQuote(filePath))})
+}
+
+// uploadSFTP writes a file using the SFTP subsystem. The file is restricted
+// to the user before the content is written, as it may contain secrets.
+func (c *Client) uploadSFTP(ctx context.Context, sftpClient *sftp.Client, filePath string, content io.Reader) error {
+	return wait(ctx, func() error {
+		file, err := sftpClient.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+
+		if err := file.Chmod(uploadMode); err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := io.Copy(file, content); err != nil {
+			file.Close()
+			return err
+		}
+
+		return file.Close()
+	})
+}
+
+// uploadExec writes a file by piping its base64 encoded content to the remote
+// shell. This is used as a fallback if the SFTP subsystem is not available.
+// Like uploadSFTP, it restricts the file to the user before writing it.
+func (c *Client) uploadExec(ctx context.Context, filePath string, content io.Reader) error {
+	reader, writer := io.Pipe()
+	go func() {
+		encoder := base64.NewEncoder(base64.StdEncoding, writer)
+		_, err := io.Copy(encoder, content)
+		if err == nil {
+			err = encoder.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	defer reader.Close()
+
+	quotedPath := common.ShellQuote(filePath)
+	result, err := c.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf("umask 077 && : >> %s && chmod %04o %s && base64 -d > %s", quotedPath, common.UnixMode(uploadMode), quotedPath, quotedPath),
+		Stdin:   reader,
+	})
+	if err != nil {
+		return err
+	}
+
+	return result.Err()
+}
+
+// chmod changes the mode of a file, including the setuid, setgid and sticky bits.
+func (c *Client) chmod(ctx context.Context, sftpClient *sftp.Client, filePath string, mode os.FileMode) error {
+	if sftpClient != nil {
+		return wait(ctx, func() error {
+			return sftpClient.Chmod(filePath, mode)
+		})
+	}
+
+	_, err := c.output(ctx, fmt.Sprintf("chmod %04o %s", common.UnixMode(mode), common.ShellQuote(filePath)))
+	return err
+}
+
+// chown changes the owner and the group of a file, if configured.
+func (c *Client) chown(ctx context.Context, filePath string, opts *common.FileOptions) error {
+	if opts.Owner == "" && opts.Group == "" {
+		return nil
+	}
+
+	owner := opts.Owner
+	if opts.Group != "" {
+		owner = fmt.Sprintf("%s:%s", owner, opts.Group)
+	}
+
+	_, err := c.output(ctx, fmt.Sprintf("chown %s %s", common.ShellQuote(owner), common.ShellQuote(filePath)))
+	return err
+}
+
+// rename atomically replaces the destination with the source file.
+func (c *Client) rename(ctx context.Context, source string, destination string) error {
+	if sftpClient := c.sftpClient(ctx); sftpClient != nil {
+		err := wait(ctx, func() error {
+			return sftpClient.PosixRename(source, destination)
+		})
+		if err == nil {
+			return nil
+		}
+	}
+
+	_, err := c.output(ctx, fmt.Sprintf("mv -f %s %s", common.ShellQuote(source), common.ShellQuote(destination)))
+	return err
+}
+
+// Download writes the content of a file on the host to the writer.
+// The returned error wraps os.ErrNotExist if the file does not exist.
+func (c *Client) Download(ctx context.Context, filePath string, content io.Writer) error {
+	if c.ssh == nil {
+		return fmt.Errorf("not connected: %s", c.host.Spec.Host)
+	}
+
+	if sftpClient := c.sftpClient(ctx); sftpClient != nil {
+		return wait(ctx, func() error {
+			file, err := sftpClient.Open(filePath)
+			if err != nil {
+				return fmt.Errorf("failed to open file: %s: %w", filePath, err)
+			}
+			defer file.Close()
+
+			_, err = io.Copy(content, file)
+			return err
+		})
+	}
+
+	stderr := new(strings.Builder)
+	quotedPath := common.ShellQuote(filePath)
+	result, err := c.ExecStream(ctx, &common.Command{
+		Command: fmt.Sprintf("[ -e %s ] || exit %d; cat %s", quotedPath, common.ExitCodeNotExist, quotedPath),
+	}, content, stderr)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode == common.ExitCodeNotExist {
+		return fmt.Errorf("failed to open file: %s: %w", filePath, os.ErrNotExist)
+	}
+	result.Stderr = []byte(stderr.String())
+
+	return result.Err()
+}
+
+// Stat returns information about a file on the host. The
+// returned error wraps os.ErrNotExist if the file does not exist.
+func (c *Client) Stat(ctx context.Context, filePath string) (*common.FileInfo, error) {
+	if c.ssh == nil {
+		return nil, fmt.Errorf("not connected: %s", c.host.Spec.Host)
+	}
+
+	if sftpClient := c.sftpClient(ctx); sftpClient != nil {
+		var info *common.FileInfo
+		err := wait(ctx, func() error {
+			fileInfo, err := sftpClient.Stat(filePath)
+			if err != nil {
+				return fmt.Errorf("failed to stat file: %s: %w", filePath, err)
+			}
+
+			info = &common.FileInfo{
+				Size:    fileInfo.Size(),
+				Mode:    fileInfo.Mode(),
+				ModTime: fileInfo.ModTime(),
+			}
+			if stat, ok := fileInfo.Sys().(*sftp.FileStat); ok {
+				info.UID = int(stat.UID)
+				info.GID = int(stat.GID)
+			}
+			return nil
+		})
+		return info, err
+	}
+
+	quotedPath := common.ShellQuote(filePath)
+	result, err := c.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf("[ -e %s ] || exit %d; stat -L -c '%%s %%f %%u %%g %%Y' %s", quotedPath, common.ExitCodeNotExist, quotedPath),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode == common.ExitCodeNotExist {
+		return nil, fmt.Errorf("failed to stat file: %s: %w", filePath, os.ErrNotExist)
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+
+	return parseStat(string(result.Stdout))
+}
+
+// parseStat parses the output of `stat -c '%s %f %u %g %Y'`.
+func parseStat(output string) (*common.FileInfo, error) {
+	fields := strings.Fields(output)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("failed to parse stat output: %q", output)
+	}
+
+	values := make([]int64, len(fields))
+	for i, field := range fields {
+		base := 10
+		// The raw mode is printed in hexadecimal.
+		if i == 1 {
+			base = 16
+		}
+
+		value, err := strconv.ParseInt(field, base, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse stat output: %q", output)
+		}
+		values[i] = value
+	}
+
+	return &common.FileInfo{
+		Size:    values[0],
+		Mode:    fileModeFromUnix(uint32(values[1])),
+		UID:     int(values[2]),
+		GID:     int(values[3]),
+		ModTime: time.Unix(values[4], 0),
+	}, nil
+}
+
+// fileModeFromUnix converts a raw unix mode to a file mode.
+func fileModeFromUnix(mode uint32) os.FileMode {
+	fileMode := os.FileMode(mode & 0777)
+	if mode&04000 != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		fileMode |= os.ModeSticky
+	}
+
+	switch mode & 0170000 {
+	case 0040000:
+		fileMode |= os.ModeDir
+	case 0120000:
+		fileMode |= os.ModeSymlink
+	}
+
+	return fileMode
+}
+
+// temporaryPath returns a unique path in the same directory as the file.
+func temporaryPath(filePath string) string {
+	return path.Join(path.Dir(filePath), fmt.Sprintf(".%s.kraut-%d", path.Base(filePath), time.Now().UnixNano()))
+}
@@ -0,0 +1,379 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    common.FileInfo
+		wantErr bool
+	}{
+		{
+			name:   "regular file",
+			output: "42 81a4 0 0 1700000000\n",
+			want:   common.FileInfo{Size: 42, Mode: 0644, ModTime: time.Unix(1700000000, 0)},
+		},
+		{
+			name:   "setuid binary",
+			output: "1024 89ed 0 0 1700000000",
+			want:   common.FileInfo{Size: 1024, Mode: 0755 | os.ModeSetuid, ModTime: time.Unix(1700000000, 0)},
+		},
+		{
+			name:   "sticky directory",
+			output: "4096 43ff 0 0 1700000000",
+			want:   common.FileInfo{Size: 4096, Mode: 0777 | os.ModeDir | os.ModeSticky, ModTime: time.Unix(1700000000, 0)},
+		},
+		{
+			name:   "setgid directory with owner",
+			output: "4096 45f8 1000 50 1700000000",
+			want:   common.FileInfo{Size: 4096, Mode: 0770 | os.ModeDir | os.ModeSetgid, UID: 1000, GID: 50, ModTime: time.Unix(1700000000, 0)},
+		},
+		{
+			name:    "missing field",
+			output:  "42 81a4 0 0",
+			wantErr: true,
+		},
+		{
+			name:    "invalid mode",
+			output:  "42 xyz 0 0 1700000000",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := parseStat(test.output)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseStat() error = %v, want error %t", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if *info != test.want {
+				t.Errorf("parseStat() = %+v, want %+v", *info, test.want)
+			}
+		})
+	}
+}
+
+func TestFileModeRoundTrip(t *testing.T) {
+	tests := []struct {
+		mode os.FileMode
+		unix uint32
+	}{
+		{mode: 0644, unix: 0644},
+		{mode: 0755 | os.ModeSetuid, unix: 04755},
+		{mode: 0750 | os.ModeSetgid, unix: 02750},
+		{mode: 0777 | os.ModeSticky, unix: 01777},
+		{mode: 0700 | os.ModeSetuid | os.ModeSetgid | os.ModeSticky, unix: 07700},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode.String(), func(t *testing.T) {
+			opts := &common.FileOptions{Mode: test.mode}
+			if got := common.UnixMode(opts.FileMode()); got != test.unix {
+				t.Errorf("UnixMode() = %04o, want %04o", got, test.unix)
+			}
+			if got := fileModeFromUnix(0100000 | test.unix); got != test.mode {
+				t.Errorf("fileModeFromUnix() = %s, want %s", got, test.mode)
+			}
+		})
+	}
+}
+
+// testServer is an SSH server, which runs commands with the local shell and
+// optionally serves the SFTP subsystem from the local file system.
+type testServer struct {
+	sftp bool
+}
+
+// listen starts the SSH server and returns its address.
+func (s *testServer) listen(t *testing.T) string {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleConn(conn, config)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// handleConn serves the sessions of an SSH connection.
+func (s *testServer) handleConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for request := range requests {
+				var payload struct{ Value string }
+				if ssh.Unmarshal(request.Payload, &payload) != nil {
+					request.Reply(false, nil)
+					continue
+				}
+
+				switch {
+				case request.Type == "exec":
+					request.Reply(true, nil)
+					go s.exec(channel, payload.Value)
+				case request.Type == "subsystem" && payload.Value == "sftp" && s.sftp:
+					request.Reply(true, nil)
+					go func() {
+						sftp.NewRequestServer(channel, sftp.Handlers{
+							FileGet:  testHandler{},
+							FilePut:  testHandler{},
+							FileCmd:  testHandler{},
+							FileList: testHandler{},
+						}).Serve()
+						channel.Close()
+					}()
+				default:
+					request.Reply(false, nil)
+				}
+			}
+		}()
+	}
+}
+
+// exec runs a command with the local shell and reports its exit status.
+func (s *testServer) exec(channel ssh.Channel, command string) {
+	defer channel.Close()
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = channel
+	cmd.Stdout = channel
+	cmd.Stderr = channel.Stderr()
+
+	var status struct{ Status uint32 }
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); errors.As(err, &exitErr) {
+		status.Status = uint32(exitErr.ExitCode())
+	} else if err != nil {
+		status.Status = 127
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(&status))
+}
+
+// testHandler serves the SFTP requests from the local file system. Unlike
+// the default server of the SFTP package, it keeps the setuid, setgid and
+// sticky bits, like OpenSSH.
+type testHandler struct{}
+
+func (testHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return os.Open(r.Filepath)
+}
+
+func (testHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	flags := os.O_WRONLY
+	if r.Pflags().Creat {
+		flags |= os.O_CREATE
+	}
+	if r.Pflags().Trunc {
+		flags |= os.O_TRUNC
+	}
+
+	return os.OpenFile(r.Filepath, flags, 0644)
+}
+
+func (testHandler) Filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		if !r.AttrFlags().Permissions {
+			return nil
+		}
+		return syscall.Chmod(r.Filepath, r.Attributes().Mode&07777)
+	case "Rename", "PosixRename":
+		return os.Rename(r.Filepath, r.Target)
+	case "Remove":
+		return os.Remove(r.Filepath)
+	}
+
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (testHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// connectTestClient connects a client to the SSH server.
+func connectTestClient(t *testing.T, server *testServer) *Client {
+	t.Helper()
+
+	conn, err := ssh.Dial("tcp", server.listen(t), &ssh.ClientConfig{
+		User:            "root",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &Client{
+		host: &mgmtv1alpha1.Host{Spec: mgmtv1alpha1.HostSpec{Host: "127.0.0.1"}},
+		opts: common.GetDefaultOptions(),
+		ssh:  conn,
+	}
+	t.Cleanup(func() { client.Disconnect() })
+
+	return client
+}
+
+// probeReader returns its content and records the mode of a
+// file while the content is read, if the file already exists.
+type probeReader struct {
+	io.Reader
+	path string
+	mode os.FileMode
+}
+
+func (r *probeReader) Read(p []byte) (int, error) {
+	if info, err := os.Stat(r.path); err == nil {
+		r.mode = info.Mode()
+	}
+
+	return r.Reader.Read(p)
+}
+
+func TestUpload(t *testing.T) {
+	owner := strconv.Itoa(os.Getuid())
+
+	tests := []struct {
+		name string
+		sftp bool
+		opts *common.FileOptions
+		want os.FileMode
+	}{
+		{
+			name: "SFTP",
+			sftp: true,
+			want: 0644,
+		},
+		{
+			name: "SFTP setuid with owner",
+			sftp: true,
+			opts: &common.FileOptions{Mode: 0755 | os.ModeSetuid, Owner: owner},
+			want: 0755 | os.ModeSetuid,
+		},
+		{
+			name: "SFTP atomic setgid with owner",
+			sftp: true,
+			opts: &common.FileOptions{Mode: 0750 | os.ModeSetgid, Owner: owner, Atomic: true},
+			want: 0750 | os.ModeSetgid,
+		},
+		{
+			name: "shell",
+			want: 0644,
+		},
+		{
+			name: "shell setuid with owner",
+			opts: &common.FileOptions{Mode: 0755 | os.ModeSetuid, Owner: owner},
+			want: 0755 | os.ModeSetuid,
+		},
+		{
+			name: "shell atomic setuid with owner",
+			opts: &common.FileOptions{Mode: 0755 | os.ModeSetuid, Owner: owner, Atomic: true},
+			want: 0755 | os.ModeSetuid,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := connectTestClient(t, &testServer{sftp: test.sftp})
+
+			// An existing file must not expose the new content while it is written.
+			filePath := filepath.Join(t.TempDir(), "kraut's file")
+			if err := os.WriteFile(filePath, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(filePath, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			content := &probeReader{Reader: strings.NewReader("#!/bin/sh\n"), path: filePath}
+			if err := client.Upload(context.Background(), filePath, content, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			if (client.sftp != nil) != test.sftp {
+				t.Fatalf("SFTP used = %t, want %t", client.sftp != nil, test.sftp)
+			}
+
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "#!/bin/sh\n" {
+				t.Errorf("content = %q, want %q", data, "#!/bin/sh\n")
+			}
+
+			info, err := os.Stat(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode() != test.want {
+				t.Errorf("mode = %s, want %s", info.Mode(), test.want)
+			}
+
+			// The content is piped to the shell before the file is opened,
+			// so the mode can only be observed reliably if SFTP is used.
+			if test.sftp && (test.opts == nil || !test.opts.Atomic) && content.mode != uploadMode {
+				t.Errorf("mode while writing = %s, want %s", content.mode, uploadMode)
+			}
+		})
+	}
+}
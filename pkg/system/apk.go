@@ -0,0 +1,171 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// apk manages packages of Alpine Linux.
+type apk struct {
+	client common.Client
+}
+
+// Name returns the name of the package manager.
+func (a *apk) Name() mgmtv1alpha1.PackageManager {
+	return mgmtv1alpha1.PackageManagerAPK
+}
+
+// exec runs an apk command non-interactively.
+func (a *apk) exec(ctx context.Context, args string) ([]byte, error) {
+	return run(ctx, a.client, &common.Command{
+		Command: fmt.Sprintf("apk --quiet --no-progress %s", args),
+		Timeout: packageTimeout,
+	})
+}
+
+// Refresh updates the package index.
+func (a *apk) Refresh(ctx context.Context) error {
+	_, err := a.exec(ctx, "update")
+	return err
+}
+
+// Installed returns the installed versions of the given packages.
+func (a *apk) Installed(ctx context.Context, names []string) (map[string]string, error) {
+	installed := make(map[string]string)
+	if len(names) == 0 {
+		return installed, nil
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	// The installed packages are listed as lines such as:
+	// chrony-4.5-r0 x86_64 {chrony} (GPL-2.0-only) [installed]
+	output, err := a.exec(ctx, "list --installed")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		name, version := splitAPKPackage(fields[0])
+		if wanted[name] {
+			installed[name] = version
+		}
+	}
+
+	return installed, nil
+}
+
+// Install installs the given packages. Packages with a version
+// are pinned to that version in the world file of apk.
+func (a *apk) Install(ctx context.Context, packages []Package) error {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	args := make([]string, len(packages))
+	for i, pkg := range packages {
+		args[i] = pkg.Name
+		if pkg.Version != "" {
+			args[i] = fmt.Sprintf("%s=%s", pkg.Name, pkg.Version)
+		}
+	}
+
+	_, err := a.exec(ctx, fmt.Sprintf("add -- %s", strings.Join(quoteAll(args), " ")))
+	return err
+}
+
+// Upgrade upgrades the given packages to their latest version.
+func (a *apk) Upgrade(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	_, err := a.exec(ctx, fmt.Sprintf("add --upgrade -- %s", strings.Join(quoteAll(names), " ")))
+	return err
+}
+
+// UpgradeAll upgrades all installed packages to their latest version.
+func (a *apk) UpgradeAll(ctx context.Context) error {
+	_, err := a.exec(ctx, "upgrade")
+	return err
+}
+
+// RebootRequired checks if the modules of the running kernel are still
+// installed, as apk removes them when the kernel package is upgraded.
+func (a *apk) RebootRequired(ctx context.Context) (bool, error) {
+	result, err := a.client.Exec(ctx, &common.Command{
+		Command: `test -d "/lib/modules/$(uname -r)"`,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.ExitCode != 0, nil
+}
+
+// Remove removes the given packages.
+func (a *apk) Remove(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	_, err := a.exec(ctx, fmt.Sprintf("del -- %s", strings.Join(quoteAll(names), " ")))
+	return err
+}
+
+// Hold prevents or allows upgrades of the given packages by
+// pinning them to their installed version in the world file.
+func (a *apk) Hold(ctx context.Context, names []string, hold bool) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if !hold {
+		_, err := a.exec(ctx, fmt.Sprintf("add -- %s", strings.Join(quoteAll(names), " ")))
+		return err
+	}
+
+	installed, err := a.Installed(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	packages := make([]Package, 0, len(installed))
+	for name, version := range installed {
+		packages = append(packages, Package{Name: name, Version: version})
+	}
+
+	return a.Install(ctx, packages)
+}
+
+// SecurityUpdates is not supported, because the package
+// index of Alpine Linux does not contain security metadata.
+func (a *apk) SecurityUpdates(ctx context.Context) ([]string, error) {
+	return nil, fmt.Errorf("%w: apk does not provide security metadata", ErrUnsupported)
+}
+
+// splitAPKPackage splits a package in the format
+// name-version-release into its name and its version.
+func splitAPKPackage(pkg string) (string, string) {
+	end := len(pkg)
+	for n := 0; n < 2; n++ {
+		i := strings.LastIndex(pkg[:end], "-")
+		if i < 0 {
+			return pkg, ""
+		}
+		end = i
+	}
+
+	return pkg[:end], pkg[end+1:]
+}
@@ -0,0 +1,220 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// aptGet is the invocation of apt-get, which never prompts and
+// waits for concurrent package operations to release the lock.
+const aptGet = "apt-get -y -q -o DPkg::Lock::Timeout=120 -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold"
+
+// apt manages packages of Debian and its derivatives.
+type apt struct {
+	client common.Client
+}
+
+// Name returns the name of the package manager.
+func (a *apt) Name() mgmtv1alpha1.PackageManager {
+	return mgmtv1alpha1.PackageManagerAPT
+}
+
+// exec runs an apt-get command non-interactively.
+func (a *apt) exec(ctx context.Context, args string) error {
+	_, err := run(ctx, a.client, &common.Command{
+		Command: fmt.Sprintf("%s %s", aptGet, args),
+		Env:     map[string]string{"DEBIAN_FRONTEND": "noninteractive"},
+		Timeout: packageTimeout,
+	})
+	return err
+}
+
+// Refresh updates the package index.
+func (a *apt) Refresh(ctx context.Context) error {
+	return a.exec(ctx, "update")
+}
+
+// Installed returns the installed versions of the given packages.
+func (a *apt) Installed(ctx context.Context, names []string) (map[string]string, error) {
+	installed := make(map[string]string)
+	if len(names) == 0 {
+		return installed, nil
+	}
+
+	// dpkg-query exits with a non-zero exit code if a package is unknown.
+	result, err := a.client.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf("dpkg-query -W -f='${Package} ${Version} ${db:Status-Abbrev}\\n' -- %s 2>/dev/null", strings.Join(quoteAll(names), " ")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		fields := strings.Fields(line)
+		// Only packages in the state "ii" are fully installed.
+		if len(fields) == 3 && fields[2] == "ii" {
+			installed[fields[0]] = fields[1]
+		}
+	}
+
+	return installed, nil
+}
+
+// Install installs the given packages.
+func (a *apt) Install(ctx context.Context, packages []Package) error {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	held, err := a.held(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Only holds that were placed by kraut may be overridden.
+	var foreign []string
+	allowHeld := false
+	args := make([]string, len(packages))
+	for i, pkg := range packages {
+		args[i] = pkg.Name
+		if pkg.Version != "" {
+			args[i] = fmt.Sprintf("%s=%s", pkg.Name, pkg.Version)
+		}
+
+		if held[pkg.Name] {
+			if !pkg.Held {
+				foreign = append(foreign, pkg.Name)
+			}
+			allowHeld = true
+		}
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("%w: %s", ErrHeld, strings.Join(foreign, ", "))
+	}
+
+	flags := "--allow-downgrades"
+	if allowHeld {
+		flags += " --allow-change-held-packages"
+	}
+
+	return a.exec(ctx, fmt.Sprintf("install %s -- %s", flags, strings.Join(quoteAll(args), " ")))
+}
+
+// Upgrade upgrades the given packages to their latest version.
+func (a *apt) Upgrade(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	return a.exec(ctx, fmt.Sprintf("install --only-upgrade -- %s", strings.Join(quoteAll(names), " ")))
+}
+
+// UpgradeAll upgrades all installed packages to their latest version. New
+// dependencies, such as new kernel versions, are installed, but packages
+// are never removed.
+func (a *apt) UpgradeAll(ctx context.Context) error {
+	return a.exec(ctx, "upgrade --with-new-pkgs")
+}
+
+// RebootRequired checks if a package requested a reboot
+// by creating the file /var/run/reboot-required.
+func (a *apt) RebootRequired(ctx context.Context) (bool, error) {
+	result, err := a.client.Exec(ctx, &common.Command{
+		Command: "test -e /var/run/reboot-required",
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.ExitCode == 0, nil
+}
+
+// Remove removes the given packages.
+func (a *apt) Remove(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	held, err := a.held(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Holds that were placed by kraut are released before removal.
+	var foreign []string
+	for _, name := range names {
+		if held[name] {
+			foreign = append(foreign, name)
+		}
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("%w: %s", ErrHeld, strings.Join(foreign, ", "))
+	}
+
+	return a.exec(ctx, fmt.Sprintf("remove -- %s", strings.Join(quoteAll(names), " ")))
+}
+
+// held returns the names of all held packages.
+func (a *apt) held(ctx context.Context) (map[string]bool, error) {
+	output, err := run(ctx, a.client, &common.Command{
+		Command: "apt-mark showhold",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[string]bool)
+	for _, name := range strings.Fields(string(output)) {
+		held[name] = true
+	}
+
+	return held, nil
+}
+
+// Hold prevents or allows upgrades of the given packages.
+func (a *apt) Hold(ctx context.Context, names []string, hold bool) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	action := "unhold"
+	if hold {
+		action = "hold"
+	}
+
+	_, err := run(ctx, a.client, &common.Command{
+		Command: fmt.Sprintf("apt-mark %s -- %s", action, strings.Join(quoteAll(names), " ")),
+	})
+	return err
+}
+
+// SecurityUpdates returns the names of installed packages
+// for which an update from a security archive is available.
+func (a *apt) SecurityUpdates(ctx context.Context) ([]string, error) {
+	output, err := run(ctx, a.client, &common.Command{
+		Command: "apt-get -s -q -o Debug::NoLocking=1 dist-upgrade",
+		Env:     map[string]string{"DEBIAN_FRONTEND": "noninteractive"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The simulation prints lines such as:
+	// Inst openssl [3.0.2-0ubuntu1.10] (3.0.2-0ubuntu1.12 Ubuntu:22.04/jammy-security [amd64])
+	updates := make([]string, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "Inst" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(line), "-security") {
+			updates = append(updates, fields[1])
+		}
+	}
+
+	return updates, nil
+}
@@ -0,0 +1,194 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// dnf manages packages of RHEL and its derivatives. Older releases, which
+// ship with yum instead of dnf, are supported via the compatible CLI.
+type dnf struct {
+	client common.Client
+	binary mgmtv1alpha1.PackageManager
+}
+
+// Name returns the name of the package manager.
+func (d *dnf) Name() mgmtv1alpha1.PackageManager {
+	return d.binary
+}
+
+// exec runs a command of the package manager non-interactively.
+func (d *dnf) exec(ctx context.Context, args string) ([]byte, error) {
+	return run(ctx, d.client, &common.Command{
+		Command: fmt.Sprintf("%s -y -q %s", d.binary, args),
+		Timeout: packageTimeout,
+	})
+}
+
+// Refresh updates the package index.
+func (d *dnf) Refresh(ctx context.Context) error {
+	_, err := d.exec(ctx, "makecache")
+	return err
+}
+
+// Installed returns the installed versions of the given packages.
+func (d *dnf) Installed(ctx context.Context, names []string) (map[string]string, error) {
+	installed := make(map[string]string)
+	if len(names) == 0 {
+		return installed, nil
+	}
+
+	// rpm exits with a non-zero exit code if a package is not installed.
+	result, err := d.client.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf("rpm -q --qf '%%{NAME} %%{VERSION}-%%{RELEASE}\\n' -- %s", strings.Join(quoteAll(names), " ")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		// Packages that are not installed are reported as
+		// "package <name> is not installed" and thus ignored.
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			installed[fields[0]] = fields[1]
+		}
+	}
+
+	return installed, nil
+}
+
+// Install installs the given packages.
+func (d *dnf) Install(ctx context.Context, packages []Package) error {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	args := make([]string, len(packages))
+	for i, pkg := range packages {
+		args[i] = pkg.Name
+		if pkg.Version != "" {
+			args[i] = fmt.Sprintf("%s-%s", pkg.Name, pkg.Version)
+		}
+	}
+
+	_, err := d.exec(ctx, fmt.Sprintf("install -- %s", strings.Join(quoteAll(args), " ")))
+	return err
+}
+
+// Upgrade upgrades the given packages to their latest version.
+func (d *dnf) Upgrade(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	_, err := d.exec(ctx, fmt.Sprintf("upgrade -- %s", strings.Join(quoteAll(names), " ")))
+	return err
+}
+
+// UpgradeAll upgrades all installed packages to their latest version.
+func (d *dnf) UpgradeAll(ctx context.Context) error {
+	_, err := d.exec(ctx, "upgrade")
+	return err
+}
+
+// RebootRequired checks if updated core packages, such as the kernel,
+// require a reboot. This requires needs-restarting to be installed,
+// which is part of dnf-utils and yum-utils.
+func (d *dnf) RebootRequired(ctx context.Context) (bool, error) {
+	result, err := d.client.Exec(ctx, &common.Command{
+		Command: "needs-restarting -r",
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch result.ExitCode {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	case 127:
+		return false, fmt.Errorf("%w: needs-restarting is not installed", ErrUnsupported)
+	default:
+		return false, result.Err()
+	}
+}
+
+// Remove removes the given packages.
+func (d *dnf) Remove(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	_, err := d.exec(ctx, fmt.Sprintf("remove -- %s", strings.Join(quoteAll(names), " ")))
+	return err
+}
+
+// Hold prevents or allows upgrades of the given packages.
+// This requires the versionlock plugin to be installed.
+func (d *dnf) Hold(ctx context.Context, names []string, hold bool) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	action := "delete"
+	if hold {
+		action = "add"
+	}
+
+	_, err := d.exec(ctx, fmt.Sprintf("versionlock %s -- %s", action, strings.Join(quoteAll(names), " ")))
+	return err
+}
+
+// SecurityUpdates returns the names of installed packages
+// for which a security advisory has been published.
+func (d *dnf) SecurityUpdates(ctx context.Context) ([]string, error) {
+	output, err := d.exec(ctx, "updateinfo list --security")
+	if err != nil {
+		return nil, err
+	}
+
+	// The advisories are listed as lines such as:
+	// RHSA-2024:1234 Important/Sec. openssl-1:3.0.7-25.el9_3.x86_64
+	seen := make(map[string]bool)
+	updates := make([]string, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !strings.Contains(fields[1], "Sec") {
+			continue
+		}
+
+		name := packageNameFromNEVRA(fields[2])
+		if name != "" && !seen[name] {
+			seen[name] = true
+			updates = append(updates, name)
+		}
+	}
+	sort.Strings(updates)
+
+	return updates, nil
+}
+
+// packageNameFromNEVRA extracts the name of a
+// package from the format name-[epoch:]version-release.arch.
+func packageNameFromNEVRA(nevra string) string {
+	if i := strings.LastIndex(nevra, "."); i >= 0 {
+		nevra = nevra[:i]
+	}
+
+	for n := 0; n < 2; n++ {
+		i := strings.LastIndex(nevra, "-")
+		if i < 0 {
+			return ""
+		}
+		nevra = nevra[:i]
+	}
+
+	return nevra
+}
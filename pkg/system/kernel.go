@@ -0,0 +1,222 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	// SysctlDirectory is the directory for kernel parameters that are applied during boot.
+	SysctlDirectory = "/etc/sysctl.d"
+	// ModulesLoadDirectory is the directory for kernel modules that are loaded during boot.
+	ModulesLoadDirectory = "/etc/modules-load.d"
+	// moduleDirectory contains an entry for each loaded or built-in kernel module.
+	moduleDirectory = "/sys/module"
+)
+
+var (
+	// sysctlKey matches the names of kernel parameters, which
+	// may either be separated by dots or by slashes.
+	sysctlKey = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.:/-]*$`)
+	// moduleName matches the names of kernel modules.
+	moduleName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// Kernel manages the runtime configuration of the Linux kernel of a host.
+type Kernel struct {
+	client common.Client
+}
+
+// NewKernel returns a kernel manager for a host. ErrUnsupported
+// is returned if the host does not run the Linux kernel.
+func NewKernel(c common.Client, host *mgmtv1alpha1.Host) (*Kernel, error) {
+	switch host.Status.OS.Family {
+	case mgmtv1alpha1.OSFamilyDebian, mgmtv1alpha1.OSFamilyRHEL, mgmtv1alpha1.OSFamilyAlpine, mgmtv1alpha1.OSFamilyFlatcar:
+		return &Kernel{client: c}, nil
+	case "":
+		return nil, fmt.Errorf("%w: operating system of host %s has not been probed yet", ErrUnsupported, host.Name)
+	}
+
+	return nil, fmt.Errorf("%w: cannot manage kernel of family %s", ErrUnsupported, host.Status.OS.Family)
+}
+
+// ValidateSysctlKey checks if the key is a valid name for a kernel parameter.
+func ValidateSysctlKey(key string) error {
+	if !sysctlKey.MatchString(key) {
+		return fmt.Errorf("invalid kernel parameter: %q", key)
+	}
+
+	return nil
+}
+
+// ValidateModuleName checks if the name is a valid name for a kernel module.
+func ValidateModuleName(name string) error {
+	if !moduleName.MatchString(name) {
+		return fmt.Errorf("invalid kernel module: %q", name)
+	}
+
+	return nil
+}
+
+// NormalizeSysctlValue collapses the whitespace of a value, because the
+// kernel separates the fields of values with multiple fields by tabs.
+func NormalizeSysctlValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// SysctlPath returns the path of the file that persists the kernel parameters of a configuration.
+func SysctlPath(name string) string {
+	return path.Join(SysctlDirectory, fmt.Sprintf("90-kraut-%s.conf", name))
+}
+
+// ModulesLoadPath returns the path of the file that persists the kernel modules of a configuration.
+func ModulesLoadPath(name string) string {
+	return path.Join(ModulesLoadDirectory, fmt.Sprintf("kraut-%s.conf", name))
+}
+
+// Sysctl returns the normalized effective values of kernel parameters.
+func (k *Kernel) Sysctl(ctx context.Context, keys []string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	// The values are printed in the order of the keys.
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	quoted := make([]string, len(sorted))
+	for i, key := range sorted {
+		quoted[i] = common.ShellQuote(key)
+	}
+
+	output, err := run(ctx, k.client, &common.Command{
+		Command: fmt.Sprintf("sysctl -n %s", strings.Join(quoted, " ")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel parameters: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if len(lines) != len(sorted) {
+		return nil, fmt.Errorf("failed to read kernel parameters: expected %d values, got %d", len(sorted), len(lines))
+	}
+	for i, key := range sorted {
+		values[key] = NormalizeSysctlValue(lines[i])
+	}
+
+	return values, nil
+}
+
+// SetSysctl sets the values of kernel parameters at runtime.
+func (k *Kernel) SetSysctl(ctx context.Context, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, len(values))
+	for key, value := range values {
+		args = append(args, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	if _, err := run(ctx, k.client, &common.Command{
+		Command: fmt.Sprintf("sysctl -w %s", strings.Join(quoteAll(args), " ")),
+	}); err != nil {
+		return fmt.Errorf("failed to set kernel parameters: %s", err)
+	}
+
+	return nil
+}
+
+// WriteSysctlFile persists kernel parameters, if they differ from the desired
+// values. The file is removed if no values are given. It returns whether the
+// file was changed. The values are not applied at runtime.
+func (k *Kernel) WriteSysctlFile(ctx context.Context, filePath string, values map[string]string) (bool, error) {
+	if len(values) == 0 {
+		return removeFile(ctx, k.client, filePath)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var content bytes.Buffer
+	content.WriteString("# This file is managed by kraut.\n")
+	for _, key := range keys {
+		fmt.Fprintf(&content, "%s = %s\n", key, values[key])
+	}
+
+	return writeFile(ctx, k.client, filePath, content.Bytes(), 0644)
+}
+
+// LoadedModules returns the kernel modules that are loaded or built into the kernel.
+func (k *Kernel) LoadedModules(ctx context.Context, names []string) ([]string, error) {
+	loaded := make([]string, 0, len(names))
+	if len(names) == 0 {
+		return loaded, nil
+	}
+
+	// The kernel exposes modules with underscores instead of dashes.
+	output, err := run(ctx, k.client, &common.Command{
+		Command: fmt.Sprintf("for m in %s; do [ -d %s/\"$(echo \"$m\" | tr - _)\" ] && echo \"$m\"; done; true", strings.Join(quoteAll(names), " "), moduleDirectory),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kernel modules: %s", err)
+	}
+
+	loaded = append(loaded, strings.Fields(string(output))...)
+	sort.Strings(loaded)
+
+	return loaded, nil
+}
+
+// LoadModules loads kernel modules at runtime.
+func (k *Kernel) LoadModules(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	if _, err := run(ctx, k.client, &common.Command{
+		Command: fmt.Sprintf("modprobe -a %s", strings.Join(quoteAll(names), " ")),
+	}); err != nil {
+		return fmt.Errorf("failed to load kernel modules: %s", err)
+	}
+
+	return nil
+}
+
+// WriteModulesFile persists kernel modules, if they differ from the desired
+// modules. The file is removed if no modules are given. It returns whether
+// the file was changed. The modules are not loaded at runtime.
+func (k *Kernel) WriteModulesFile(ctx context.Context, filePath string, names []string) (bool, error) {
+	if len(names) == 0 {
+		return removeFile(ctx, k.client, filePath)
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	var content bytes.Buffer
+	content.WriteString("# This file is managed by kraut.\n")
+	for _, name := range sorted {
+		fmt.Fprintf(&content, "%s\n", name)
+	}
+
+	return writeFile(ctx, k.client, filePath, content.Bytes(), 0644)
+}
+
+// RemoveFile removes a file that persists kernel parameters or
+// modules. It returns whether the file existed. The runtime
+// configuration of the kernel is not changed.
+func (k *Kernel) RemoveFile(ctx context.Context, filePath string) (bool, error) {
+	return removeFile(ctx, k.client, filePath)
+}
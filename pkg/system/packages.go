@@ -0,0 +1,83 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// packageTimeout is the timeout for operations of the package
+// manager, which may need to download and install packages.
+const packageTimeout = 15 * time.Minute
+
+// ErrHeld is returned if a package cannot be changed, because
+// it was held by an administrator or by another tool.
+var ErrHeld = errors.New("packages are held outside of kraut")
+
+// Package describes a package and its version.
+type Package struct {
+	// Name is the name of the package.
+	Name string
+	// Version is the version of the package as reported
+	// by the package manager. If empty, any version is used.
+	Version string
+	// Held marks a package that was held by kraut. Only such
+	// packages may be changed while they are held.
+	Held bool
+}
+
+// PackageManager manages the packages of a host.
+type PackageManager interface {
+	// Name returns the name of the package manager.
+	Name() mgmtv1alpha1.PackageManager
+	// Refresh updates the package index.
+	Refresh(ctx context.Context) error
+	// Installed returns the installed versions of the given packages.
+	// Packages that are not installed are omitted from the result.
+	Installed(ctx context.Context, names []string) (map[string]string, error)
+	// Install installs the given packages. Packages with a version
+	// are upgraded or downgraded to the exact version. ErrHeld is
+	// returned if a package is held, but not marked as Held.
+	Install(ctx context.Context, packages []Package) error
+	// Upgrade upgrades the given packages to their latest version.
+	Upgrade(ctx context.Context, names []string) error
+	// UpgradeAll upgrades all installed packages to their latest version.
+	UpgradeAll(ctx context.Context) error
+	// RebootRequired checks if a reboot is required to complete upgrades.
+	RebootRequired(ctx context.Context) (bool, error)
+	// Remove removes the given packages. ErrHeld is
+	// returned if a package is still held.
+	Remove(ctx context.Context, names []string) error
+	// Hold prevents or allows upgrades of the given packages.
+	Hold(ctx context.Context, names []string, hold bool) error
+	// SecurityUpdates returns the names of installed
+	// packages for which a security update is available.
+	SecurityUpdates(ctx context.Context) ([]string, error)
+}
+
+// NewPackageManager returns the package manager for a host based on its
+// discovered operating system family and capabilities. ErrUnsupported is
+// returned if the host does not use a supported package manager.
+func NewPackageManager(c common.Client, host *mgmtv1alpha1.Host) (PackageManager, error) {
+	family := host.Status.OS.Family
+	manager := host.Status.Capabilities.PackageManager
+
+	switch {
+	case family == mgmtv1alpha1.OSFamilyDebian && manager == mgmtv1alpha1.PackageManagerAPT:
+		return &apt{client: c}, nil
+	case family == mgmtv1alpha1.OSFamilyRHEL && (manager == mgmtv1alpha1.PackageManagerDNF || manager == mgmtv1alpha1.PackageManagerYUM):
+		return &dnf{client: c, binary: manager}, nil
+	case family == mgmtv1alpha1.OSFamilyAlpine && manager == mgmtv1alpha1.PackageManagerAPK:
+		return &apk{client: c}, nil
+	}
+
+	if family == "" {
+		return nil, fmt.Errorf("%w: operating system of host %s has not been probed yet", ErrUnsupported, host.Name)
+	}
+
+	return nil, fmt.Errorf("%w: cannot manage packages of family %s with package manager %q", ErrUnsupported, family, manager)
+}
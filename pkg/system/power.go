@@ -0,0 +1,76 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+const (
+	// bootIDPath contains a random ID, which the Linux kernel generates on every boot.
+	bootIDPath = "/proc/sys/kernel/random/boot_id"
+	// powerDelay is the number of seconds that a reboot or a shutdown is delayed,
+	// which allows the command that requested it to complete successfully.
+	powerDelay = 2
+)
+
+// Power reboots and shuts down a host via its operating system.
+type Power struct {
+	client common.Client
+}
+
+// NewPower returns a power manager for a host. ErrUnsupported
+// is returned if the host does not run the Linux kernel.
+func NewPower(c common.Client, host *mgmtv1alpha1.Host) (*Power, error) {
+	switch host.Status.OS.Family {
+	case mgmtv1alpha1.OSFamilyDebian, mgmtv1alpha1.OSFamilyRHEL, mgmtv1alpha1.OSFamilyAlpine, mgmtv1alpha1.OSFamilyFlatcar:
+		return &Power{client: c}, nil
+	case "":
+		return nil, fmt.Errorf("%w: operating system of host %s has not been probed yet", ErrUnsupported, host.Name)
+	}
+
+	return nil, fmt.Errorf("%w: cannot manage power of family %s", ErrUnsupported, host.Status.OS.Family)
+}
+
+// BootID returns the ID of the current boot of the host,
+// which changes whenever the host is booted.
+func (p *Power) BootID(ctx context.Context) (string, error) {
+	output, err := run(ctx, p.client, &common.Command{
+		Command: fmt.Sprintf("cat %s", bootIDPath),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read boot ID: %w", err)
+	}
+
+	bootID := strings.TrimSpace(string(output))
+	if bootID == "" {
+		return "", fmt.Errorf("failed to read boot ID: %s is empty", bootIDPath)
+	}
+
+	return bootID, nil
+}
+
+// Reboot requests the host to reboot. It returns once the reboot was scheduled.
+func (p *Power) Reboot(ctx context.Context) error {
+	return p.schedule(ctx, "systemctl reboot", "reboot")
+}
+
+// Shutdown requests the host to power off. It returns once the shutdown was scheduled.
+func (p *Power) Shutdown(ctx context.Context) error {
+	return p.schedule(ctx, "systemctl poweroff", "poweroff")
+}
+
+// schedule runs the command for systemd, or the fallback command for other
+// init systems, in the background after a short delay. Running it in the
+// foreground would terminate the connection before the command completes.
+func (p *Power) schedule(ctx context.Context, systemd string, fallback string) error {
+	script := fmt.Sprintf("sleep %d; if command -v systemctl >/dev/null 2>&1; then %s; else %s; fi", powerDelay, systemd, fallback)
+
+	_, err := run(ctx, p.client, &common.Command{
+		Command: fmt.Sprintf("nohup sh -c %s >/dev/null 2>&1 &", common.ShellQuote(script)),
+	})
+	return err
+}
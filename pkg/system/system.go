@@ -0,0 +1,94 @@
+// Package system implements the management of operating system
+// components, such as packages, on top of the management clients.
+package system
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// ErrUnsupported is returned if the operating system of a host
+// or its tooling is not supported by an implementation.
+var ErrUnsupported = errors.New("unsupported operating system")
+
+// run runs a command and returns its standard
+// output. A non-zero exit code is treated as an error.
+func run(ctx context.Context, c common.Client, cmd *common.Command) ([]byte, error) {
+	result, err := c.Exec(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+
+	return result.Stdout, nil
+}
+
+// quoteAll quotes each argument for a POSIX shell and sorts
+// them, which ensures that the command line is deterministic.
+func quoteAll(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = common.ShellQuote(arg)
+	}
+	sort.Strings(quoted)
+
+	return quoted
+}
+
+// sha256Hex returns the hex encoded SHA256 checksum of the content.
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFile atomically writes a file, if its content differs from the desired
+// content. Missing parent directories are created. It returns whether the
+// file was changed.
+func writeFile(ctx context.Context, c common.Client, filePath string, content []byte, mode os.FileMode) (bool, error) {
+	checksum, err := common.Checksum(ctx, c, filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if checksum == sha256Hex(content) {
+		return false, nil
+	}
+
+	if _, err := run(ctx, c, &common.Command{
+		Command: fmt.Sprintf("mkdir -p %s", common.ShellQuote(path.Dir(filePath))),
+	}); err != nil {
+		return false, err
+	}
+
+	err = c.Upload(ctx, filePath, bytes.NewReader(content), &common.FileOptions{
+		Mode:   mode,
+		Atomic: true,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// removeFile removes a file. It returns whether the file existed.
+func removeFile(ctx context.Context, c common.Client, filePath string) (bool, error) {
+	output, err := run(ctx, c, &common.Command{
+		Command: fmt.Sprintf("if [ -e %[1]s ]; then rm -f %[1]s && echo removed; fi", common.ShellQuote(filePath)),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
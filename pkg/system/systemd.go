@@ -0,0 +1,220 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// UnitDirectory is the directory for unit files of the system administrator.
+const UnitDirectory = "/etc/systemd/system"
+
+// unitName matches the names of systemd units.
+var unitName = regexp.MustCompile(`^[a-zA-Z0-9:_.@\\-]+\.(service|socket|timer|target|mount|automount|path|slice|swap)$`)
+
+// UnitStatus describes the runtime state of a systemd unit.
+type UnitStatus struct {
+	// LoadState describes if the unit definition was loaded, such as "loaded" or "not-found".
+	LoadState string
+	// ActiveState is the high-level state of the unit, such as "active" or "failed".
+	ActiveState string
+	// SubState is the low-level state of the unit, such as "running" or "exited".
+	SubState string
+	// UnitFileState describes if the unit is enabled, such as "enabled" or "static".
+	UnitFileState string
+	// MainPID is the ID of the main process of a service, or 0 if it is not running.
+	MainPID int
+}
+
+// Active checks if the unit is active or about to become active.
+func (s *UnitStatus) Active() bool {
+	switch s.ActiveState {
+	case "active", "activating", "reloading":
+		return true
+	}
+
+	return false
+}
+
+// Enabled checks if the unit is enabled to start automatically.
+func (s *UnitStatus) Enabled() bool {
+	return strings.HasPrefix(s.UnitFileState, "enabled")
+}
+
+// Switchable checks if the unit can be enabled or disabled. This is not
+// the case for units without an install section or generated units.
+func (s *UnitStatus) Switchable() bool {
+	switch s.UnitFileState {
+	case "static", "generated", "transient", "indirect", "alias":
+		return false
+	}
+
+	return true
+}
+
+// UnitOptions describes the desired state of a systemd unit.
+type UnitOptions struct {
+	// Enabled configures whether the unit starts automatically.
+	// The state is not changed if not set.
+	Enabled *bool
+	// Active configures whether the unit is started or stopped.
+	// The state is not changed if not set.
+	Active *bool
+}
+
+// Systemd manages the units of a host using systemd as its init system.
+type Systemd struct {
+	client common.Client
+}
+
+// NewSystemd returns a systemd manager for a host. ErrUnsupported
+// is returned if systemd was not detected as the init system.
+func NewSystemd(c common.Client, host *mgmtv1alpha1.Host) (*Systemd, error) {
+	initSystem := host.Status.Capabilities.InitSystem
+	if initSystem != mgmtv1alpha1.InitSystemSystemd {
+		if initSystem == "" {
+			return nil, fmt.Errorf("%w: init system of host %s has not been probed yet", ErrUnsupported, host.Name)
+		}
+		return nil, fmt.Errorf("%w: cannot manage units with init system %q", ErrUnsupported, initSystem)
+	}
+
+	return &Systemd{client: c}, nil
+}
+
+// ValidateUnitName checks if the name is a valid name for a systemd unit.
+func ValidateUnitName(unit string) error {
+	if !unitName.MatchString(unit) {
+		return fmt.Errorf("invalid unit name: %q", unit)
+	}
+
+	return nil
+}
+
+// UnitPath returns the path of the unit file of the system administrator.
+func UnitPath(unit string) string {
+	return path.Join(UnitDirectory, unit)
+}
+
+// DropInPath returns the path of a drop-in file for the unit.
+func DropInPath(unit string, name string) string {
+	return path.Join(UnitDirectory, unit+".d", name)
+}
+
+// systemctl runs a systemctl command for a unit.
+func (s *Systemd) systemctl(ctx context.Context, action string, unit string) ([]byte, error) {
+	return run(ctx, s.client, &common.Command{
+		Command: fmt.Sprintf("systemctl %s -- %s", action, common.ShellQuote(unit)),
+	})
+}
+
+// Status returns the runtime state of a unit.
+func (s *Systemd) Status(ctx context.Context, unit string) (*UnitStatus, error) {
+	output, err := s.systemctl(ctx, "show --property=LoadState,ActiveState,SubState,UnitFileState,MainPID", unit)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &UnitStatus{}
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "LoadState":
+			status.LoadState = value
+		case "ActiveState":
+			status.ActiveState = value
+		case "SubState":
+			status.SubState = value
+		case "UnitFileState":
+			status.UnitFileState = value
+		case "MainPID":
+			status.MainPID, _ = strconv.Atoi(value)
+		}
+	}
+
+	return status, nil
+}
+
+// Ensure brings a unit into the desired state and returns
+// a description of the changes that were made to it.
+func (s *Systemd) Ensure(ctx context.Context, unit string, opts *UnitOptions) ([]string, error) {
+	status, err := s.Status(ctx, unit)
+	if err != nil {
+		return nil, err
+	}
+	if status.LoadState == "not-found" {
+		return nil, fmt.Errorf("failed to find unit: %s", unit)
+	}
+
+	changes := make([]string, 0)
+
+	if opts.Enabled != nil && status.Switchable() && *opts.Enabled != status.Enabled() {
+		action := "disable"
+		if *opts.Enabled {
+			action = "enable"
+		}
+		if _, err := s.systemctl(ctx, action, unit); err != nil {
+			return changes, fmt.Errorf("failed to %s unit: %s: %s", action, unit, err)
+		}
+		changes = append(changes, action+"d")
+	}
+
+	if opts.Active != nil && *opts.Active != status.Active() {
+		action, change := "stop", "stopped"
+		if *opts.Active {
+			action, change = "start", "started"
+		}
+		if _, err := s.systemctl(ctx, action, unit); err != nil {
+			return changes, fmt.Errorf("failed to %s unit: %s: %s", action, unit, err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// Restart restarts a unit.
+func (s *Systemd) Restart(ctx context.Context, unit string) error {
+	_, err := s.systemctl(ctx, "restart", unit)
+	return err
+}
+
+// DaemonReload reloads the unit files of systemd.
+func (s *Systemd) DaemonReload(ctx context.Context) error {
+	_, err := run(ctx, s.client, &common.Command{Command: "systemctl daemon-reload"})
+	return err
+}
+
+// WriteUnitFile writes a unit file or a drop-in file, if its content differs
+// from the desired content. It returns whether the file was changed. Systemd
+// must be reloaded for changes to take effect.
+func (s *Systemd) WriteUnitFile(ctx context.Context, filePath string, content []byte) (bool, error) {
+	return writeFile(ctx, s.client, filePath, content, 0644)
+}
+
+// RemoveUnitFile removes a unit file or a drop-in file. It returns whether
+// the file existed. Systemd must be reloaded for changes to take effect.
+func (s *Systemd) RemoveUnitFile(ctx context.Context, filePath string) (bool, error) {
+	return removeFile(ctx, s.client, filePath)
+}
+
+// Journal returns the most recent lines of the journal of a unit.
+func (s *Systemd) Journal(ctx context.Context, unit string, lines int) (string, error) {
+	output, err := run(ctx, s.client, &common.Command{
+		Command: fmt.Sprintf("journalctl --no-pager --output=short-iso --lines=%d --unit=%s", lines, common.ShellQuote(unit)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(output), nil
+}
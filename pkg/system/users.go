@@ -0,0 +1,374 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	mgmtv1alpha1 "github.com/nicklasfrahm/kraut/api/management/v1alpha1"
+	"github.com/nicklasfrahm/kraut/pkg/management/common"
+)
+
+// SudoersDirectory is the directory for additional sudo rules.
+const SudoersDirectory = "/etc/sudoers.d"
+
+// exitCodeNotFound is the exit code of getent if a key does not exist.
+const exitCodeNotFound = 2
+
+// userName matches the names of local users and groups.
+var userName = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,30}[$]?$`)
+
+// User describes a local user of a host.
+type User struct {
+	// Name is the name of the user.
+	Name string
+	// UID is the numeric ID of the user.
+	UID int
+	// GID is the numeric ID of the primary group of the user.
+	GID int
+	// Home is the home directory of the user.
+	Home string
+	// Shell is the login shell of the user.
+	Shell string
+	// Groups contains the names of the supplementary groups of the user.
+	Groups []string
+}
+
+// UserOptions describes the desired state of a local user.
+type UserOptions struct {
+	// UID is the numeric ID of the user. It is only used to create the user.
+	UID *int
+	// Shell is the login shell of the user. The shell is not changed if empty.
+	Shell string
+	// Groups contains the names of the supplementary groups of the user.
+	// Missing groups are created. The groups are not changed if nil.
+	Groups []string
+	// AppendGroups only adds the user to the missing groups and
+	// keeps its other supplementary groups.
+	AppendGroups bool
+}
+
+// Users manages the local users of a host.
+type Users struct {
+	client common.Client
+}
+
+// NewUsers returns a user manager for a host. ErrUnsupported is returned if
+// the operating system of the host does not provide the shadow utilities.
+func NewUsers(c common.Client, host *mgmtv1alpha1.Host) (*Users, error) {
+	switch host.Status.OS.Family {
+	case mgmtv1alpha1.OSFamilyDebian, mgmtv1alpha1.OSFamilyRHEL, mgmtv1alpha1.OSFamilyAlpine, mgmtv1alpha1.OSFamilyFlatcar:
+		return &Users{client: c}, nil
+	case "":
+		return nil, fmt.Errorf("%w: operating system of host %s has not been probed yet", ErrUnsupported, host.Name)
+	}
+
+	return nil, fmt.Errorf("%w: cannot manage users of family %s", ErrUnsupported, host.Status.OS.Family)
+}
+
+// ValidateUserName checks if the name is a valid name for a local user or group.
+func ValidateUserName(name string) error {
+	if !userName.MatchString(name) {
+		return fmt.Errorf("invalid user name: %q", name)
+	}
+
+	return nil
+}
+
+// Lookup returns a local user. Nil is returned if the user does not exist.
+func (u *Users) Lookup(ctx context.Context, name string) (*User, error) {
+	result, err := u.client.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf("getent passwd %s", common.ShellQuote(name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode == exitCodeNotFound {
+		return nil, nil
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+
+	// The entry has the format name:password:uid:gid:gecos:home:shell.
+	fields := strings.Split(strings.TrimSpace(string(result.Stdout)), ":")
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("failed to parse passwd entry: %q", result.Stdout)
+	}
+
+	user := &User{
+		Name:  fields[0],
+		Home:  fields[5],
+		Shell: fields[6],
+	}
+	if user.UID, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, fmt.Errorf("failed to parse passwd entry: %q", result.Stdout)
+	}
+	if user.GID, err = strconv.Atoi(fields[3]); err != nil {
+		return nil, fmt.Errorf("failed to parse passwd entry: %q", result.Stdout)
+	}
+
+	// The first group is the primary group, which is not a supplementary group.
+	output, err := run(ctx, u.client, &common.Command{
+		Command: fmt.Sprintf("id -nG %s", common.ShellQuote(name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	groups := strings.Fields(string(output))
+	if len(groups) > 0 {
+		groups = groups[1:]
+	}
+	sort.Strings(groups)
+	user.Groups = groups
+
+	return user, nil
+}
+
+// Ensure creates or modifies a local user and returns a
+// description of the changes that were made to it.
+func (u *Users) Ensure(ctx context.Context, name string, opts *UserOptions) ([]string, error) {
+	changes := make([]string, 0)
+
+	for _, group := range opts.Groups {
+		created, err := u.ensureGroup(ctx, group)
+		if err != nil {
+			return changes, err
+		}
+		if created {
+			changes = append(changes, fmt.Sprintf("created group %s", group))
+		}
+	}
+
+	user, err := u.Lookup(ctx, name)
+	if err != nil {
+		return changes, err
+	}
+
+	if user == nil {
+		args := []string{"--create-home"}
+		if opts.UID != nil {
+			args = append(args, "--uid", strconv.Itoa(*opts.UID))
+		}
+		if opts.Shell != "" {
+			args = append(args, "--shell", common.ShellQuote(opts.Shell))
+		}
+		if len(opts.Groups) > 0 {
+			args = append(args, "--groups", common.ShellQuote(strings.Join(opts.Groups, ",")))
+		}
+
+		if _, err := run(ctx, u.client, &common.Command{
+			Command: fmt.Sprintf("useradd %s -- %s", strings.Join(args, " "), common.ShellQuote(name)),
+		}); err != nil {
+			return changes, fmt.Errorf("failed to create user: %s: %s", name, err)
+		}
+
+		return append(changes, "created user"), nil
+	}
+
+	args := make([]string, 0)
+	if opts.Shell != "" && opts.Shell != user.Shell {
+		args = append(args, "--shell", common.ShellQuote(opts.Shell))
+		changes = append(changes, fmt.Sprintf("changed shell to %s", opts.Shell))
+	}
+
+	groups := append([]string{}, opts.Groups...)
+	sort.Strings(groups)
+	if opts.AppendGroups {
+		missing := make([]string, 0)
+		for _, group := range groups {
+			if !slices.Contains(user.Groups, group) {
+				missing = append(missing, group)
+			}
+		}
+		if len(missing) > 0 {
+			args = append(args, "--append", "--groups", common.ShellQuote(strings.Join(missing, ",")))
+			changes = append(changes, fmt.Sprintf("added to groups [%s]", strings.Join(missing, ", ")))
+		}
+	} else if opts.Groups != nil && strings.Join(groups, ",") != strings.Join(user.Groups, ",") {
+		args = append(args, "--groups", common.ShellQuote(strings.Join(groups, ",")))
+		changes = append(changes, fmt.Sprintf("changed groups to [%s]", strings.Join(groups, ", ")))
+	}
+
+	if len(args) == 0 {
+		return changes, nil
+	}
+
+	if _, err := run(ctx, u.client, &common.Command{
+		Command: fmt.Sprintf("usermod %s -- %s", strings.Join(args, " "), common.ShellQuote(name)),
+	}); err != nil {
+		return changes, fmt.Errorf("failed to modify user: %s: %s", name, err)
+	}
+
+	return changes, nil
+}
+
+// ensureGroup creates a local group if it does not exist.
+func (u *Users) ensureGroup(ctx context.Context, group string) (bool, error) {
+	result, err := u.client.Exec(ctx, &common.Command{
+		Command: fmt.Sprintf("getent group %s", common.ShellQuote(group)),
+	})
+	if err != nil {
+		return false, err
+	}
+	if result.ExitCode != exitCodeNotFound {
+		return false, result.Err()
+	}
+
+	if _, err := run(ctx, u.client, &common.Command{
+		Command: fmt.Sprintf("groupadd -- %s", common.ShellQuote(group)),
+	}); err != nil {
+		return false, fmt.Errorf("failed to create group: %s: %s", group, err)
+	}
+
+	return true, nil
+}
+
+// Remove removes a local user including its home directory and its sudo
+// rules. It returns whether the user existed. The user must not be logged in.
+func (u *Users) Remove(ctx context.Context, name string) (bool, error) {
+	if _, err := u.SetSudoRules(ctx, name, nil); err != nil {
+		return false, err
+	}
+
+	user, err := u.Lookup(ctx, name)
+	if err != nil || user == nil {
+		return false, err
+	}
+
+	if _, err := run(ctx, u.client, &common.Command{
+		Command: fmt.Sprintf("userdel --remove -- %s", common.ShellQuote(name)),
+	}); err != nil {
+		return false, fmt.Errorf("failed to remove user: %s: %s", name, err)
+	}
+
+	return true, nil
+}
+
+// SetAuthorizedKeys writes the authorized SSH keys of a user, if they differ
+// from the desired keys. It returns whether the keys were changed.
+func (u *Users) SetAuthorizedKeys(ctx context.Context, user *User, keys []string) (bool, error) {
+	content := []byte(strings.Join(keys, "\n") + "\n")
+	directory := path.Join(user.Home, ".ssh")
+	filePath := path.Join(directory, "authorized_keys")
+
+	checksum, err := common.Checksum(ctx, u.client, filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if checksum == sha256Hex(content) {
+		return false, nil
+	}
+
+	owner := strconv.Itoa(user.UID)
+	group := strconv.Itoa(user.GID)
+	if _, err := run(ctx, u.client, &common.Command{
+		Command: fmt.Sprintf("install -d -m 0700 -o %s -g %s -- %s", owner, group, common.ShellQuote(directory)),
+	}); err != nil {
+		return false, err
+	}
+
+	err = u.client.Upload(ctx, filePath, bytes.NewReader(content), &common.FileOptions{
+		Mode:   0600,
+		Owner:  owner,
+		Group:  group,
+		Atomic: true,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// sudoersPath returns the path of the file that contains the sudo rules of a user.
+func sudoersPath(name string) string {
+	return path.Join(SudoersDirectory, fmt.Sprintf("kraut-%s", name))
+}
+
+// SudoRules returns the sudo rules of a user without the user name,
+// which were written by SetSudoRules. Nil is returned if there are none.
+func (u *Users) SudoRules(ctx context.Context, name string) ([]string, error) {
+	quotedPath := common.ShellQuote(sudoersPath(name))
+	output, err := run(ctx, u.client, &common.Command{
+		Command: fmt.Sprintf("if [ -e %[1]s ]; then cat %[1]s; fi", quotedPath),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rule, ok := strings.CutPrefix(line, name+" "); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// SetSudoRules writes the sudo rules of a user to a file in the sudoers
+// directory, if they differ from the desired rules. The file is removed if
+// no rules are given. The rules are validated with visudo before they are
+// activated. It returns whether the rules were changed.
+func (u *Users) SetSudoRules(ctx context.Context, name string, rules []string) (bool, error) {
+	// Files that contain a dot are ignored by sudo, which
+	// prevents the temporary file from being activated.
+	filePath := sudoersPath(name)
+	temporaryPath := path.Join(SudoersDirectory, fmt.Sprintf(".kraut-%s.tmp", name))
+
+	checksum, err := common.Checksum(ctx, u.client, filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	if len(rules) == 0 {
+		if checksum == "" {
+			return false, nil
+		}
+
+		_, err := run(ctx, u.client, &common.Command{
+			Command: fmt.Sprintf("rm -f %s", common.ShellQuote(filePath)),
+		})
+		return err == nil, err
+	}
+
+	var content bytes.Buffer
+	content.WriteString("# This file is managed by kraut.\n")
+	for _, rule := range rules {
+		fmt.Fprintf(&content, "%s %s\n", name, rule)
+	}
+	if checksum == sha256Hex(content.Bytes()) {
+		return false, nil
+	}
+
+	err = u.client.Upload(ctx, temporaryPath, bytes.NewReader(content.Bytes()), &common.FileOptions{
+		Mode:  0440,
+		Owner: "0",
+		Group: "0",
+	})
+	if err != nil {
+		return false, err
+	}
+
+	quotedTemporaryPath := common.ShellQuote(temporaryPath)
+	if _, err := run(ctx, u.client, &common.Command{
+		Command: fmt.Sprintf("visudo -c -q -f %s && mv -f %s %s || { rm -f %s; exit 1; }", quotedTemporaryPath, quotedTemporaryPath, common.ShellQuote(filePath), quotedTemporaryPath),
+	}); err != nil {
+		return false, fmt.Errorf("failed to validate sudo rules: %s", err)
+	}
+
+	return true, nil
+}